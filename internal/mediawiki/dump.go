@@ -0,0 +1,97 @@
+// Package mediawiki reads MediaWiki XML exports and converts their wikitext
+// to the Markdown used by the wiki.
+package mediawiki
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Revision is one revision of a page from a dump, together with the page it
+// belongs to. Dumps list the revisions of a page oldest first.
+type Revision struct {
+	Title       string
+	Namespace   int
+	Time        time.Time
+	Contributor string
+	Comment     string
+	Minor       bool
+	Text        string
+}
+
+type xmlRevision struct {
+	Timestamp   string `xml:"timestamp"`
+	Contributor struct {
+		Username string `xml:"username"`
+		IP       string `xml:"ip"`
+	} `xml:"contributor"`
+	Comment string    `xml:"comment"`
+	Minor   *struct{} `xml:"minor"`
+	Text    string    `xml:"text"`
+}
+
+// Decoder streams revisions out of a dump. Only one revision is held in
+// memory at a time, so dumps of any size can be read.
+type Decoder struct {
+	xml       *xml.Decoder
+	title     string
+	namespace int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{xml: xml.NewDecoder(r)}
+}
+
+// Next returns the next revision in the dump, or io.EOF after the last one.
+func (d *Decoder) Next() (Revision, error) {
+	for {
+		tok, err := d.xml.Token()
+		if err != nil {
+			return Revision{}, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "siteinfo", "upload":
+			if err := d.xml.Skip(); err != nil {
+				return Revision{}, err
+			}
+		case "page":
+			d.title, d.namespace = "", 0
+		case "title":
+			if err := d.xml.DecodeElement(&d.title, &start); err != nil {
+				return Revision{}, err
+			}
+		case "ns":
+			if err := d.xml.DecodeElement(&d.namespace, &start); err != nil {
+				return Revision{}, err
+			}
+		case "revision":
+			var rev xmlRevision
+			if err := d.xml.DecodeElement(&rev, &start); err != nil {
+				return Revision{}, err
+			}
+
+			contributor := rev.Contributor.Username
+			if contributor == "" {
+				contributor = rev.Contributor.IP
+			}
+			t, _ := time.Parse(time.RFC3339, rev.Timestamp)
+
+			return Revision{
+				Title:       d.title,
+				Namespace:   d.namespace,
+				Time:        t,
+				Contributor: contributor,
+				Comment:     rev.Comment,
+				Minor:       rev.Minor != nil,
+				Text:        rev.Text,
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package mediawiki
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDump = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/">
+  <siteinfo>
+    <sitename>Test</sitename>
+    <namespaces><namespace key="0" /></namespaces>
+  </siteinfo>
+  <page>
+    <title>Main Page</title>
+    <ns>0</ns>
+    <revision>
+      <timestamp>2020-01-02T03:04:05Z</timestamp>
+      <contributor><username>Alice</username></contributor>
+      <comment>first</comment>
+      <text>Hello</text>
+    </revision>
+    <revision>
+      <timestamp>2020-02-02T03:04:05Z</timestamp>
+      <contributor><ip>10.0.0.1</ip></contributor>
+      <minor />
+      <text>Hello &amp; welcome</text>
+    </revision>
+  </page>
+  <page>
+    <title>Talk:Main Page</title>
+    <ns>1</ns>
+    <revision>
+      <timestamp>2020-03-02T03:04:05Z</timestamp>
+      <text>Discussion</text>
+    </revision>
+  </page>
+</mediawiki>`
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(testDump))
+
+	var revs []Revision
+	for {
+		rev, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		revs = append(revs, rev)
+	}
+
+	want := []Revision{
+		{Title: "Main Page", Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Contributor: "Alice", Comment: "first", Text: "Hello"},
+		{Title: "Main Page", Time: time.Date(2020, 2, 2, 3, 4, 5, 0, time.UTC), Contributor: "10.0.0.1", Minor: true, Text: "Hello & welcome"},
+		{Title: "Talk:Main Page", Namespace: 1, Time: time.Date(2020, 3, 2, 3, 4, 5, 0, time.UTC), Text: "Discussion"},
+	}
+	if len(revs) != len(want) {
+		t.Fatalf("got %d revisions, want %d", len(revs), len(want))
+	}
+	for i := range want {
+		if !revs[i].Time.Equal(want[i].Time) {
+			t.Errorf("revision %d time = %v, want %v", i, revs[i].Time, want[i].Time)
+		}
+		revs[i].Time = want[i].Time
+		if revs[i] != want[i] {
+			t.Errorf("revision %d = %+v, want %+v", i, revs[i], want[i])
+		}
+	}
+}
+
+func TestDecoderMalformed(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`<mediawiki><page><title>A</title><revision><text>unterminated`))
+	if _, err := dec.Next(); err == nil || err == io.EOF {
+		t.Errorf("Next on a truncated dump = %v, want a syntax error", err)
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	var dump strings.Builder
+	dump.WriteString("<mediawiki>")
+	for range 1000 {
+		dump.WriteString("<page><title>P</title><ns>0</ns><revision><timestamp>2020-01-02T03:04:05Z</timestamp><text>")
+		dump.WriteString(strings.Repeat("text ", 200))
+		dump.WriteString("</text></revision></page>")
+	}
+	dump.WriteString("</mediawiki>")
+	data := dump.String()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		dec := NewDecoder(strings.NewReader(data))
+		for {
+			if _, err := dec.Next(); err != nil {
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package mediawiki
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern  = regexp.MustCompile(`^(={1,6})\s*(.*?)\s*=+\s*$`)
+	listPattern     = regexp.MustCompile(`^([*#:]+)\s*(.*)$`)
+	redirectPattern = regexp.MustCompile(`(?i)^#redirect\s*`)
+	wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`)
+	extLinkPattern  = regexp.MustCompile(`\[(https?://[^\s\]]+)(?:\s+([^\]]*))?\]`)
+	boldItalic      = regexp.MustCompile(`'''''(.+?)'''''`)
+	bold            = regexp.MustCompile(`'''(.+?)'''`)
+	italic          = regexp.MustCompile(`''(.+?)''`)
+)
+
+// ToMarkdown converts the common wikitext constructs (headings, lists, bold,
+// italic, internal and external links) and leaves everything else as it is.
+// link maps a link target to a wiki title; targets it rejects are kept as
+// plain text.
+func ToMarkdown(wikitext string, link func(target string) (string, bool)) string {
+	lines := strings.Split(strings.ReplaceAll(wikitext, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if loc := redirectPattern.FindStringIndex(line); loc != nil {
+			lines[i] = "Redirects to " + convertInline(line[loc[1]:], link)
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			lines[i] = strings.Repeat("#", len(m[1])) + " " + convertInline(m[2], link)
+			continue
+		}
+
+		if line == "----" {
+			lines[i] = "***"
+			continue
+		}
+
+		if m := listPattern.FindStringSubmatch(line); m != nil {
+			lines[i] = listItem(m[1]) + convertInline(m[2], link)
+			continue
+		}
+
+		lines[i] = convertInline(line, link)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// listItem maps a run of wikitext list markers such as "*#" to an indented
+// Markdown marker. Definition-style ":" indents become block quotes.
+func listItem(markers string) string {
+	if strings.Trim(markers, ":") == "" {
+		return strings.Repeat("> ", len(markers))
+	}
+
+	var indent strings.Builder
+	for _, m := range markers[:len(markers)-1] {
+		if m == '#' {
+			indent.WriteString("   ")
+		} else {
+			indent.WriteString("  ")
+		}
+	}
+
+	if markers[len(markers)-1] == '#' {
+		return indent.String() + "1. "
+	}
+	return indent.String() + "- "
+}
+
+func convertInline(s string, link func(string) (string, bool)) string {
+	s = wikiLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := wikiLinkPattern.FindStringSubmatch(m)
+		target, label := strings.TrimSpace(parts[1]), parts[2]
+		if label == "" {
+			label = target
+		}
+
+		ns, _, hasNS := strings.Cut(target, ":")
+		if hasNS {
+			switch strings.ToLower(ns) {
+			case "category", "file", "image":
+				return ""
+			}
+		}
+
+		name, _, _ := strings.Cut(target, "#")
+		title, ok := link(name)
+		if !ok {
+			return label
+		}
+		return "[" + label + "](/view/" + title + ")"
+	})
+
+	s = extLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := extLinkPattern.FindStringSubmatch(m)
+		if parts[2] == "" {
+			return "<" + parts[1] + ">"
+		}
+		return "[" + parts[2] + "](" + parts[1] + ")"
+	})
+
+	s = boldItalic.ReplaceAllString(s, "***$1***")
+	s = bold.ReplaceAllString(s, "**$1**")
+	s = italic.ReplaceAllString(s, "*$1*")
+
+	return s
+}
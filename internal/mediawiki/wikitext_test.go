@@ -0,0 +1,50 @@
+package mediawiki
+
+import (
+	"strings"
+	"testing"
+)
+
+func testLink(target string) (string, bool) {
+	if strings.ContainsAny(target, "!?") {
+		return "", false
+	}
+	return strings.ReplaceAll(strings.TrimSpace(target), " ", "-"), true
+}
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"heading", "== Section ==", "## Section"},
+		{"deep heading", "====Sub====", "#### Sub"},
+		{"rule", "----", "***"},
+		{"bullets", "* one\n** two", "- one\n  - two"},
+		{"numbers", "# one\n## two", "1. one\n   1. two"},
+		{"mixed list", "#* item", "   - item"},
+		{"indent", ":: quoted", "> > quoted"},
+		{"bold", "'''bold'''", "**bold**"},
+		{"italic", "''it''", "*it*"},
+		{"bold italic", "'''''both'''''", "***both***"},
+		{"link", "[[Main Page]]", "[Main Page](/view/Main-Page)"},
+		{"labelled link", "[[Main Page|home]]", "[home](/view/Main-Page)"},
+		{"section link", "[[Main Page#Top|top]]", "[top](/view/Main-Page)"},
+		{"rejected link", "[[What?]]", "What?"},
+		{"category", "text[[Category:Foo]]", "text"},
+		{"file", "[[File:a.png|thumb]]", ""},
+		{"external", "[https://example.com Example]", "[Example](https://example.com)"},
+		{"bare external", "[https://example.com]", "<https://example.com>"},
+		{"redirect", "#REDIRECT [[Other Page]]", "Redirects to [Other Page](/view/Other-Page)"},
+		{"crlf", "a\r\nb", "a\nb"},
+		{"untouched", "{{template}} <ref>x</ref>", "{{template}} <ref>x</ref>"},
+		{"inline in heading", "== '''Bold''' [[A]] ==", "## **Bold** [A](/view/A)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(tt.in, testLink); got != tt.want {
+				t.Errorf("ToMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
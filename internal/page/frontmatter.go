@@ -0,0 +1,57 @@
+// Package page holds the rules for page titles and content that do not
+// depend on how pages are stored or served.
+package page
+
+import (
+	"bytes"
+	"strings"
+)
+
+const frontMatterDelim = "---"
+
+// SplitFrontMatter separates a block delimited by "---" lines at the very start
+// of the body from the rest of the content.
+func SplitFrontMatter(body []byte) (front, rest []byte, ok bool) {
+	if !bytes.HasPrefix(body, []byte(frontMatterDelim)) {
+		return nil, body, false
+	}
+
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	if string(bytes.TrimSpace(lines[0])) != frontMatterDelim {
+		return nil, body, false
+	}
+
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		if string(bytes.TrimSpace(line)) == frontMatterDelim {
+			return body[len(lines[0]):offset], body[offset+len(line):], true
+		}
+		offset += len(line)
+	}
+
+	return nil, body, false
+}
+
+// ParseFrontMatter reads the "key: value" lines of the front matter block.
+func ParseFrontMatter(body []byte) map[string]string {
+	front, _, ok := SplitFrontMatter(body)
+	if !ok {
+		return nil
+	}
+
+	meta := map[string]string{}
+	for _, line := range strings.Split(string(front), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		meta[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	return meta
+}
+
+func StripFrontMatter(body []byte) []byte {
+	_, rest, _ := SplitFrontMatter(body)
+	return rest
+}
@@ -0,0 +1,46 @@
+package page
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		body, front, rest string
+		ok                bool
+	}{
+		{"---\ntitle: Home\n---\nbody", "title: Home\n", "body", true},
+		{"--- \r\na: 1\r\n---\r\nbody", "a: 1\r\n", "body", true},
+		{"---\n---\n", "", "", true},
+		{"no front matter", "", "no front matter", false},
+		{"---\nunterminated", "", "---\nunterminated", false},
+		{"----\na: 1\n---\n", "", "----\na: 1\n---\n", false},
+		{"text\n---\na: 1\n---\n", "", "text\n---\na: 1\n---\n", false},
+	}
+
+	for _, tt := range tests {
+		front, rest, ok := SplitFrontMatter([]byte(tt.body))
+		if string(front) != tt.front || string(rest) != tt.rest || ok != tt.ok {
+			t.Errorf("SplitFrontMatter(%q) = %q, %q, %v, want %q, %q, %v", tt.body, front, rest, ok, tt.front, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	got := ParseFrontMatter([]byte("---\nTitle: My Page \ntags: a, b\nnot a pair\nurl: http://x\n---\nbody"))
+	want := map[string]string{"title": "My Page", "tags": "a, b", "url": "http://x"}
+	if !maps.Equal(got, want) {
+		t.Errorf("ParseFrontMatter = %v, want %v", got, want)
+	}
+
+	if got := ParseFrontMatter([]byte("body")); got != nil {
+		t.Errorf("ParseFrontMatter without front matter = %v, want nil", got)
+	}
+}
+
+func TestStripFrontMatter(t *testing.T) {
+	if got := StripFrontMatter([]byte("---\na: 1\n---\nbody")); string(got) != "body" {
+		t.Errorf("StripFrontMatter = %q, want %q", got, "body")
+	}
+}
@@ -0,0 +1,36 @@
+package page
+
+import "time"
+
+// Page is a page as it is loaded or saved. Editor, Summary and Minor describe
+// the change being saved and are not stored in the page itself.
+type Page struct {
+	Title   string
+	Body    []byte
+	Editor  string
+	Summary string
+	Minor   bool
+}
+
+// Meta holds the per-page settings kept beside the page body.
+type Meta struct {
+	Protection   string `json:"protection,omitempty"`
+	DisplayTitle string `json:"display_title,omitempty"`
+}
+
+// ExpiresAt reads the "expires" front matter key, as an RFC 3339 time or a
+// local date with or without the time of day.
+func (p *Page) ExpiresAt() (time.Time, bool) {
+	value, ok := ParseFrontMatter(p.Body)["expires"]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,26 @@
+package page
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiresAt(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		want time.Time
+		ok   bool
+	}{
+		{"---\nexpires: 2026-03-01T12:00:00Z\n---\nnote", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), true},
+		{"---\nExpires: 2026-03-01 08:30\n---\nnote", time.Date(2026, 3, 1, 8, 30, 0, 0, time.Local), true},
+		{"---\nexpires: 2026-03-01\n---\nnote", time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), true},
+		{"---\nexpires: next week\n---\nnote", time.Time{}, false},
+		{"---\ntags: scratch\n---\nnote", time.Time{}, false},
+		{"expires: 2026-03-01\n", time.Time{}, false},
+	} {
+		got, ok := (&Page{Body: []byte(tt.body)}).ExpiresAt()
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ExpiresAt(%q) = %v, %v, want %v, %v", tt.body, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package page
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Separator policies for Slug.
+const (
+	SeparatorDash = "dash"
+	SeparatorNone = "none"
+)
+
+// Slug maps a display title such as "My Great Page" to the title used in URLs
+// and file names. Accents are dropped, and every run of spaces or punctuation
+// becomes a single dash, or with SeparatorNone joins the words by capitalizing
+// them ("MyGreatPage"). Separators at either end are trimmed, so a slug is
+// its own slug.
+func Slug(display, policy string) string {
+	plain, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn))), display)
+	if err != nil {
+		plain = display
+	}
+
+	var b strings.Builder
+	separate := false
+	for _, r := range plain {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			separate = b.Len() > 0
+			continue
+		}
+		if separate {
+			if policy == SeparatorNone {
+				r = unicode.ToUpper(r)
+			} else {
+				b.WriteByte('-')
+			}
+			separate = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package page
+
+import "testing"
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		display, policy, want string
+	}{
+		{"My Great Page", SeparatorDash, "My-Great-Page"},
+		{"My Great Page", SeparatorNone, "MyGreatPage"},
+		{"  Café   au lait!  ", SeparatorDash, "Cafe-au-lait"},
+		{"über-cool", SeparatorNone, "uberCool"},
+		{"C++ / Go, 2024", SeparatorDash, "C-Go-2024"},
+		{"日本語", SeparatorDash, ""},
+		{"Wiki 日本 Page", SeparatorDash, "Wiki-Page"},
+		{"---", SeparatorDash, ""},
+		{"", SeparatorDash, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slug(tt.display, tt.policy); got != tt.want {
+			t.Errorf("Slug(%q, %q) = %q, want %q", tt.display, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestSlugIsIdempotent(t *testing.T) {
+	for _, display := range []string{"My Great Page", "Ça va?", "a--b", "x_y.z"} {
+		for _, policy := range []string{SeparatorDash, SeparatorNone} {
+			once := Slug(display, policy)
+			if twice := Slug(once, policy); twice != once {
+				t.Errorf("Slug(Slug(%q)) = %q, want %q", display, twice, once)
+			}
+		}
+	}
+}
+
+func TestSlugIsValidTitle(t *testing.T) {
+	for _, display := range []string{"My Great Page", "  Café  ", "x_y.z", "1 2 3"} {
+		if err := ValidateTitle(Slug(display, SeparatorDash), 100); err != nil {
+			t.Errorf("Slug(%q) is not a valid title: %v", display, err)
+		}
+	}
+}
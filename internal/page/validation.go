@@ -0,0 +1,54 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var validTitlePattern = regexp.MustCompile("^[a-zA-Z0-9]+(?:-[a-zA-Z0-9]+)*$")
+
+var ErrUnsafeTitle = errors.New("Title must not contain path separators or start with a dot")
+
+// CheckPathTitle rejects titles that would point outside the storage
+// directory once used in a file name.
+func CheckPathTitle(title string) error {
+	if title == "" || strings.HasPrefix(title, ".") || strings.Contains(title, "..") ||
+		strings.ContainsAny(title, "/\\\x00") {
+		return ErrUnsafeTitle
+	}
+
+	return nil
+}
+
+// ValidateTitle checks that title is made of latin letters and digits, with
+// single dashes between words, and is at most maxLength bytes long.
+func ValidateTitle(title string, maxLength int) error {
+	if !validTitlePattern.MatchString(title) {
+		return errors.New("Title may contain only latin letters, digits and single dashes between them")
+	}
+	if len(title) > maxLength {
+		return fmt.Errorf("Title may be at most %d characters long", maxLength)
+	}
+
+	return nil
+}
+
+// ValidateBody rejects content that is not UTF-8 text. Control characters other
+// than tabs and line breaks are a sign of pasted binary data.
+func ValidateBody(body []byte) error {
+	if !utf8.Valid(body) {
+		return errors.New("Page body is not valid UTF-8 text")
+	}
+
+	for _, r := range string(body) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return errors.New("Page body looks like binary data, only text is allowed")
+		}
+	}
+
+	return nil
+}
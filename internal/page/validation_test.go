@@ -0,0 +1,69 @@
+package page
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPathTitle(t *testing.T) {
+	for _, title := range []string{"Home", "My-Page", "a.b"} {
+		if err := CheckPathTitle(title); err != nil {
+			t.Errorf("CheckPathTitle(%q) = %v, want nil", title, err)
+		}
+	}
+	for _, title := range []string{"", ".", "..", ".hidden", "a..b", "a/b", `a\b`, "a\x00b", "../etc"} {
+		if err := CheckPathTitle(title); err != ErrUnsafeTitle {
+			t.Errorf("CheckPathTitle(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+	}
+}
+
+func TestValidateTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		ok    bool
+	}{
+		{"Home", true},
+		{"My-Page-2", true},
+		{"a", true},
+		{"", false},
+		{"-Home", false},
+		{"Home-", false},
+		{"My--Page", false},
+		{"My Page", false},
+		{"..", false},
+		{"../Home", false},
+		{"Café", false},
+		{"a_b", false},
+		{strings.Repeat("a", 10), true},
+		{strings.Repeat("a", 11), false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTitle(tt.title, 10)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateTitle(%q) = %v, want ok %v", tt.title, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	tests := []struct {
+		body string
+		ok   bool
+	}{
+		{"", true},
+		{"plain text\r\n\twith tabs", true},
+		{"ünïcödé ✓", true},
+		{"\xff\xfe", false},
+		{"PNG\x00\x01", false},
+		{"bell\a", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateBody([]byte(tt.body))
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateBody(%q) = %v, want ok %v", tt.body, err, tt.ok)
+		}
+	}
+}
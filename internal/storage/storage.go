@@ -0,0 +1,211 @@
+// Package storage keeps wiki pages on disk, one <title>.txt file per page.
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// Storage reads and writes the current version of pages. History, drafts
+// and the other sidecar files live next to the pages and are not part of it.
+type Storage interface {
+	Read(title string) ([]byte, error)
+	Write(title string, body []byte) error
+	Open(title string) (File, error)
+	Stat(title string) (fs.FileInfo, error)
+	Remove(title string) error
+	List() ([]string, error)
+}
+
+type File interface {
+	io.ReadCloser
+	Stat() (fs.FileInfo, error)
+}
+
+type FileStore struct {
+	dir            string
+	followSymlinks bool
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// SetFollowSymlinks lets page files be symlinks to anywhere. By default a
+// page whose path resolves outside the storage directory is refused.
+func (s *FileStore) SetFollowSymlinks(follow bool) {
+	s.followSymlinks = follow
+}
+
+// Path refuses titles that could escape the storage directory, independently
+// of any validation done by the caller.
+func (s *FileStore) Path(title string) (string, error) {
+	if err := page.CheckPathTitle(title); err != nil {
+		return "", err
+	}
+
+	fn := s.dir + "/" + title + ".txt"
+	if !s.followSymlinks {
+		if err := CheckContained(s.dir, fn); err != nil {
+			return "", err
+		}
+	}
+
+	return fn, nil
+}
+
+// ErrOutsideRoot is returned for page files that are symlinks out of the
+// storage directory.
+var ErrOutsideRoot = errors.New("path resolves outside the storage directory")
+
+// CheckContained resolves the symlinks in fn and refuses it when the real
+// file is outside root. A file that does not exist yet is checked through
+// its directory, and a dangling symlink is always refused since writing
+// through it would create its target.
+func CheckContained(root, fn string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	real, err := filepath.EvalSymlinks(fn)
+	if os.IsNotExist(err) {
+		if fi, lerr := os.Lstat(fn); lerr == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s: %w", filepath.Base(fn), ErrOutsideRoot)
+		}
+		real, err = filepath.EvalSymlinks(filepath.Dir(fn))
+		if os.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(realRoot, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s: %w", filepath.Base(fn), ErrOutsideRoot)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Read(title string) ([]byte, error) {
+	fn, err := s.Path(title)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(fn)
+}
+
+func (s *FileStore) Write(title string, body []byte) error {
+	fn, err := s.Path(title)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		if err := os.Mkdir(s.dir, 0750); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(fn, body, 0600)
+}
+
+func (s *FileStore) Open(title string) (File, error) {
+	fn, err := s.Path(title)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (s *FileStore) Stat(title string) (fs.FileInfo, error) {
+	fn, err := s.Path(title)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Stat(fn)
+}
+
+func (s *FileStore) Remove(title string) error {
+	fn, err := s.Path(title)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(fn)
+}
+
+func (s *FileStore) List() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range files {
+		files[i] = strings.TrimSuffix(filepath.Base(file), ".txt")
+	}
+
+	return files, nil
+}
+
+// SelfTest writes, reads back and removes a probe file so a read-only or
+// missing mount is reported at startup rather than on the first save.
+func (s *FileStore) SelfTest() error {
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("storage %s is not usable: %w", s.dir, err)
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	probe := []byte(hex.EncodeToString(b))
+	fn := filepath.Join(s.dir, ".selftest-"+string(probe))
+
+	if err := os.WriteFile(fn, probe, 0600); err != nil {
+		return fmt.Errorf("storage %s is not writable: %w", s.dir, err)
+	}
+	defer os.Remove(fn)
+
+	got, err := os.ReadFile(fn)
+	if err != nil {
+		return fmt.Errorf("storage %s is not readable: %w", s.dir, err)
+	}
+	if !bytes.Equal(got, probe) {
+		return fmt.Errorf("storage %s returned different content than was written", s.dir)
+	}
+
+	if err := os.Remove(fn); err != nil {
+		return fmt.Errorf("storage %s does not allow deleting files: %w", s.dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func TestFileStoreReadWrite(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "pages"))
+
+	if _, err := s.Read("Home"); !os.IsNotExist(err) {
+		t.Fatalf("Read of a missing page = %v, want not exist", err)
+	}
+	if err := s.Write("Home", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("About", []byte("about")); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := s.Read("Home")
+	if err != nil || string(body) != "hello" {
+		t.Fatalf("Read = %q, %v, want %q", body, err, "hello")
+	}
+
+	fi, err := s.Stat("Home")
+	if err != nil || fi.Size() != 5 {
+		t.Fatalf("Stat = %v, %v, want size 5", fi, err)
+	}
+
+	f, err := s.Open("About")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	titles, err := s.List()
+	slices.Sort(titles)
+	if err != nil || !slices.Equal(titles, []string{"About", "Home"}) {
+		t.Fatalf("List = %v, %v, want [About Home]", titles, err)
+	}
+
+	if err := s.Remove("About"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat("About"); !os.IsNotExist(err) {
+		t.Errorf("Stat after Remove = %v, want not exist", err)
+	}
+	if err := s.Remove("About"); !os.IsNotExist(err) {
+		t.Errorf("Remove of a missing page = %v, want not exist", err)
+	}
+}
+
+func TestFileStoreRefusesUnsafeTitles(t *testing.T) {
+	s := NewFileStore(t.TempDir())
+
+	for _, title := range []string{"../escape", ".hidden", "a/b", ""} {
+		if err := s.Write(title, []byte("x")); !errors.Is(err, page.ErrUnsafeTitle) {
+			t.Errorf("Write(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+		if _, err := s.Read(title); !errors.Is(err, page.ErrUnsafeTitle) {
+			t.Errorf("Read(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+		if err := s.Remove(title); !errors.Is(err, page.ErrUnsafeTitle) {
+			t.Errorf("Remove(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+	}
+}
+
+func TestFileStoreSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "pages")
+	if err := os.Mkdir(root, 0750); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "Target.txt"), []byte("inside"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"Escape":   outside,
+		"Dangling": filepath.Join(dir, "missing.txt"),
+		"Inside":   filepath.Join(root, "Target.txt"),
+	}
+	for title, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, title+".txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewFileStore(root)
+	if _, err := s.Read("Escape"); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Read through a symlink out of the root = %v, want ErrOutsideRoot", err)
+	}
+	if err := s.Write("Dangling", []byte("x")); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Write through a dangling symlink = %v, want ErrOutsideRoot", err)
+	}
+	if _, err := os.Stat(links["Dangling"]); !os.IsNotExist(err) {
+		t.Errorf("writing through a dangling symlink created its target")
+	}
+	if body, err := s.Read("Inside"); err != nil || string(body) != "inside" {
+		t.Errorf("Read through a symlink inside the root = %q, %v", body, err)
+	}
+	if err := s.Write("New", []byte("new")); err != nil {
+		t.Errorf("Write of a new page = %v", err)
+	}
+
+	s.SetFollowSymlinks(true)
+	if body, err := s.Read("Escape"); err != nil || string(body) != "secret" {
+		t.Errorf("Read with symlinks followed = %q, %v, want %q", body, err, "secret")
+	}
+}
+
+func TestCheckContainedThroughLinkedRoot(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real")
+	if err := os.Mkdir(real, 0750); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckContained(link, filepath.Join(link, "Home.txt")); err != nil {
+		t.Errorf("CheckContained under a symlinked root = %v, want nil", err)
+	}
+	if err := CheckContained(filepath.Join(dir, "missing"), filepath.Join(dir, "missing", "Home.txt")); err != nil {
+		t.Errorf("CheckContained with a missing root = %v, want nil", err)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pages")
+	if err := NewFileStore(dir).SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("SelfTest left %v behind, err %v", entries, err)
+	}
+
+	if os.Getuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	ro := t.TempDir()
+	if err := os.Chmod(ro, 0500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(ro, 0700) })
+	if err := NewFileStore(ro).SelfTest(); err == nil {
+		t.Error("SelfTest of a read-only directory succeeded")
+	}
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+const (
+	abuseWarn  = "warn"
+	abuseBlock = "block"
+	abuseFlag  = "flag"
+)
+
+type abuseRule struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+
+	re   *regexp.Regexp
+	hits atomic.Int64
+}
+
+var abuseRules []*abuseRule
+
+var abuseRuleTimeout = 100 * time.Millisecond
+
+// setupAbuseRules loads ABUSE_RULES_FILE, a JSON array of rules. Every
+// pattern is compiled up front so a broken rule stops the wiki from starting
+// instead of being silently skipped.
+func setupAbuseRules() error {
+	if raw := os.Getenv("ABUSE_RULE_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid ABUSE_RULE_TIMEOUT %q", raw)
+		}
+		abuseRuleTimeout = d
+	}
+
+	path := os.Getenv("ABUSE_RULES_FILE")
+	if path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading ABUSE_RULES_FILE: %w", err)
+	}
+
+	var rules []*abuseRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return fmt.Errorf("error parsing ABUSE_RULES_FILE: %w", err)
+	}
+
+	for i, rule := range rules {
+		switch rule.Action {
+		case abuseWarn, abuseBlock, abuseFlag:
+		default:
+			return fmt.Errorf("abuse rule %d: unknown action %q", i+1, rule.Action)
+		}
+
+		rule.re, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("abuse rule %d: %w", i+1, err)
+		}
+		if rule.Message == "" {
+			rule.Message = "Your edit matches a content rule"
+		}
+	}
+	abuseRules = rules
+
+	return nil
+}
+
+// matches runs the rule against body, giving up after abuseRuleTimeout so a
+// slow rule cannot hold up saving.
+func (rule *abuseRule) matches(body []byte) bool {
+	done := make(chan bool, 1)
+	go func() {
+		done <- rule.re.Match(body)
+	}()
+
+	select {
+	case matched := <-done:
+		return matched
+	case <-time.After(abuseRuleTimeout):
+		slog.Warn("abuse rule timed out", "pattern", rule.Pattern)
+		return false
+	}
+}
+
+type abuseResult struct {
+	block    *abuseRule
+	warnings []string
+	flags    []string
+}
+
+// checkAbuseRules evaluates all rules against body. Hits are only counted
+// when count is set, so dry runs do not skew the dashboard.
+func checkAbuseRules(body []byte, count bool) abuseResult {
+	var res abuseResult
+
+	for _, rule := range abuseRules {
+		if !rule.matches(body) {
+			continue
+		}
+		if count {
+			rule.hits.Add(1)
+		}
+
+		switch rule.Action {
+		case abuseBlock:
+			if res.block == nil {
+				res.block = rule
+			}
+		case abuseWarn:
+			res.warnings = append(res.warnings, rule.Message)
+		case abuseFlag:
+			res.flags = append(res.flags, rule.Message)
+		}
+	}
+
+	return res
+}
+
+// flagEdit records an audit entry for a saved edit that matched flag rules.
+func flagEdit(p *page.Page, flags []string) {
+	entry := auditEntry{User: p.Editor, Action: "flag", Title: p.Title, Detail: strings.Join(flags, "; ")}
+	if err := recordAudit(entry); err != nil {
+		slog.Error("error recording flagged edit", "title", p.Title, "err", err)
+	}
+}
+
+type abuseRuleStats struct {
+	Pattern string
+	Action  string
+	Message string
+	Hits    int64
+}
+
+func abuseRulesHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can view content rules.")
+		return
+	}
+
+	stats := make([]abuseRuleStats, 0, len(abuseRules))
+	for _, rule := range abuseRules {
+		stats = append(stats, abuseRuleStats{rule.Pattern, rule.Action, rule.Message, rule.hits.Load()})
+	}
+
+	renderTemplate(w, r, pageData{Title: "Content rules", Content: stats}, "abuserules")
+}
@@ -0,0 +1,223 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAccessLogMaxMB = 100
+	defaultAccessLogKeep  = 5
+	accessLogFlushEvery   = time.Second
+)
+
+// accessLog writes one Combined Log Format line per request, for tools like
+// goaccess that read Apache logs. Nil when ACCESS_LOG is not set.
+var accessLog *rotatingFile
+
+// rotatingFile is an append-only file that is moved to name.1, name.2, ...
+// once it grows past maxSize, keeping the newest keep of them.
+type rotatingFile struct {
+	mu      sync.Mutex
+	name    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	w       *bufio.Writer
+	size    int64
+}
+
+func setupAccessLog() error {
+	name := os.Getenv("ACCESS_LOG")
+	if name == "" {
+		return nil
+	}
+
+	maxMB, keep := defaultAccessLogMaxMB, defaultAccessLogKeep
+	if raw := os.Getenv("ACCESS_LOG_MAX_MB"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid ACCESS_LOG_MAX_MB %q", raw)
+		}
+		maxMB = n
+	}
+	if raw := os.Getenv("ACCESS_LOG_KEEP"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid ACCESS_LOG_KEEP %q", raw)
+		}
+		keep = n
+	}
+
+	rf := &rotatingFile{name: name, maxSize: int64(maxMB) << 20, keep: keep}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	accessLog = rf
+	go rf.flushEvery(accessLogFlushEvery)
+
+	return nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.f, rf.w, rf.size = f, bufio.NewWriter(f), fi.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.w.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts name.N-1 to name.N down to name to name.1 and starts a new
+// file. The caller holds mu.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.closeFile(); err != nil {
+		return err
+	}
+
+	if rf.keep == 0 {
+		os.Remove(rf.name)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", rf.name, rf.keep))
+		for i := rf.keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.name, i), fmt.Sprintf("%s.%d", rf.name, i+1))
+		}
+		if err := os.Rename(rf.name, rf.name+".1"); err != nil {
+			return err
+		}
+	}
+
+	return rf.open()
+}
+
+func (rf *rotatingFile) closeFile() error {
+	err := rf.w.Flush()
+	if cerr := rf.f.Close(); err == nil {
+		err = cerr
+	}
+	rf.f, rf.w = nil, nil
+	return err
+}
+
+func (rf *rotatingFile) flushEvery(d time.Duration) {
+	for range time.Tick(d) {
+		rf.mu.Lock()
+		if rf.f == nil {
+			rf.mu.Unlock()
+			return
+		}
+		if err := rf.w.Flush(); err != nil {
+			slog.Error("error flushing access log", "err", err)
+		}
+		rf.mu.Unlock()
+	}
+}
+
+// Close flushes what is buffered. Later writes fail.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+	return rf.closeFile()
+}
+
+// combinedLogLine formats a request like Apache's
+// "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\"".
+func combinedLogLine(r *http.Request, user string, t time.Time, status int, size int64) string {
+	host := "-"
+	if addr := clientIP(r); addr.IsValid() {
+		host = addr.String()
+	}
+	if user == "" {
+		user = "-"
+	}
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.FormatInt(size, 10)
+	}
+	request := r.Method + " " + r.RequestURI + " " + r.Proto
+	referer, agent := r.Referer(), r.UserAgent()
+	if referer == "" {
+		referer = "-"
+	}
+	if agent == "" {
+		agent = "-"
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s\" %d %s \"%s\" \"%s\"\n",
+		host, logEscape(user), t.Format("02/Jan/2006:15:04:05 -0700"), logEscape(request),
+		status, bytes, logEscape(referer), logEscape(agent))
+}
+
+// logEscape keeps a client supplied value on one line and inside its quotes,
+// the way Apache escapes them.
+func logEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func withAccessLog(next http.Handler) http.Handler {
+	if accessLog == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		line := combinedLogLine(r, currentUser(r), start, status, rec.bytes)
+		if _, err := accessLog.Write([]byte(line)); err != nil {
+			slog.Error("error writing access log", "err", err)
+		}
+	})
+}
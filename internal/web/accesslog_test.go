@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// combinedLogPattern parses a Combined Log Format line into host, ident,
+// user, time, request, status, bytes, referer and user agent.
+var combinedLogPattern = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-) "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"$`)
+
+func readAccessLog(t *testing.T, name string) [][]string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines [][]string
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		m := combinedLogPattern.FindStringSubmatch(sc.Text())
+		if m == nil {
+			t.Fatalf("not a Combined Log Format line: %q", sc.Text())
+		}
+		lines = append(lines, m[1:])
+	}
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home text")
+	name := filepath.Join(t.TempDir(), "access.log")
+	rf := &rotatingFile{name: name, maxSize: 1 << 20, keep: 1}
+	if err := rf.open(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, &accessLog, rf)
+	ts := httptest.NewServer(newHandler(testServer, routes()))
+	t.Cleanup(ts.Close)
+	w = &testWiki{Server: ts, t: t, dir: w.dir}
+
+	before := time.Now().Truncate(time.Second)
+	req, err := http.NewRequest(http.MethodGet, w.URL+"/view/Home?x=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", `agent "quoted"`)
+	_, body := w.do(req, w.login("alice", roleEditor))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.get("/missing-" + strconv.Itoa(i))
+		}()
+	}
+	wg.Wait()
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readAccessLog(t, name)
+	if len(lines) != 21 {
+		t.Fatalf("%d lines, want 21", len(lines))
+	}
+	first := lines[0]
+	want := []string{"127.0.0.1", "-", "alice", first[3], "GET /view/Home?x=1 HTTP/1.1", "200", strconv.Itoa(len(body)), "https://example.com/", `agent \"quoted\"`}
+	for i, field := range []string{"host", "ident", "user", "time", "request", "status", "bytes", "referer", "agent"} {
+		if first[i] != want[i] {
+			t.Errorf("%s = %q, want %q", field, first[i], want[i])
+		}
+	}
+	logged, err := time.Parse("02/Jan/2006:15:04:05 -0700", first[3])
+	if err != nil || logged.Before(before) || logged.After(time.Now()) {
+		t.Errorf("time %q = %v, %v", first[3], logged, err)
+	}
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line[4], "GET /missing-") || line[5] != "404" || line[2] != "-" || line[7] != "-" {
+			t.Errorf("line = %q", line)
+		}
+	}
+
+	// Writes after Close fail instead of going nowhere.
+	if _, err := rf.Write([]byte("late\n")); err == nil {
+		t.Error("write after Close succeeded")
+	}
+}
+
+func TestLogEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		`plain /path?a=b`:  `plain /path?a=b`,
+		`say "hi" \ bye`:   `say \"hi\" \\ bye`,
+		"two\nlines\t\x7f": `two\x0alines\x09\x7f`,
+		"привет":           "привет",
+	} {
+		if got := logEscape(in); got != want {
+			t.Errorf("logEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "access.log")
+	rf := &rotatingFile{name: name, maxSize: 100, keep: 2}
+	if err := rf.open(); err != nil {
+		t.Fatal(err)
+	}
+	// Every line is 20 bytes, so each file takes five.
+	for i := range 18 {
+		if _, err := fmt.Fprintf(rf, "line %02d ..........\n", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{"access.log": "15", "access.log.1": "10", "access.log.2": "05"} {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 100 || !strings.HasPrefix(string(b), "line "+want) {
+			t.Errorf("%s = %q, want at most 100 bytes starting at line %s", file, b, want)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than keep files left: %v", err)
+	}
+
+	// With keep 0 the old lines are dropped.
+	name = filepath.Join(dir, "dropped.log")
+	rf = &rotatingFile{name: name, maxSize: 100, keep: 0}
+	if err := rf.open(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 6 {
+		fmt.Fprintf(rf, "line %02d ..........\n", i)
+	}
+	rf.Close()
+	if b, _ := os.ReadFile(name); string(b) != "line 05 ..........\n" {
+		t.Errorf("after rotating with keep 0: %q", b)
+	}
+	if _, err := os.Stat(name + ".1"); !os.IsNotExist(err) {
+		t.Errorf("keep 0 left a rotated file: %v", err)
+	}
+}
+
+func TestSetupAccessLog(t *testing.T) {
+	setGlobal(t, &accessLog, nil)
+	t.Setenv("ACCESS_LOG", filepath.Join(t.TempDir(), "access.log"))
+	for key, raw := range map[string]string{"ACCESS_LOG_MAX_MB": "0", "ACCESS_LOG_KEEP": "-1"} {
+		t.Setenv(key, raw)
+		if err := setupAccessLog(); err == nil {
+			t.Errorf("%s=%q accepted", key, raw)
+		}
+		t.Setenv(key, "")
+	}
+	t.Setenv("ACCESS_LOG_MAX_MB", "2")
+	t.Setenv("ACCESS_LOG_KEEP", "3")
+	if err := setupAccessLog(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { accessLog.Close() })
+	if accessLog.maxSize != 2<<20 || accessLog.keep != 3 {
+		t.Errorf("access log = %d bytes, keep %d", accessLog.maxSize, accessLog.keep)
+	}
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var admonitionMarker = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution|todo)\]\s*$`)
+
+// setupAdmonitions turns blockquotes starting with a marker line such as
+// "[!NOTE]" into callouts. ADMONITIONS=false renders them as plain quotes.
+func setupAdmonitions() {
+	if os.Getenv("ADMONITIONS") == "false" {
+		return
+	}
+
+	addMarkdownOptions(goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(admonitionTransformer{}, 500))))
+	sanitizer.AllowAttrs("class").Matching(regexp.MustCompile(`^admonition admonition-[a-z]+$`)).OnElements("blockquote")
+}
+
+type admonitionTransformer struct{}
+
+func (admonitionTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		quote, ok := n.(*ast.Blockquote)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		para, ok := quote.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+		first := para.Lines().At(0)
+		m := admonitionMarker.FindSubmatch(first.Value(source))
+		if m == nil {
+			return ast.WalkContinue, nil
+		}
+
+		// Drop the inline nodes of the marker line; the rest of the
+		// paragraph stays as the callout's first paragraph.
+		for c := para.FirstChild(); c != nil; {
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Stop > first.Stop {
+				break
+			}
+			next := c.NextSibling()
+			para.RemoveChild(para, c)
+			c = next
+		}
+		if !para.HasChildren() {
+			quote.RemoveChild(quote, para)
+		}
+
+		quote.SetAttributeString("class", []byte("admonition admonition-"+strings.ToLower(string(m[1]))))
+		return ast.WalkSkipChildren, nil
+	})
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAdmonitions(t *testing.T) {
+	for source, want := range map[string]string{
+		"> [!NOTE]\n> Be careful.":            "<blockquote class=\"admonition admonition-note\"><p>Be careful.</p>\n</blockquote>",
+		"> [!warning]  \n> Mind the **gap**.": "<blockquote class=\"admonition admonition-warning\"><p>Mind the <strong>gap</strong>.</p>\n</blockquote>",
+		"> [!TODO]\n>\n> - one\n> - two":      "<blockquote class=\"admonition admonition-todo\"><ul>\n<li>one</li>\n<li>two</li>\n</ul>\n</blockquote>",
+		"> [!TIP]":                            "<blockquote class=\"admonition admonition-tip\"></blockquote>",
+		"> A plain quote.":                    "<blockquote>\n<p>A plain quote.</p>\n</blockquote>",
+		"> [!NOTE] on the same line":          "<blockquote>\n<p>[!NOTE] on the same line</p>\n</blockquote>",
+		"> [!SHOUT]\n> Unknown kind.":         "<blockquote>\n<p>[!SHOUT]\nUnknown kind.</p>\n</blockquote>",
+		"Text\n\n> quote\n> [!NOTE]":          "<p>Text</p>\n<blockquote>\n<p>quote\n[!NOTE]</p>\n</blockquote>",
+	} {
+		html, err := renderPage(context.Background(), "Callouts", []byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(html)); got != want {
+			t.Errorf("render(%q):\n got %q\nwant %q", source, got, want)
+		}
+	}
+}
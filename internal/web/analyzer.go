@@ -0,0 +1,149 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// analyzer turns text into the terms the search index stores: lowercased
+// words without stop words, cut down to a stem. The same analyzer runs over
+// pages and queries so that "deploying" finds "deployed".
+type analyzer struct {
+	stop map[string]bool
+	stem func(string) string
+}
+
+var analyzers = map[string]*analyzer{
+	"english": {stop: wordSet(englishStopWords), stem: stemEnglish},
+	"russian": {stop: wordSet(russianStopWords), stem: stemRussian},
+	"none":    {stop: map[string]bool{}, stem: func(s string) string { return s }},
+}
+
+// searchAnalyzer is picked by SEARCH_LANGUAGE. The index lives in memory and
+// is built on start, so changing the language reindexes every page.
+var searchAnalyzer = analyzers["english"]
+
+func setupSearchLanguage() error {
+	lang := strings.ToLower(os.Getenv("SEARCH_LANGUAGE"))
+	if lang == "" {
+		return nil
+	}
+
+	a, ok := analyzers[lang]
+	if !ok {
+		return fmt.Errorf("invalid SEARCH_LANGUAGE %q, expected english, russian or none", lang)
+	}
+	searchAnalyzer = a
+
+	return nil
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// words splits text on anything that is not a letter or a digit and
+// lowercases the pieces.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// terms analyzes text into index terms, in order and with repeats.
+func (a *analyzer) terms(text string) []string {
+	var terms []string
+	for _, w := range words(text) {
+		w = strings.ReplaceAll(w, "ё", "е")
+		if a.stop[w] {
+			continue
+		}
+		terms = append(terms, a.stem(w))
+	}
+	return terms
+}
+
+// Both stemmers only ever cut letters off the end of a word, so a stem is
+// always a prefix of the words it came from and can be looked up in the
+// plain text for snippets.
+
+var englishSuffixes = []string{"ingly", "edly", "ings", "ing", "ies", "ied", "ed", "ly", "es", "s"}
+
+func stemEnglish(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+
+	for _, suffix := range englishSuffixes {
+		stem, ok := strings.CutSuffix(w, suffix)
+		if !ok || len(stem) < 3 {
+			continue
+		}
+
+		switch suffix {
+		case "s":
+			if strings.HasSuffix(stem, "s") || strings.HasSuffix(stem, "u") || strings.HasSuffix(stem, "i") {
+				continue
+			}
+		case "es":
+			// "boxes" loses "es", "notes" only loses the "s".
+			if !strings.HasSuffix(stem, "s") && !strings.HasSuffix(stem, "x") && !strings.HasSuffix(stem, "z") &&
+				!strings.HasSuffix(stem, "ch") && !strings.HasSuffix(stem, "sh") {
+				stem += "e"
+			}
+		case "ies", "ied":
+			stem += "i"
+		case "ing", "ings", "ingly", "ed", "edly":
+			// "running" and "planned" lose the doubled consonant.
+			if n := len(stem); n >= 4 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiouylsz", rune(stem[n-1])) {
+				stem = stem[:n-1]
+			}
+		}
+		w = stem
+		break
+	}
+
+	// "save", "saved" and "saving" all become "sav".
+	if len(w) > 3 && strings.HasSuffix(w, "e") {
+		w = w[:len(w)-1]
+	}
+
+	return w
+}
+
+// russianSuffixes are the common noun, adjective and verb endings, longest
+// first.
+var russianSuffixes = []string{
+	"ениями", "ениях", "иями", "ение", "ения", "ению", "ением",
+	"ающий", "яющий", "ывать", "ивать", "ующий",
+	"ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ать", "ять", "еть", "ить", "ешь", "ишь",
+	"ует", "уют", "ают", "яют", "ала", "ило", "ила",
+	"ая", "яя", "ое", "ее", "ые", "ие", "ой", "ей", "ий", "ый", "ом", "ем", "ам", "ям",
+	"ах", "ях", "ов", "ев", "ию", "ью", "ия", "ья", "ть", "ла", "ло", "ли", "ет", "ит", "ут", "ют", "ат", "ят",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+func stemRussian(w string) string {
+	for _, suffix := range russianSuffixes {
+		if stem, ok := strings.CutSuffix(w, suffix); ok && utf8.RuneCountInString(stem) >= 3 {
+			return stem
+		}
+	}
+	return w
+}
+
+const englishStopWords = `a an and are as at be but by for from has have he her his i if in into is it
+its of on or she so that the their them then there these they this to was we were what when where which
+who will with you your`
+
+const russianStopWords = `а без более бы был была были было быть в вам вас весь во вот все всего всех вы
+где да даже для до его ее ей ему если есть еще же за здесь и из или им их к как ко когда кто ли либо мне
+может мы на над надо не него нее нет ни них но ну о об однако он она они оно от очень по под при с со так
+также такой там те тем то того тоже той только том ты у уже хотя чего чей чем что чтобы чье эта эти это я`
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"slices"
+	"testing"
+)
+
+// sameStem checks that every word of each group analyzes to one stem and
+// that the groups stay apart.
+func sameStem(t *testing.T, a *analyzer, groups [][]string) {
+	t.Helper()
+
+	stems := map[string]int{}
+	for g, group := range groups {
+		first := a.terms(group[0])
+		if len(first) != 1 {
+			t.Errorf("terms(%q) = %v, want one term", group[0], first)
+			continue
+		}
+		for _, w := range group[1:] {
+			if got := a.terms(w); !slices.Equal(got, first) {
+				t.Errorf("terms(%q) = %v, want %v like %q", w, got, first, group[0])
+			}
+		}
+		if other, ok := stems[first[0]]; ok {
+			t.Errorf("%q and %q share the stem %q", groups[other][0], group[0], first[0])
+		}
+		stems[first[0]] = g
+	}
+}
+
+func TestEnglishAnalyzer(t *testing.T) {
+	a := analyzers["english"]
+
+	sameStem(t, a, [][]string{
+		{"deploy", "deploys", "deployed", "deploying", "Deployings"},
+		{"save", "saves", "saved", "saving"},
+		{"run", "runs", "running"},
+		{"plan", "plans", "planned", "planning"},
+		{"box", "boxes"},
+		{"note", "notes", "noted"},
+		{"studies", "studied"},
+		{"quick", "quickly"},
+		{"class", "classes"},
+		{"status"},
+		{"fall", "falls", "falling"},
+	})
+
+	if got := a.terms("The wiki is for the team, and it works!"); !slices.Equal(got, []string{"wiki", "team", "work"}) {
+		t.Errorf("terms = %v, want stop words and punctuation dropped", got)
+	}
+	if got := a.terms("bus gas is"); !slices.Equal(got, []string{"bus", "gas"}) {
+		t.Errorf("short words = %v, want them kept whole", got)
+	}
+	if got := a.terms("HTTP2 v1.2 über"); !slices.Equal(got, []string{"http2", "v1", "2", "über"}) {
+		t.Errorf("terms = %v", got)
+	}
+}
+
+func TestRussianAnalyzer(t *testing.T) {
+	a := analyzers["russian"]
+
+	sameStem(t, a, [][]string{
+		{"страница", "страницы", "странице", "страницу", "страницей", "страницами", "страницах"},
+		{"сервер", "сервера", "серверу", "серверов", "серверами"},
+		{"новый", "новая", "новое", "новые", "нового", "новыми"},
+		{"скрипт", "скрипты", "скриптом", "скриптами"},
+		{"ёлка", "елка", "ёлки"},
+	})
+
+	if got := a.terms("Это не страница, а сервер"); !slices.Equal(got, []string{"страниц", "сервер"}) {
+		t.Errorf("terms = %v, want stop words dropped", got)
+	}
+	if got := a.terms("дом"); !slices.Equal(got, []string{"дом"}) {
+		t.Errorf("terms(дом) = %v, want short words kept", got)
+	}
+}
+
+func TestNoneAnalyzer(t *testing.T) {
+	if got := analyzers["none"].terms("The Running dogs"); !slices.Equal(got, []string{"the", "running", "dogs"}) {
+		t.Errorf("terms = %v, want only lowercasing", got)
+	}
+}
+
+// Stems must stay prefixes of their words so snippets can find them.
+func TestStemsArePrefixes(t *testing.T) {
+	for lang, words := range map[string][]string{
+		"english": {"deploying", "saved", "boxes", "studies", "running", "planned", "notes", "quickly"},
+		"russian": {"страницами", "серверов", "новыми", "разворачивают"},
+	} {
+		for _, w := range words {
+			stem := analyzers[lang].terms(w)[0]
+			if len(stem) > len(w) || w[:len(stem)] != stem {
+				t.Errorf("%s stem of %q is %q, not a prefix", lang, w, stem)
+			}
+		}
+	}
+}
+
+func TestSetupSearchLanguage(t *testing.T) {
+	setGlobal(t, &searchAnalyzer, searchAnalyzer)
+
+	t.Setenv("SEARCH_LANGUAGE", "Russian")
+	if err := setupSearchLanguage(); err != nil || searchAnalyzer != analyzers["russian"] {
+		t.Errorf("setupSearchLanguage = %v, analyzer %p", err, searchAnalyzer)
+	}
+	t.Setenv("SEARCH_LANGUAGE", "klingon")
+	if err := setupSearchLanguage(); err == nil {
+		t.Error("setupSearchLanguage accepted an unknown language")
+	}
+}
+
+var searchCorpus = map[string]string{
+	"Deploy-Guide":  "Deploying the service: we deployed version two by running the deploy script.",
+	"Release-Notes": "Release notes. The deploy of version two went fine.",
+	"Lunch":         "Where the team goes for lunch on Fridays.",
+	"Runbook":       "What to do when the service is down: restart it, then check the logs.",
+	"Onboarding":    "New people read the runbook and the deploy guide in their first week.",
+	"Russian-Page":  "Как развернуть сервер: разворачивают серверы скриптом.",
+}
+
+func seedCorpus(t *testing.T) {
+	for title, body := range searchCorpus {
+		if err := store.Write(title, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resetState(t)
+	setGlobal(t, &searchWeights, rankWeights{Relevance: 1})
+}
+
+func searchTitles(query string) []string {
+	var titles []string
+	for _, r := range searchPages(query) {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+// TestSearchRelevance is a regression test of the ranking over a fixed
+// corpus. Deploy-Guide wins "deploying" for its title and its three uses.
+func TestSearchRelevance(t *testing.T) {
+	newTestWiki(t)
+	seedCorpus(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"deploying", []string{"Deploy-Guide", "Onboarding", "Release-Notes"}},
+		{"deployed version", []string{"Deploy-Guide", "Release-Notes"}},
+		{"lunch", []string{"Lunch"}},
+		{"services", []string{"Deploy-Guide", "Runbook"}},
+		{"runbook restart", []string{"Runbook"}},
+		{"the", []string{"Deploy-Guide", "Lunch", "Onboarding", "Release-Notes", "Runbook"}},
+		{`"deploy script"`, []string{"Deploy-Guide"}},
+		{`"deployed versions"`, nil},
+		{"nothing matches this", nil},
+	}
+	for _, tt := range tests {
+		got := searchTitles(tt.query)
+		if tt.query == "the" {
+			slices.Sort(got)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// Switching the language and reindexing finds the Russian inflections.
+func TestSearchLanguageReindex(t *testing.T) {
+	newTestWiki(t)
+	seedCorpus(t)
+
+	if got := searchTitles("серверами"); len(got) != 0 {
+		t.Errorf("english index found %v for a Russian inflection", got)
+	}
+
+	setGlobal(t, &searchAnalyzer, analyzers["russian"])
+	rebuildIndexes()
+	if got := searchTitles("серверами"); !slices.Equal(got, []string{"Russian-Page"}) {
+		t.Errorf("russian index found %v, want [Russian-Page]", got)
+	}
+}
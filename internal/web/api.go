@@ -0,0 +1,403 @@
+package web
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+type apiRoleKey struct{}
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+type apiPage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+const (
+	defaultRecentLimit = 20
+	maxRecentLimit     = 500
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchOffset    = 10000
+	maxBatchTitles     = 50
+)
+
+type apiSearchResult struct {
+	Title      string      `json:"title"`
+	Score      float64     `json:"score"`
+	Scores     *scoreParts `json:"scores,omitempty"`
+	Modified   time.Time   `json:"modified"`
+	Snippet    string      `json:"snippet"`
+	Highlights []textRange `json:"highlights"`
+}
+
+var apiTokens = map[string]string{}
+
+// setupAPITokens reads static bearer tokens from API_TOKENS as "token:role" pairs.
+func setupAPITokens() error {
+	for _, pair := range splitList(os.Getenv("API_TOKENS"), ",") {
+		token, role, ok := strings.Cut(pair, ":")
+		if !ok {
+			role = roleEditor
+		}
+		if !validRole(role) {
+			return fmt.Errorf("invalid API_TOKENS: unknown role %q", role)
+		}
+		apiTokens[token] = role
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// requireAPIAuth accepts either a static token or a JWT. When neither is
+// configured the API is as open as the HTML wiki.
+func requireAPIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(apiTokens) == 0 && jwtAuth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized", "reason": "missing_token"})
+			return
+		}
+
+		role, ok := apiTokens[token]
+		if !ok {
+			if jwtAuth == nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized", "reason": "invalid_token"})
+				return
+			}
+
+			var reason string
+			role, reason = jwtAuth.verify(token)
+			if reason != "" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized", "reason": reason})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiRoleKey{}, role)))
+	})
+}
+
+func apiPagesHandler(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutPrefix(r.URL.Path, "/api/pages/"); ok && apiPageRoute(w, r, path) {
+		return
+	}
+	title := strings.TrimPrefix(r.URL.Path, "/api/pages/")
+	if (r.Method == http.MethodPut || r.Method == http.MethodPost) && r.URL.Path != "/api/pages" {
+		apiSavePageHandler(w, r, title)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if r.URL.Path == "/api/pages" {
+		titles, err := listPages()
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if titles == nil {
+			titles = []string{}
+		}
+
+		writeJSON(w, http.StatusOK, map[string][]string{"pages": titles})
+		return
+	}
+
+	if !validTitle(title) {
+		writeAPIError(w, http.StatusBadRequest, "invalid title")
+		return
+	}
+
+	p, err := loadPage(title)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	if fi, err := statPage(title); err == nil {
+		w.Header().Set("ETag", pageETag(fi))
+	}
+
+	writeJSON(w, http.StatusOK, apiPage{Title: p.Title, Body: string(p.Body)})
+}
+
+// apiWriteMu makes the precondition check and the write of an API save one
+// step, so two create-only requests cannot both succeed.
+var apiWriteMu sync.Mutex
+
+// apiSavePageHandler creates or replaces a page. "If-None-Match: *" only
+// creates, If-Match only updates, either the page with that ETag or, with
+// "*", any existing version.
+func apiSavePageHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if !validTitle(title) {
+		writeAPIError(w, http.StatusBadRequest, "invalid title")
+		return
+	}
+	if !apiCanEdit(r, title) {
+		writeAPIError(w, http.StatusForbidden, "not allowed to edit "+title)
+		return
+	}
+	if ipBlocked(r) {
+		writeAPIError(w, errIPBlocked.status, errIPBlocked.msg)
+		return
+	}
+
+	var req struct {
+		Body    string `json:"body"`
+		Summary string `json:"summary"`
+		Minor   bool   `json:"minor"`
+		Confirm bool   `json:"confirm"`
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxPageSize)+4096))
+	if err == nil && !utf8.Valid(raw) {
+		// The decoder would quietly swap invalid bytes for U+FFFD.
+		writeAPIError(w, http.StatusBadRequest, "Page body is not valid UTF-8 text")
+		return
+	}
+	if err != nil || json.Unmarshal(raw, &req) != nil {
+		writeAPIError(w, http.StatusBadRequest, "body must be {\"body\": <markdown>}")
+		return
+	}
+
+	apiWriteMu.Lock()
+	defer apiWriteMu.Unlock()
+
+	fi, err := statPage(title)
+	exists := err == nil
+	if match := r.Header.Get("If-None-Match"); match == "*" && exists {
+		writeAPIError(w, http.StatusPreconditionFailed, "page already exists")
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists || match != "*" && match != pageETag(fi) {
+			writeAPIError(w, http.StatusPreconditionFailed, "page does not exist or has changed")
+			return
+		}
+	}
+
+	p := &page.Page{Title: title, Body: []byte(req.Body), Summary: capSummary(req.Summary), Minor: req.Minor}
+	outcome, err := submitEdit(r, title, p, "", req.Confirm)
+	if status, ok := saveErrorStatus(err); ok {
+		setRetryAfter(w, err)
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if outcome.queued {
+		writeJSON(w, http.StatusAccepted, map[string]any{"title": title, "queued": true})
+		return
+	}
+
+	if fi, err := statPage(title); err == nil {
+		w.Header().Set("ETag", pageETag(fi))
+	}
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, apiPage{Title: p.Title, Body: string(p.Body)})
+}
+
+func apiRecentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := defaultRecentLimit
+	if raw := r.FormValue("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxRecentLimit)
+	}
+
+	changes, err := recentChanges(limit, r.FormValue("hideminor") == "1")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]recentChange{"changes": changes})
+}
+
+type apiBatchEntry struct {
+	Body  *string `json:"body,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// apiBatchHandler returns several pages in one request. Each title gets
+// either its body or the reason it could not be read, so one missing page
+// does not fail the whole batch. It takes the place of a page titled
+// "batch" in the API.
+func apiBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	titles := splitList(r.FormValue("titles"), ",")
+	if len(titles) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "titles is required")
+		return
+	}
+	if len(titles) > maxBatchTitles {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("at most %d titles per batch", maxBatchTitles))
+		return
+	}
+
+	pages := make(map[string]apiBatchEntry, len(titles))
+	for _, title := range titles {
+		if !validTitle(title) {
+			pages[title] = apiBatchEntry{Error: "invalid title"}
+			continue
+		}
+		p, err := loadPage(title)
+		if err != nil {
+			pages[title] = apiBatchEntry{Error: "page not found"}
+			continue
+		}
+		body := string(p.Body)
+		pages[title] = apiBatchEntry{Body: &body}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"pages": pages})
+}
+
+// apiIsAdmin reports whether the API token, or without API auth the session,
+// has the admin role, and the admin client certificate when one is required.
+func apiIsAdmin(r *http.Request) bool {
+	if adminCertError(r) != nil {
+		return false
+	}
+	if role, ok := r.Context().Value(apiRoleKey{}).(string); ok {
+		return role == roleAdmin
+	}
+	s, _ := currentSession(r)
+	return s.Role == roleAdmin
+}
+
+// apiSearchHandler runs the same search as /search and returns one page of
+// results as JSON.
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := strings.TrimSpace(r.FormValue("q"))
+	if query == "" {
+		writeAPIError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	debug := r.FormValue("debug") == "1"
+	if debug && !apiIsAdmin(r) {
+		writeAPIError(w, http.StatusForbidden, "debug scores are only shown to admins")
+		return
+	}
+
+	limit, offset := defaultSearchLimit, 0
+	if raw := r.FormValue("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+	if raw := r.FormValue("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxSearchOffset {
+			writeAPIError(w, http.StatusBadRequest, "offset must be between 0 and "+strconv.Itoa(maxSearchOffset))
+			return
+		}
+		offset = n
+	}
+
+	infos, err := listPageInfos()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	modified := make(map[string]time.Time, len(infos))
+	for _, info := range infos {
+		modified[info.Title] = info.Modified
+	}
+
+	hits := searchPages(query)
+	start := min(offset, len(hits))
+	end := start + min(limit, len(hits)-start)
+	window := hits[start:end]
+	results := make([]apiSearchResult, 0, len(window))
+	for _, hit := range window {
+		highlights := hit.Highlights
+		if highlights == nil {
+			highlights = []textRange{}
+		}
+		result := apiSearchResult{
+			Title:      hit.Title,
+			Score:      hit.score,
+			Modified:   modified[hit.Title].UTC(),
+			Snippet:    hit.Snippet,
+			Highlights: highlights,
+		}
+		if debug {
+			result.Scores = &hit.parts
+		}
+		results = append(results, result)
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Query   string            `json:"query"`
+		Total   int               `json:"total"`
+		Limit   int               `json:"limit"`
+		Offset  int               `json:"offset"`
+		Results []apiSearchResult `json:"results"`
+	}{query, len(hits), limit, offset, results})
+}
+
+func apiOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,223 @@
+package web
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T, w *testWiki) openAPIDoc {
+	t.Helper()
+	resp, body := w.get("/api/openapi.json")
+	wantStatus(t, resp, body, http.StatusOK)
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	w := newTestWiki(t)
+	doc := loadOpenAPI(t, w)
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want version 3", doc.OpenAPI)
+	}
+
+	for path, verbs := range map[string][]string{
+		"/api/pages":         {"get"},
+		"/api/pages/{title}": {"get", "put", "post"},
+	} {
+		for _, verb := range verbs {
+			if _, ok := doc.Paths[path][verb]; !ok {
+				t.Errorf("spec lacks %s %s", strings.ToUpper(verb), path)
+			}
+		}
+	}
+
+	// Every schema reference points at a defined schema.
+	raw, err := os.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range regexp.MustCompile(`"\$ref":\s*"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		if _, ok := doc.Components.Schemas[m[1]]; !ok {
+			t.Errorf("reference to undefined schema %q", m[1])
+		}
+	}
+}
+
+// The spec and the routes describe the same API: every registered API route
+// is documented, and every documented operation is served.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	doc := loadOpenAPI(t, w)
+	editor := w.login("alice", roleEditor)
+
+	src, err := os.ReadFile("wiki.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range regexp.MustCompile(`mux\.Handle(?:Func)?\("(/api/[^"]*)"`).FindAllStringSubmatch(string(src), -1) {
+		route := m[1]
+		if route == "/api/openapi.json" {
+			continue
+		}
+		documented := slices.ContainsFunc(slices.Collect(maps.Keys(doc.Paths)), func(p string) bool {
+			return p == route || strings.HasSuffix(route, "/") && strings.HasPrefix(p, route)
+		})
+		if !documented {
+			t.Errorf("route %s is not in the spec", route)
+		}
+	}
+
+	params := strings.NewReplacer("{title}", "Home", "{rev}", "1")
+	for path, ops := range doc.Paths {
+		for verb := range ops {
+			if verb == "parameters" {
+				continue
+			}
+			resp, body := w.api(strings.ToUpper(verb), params.Replace(path), `{}`, editor)
+			if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotFound && !strings.Contains(body, "error") {
+				t.Errorf("%s %s documented but not served: %d %s", strings.ToUpper(verb), path, resp.StatusCode, body)
+			}
+		}
+	}
+}
+
+// apiSave sends a PUT of body to title with the given precondition header.
+func (w *testWiki) apiSave(title, body, header, value string) (*http.Response, string) {
+	w.t.Helper()
+	req, err := http.NewRequest(http.MethodPut, w.URL+"/api/pages/"+title, strings.NewReader(`{"body":"`+body+`"}`))
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	return w.do(req)
+}
+
+func TestAPIConditionalSave(t *testing.T) {
+	w := newTestWiki(t)
+
+	resp, body := w.apiSave("Home", "first", "If-None-Match", "*")
+	wantStatus(t, resp, body, http.StatusCreated)
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("created page has no ETag")
+	}
+	resp, body = w.apiSave("Home", "clobbered", "If-None-Match", "*")
+	wantStatus(t, resp, body, http.StatusPreconditionFailed)
+	if _, body := w.get("/raw/Home"); body != "first" {
+		t.Errorf("create-only request replaced the page: %q", body)
+	}
+
+	// Update-only needs the page, and with an ETag that exact version.
+	resp, body = w.apiSave("Missing", "new", "If-Match", "*")
+	wantStatus(t, resp, body, http.StatusPreconditionFailed)
+	if _, err := loadPage("Missing"); err == nil {
+		t.Error("update-only request created a page")
+	}
+	resp, body = w.apiSave("Home", "second version", "If-Match", etag)
+	wantStatus(t, resp, body, http.StatusOK)
+	resp, body = w.apiSave("Home", "based on the first", "If-Match", etag)
+	wantStatus(t, resp, body, http.StatusPreconditionFailed)
+	resp, body = w.apiSave("Home", "any version", "If-Match", "*")
+	wantStatus(t, resp, body, http.StatusOK)
+	if _, body := w.get("/raw/Home"); body != "any version" {
+		t.Errorf("page = %q", body)
+	}
+
+	// Of concurrent create-only requests exactly one wins.
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, body := w.apiSave("Race", "racer "+strconv.Itoa(i), "If-None-Match", "*")
+			switch resp.StatusCode {
+			case http.StatusCreated:
+				created.Add(1)
+			case http.StatusPreconditionFailed:
+			default:
+				t.Errorf("racing create = %d\n%s", resp.StatusCode, body)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := created.Load(); n != 1 {
+		t.Errorf("%d create-only requests succeeded", n)
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Alpha", "alpha body")
+	w.seed("Empty", "")
+
+	resp, body := w.get("/api/pages/batch?titles=Alpha,%20Missing%20,Empty,,..%2Fsecret")
+	wantStatus(t, resp, body, http.StatusOK)
+	var got struct {
+		Pages map[string]apiBatchEntry
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	str := func(s string) *string { return &s }
+	want := map[string]apiBatchEntry{
+		"Alpha":     {Body: str("alpha body")},
+		"Empty":     {Body: str("")},
+		"Missing":   {Error: "page not found"},
+		"../secret": {Error: "invalid title"},
+	}
+	if !reflect.DeepEqual(got.Pages, want) {
+		t.Errorf("batch = %s", body)
+	}
+	// An empty page still has a body, so it can be told from a missing one.
+	if !strings.Contains(body, `"Empty":{"body":""}`) {
+		t.Errorf("empty page in batch: %s", body)
+	}
+
+	titles := make([]string, maxBatchTitles)
+	for i := range titles {
+		titles[i] = "Page-" + strconv.Itoa(i)
+	}
+	resp, body = w.get("/api/pages/batch?titles=" + strings.Join(titles, ","))
+	wantStatus(t, resp, body, http.StatusOK)
+	resp, body = w.get("/api/pages/batch?titles=" + strings.Join(append(titles, "One-more"), ","))
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	for _, query := range []string{"", "?titles=", "?titles=%20,%20"} {
+		resp, body := w.get("/api/pages/batch" + query)
+		wantStatus(t, resp, body, http.StatusBadRequest)
+	}
+	resp, body = w.api(http.MethodPut, "/api/pages/batch", `{"body":"x"}`)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+	if _, err := loadPage("batch"); err == nil {
+		t.Error("PUT to the batch endpoint saved a page")
+	}
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// apiPageRoute serves /api/pages/<title>/history, /history/<rev>, /revert
+// and /copy. It reports false for paths that are not one of those.
+func apiPageRoute(w http.ResponseWriter, r *http.Request, path string) bool {
+	title, rest, ok := strings.Cut(path, "/")
+	if !ok {
+		return false
+	}
+	if !validTitle(title) {
+		writeAPIError(w, http.StatusBadRequest, "invalid title")
+		return true
+	}
+
+	switch {
+	case rest == "history":
+		apiHistoryHandler(w, r, title)
+	case strings.HasPrefix(rest, "history/"):
+		apiRevisionHandler(w, r, title, strings.TrimPrefix(rest, "history/"))
+	case rest == "revert":
+		apiRevertHandler(w, r, title)
+	case rest == "copy":
+		apiCopyHandler(w, r, title)
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+	}
+
+	return true
+}
+
+func apiHistoryHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit, offset := defaultHistoryLimit, 0
+	if raw := r.FormValue("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+	if raw := r.FormValue("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "offset must not be negative")
+			return
+		}
+		offset = n
+	}
+
+	if _, err := statPage(title); err != nil {
+		writeAPIError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	revs, err := listRevisions(title)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	start := min(offset, len(revs))
+	end := min(start+limit, len(revs))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"title":     title,
+		"total":     len(revs),
+		"limit":     limit,
+		"offset":    offset,
+		"revisions": append([]revision{}, revs[start:end]...),
+	})
+}
+
+func apiRevisionHandler(w http.ResponseWriter, r *http.Request, title, raw string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+
+	rev, body, err := findRevision(title, id)
+	if os.IsNotExist(err) {
+		writeAPIError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"title": title, "revision": rev, "body": string(body)})
+}
+
+// apiCanEdit applies the edit and protection rules of the HTML wiki to the
+// role of the API token. Without API auth configured it follows anonymous
+// editing.
+func apiCanEdit(r *http.Request, title string) bool {
+	role, ok := r.Context().Value(apiRoleKey{}).(string)
+	if !ok {
+		return config.AllowAnonymousEdit && pageProtection(title) == protectionOpen
+	}
+
+	switch pageProtection(title) {
+	case protectionAdmins:
+		return role == roleAdmin && adminCertError(r) == nil
+	default:
+		return role == roleEditor || role == roleAdmin
+	}
+}
+
+// apiAnonymous reports a request made without API auth, which is held to the
+// rules of anonymous visitors.
+func apiAnonymous(r *http.Request) bool {
+	_, ok := r.Context().Value(apiRoleKey{}).(string)
+	return !ok
+}
+
+func apiRevertHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !apiCanEdit(r, title) {
+		writeAPIError(w, http.StatusForbidden, "not allowed to edit "+title)
+		return
+	}
+	if ipBlocked(r) {
+		writeAPIError(w, errIPBlocked.status, errIPBlocked.msg)
+		return
+	}
+
+	var req struct {
+		Revision int `json:"revision"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil || req.Revision < 1 {
+		writeAPIError(w, http.StatusBadRequest, "body must be {\"revision\": <id>}")
+		return
+	}
+
+	rev, queued, err := revertPage(r, title, req.Revision)
+	if os.IsNotExist(err) {
+		writeAPIError(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	if status, ok := saveErrorStatus(err); ok {
+		setRetryAfter(w, err)
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if queued {
+		writeJSON(w, http.StatusAccepted, map[string]any{"title": title, "queued": true})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"title": title, "revision": rev})
+}
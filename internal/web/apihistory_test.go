@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAPIHistory(t *testing.T) {
+	w := newTestWiki(t)
+	for _, body := range []string{"one", "two", "three"} {
+		w.seed("Home", body)
+	}
+
+	var res struct {
+		Title     string     `json:"title"`
+		Total     int        `json:"total"`
+		Limit     int        `json:"limit"`
+		Offset    int        `json:"offset"`
+		Revisions []revision `json:"revisions"`
+	}
+	list := func(query string) []int {
+		t.Helper()
+		resp, body := w.get("/api/pages/Home/history" + query)
+		wantStatus(t, resp, body, http.StatusOK)
+		res.Revisions = nil
+		if err := json.Unmarshal([]byte(body), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Revisions == nil {
+			t.Errorf("%s: revisions is not an array:\n%s", query, body)
+		}
+		var ids []int
+		for _, rev := range res.Revisions {
+			ids = append(ids, rev.ID)
+		}
+		return ids
+	}
+
+	if ids := list(""); !slices.Equal(ids, []int{3, 2, 1}) || res.Title != "Home" || res.Total != 3 || res.Limit != defaultHistoryLimit {
+		t.Errorf("history = %v, %+v", ids, res)
+	}
+	if res.Revisions[0].Size != len("three") {
+		t.Errorf("newest revision = %+v", res.Revisions[0])
+	}
+	if ids := list("?limit=1&offset=1"); !slices.Equal(ids, []int{2}) || res.Total != 3 || res.Offset != 1 {
+		t.Errorf("second page = %v, %+v", ids, res)
+	}
+	if ids := list("?offset=10"); len(ids) != 0 || res.Total != 3 {
+		t.Errorf("past the end = %v, %+v", ids, res)
+	}
+	if list("?limit=100000"); res.Limit != maxHistoryLimit {
+		t.Errorf("limit = %d, want it capped at %d", res.Limit, maxHistoryLimit)
+	}
+
+	resp, body := w.get("/api/pages/Home/history/1")
+	wantStatus(t, resp, body, http.StatusOK)
+	var rev struct {
+		Revision revision `json:"revision"`
+		Body     string   `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(body), &rev); err != nil || rev.Revision.ID != 1 || rev.Body != "one" {
+		t.Errorf("revision 1 = %+v, %v", rev, err)
+	}
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/pages/Home/history?limit=0", http.StatusBadRequest},
+		{http.MethodGet, "/api/pages/Home/history?offset=-1", http.StatusBadRequest},
+		{http.MethodGet, "/api/pages/Missing/history", http.StatusNotFound},
+		{http.MethodGet, "/api/pages/Bad_Title/history", http.StatusBadRequest},
+		{http.MethodGet, "/api/pages/Home/history/9", http.StatusNotFound},
+		{http.MethodGet, "/api/pages/Home/history/first", http.StatusBadRequest},
+		{http.MethodGet, "/api/pages/Home/blame", http.StatusNotFound},
+		{http.MethodPost, "/api/pages/Home/history", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/pages/Home/history/1", http.StatusMethodNotAllowed},
+	} {
+		resp, body := w.api(tt.method, tt.path, "")
+		wantStatus(t, resp, body, tt.status)
+		if resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s error is not JSON: %s", tt.method, tt.path, body)
+		}
+	}
+}
+
+func TestAPIRevert(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "one")
+	w.seed("Home", "two")
+
+	resp, body := w.api(http.MethodPost, "/api/pages/Home/revert", `{"revision": 1}`)
+	wantStatus(t, resp, body, http.StatusOK)
+	var res struct {
+		Revision revision `json:"revision"`
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil || res.Revision.ID != 3 || res.Revision.Summary != "Reverted to revision 1" {
+		t.Errorf("revert = %+v, %v", res, err)
+	}
+	if b, _ := store.Read("Home"); string(b) != "one" {
+		t.Errorf("page after revert = %q", b)
+	}
+
+	for _, tt := range []struct {
+		method, body string
+		status       int
+	}{
+		{http.MethodPost, `{"revision": 9}`, http.StatusNotFound},
+		{http.MethodPost, `{"revision": 0}`, http.StatusBadRequest},
+		{http.MethodPost, `not json`, http.StatusBadRequest},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+	} {
+		resp, body := w.api(tt.method, "/api/pages/Home/revert", tt.body)
+		wantStatus(t, resp, body, tt.status)
+	}
+
+	// Reverting is editing: readers and, when anonymous editing is off,
+	// callers without a token are refused.
+	config.AllowAnonymousEdit = false
+	resp, body = w.api(http.MethodPost, "/api/pages/Home/revert", `{"revision": 2}`)
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	setGlobal(t, &apiTokens, map[string]string{"reader": roleReader, "editor": roleEditor})
+	revert := func(token string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, w.URL+"/api/pages/Home/revert", strings.NewReader(`{"revision": 2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return w.do(req)
+	}
+	resp, body = revert("reader")
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = revert("editor")
+	wantStatus(t, resp, body, http.StatusOK)
+	if b, _ := store.Read("Home"); string(b) != "two" {
+		t.Errorf("page after the editor's revert = %q", b)
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+const (
+	roleReader = "reader"
+	roleEditor = "editor"
+	roleAdmin  = "admin"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid username or password")
+	errAuthUnavailable    = errors.New("auth backend unavailable")
+)
+
+// authBackend verifies a username and password and reports the user's wiki role.
+// Implementations return errInvalidCredentials for rejected logins and wrap
+// errAuthUnavailable when the backend itself cannot be reached.
+type authBackend interface {
+	Authenticate(username, password string) (string, error)
+}
+
+var authenticator authBackend
+
+type loginData struct {
+	Username      string
+	Error         string
+	PasswordLogin bool
+	GitHubLogin   bool
+}
+
+func validRole(role string) bool {
+	return role == roleReader || role == roleEditor || role == roleAdmin
+}
+
+func setupAuthBackend() error {
+	if os.Getenv("LDAP_URL") != "" {
+		backend, err := newLDAPBackend()
+		if err != nil {
+			return err
+		}
+		authenticator = backend
+	} else if os.Getenv("LOCAL_AUTH") == "true" {
+		authenticator = localBackend{}
+	}
+
+	return nil
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if authenticator == nil && github == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := pageData{
+		Title:   "Log in",
+		Content: &loginData{PasswordLogin: authenticator != nil, GitHubLogin: github != nil},
+	}
+
+	if r.Method != http.MethodPost || authenticator == nil {
+		renderTemplate(w, r, data, "login")
+		return
+	}
+
+	username := r.FormValue("username")
+	role, err := authenticator.Authenticate(username, r.FormValue("password"))
+	if err != nil {
+		content := data.Content.(*loginData)
+		content.Username = username
+		content.Error = err.Error()
+		data.Status = http.StatusUnauthorized
+
+		if errors.Is(err, errAuthUnavailable) {
+			slog.Error("error authenticating user", "user", username, "err", err)
+			content.Error = "Authentication backend is unavailable, please try again later."
+			data.Status = http.StatusServiceUnavailable
+		} else if !errors.Is(err, errInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		renderTemplate(w, r, data, "login")
+		return
+	}
+
+	if err := setSession(w, username, role); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Logged in as " + username})
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
+
+// canEdit reports whether the request may change pages: logged-in editors and
+// admins always can, anonymous visitors only when ALLOW_ANONYMOUS_EDIT allows it.
+func canEdit(r *http.Request) bool {
+	s, ok := currentSession(r)
+	if !ok {
+		return config.AllowAnonymousEdit
+	}
+
+	return s.Role == roleEditor || s.Role == roleAdmin
+}
+
+func requireEdit(fn func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request, string) {
+	return func(w http.ResponseWriter, r *http.Request, param string) {
+		if readOnly.Load() {
+			rejectReadOnly(w, r)
+			return
+		}
+		if ipBlocked(r) {
+			renderError(w, r, errIPBlocked.status, errIPBlocked.msg)
+			return
+		}
+
+		if canEdit(r) {
+			if level := pageProtection(param); !allowedByProtection(r, level) {
+				renderProtected(w, r, param, level)
+				return
+			}
+
+			fn(w, r, param)
+			return
+		}
+
+		if currentUser(r) != "" {
+			renderError(w, r, http.StatusForbidden, "Your account is not allowed to edit pages.")
+			return
+		}
+		if r.Method == http.MethodGet && (authenticator != nil || github != nil) {
+			http.Redirect(w, r, pageURL("/login"), http.StatusFound)
+			return
+		}
+
+		renderError(w, r, http.StatusUnauthorized, "Please log in to edit pages.")
+	}
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// basePath is the prefix the wiki is mounted under, like "/wiki", from
+// BASE_PATH. Empty serves it from the root.
+var basePath string
+
+func setupBasePath() error {
+	raw := os.Getenv("BASE_PATH")
+	p := strings.TrimSuffix(raw, "/")
+	if p == "" {
+		return nil
+	}
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#%") || path.Clean(p) != p {
+		return fmt.Errorf("invalid BASE_PATH %q, expected a path like /wiki", raw)
+	}
+	basePath = p
+
+	addMarkdownOptions(goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(basePathTransformer{}, 600))))
+
+	return nil
+}
+
+// pageURL prefixes a root-relative path with BASE_PATH. Every URL the wiki
+// generates for itself, links and redirects alike, goes through here. Full
+// and protocol-relative URLs are returned unchanged.
+func pageURL(p string) string {
+	if basePath == "" || !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") {
+		return p
+	}
+	return basePath + p
+}
+
+// withBasePath strips BASE_PATH before routing, so the handlers keep seeing
+// paths from the root. Requests outside the prefix are not the wiki's.
+func withBasePath(next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, basePath)
+		if !ok || (rest != "" && rest[0] != '/') {
+			http.NotFound(w, r)
+			return
+		}
+		if rest == "" {
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		if raw, ok := strings.CutPrefix(r.URL.RawPath, basePath); ok {
+			r2.URL.RawPath = raw
+		}
+
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// basePathTransformer prefixes the root-relative links and images that
+// pages are written with, such as [Home](/view/Home).
+type basePathTransformer struct{}
+
+func (basePathTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Link:
+			n.Destination = []byte(pageURL(string(n.Destination)))
+		case *ast.Image:
+			n.Destination = []byte(pageURL(string(n.Destination)))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mountAt serves a fresh test wiki under prefix, as BASE_PATH=prefix would.
+func mountAt(t *testing.T, prefix string) *testWiki {
+	t.Helper()
+	setGlobal(t, &basePath, "")
+	setGlobal(t, &markdownOptions, slices.Clone(markdownOptions))
+	setGlobal(t, &markdown, markdown)
+	t.Setenv("BASE_PATH", prefix)
+	if err := setupBasePath(); err != nil {
+		t.Fatal(err)
+	}
+	return newTestWiki(t)
+}
+
+var urlAttrPattern = regexp.MustCompile(`(?:href|src|action)="([^"]*)"`)
+
+// crawlDenied are links the crawl checks but does not follow, since
+// following them changes the wiki or leaves it.
+var crawlDenied = []string{"/delete/", "/undo/", "/discard/", "/logout", "/auth/"}
+
+// TestBasePathCrawl follows every link of a wiki mounted under /wiki and
+// fails on any URL, in the HTML or in a redirect, that escapes the prefix.
+func TestBasePathCrawl(t *testing.T) {
+	w := mountAt(t, "/wiki/")
+	w.seed("Home", "See [Other](/view/Other), [the relative one](Other), ![logo](/logo) and [elsewhere](https://example.com/view/X).")
+	w.seed("Other", "Back [home](/view/Home).")
+	w.seed("Other", "Second revision, linking [a missing page](/view/Missing).")
+	rebuildIndexes()
+	admin := w.login("root", roleAdmin)
+
+	base, err := url.Parse(w.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(from *url.URL, raw string) (*url.URL, bool) {
+		u, err := from.Parse(html.UnescapeString(raw))
+		if err != nil {
+			t.Errorf("%s links to unparsable %q", from.Path, raw)
+			return nil, false
+		}
+		if u.Host != base.Host || u.Scheme != base.Scheme {
+			return nil, false
+		}
+		if u.Path != "/wiki" && !strings.HasPrefix(u.Path, "/wiki/") {
+			t.Errorf("%s links to %q outside the prefix", from.RequestURI(), raw)
+			return nil, false
+		}
+		return u, true
+	}
+
+	// The crawl starts from the index and the pages nothing links to.
+	queue := []string{"/wiki/", "/wiki/search?q=home", "/wiki/view/Missing", "/wiki/diff/Other?from=1&to=2",
+		"/wiki/changelog", "/wiki/trash", "/wiki/drafts", "/wiki/moderation", "/wiki/admin/ipblocks", "/wiki/admin/rules"}
+	seen := map[string]bool{}
+	for len(queue) > 0 && len(seen) < 300 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+
+		resp, body := w.get(next, admin)
+		if resp.StatusCode >= 500 {
+			t.Errorf("GET %s = %d\n%s", next, resp.StatusCode, body)
+		}
+		from := resp.Request.URL
+		var links []string
+		if loc := resp.Header.Get("Location"); loc != "" {
+			links = append(links, loc)
+		}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			for _, m := range urlAttrPattern.FindAllStringSubmatch(body, -1) {
+				links = append(links, m[1])
+			}
+		}
+
+		for _, raw := range links {
+			u, ok := check(from, raw)
+			if !ok || slices.ContainsFunc(crawlDenied, func(p string) bool { return strings.HasPrefix(u.Path, "/wiki"+p) }) {
+				continue
+			}
+			u.Fragment = ""
+			queue = append(queue, u.RequestURI())
+		}
+	}
+
+	for _, want := range []string{"/wiki/view/Other", "/wiki/history/Other", "/wiki/edit/Home", "/wiki/edit/Missing", "/wiki/logo", "/wiki/preferences"} {
+		if !seen[want] {
+			t.Errorf("crawl never reached %s; seen %d URLs", want, len(seen))
+		}
+	}
+
+	// Form submissions redirect inside the prefix too.
+	resp, body := w.post("/wiki/save/Home", url.Values{"title": {"Home"}, "body": {"edited"}}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	check(resp.Request.URL, resp.Header.Get("Location"))
+	resp, body = w.post("/wiki/rename/Other", url.Values{"newTitle": {"Moved"}}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	if loc := resp.Header.Get("Location"); loc != "/wiki/view/Moved" {
+		t.Errorf("rename redirects to %q", loc)
+	}
+}
+
+func TestBasePathRouting(t *testing.T) {
+	w := mountAt(t, "/wiki")
+	w.seed("Home", "home")
+
+	for _, tt := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/wiki/view/Home", http.StatusOK, ""},
+		{"/wiki/api/pages/Home", http.StatusOK, ""},
+		{"/wiki", http.StatusMovedPermanently, "/wiki/"},
+		{"/wiki?sort=size", http.StatusMovedPermanently, "/wiki/?sort=size"},
+		{"/view/Home", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+		{"/wikiview/Home", http.StatusNotFound, ""},
+		{"/wiki2/view/Home", http.StatusNotFound, ""},
+		{"/wiki/view/Missing", http.StatusFound, "/wiki/edit/Missing"},
+	} {
+		resp, body := w.get(tt.path)
+		if resp.StatusCode != tt.status || resp.Header.Get("Location") != tt.location {
+			t.Errorf("GET %s = %d %q, want %d %q\n%s", tt.path, resp.StatusCode, resp.Header.Get("Location"), tt.status, tt.location, body)
+		}
+	}
+}
+
+func TestSetupBasePath(t *testing.T) {
+	for _, raw := range []string{"wiki", "/wiki/../x", "/wiki?x", "/wi%20ki", "/wiki//sub"} {
+		setGlobal(t, &basePath, "")
+		t.Setenv("BASE_PATH", raw)
+		if err := setupBasePath(); err == nil {
+			t.Errorf("setupBasePath accepted %q", raw)
+		}
+	}
+}
+
+// With no BASE_PATH the wiki is served from the root as before.
+func TestNoBasePath(t *testing.T) {
+	w := mountAt(t, "")
+	w.seed("Home", "See [Other](/view/Other).")
+
+	resp, body := w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, `href="/view/Other"`) || !strings.Contains(body, `href="/edit/Home"`) {
+		t.Errorf("links are not root-relative:\n%s", body)
+	}
+	if resp, _ := w.get("/wiki/view/Home"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /wiki/view/Home = %d without a BASE_PATH", resp.StatusCode)
+	}
+}
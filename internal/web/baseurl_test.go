@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func setBaseURL(t *testing.T, raw string) {
+	t.Helper()
+	setGlobal(t, &baseURL, baseURL)
+	setGlobal(t, &baseURLSet, false)
+	t.Setenv("BASE_URL", raw)
+	if err := setupBaseURL(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetupBaseURL(t *testing.T) {
+	setGlobal(t, &baseURL, baseURL)
+	setGlobal(t, &baseURLSet, false)
+
+	for _, raw := range []string{
+		"ftp://wiki.example.com",
+		"wiki.example.com",
+		"https://",
+		"https://wiki.example.com/wiki",
+		"https://wiki.example.com?lang=en",
+		"https://wiki.example.com#top",
+		"https://admin@wiki.example.com",
+		"https://wiki.example.com:bad",
+	} {
+		t.Setenv("BASE_URL", raw)
+		if err := setupBaseURL(); err == nil || !strings.Contains(err.Error(), "invalid BASE_URL") {
+			t.Errorf("setupBaseURL(%q) = %v, want it rejected", raw, err)
+		}
+	}
+	if baseURLSet {
+		t.Fatal("a rejected BASE_URL was kept")
+	}
+
+	t.Setenv("BASE_URL", "https://wiki.example.com:8443/")
+	if err := setupBaseURL(); err != nil || !baseURLSet {
+		t.Fatalf("setupBaseURL = %v", err)
+	}
+	if got := absoluteURL("/view/Home"); got != "https://wiki.example.com:8443/view/Home" {
+		t.Errorf("absoluteURL = %q", got)
+	}
+}
+
+func TestAbsoluteURLUnderBasePath(t *testing.T) {
+	setGlobal(t, &basePath, "/wiki")
+	setBaseURL(t, "https://example.com")
+	if got := absoluteURL("/view/Front Page"); got != "https://example.com/wiki/view/Front%20Page" {
+		t.Errorf("absoluteURL = %q", got)
+	}
+}
+
+// Without BASE_URL the links follow the request; with it they never do.
+func TestRequestURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/view/Home", nil)
+	r.Host = "intranet:8080"
+	if got := requestURL(r, "/view/Home"); got != "http://intranet:8080/view/Home" {
+		t.Errorf("without BASE_URL = %q", got)
+	}
+
+	setBaseURL(t, "https://wiki.example.com")
+	r.Host = "evil.example.net"
+	if got := requestURL(r, "/view/Home"); got != "https://wiki.example.com/view/Home" {
+		t.Errorf("with BASE_URL = %q", got)
+	}
+}
+
+func TestBaseURLLinks(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	setBaseURL(t, "https://wiki.example.com")
+
+	_, body := w.get("/view/Home")
+	if !strings.Contains(body, `<link rel="canonical" href="https://wiki.example.com/view/Home">`) {
+		t.Errorf("canonical link does not use BASE_URL:\n%s", body)
+	}
+
+	setupTestGitHub(t, stubGitHub(t), map[string]string{"GITHUB_ALLOWED_ORG": "acme"})
+	resp, _ := w.get("/auth/github/login")
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loc.Query().Get("redirect_uri"); got != "https://wiki.example.com/auth/github/callback" {
+		t.Errorf("redirect_uri = %q", got)
+	}
+	for _, c := range resp.Cookies() {
+		if c.Name == oauthStateCookieName && !c.Secure {
+			t.Error("state cookie not Secure behind an https BASE_URL")
+		}
+	}
+}
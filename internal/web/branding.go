@@ -0,0 +1,179 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const brandingUploadLimit = 1 << 20
+
+//go:embed assets/favicon.ico assets/logo.svg
+var defaultAssets embed.FS
+
+// brandingAsset is an image admins can replace. The uploaded file is kept in
+// the config area of the storage; until there is one the embedded default
+// is served.
+type brandingAsset struct {
+	name     string
+	fallback string
+}
+
+var (
+	faviconAsset = brandingAsset{name: "favicon", fallback: "assets/favicon.ico"}
+	logoAsset    = brandingAsset{name: "logo", fallback: "assets/logo.svg"}
+)
+
+var brandingTypes = map[string]bool{
+	"image/x-icon":  true,
+	"image/png":     true,
+	"image/gif":     true,
+	"image/jpeg":    true,
+	"image/webp":    true,
+	"image/svg+xml": true,
+}
+
+func brandingDir() string {
+	return filepath.Join(config.StoragePath, ".config")
+}
+
+func (a brandingAsset) filename() string {
+	return filepath.Join(brandingDir(), a.name)
+}
+
+// imageType sniffs the type of an uploaded image. SVG is text, so
+// http.DetectContentType does not recognise it.
+func imageType(b []byte) string {
+	if ct := http.DetectContentType(b); ct != "text/xml; charset=utf-8" && ct != "text/plain; charset=utf-8" {
+		return ct
+	}
+	if bytes.Contains(b[:min(len(b), 1024)], []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+func (a brandingAsset) read() ([]byte, time.Time, error) {
+	b, err := os.ReadFile(a.filename())
+	if err == nil {
+		info, statErr := os.Stat(a.filename())
+		if statErr != nil {
+			return nil, time.Time{}, statErr
+		}
+		return b, info.ModTime(), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, time.Time{}, err
+	}
+
+	b, err = defaultAssets.ReadFile(a.fallback)
+	return b, time.Time{}, err
+}
+
+func (a brandingAsset) save(b []byte) error {
+	if !brandingTypes[imageType(b)] {
+		return errors.New("not a supported image")
+	}
+	if err := os.MkdirAll(brandingDir(), 0750); err != nil {
+		return err
+	}
+
+	tmp := a.filename() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.filename())
+}
+
+func (a brandingAsset) reset() error {
+	err := os.Remove(a.filename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (a brandingAsset) serve(w http.ResponseWriter, r *http.Request) {
+	b, modified, err := a.read()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(b)
+	w.Header().Set("Content-Type", imageType(b))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	// An uploaded SVG must not run scripts when opened directly.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	http.ServeContent(w, r, a.name, modified, bytes.NewReader(b))
+}
+
+func faviconHandler(w http.ResponseWriter, r *http.Request) {
+	faviconAsset.serve(w, r)
+}
+
+func logoHandler(w http.ResponseWriter, r *http.Request) {
+	logoAsset.serve(w, r)
+}
+
+// brandingHandler lets admins upload a favicon, a logo or both, or go back
+// to the defaults.
+func brandingHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can change the favicon and logo.")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		renderTemplate(w, r, pageData{Title: "Favicon and logo"}, "branding")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*brandingUploadLimit)
+	if err := r.ParseMultipartForm(brandingUploadLimit); err != nil {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Upload images of at most %d MB.", brandingUploadLimit>>20))
+		return
+	}
+
+	for _, a := range []brandingAsset{faviconAsset, logoAsset} {
+		if r.FormValue("reset_"+a.name) == "on" {
+			if err := a.reset(); err != nil {
+				renderError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			continue
+		}
+
+		f, _, err := r.FormFile(a.name)
+		if errors.Is(err, http.ErrMissingFile) {
+			continue
+		}
+		if err != nil {
+			renderError(w, r, http.StatusBadRequest, "Upload failed.")
+			return
+		}
+		b, err := io.ReadAll(io.LimitReader(f, brandingUploadLimit+1))
+		f.Close()
+		if err != nil || len(b) > brandingUploadLimit {
+			renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Upload images of at most %d MB.", brandingUploadLimit>>20))
+			return
+		}
+		if err := a.save(b); err != nil {
+			renderError(w, r, http.StatusBadRequest, "The "+a.name+" must be an ICO, PNG, GIF, JPEG, WebP or SVG image.")
+			return
+		}
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Favicon and logo updated"})
+	http.Redirect(w, r, pageURL("/admin/branding"), http.StatusFound)
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// uploadBranding posts files, keyed by form field, to the branding form.
+func (w *testWiki) uploadBranding(files map[string][]byte, fields map[string]string, cookies ...*http.Cookie) (*http.Response, string) {
+	w.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for field, b := range files {
+		fw, err := mw.CreateFormFile(field, field+".png")
+		if err != nil {
+			w.t.Fatal(err)
+		}
+		fw.Write(b)
+	}
+	for field, value := range fields {
+		mw.WriteField(field, value)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, w.URL+"/admin/branding", &buf)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return w.do(req, cookies...)
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	img.Set(3, 3, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBrandingUpload(t *testing.T) {
+	w := newTestWiki(t)
+	admin := w.login("root", roleAdmin)
+	defaultIcon, err := defaultAssets.ReadFile("assets/favicon.ico")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultLogo, err := defaultAssets.ReadFile("assets/logo.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := w.get("/favicon.ico")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != string(defaultIcon) || resp.Header.Get("Content-Type") != "image/x-icon" {
+		t.Errorf("favicon before upload is not the default (%s)", resp.Header.Get("Content-Type"))
+	}
+	defaultETag := resp.Header.Get("ETag")
+
+	icon := testPNG(t)
+	resp, body = w.uploadBranding(map[string][]byte{"favicon": icon}, nil, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resp, body = w.get("/favicon.ico")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != string(icon) || resp.Header.Get("Content-Type") != "image/png" || resp.Header.Get("ETag") == defaultETag {
+		t.Errorf("favicon after upload is %s with ETag %s", resp.Header.Get("Content-Type"), resp.Header.Get("ETag"))
+	}
+	// The logo was not part of the upload and stays the default.
+	if _, body := w.get("/logo"); body != string(defaultLogo) {
+		t.Error("favicon upload replaced the logo")
+	}
+	_, body = w.get("/")
+	if !strings.Contains(body, `<link rel="icon" href="/favicon.ico">`) {
+		t.Errorf("base template does not reference the favicon:\n%s", body)
+	}
+
+	resp, body = w.uploadBranding(nil, map[string]string{"reset_favicon": "on"}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, body := w.get("/favicon.ico"); body != string(defaultIcon) {
+		t.Error("reset did not restore the default favicon")
+	}
+}
+
+func TestBrandingUploadRejected(t *testing.T) {
+	w := newTestWiki(t)
+	admin := w.login("root", roleAdmin)
+
+	resp, body := w.uploadBranding(map[string][]byte{"favicon": []byte("#!/bin/sh\necho hi\n")}, nil, admin)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if !strings.Contains(body, "must be an ICO, PNG, GIF, JPEG, WebP or SVG image") {
+		t.Errorf("rejected upload does not explain itself:\n%s", body)
+	}
+	resp, body = w.uploadBranding(map[string][]byte{"logo": bytes.Repeat([]byte("x"), brandingUploadLimit+1)}, nil, admin)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	for _, cookies := range [][]*http.Cookie{{w.login("alice", roleEditor)}, nil} {
+		resp, body = w.uploadBranding(map[string][]byte{"favicon": testPNG(t)}, nil, cookies...)
+		wantStatus(t, resp, body, http.StatusForbidden)
+	}
+
+	defaultIcon, _ := defaultAssets.ReadFile("assets/favicon.ico")
+	if _, body := w.get("/favicon.ico"); body != string(defaultIcon) {
+		t.Error("a rejected upload replaced the favicon")
+	}
+}
+
+// An uploaded SVG is served, but may not run scripts.
+func TestBrandingSVG(t *testing.T) {
+	w := newTestWiki(t)
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	resp, body := w.uploadBranding(map[string][]byte{"logo": svg}, nil, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resp, body = w.get("/logo")
+	if body != string(svg) || resp.Header.Get("Content-Type") != "image/svg+xml" ||
+		!strings.HasPrefix(resp.Header.Get("Content-Security-Policy"), "default-src 'none'") {
+		t.Errorf("logo = %s with CSP %q", resp.Header.Get("Content-Type"), resp.Header.Get("Content-Security-Policy"))
+	}
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// caseInsensitiveTitles makes /view/frontpage find FrontPage. The lookup
+// redirects to the stored spelling so every page keeps a single URL.
+var caseInsensitiveTitles bool
+
+func setupCanonicalTitles() {
+	caseInsensitiveTitles = os.Getenv("CASE_INSENSITIVE_TITLES") == "true"
+}
+
+// titleSeparator is the page.Slug policy for turning typed titles such as
+// "My Great Page" into page titles.
+var titleSeparator = page.SeparatorDash
+
+func setupTitleSlugs() error {
+	switch sep := os.Getenv("TITLE_SEPARATOR"); sep {
+	case "":
+	case page.SeparatorDash, page.SeparatorNone:
+		titleSeparator = sep
+	default:
+		return fmt.Errorf("invalid TITLE_SEPARATOR %q", sep)
+	}
+
+	return nil
+}
+
+func slugTitle(display string) string {
+	return page.Slug(display, titleSeparator)
+}
+
+// displayTitle is the title as it was typed when the page was saved, or the
+// page title itself.
+func displayTitle(title string) string {
+	meta, err := loadMeta(title)
+	if err != nil || meta.DisplayTitle == "" {
+		return title
+	}
+
+	return meta.DisplayTitle
+}
+
+func setDisplayTitle(title, display string) error {
+	if display == title {
+		display = ""
+	}
+
+	meta, err := loadMeta(title)
+	if err != nil {
+		return err
+	}
+	if meta.DisplayTitle == display {
+		return nil
+	}
+	meta.DisplayTitle = display
+
+	return saveMeta(title, meta)
+}
+
+// redirectToSlug sends /view/My%20Great%20Page to /view/My-Great-Page. It
+// only applies to reads, a POST is never silently retargeted.
+func redirectToSlug(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	action, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || rest == "" {
+		return false
+	}
+	target := "/" + action + "/" + slugTitle(rest)
+	if target == r.URL.Path || !validPath.MatchString(target) {
+		return false
+	}
+
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, pageURL(target), http.StatusMovedPermanently)
+
+	return true
+}
+
+// canonicalTitle returns the stored title matching param when it is spelled
+// differently.
+func canonicalTitle(param string) (string, bool) {
+	if !caseInsensitiveTitles {
+		return "", false
+	}
+
+	titles, err := listPages()
+	if err != nil {
+		return "", false
+	}
+
+	for _, title := range titles {
+		if title != param && strings.EqualFold(title, param) {
+			return title, true
+		}
+	}
+
+	return "", false
+}
+
+// canonicalParam is the one spelling a page is viewed under: the slug of
+// param, or with CASE_INSENSITIVE_TITLES the stored title it matches when no
+// page has exactly that name.
+func canonicalParam(param string) string {
+	title := slugTitle(param)
+	if _, err := statPage(title); err == nil {
+		return title
+	}
+	if stored, ok := canonicalTitle(title); ok {
+		return stored
+	}
+
+	return title
+}
+
+// redirectToCanonical answers with a 301 to the canonical spelling whenever
+// param is spelled any other way.
+func redirectToCanonical(w http.ResponseWriter, r *http.Request, param string) bool {
+	title := canonicalParam(param)
+	if title == param || !validTitle(title) {
+		return false
+	}
+
+	target := "/view/" + title
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, pageURL(target), http.StatusMovedPermanently)
+
+	return true
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func wantRedirect(t *testing.T, w *testWiki, path, location string) {
+	t.Helper()
+	resp, body := w.get(path)
+	wantStatus(t, resp, body, http.StatusMovedPermanently)
+	if got := resp.Header.Get("Location"); got != location {
+		t.Errorf("%s redirects to %q, want %q", path, got, location)
+	}
+}
+
+func TestCanonicalRedirect(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &caseInsensitiveTitles, false)
+	w.seed("My-Great-Page", "great")
+
+	wantRedirect(t, w, "/view/My%20Great%20Page", "/view/My-Great-Page")
+	wantRedirect(t, w, "/view/My%20Great%20Page?rev=1", "/view/My-Great-Page?rev=1")
+	wantRedirect(t, w, "/edit/My%20Great%20Page", "/edit/My-Great-Page")
+
+	resp, body := w.get("/view/My-Great-Page")
+	wantStatus(t, resp, body, http.StatusOK)
+	if resp, body := w.get("/view/my-great-page"); resp.StatusCode == http.StatusMovedPermanently {
+		t.Errorf("case-sensitive wiki redirected to %q\n%s", resp.Header.Get("Location"), body)
+	}
+
+	// Saves are never retargeted.
+	resp, body = w.post("/save/My%20Great%20Page", url.Values{"title": {"My Great Page"}, "body": {"saved"}})
+	if resp.StatusCode == http.StatusMovedPermanently {
+		t.Errorf("save redirected to %q\n%s", resp.Header.Get("Location"), body)
+	}
+}
+
+func TestCanonicalRedirectCaseInsensitive(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &caseInsensitiveTitles, true)
+	w.seed("FrontPage", "front")
+	w.seed("Home", "Home")
+	w.seed("home", "home")
+
+	wantRedirect(t, w, "/view/frontpage", "/view/FrontPage")
+	wantRedirect(t, w, "/view/FRONTPAGE?words=1", "/view/FrontPage?words=1")
+
+	// An exact match is served as it is.
+	for _, path := range []string{"/view/FrontPage", "/view/home", "/view/Home"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusOK)
+	}
+}
+
+func TestCanonicalRedirectBasePath(t *testing.T) {
+	w := mountAt(t, "/wiki")
+	setGlobal(t, &caseInsensitiveTitles, true)
+	w.seed("FrontPage", "front")
+
+	wantRedirect(t, w, "/wiki/view/frontpage", "/wiki/view/FrontPage")
+}
+
+func canonicalLinks(body string) []string {
+	var links []string
+	for _, m := range regexp.MustCompile(`<link rel="canonical" href="([^"]*)">`).FindAllStringSubmatch(body, -1) {
+		links = append(links, m[1])
+	}
+	return links
+}
+
+func TestCanonicalLink(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+
+	// Without BASE_URL the link follows the request.
+	_, body := w.get("/view/Home")
+	if got := canonicalLinks(body); !slices.Equal(got, []string{w.URL + "/view/Home"}) {
+		t.Errorf("canonical links = %q", got)
+	}
+
+	setBaseURL(t, "https://wiki.example.com")
+	for _, path := range []string{"/view/Home", "/view/Home?words=1&utm_source=x", "/view/Home?create=1"} {
+		_, body := w.get(path)
+		if got := canonicalLinks(body); !slices.Equal(got, []string{"https://wiki.example.com/view/Home"}) {
+			t.Errorf("%s: canonical links = %q", path, got)
+		}
+	}
+	for _, path := range []string{"/edit/Home", "/history/Home"} {
+		if _, body := w.get(path); len(canonicalLinks(body)) != 0 {
+			t.Errorf("%s has canonical links %q", path, canonicalLinks(body))
+		}
+	}
+}
+
+func TestCanonicalLinkBasePath(t *testing.T) {
+	w := mountAt(t, "/wiki")
+	setBaseURL(t, "https://example.com")
+	w.seed("Home", "home")
+
+	_, body := w.get("/wiki/view/Home")
+	if got := canonicalLinks(body); !slices.Equal(got, []string{"https://example.com/wiki/view/Home"}) {
+		t.Errorf("canonical links = %q", got)
+	}
+}
+
+func TestNoIndex(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	alice := w.login("alice", roleEditor)
+	const robots = `<meta name="robots" content="noindex,nofollow">`
+
+	for _, tt := range []struct {
+		method, path string
+		form         url.Values
+	}{
+		{http.MethodGet, "/edit/Home", nil},
+		{http.MethodGet, "/edit/Missing", nil},
+		{http.MethodGet, "/rename/Home", nil},
+		{http.MethodPost, "/preview", url.Values{"title": {"Home"}, "body": {"draft"}}},
+		// A rejected save renders the form again.
+		{http.MethodPost, "/save/Home", url.Values{"title": {"Home"}, "body": {"\xff"}}},
+	} {
+		var body string
+		if tt.method == http.MethodPost {
+			_, body = w.post(tt.path, tt.form, alice)
+		} else {
+			_, body = w.get(tt.path, alice)
+		}
+		if strings.Count(body, robots) != 1 || canonicalLinks(body) != nil {
+			t.Errorf("%s %s lacks noindex or has a canonical link:\n%s", tt.method, tt.path, body)
+		}
+	}
+
+	for _, path := range []string{"/view/Home", "/", "/history/Home"} {
+		if _, body := w.get(path, alice); strings.Contains(body, "noindex") {
+			t.Errorf("%s is not indexable:\n%s", path, body)
+		}
+	}
+	if _, body := w.get("/view/Home"); len(canonicalLinks(body)) != 1 {
+		t.Errorf("view lost its canonical link:\n%s", body)
+	}
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type changelogEntry struct {
+	Title string
+	revision
+}
+
+type changelogData struct {
+	Entries   []changelogEntry
+	HideMinor bool
+	Page      int
+	PrevPage  int
+	NextPage  int
+}
+
+// changelog merges the histories of every page into one timeline, newest
+// first, without minor edits when hideMinor is set. Edits made in the same
+// instant keep a stable order by title and revision id.
+func changelog(hideMinor bool) ([]changelogEntry, error) {
+	infos, err := listPageInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []changelogEntry
+	for _, info := range infos {
+		revs, err := listRevisions(info.Title)
+		if err != nil {
+			return nil, err
+		}
+		for _, rev := range revs {
+			if !listedChange(rev, hideMinor) {
+				continue
+			}
+			entries = append(entries, changelogEntry{Title: info.Title, revision: rev})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b changelogEntry) int {
+		if c := b.Time.Compare(a.Time); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Title, b.Title); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return entries, nil
+}
+
+func changelogHandler(w http.ResponseWriter, r *http.Request) {
+	pageNum := 1
+	if n, err := strconv.Atoi(r.FormValue("page")); err == nil && n > 0 {
+		pageNum = n
+	}
+
+	hideMinor := r.FormValue("hideminor") == "1"
+	entries, err := changelog(hideMinor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	perPage := requestPreferences(r).PerPage
+	start := min((pageNum-1)*perPage, len(entries))
+	end := min(start+perPage, len(entries))
+
+	content := &changelogData{Entries: entries[start:end], HideMinor: hideMinor, Page: pageNum}
+	if pageNum > 1 {
+		content.PrevPage = pageNum - 1
+	}
+	if end < len(entries) {
+		content.NextPage = pageNum + 1
+	}
+
+	data := pageData{
+		Title:   "Changelog",
+		Content: content,
+	}
+
+	renderTemplate(w, r, data, "changelog")
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedHistory writes each page and records its edits with fixed times, so
+// the timeline does not depend on how fast the test runs.
+func seedHistory(t *testing.T, edits []changelogEntry) {
+	t.Helper()
+	for _, e := range edits {
+		if err := store.Write(e.Title, []byte(e.Summary)); err != nil {
+			t.Fatal(err)
+		}
+		if err := recordRevision(e.Title, []byte(e.Summary), e.revision); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resetState(t)
+}
+
+var changelogStart = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// at is an edit of title made d after changelogStart.
+func at(title string, d time.Duration, editor string) changelogEntry {
+	return changelogEntry{Title: title, revision: revision{Time: changelogStart.Add(d), Editor: editor, Summary: title + " " + d.String()}}
+}
+
+func TestChangelogInterleavesPages(t *testing.T) {
+	newTestWiki(t)
+	seedHistory(t, []changelogEntry{
+		at("Alpha", 0, "alice"),
+		at("Alpha", 3*time.Minute, "bob"),
+		at("Alpha", 5*time.Minute, "alice"),
+		at("Beta", time.Minute, "bob"),
+		at("Beta", 4*time.Minute, ""),
+		at("Gamma", 2*time.Minute, "carol"),
+		// Same instant as Beta's second edit: the title breaks the tie.
+		at("Gamma", 4*time.Minute, "carol"),
+	})
+
+	entries, err := changelog(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Title+"#"+strconv.Itoa(e.ID)+" "+e.Editor)
+	}
+	want := []string{"Alpha#3 alice", "Beta#2 ", "Gamma#2 carol", "Alpha#2 bob", "Gamma#1 carol", "Beta#1 bob", "Alpha#1 alice"}
+	if !slices.Equal(got, want) {
+		t.Errorf("changelog =\n%q\nwant\n%q", got, want)
+	}
+	if !slices.IsSortedFunc(entries, func(a, b changelogEntry) int { return b.Time.Compare(a.Time) }) {
+		t.Error("changelog is not newest first")
+	}
+}
+
+func TestChangelogHandlerPaginates(t *testing.T) {
+	w := newTestWiki(t)
+	seedHistory(t, []changelogEntry{
+		at("Alpha", 0, "alice"),
+		at("Beta", time.Minute, "bob"),
+		at("Alpha", 2*time.Minute, "alice"),
+	})
+	if err := updateUser("reader", func(u *userRecord) { u.Preferences.PerPage = 2 }); err != nil {
+		t.Fatal(err)
+	}
+	reader := w.login("reader", roleEditor)
+
+	resp, body := w.get("/changelog", reader)
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "/history/Alpha?rev=2") || !strings.Contains(body, "/history/Beta?rev=1") || strings.Contains(body, "/history/Alpha?rev=1") {
+		t.Errorf("first page does not hold the two newest edits:\n%s", body)
+	}
+	if !strings.Contains(body, "/changelog?page=2") || strings.Contains(body, "Previous") {
+		t.Errorf("first page links:\n%s", body)
+	}
+	if strings.Index(body, "/history/Alpha?rev=2") > strings.Index(body, "/history/Beta?rev=1") {
+		t.Error("first page is not newest first")
+	}
+
+	resp, body = w.get("/changelog?page=2", reader)
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "/history/Alpha?rev=1") || strings.Contains(body, "/history/Beta") || !strings.Contains(body, "/changelog?page=1") || strings.Contains(body, "Next") {
+		t.Errorf("second page:\n%s", body)
+	}
+
+	resp, body = w.get("/changelog?page=9", reader)
+	wantStatus(t, resp, body, http.StatusOK)
+	if strings.Contains(body, "/history/") {
+		t.Errorf("page past the end lists edits:\n%s", body)
+	}
+}
+
+func TestChangelogEmpty(t *testing.T) {
+	w := newTestWiki(t)
+	resp, body := w.get("/changelog")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "No edits yet") {
+		t.Errorf("empty changelog:\n%s", body)
+	}
+}
+
+// The changelog and the feed leave out minor edits the same way the recent
+// changes API does.
+func TestHideMinorEdits(t *testing.T) {
+	w := newTestWiki(t)
+	minor := at("Beta", 2*time.Minute, "bob")
+	minor.Minor = true
+	seedHistory(t, []changelogEntry{
+		at("Alpha", 0, "alice"),
+		at("Beta", time.Minute, "bob"),
+		minor,
+	})
+
+	_, body := w.get("/changelog")
+	if !strings.Contains(body, "/history/Beta?rev=2") || !strings.Contains(body, `<b title="minor edit">m</b>`) {
+		t.Errorf("changelog lacks the minor edit:\n%s", body)
+	}
+	_, body = w.get("/changelog?hideminor=1")
+	if strings.Contains(body, "/history/Beta?rev=2") || !strings.Contains(body, "/history/Beta?rev=1") || !strings.Contains(body, "Show minor edits") {
+		t.Errorf("changelog with hideminor:\n%s", body)
+	}
+
+	updated := func(feed atomFeed) map[string]string {
+		m := map[string]string{}
+		for _, e := range feed.Entries {
+			m[e.Title] = e.Updated
+		}
+		return m
+	}
+	if got := updated(readFeed(t, w, "/feed.xml")); got["Beta"] != minor.Time.Format(time.RFC3339) {
+		t.Errorf("feed entries = %v", got)
+	}
+	feed := readFeed(t, w, "/feed.xml?hideminor=1")
+	if got := updated(feed); got["Beta"] != changelogStart.Add(time.Minute).Format(time.RFC3339) || len(got) != 2 {
+		t.Errorf("feed entries with hideminor = %v", got)
+	}
+	if feed.Links[0].Href != w.URL+"/feed.xml?hideminor=1" {
+		t.Errorf("self link = %q", feed.Links[0].Href)
+	}
+}
@@ -0,0 +1,324 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChangeJournalSize = 10000
+	defaultChangesLimit      = 100
+	maxChangesLimit          = 1000
+)
+
+// errCursorExpired means the journal no longer goes back as far as the
+// client asked, so it has to fetch every page again.
+var errCursorExpired = errors.New("changes before this point are no longer kept, do a full resync")
+
+type changeEvent struct {
+	Seq    int64     `json:"seq"`
+	Title  string    `json:"title"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Hash   string    `json:"hash,omitempty"`
+}
+
+// changeJournal records every save and delete in order, so sync clients can
+// ask for what happened since they last looked. Only the newest size events
+// are kept, on disk and in memory.
+type changeJournal struct {
+	mu     sync.Mutex
+	size   int
+	events []changeEvent
+	// next is the sequence number of the next event. It keeps counting when
+	// old events are dropped, so cursors stay valid.
+	next int64
+}
+
+var journal = &changeJournal{size: defaultChangeJournalSize, next: 1}
+
+func changesFilename() string {
+	return filepath.Join(config.StoragePath, ".changes.jsonl")
+}
+
+func setupChangeJournal() error {
+	if raw := os.Getenv("CHANGE_JOURNAL_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid CHANGE_JOURNAL_SIZE %q", raw)
+		}
+		journal.size = n
+	}
+
+	return journal.load()
+}
+
+func (j *changeJournal) load() error {
+	f, err := os.Open(changesFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var events []changeEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e changeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.events = events
+	if len(events) > 0 {
+		j.next = events[len(events)-1].Seq + 1
+	}
+	if len(j.events) > j.size {
+		return j.compact()
+	}
+
+	return nil
+}
+
+// compact drops the oldest events over the size limit and rewrites the file.
+// The caller holds j.mu.
+func (j *changeJournal) compact() error {
+	j.events = append([]changeEvent(nil), j.events[len(j.events)-j.size:]...)
+
+	var buf bytes.Buffer
+	for _, e := range j.events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp := changesFilename() + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, changesFilename())
+}
+
+func (j *changeJournal) record(title, action, hash string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e := changeEvent{Seq: j.next, Title: title, Action: action, Time: time.Now().UTC(), Hash: hash}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(changesFilename(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	j.next++
+	j.events = append(j.events, e)
+	// Let the file grow a little past the limit so it is not rewritten on
+	// every save.
+	if len(j.events) > j.size+j.size/10 {
+		return j.compact()
+	}
+
+	return nil
+}
+
+// existed reports whether the page was there before its latest save, going
+// by the journal and falling back to the page history.
+func (j *changeJournal) existed(title string) bool {
+	j.mu.Lock()
+	for i := len(j.events) - 1; i >= 0; i-- {
+		if j.events[i].Title == title {
+			existed := j.events[i].Action != "delete"
+			j.mu.Unlock()
+			return existed
+		}
+	}
+	j.mu.Unlock()
+
+	revs, _ := listRevisions(title)
+	return len(revs) > 1
+}
+
+func (j *changeJournal) recordSave(title string) {
+	body, err := store.Read(title)
+	if err != nil {
+		slog.Error("error reading page for the change journal", "title", title, "err", err)
+		return
+	}
+
+	action := "create"
+	if j.existed(title) {
+		action = "update"
+	}
+	sum := sha256.Sum256(body)
+	if err := j.record(title, action, hex.EncodeToString(sum[:])); err != nil {
+		slog.Error("error writing change journal", "title", title, "err", err)
+	}
+}
+
+func (j *changeJournal) recordDelete(title string) {
+	if err := j.record(title, "delete", ""); err != nil {
+		slog.Error("error writing change journal", "title", title, "err", err)
+	}
+}
+
+// after returns up to limit events with a sequence number above seq.
+func (j *changeJournal) after(seq int64, limit int) ([]changeEvent, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	first := j.next
+	if len(j.events) > 0 {
+		first = j.events[0].Seq
+	}
+	if seq < first-1 {
+		return nil, false, errCursorExpired
+	}
+
+	var out []changeEvent
+	for _, e := range j.events {
+		if e.Seq <= seq {
+			continue
+		}
+		if len(out) == limit {
+			return out, true, nil
+		}
+		out = append(out, e)
+	}
+
+	return out, false, nil
+}
+
+// seqAt returns the sequence number of the last event at or before t.
+func (j *changeJournal) seqAt(t time.Time) (int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.events) > 0 && j.events[0].Seq > 1 && t.Before(j.events[0].Time) {
+		return 0, errCursorExpired
+	}
+
+	seq := int64(0)
+	if len(j.events) > 0 {
+		seq = j.events[0].Seq - 1
+	}
+	for _, e := range j.events {
+		if e.Time.After(t) {
+			break
+		}
+		seq = e.Seq
+	}
+
+	return seq, nil
+}
+
+func encodeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("c" + strconv.FormatInt(seq, 10)))
+}
+
+func decodeCursor(cursor string) (int64, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) < 2 || b[0] != 'c' {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseInt(string(b[1:]), 10, 64)
+	return seq, err == nil && seq >= 0
+}
+
+type apiChange struct {
+	Title  string    `json:"title"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Hash   string    `json:"hash,omitempty"`
+	Cursor string    `json:"cursor"`
+}
+
+// apiChangesHandler lists saves and deletes since a point in time or a
+// cursor from an earlier response, oldest first.
+func apiChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := defaultChangesLimit
+	if raw := r.FormValue("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxChangesLimit)
+	}
+
+	var seq int64
+	var err error
+	if since := r.FormValue("since"); since != "" {
+		if t, terr := time.Parse(time.RFC3339, since); terr == nil {
+			seq, err = journal.seqAt(t)
+		} else if n, ok := decodeCursor(since); ok {
+			seq = n
+		} else {
+			writeAPIError(w, http.StatusBadRequest, "since must be an RFC 3339 time or a cursor")
+			return
+		}
+	}
+
+	var events []changeEvent
+	var more bool
+	if err == nil {
+		events, more, err = journal.after(seq, limit)
+	}
+	if errors.Is(err, errCursorExpired) {
+		writeAPIError(w, http.StatusGone, err.Error())
+		return
+	}
+
+	out := make([]apiChange, 0, len(events))
+	for _, e := range events {
+		out = append(out, apiChange{Title: e.Title, Action: e.Action, Time: e.Time, Hash: e.Hash, Cursor: encodeCursor(e.Seq)})
+	}
+	cursor := encodeCursor(seq)
+	if len(events) > 0 {
+		cursor = encodeCursor(events[len(events)-1].Seq)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"changes": out, "cursor": cursor, "more": more})
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+type changesResponse struct {
+	Changes []apiChange `json:"changes"`
+	Cursor  string      `json:"cursor"`
+	More    bool        `json:"more"`
+}
+
+func getChanges(t *testing.T, w *testWiki, query url.Values) changesResponse {
+	t.Helper()
+	resp, body := w.get("/api/changes?" + query.Encode())
+	wantStatus(t, resp, body, http.StatusOK)
+	var res changesResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func changeActions(changes []apiChange) []string {
+	var actions []string
+	for _, c := range changes {
+		actions = append(actions, c.Title+" "+c.Action)
+	}
+	return actions
+}
+
+func TestAPIChanges(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "first")
+	w.seed("Home", "second")
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	w.seed("Other", "other")
+
+	res := getChanges(t, w, nil)
+	if want := []string{"Home create", "Home update", "Home delete", "Other create"}; !slices.Equal(changeActions(res.Changes), want) {
+		t.Fatalf("changes = %q, want %q", changeActions(res.Changes), want)
+	}
+	sum := sha256.Sum256([]byte("second"))
+	if res.Changes[1].Hash != hex.EncodeToString(sum[:]) || res.Changes[2].Hash != "" {
+		t.Errorf("hashes = %q, %q", res.Changes[1].Hash, res.Changes[2].Hash)
+	}
+	if res.More || res.Cursor != res.Changes[3].Cursor {
+		t.Errorf("more %v, cursor %q, want the cursor of the last change", res.More, res.Cursor)
+	}
+
+	// Paging with the cursor walks the journal once.
+	page1 := getChanges(t, w, url.Values{"limit": {"2"}})
+	if len(page1.Changes) != 2 || !page1.More {
+		t.Fatalf("first page = %+v", page1)
+	}
+	page2 := getChanges(t, w, url.Values{"limit": {"2"}, "since": {page1.Cursor}})
+	if want := []string{"Home delete", "Other create"}; !slices.Equal(changeActions(page2.Changes), want) || page2.More {
+		t.Errorf("second page = %+v", page2)
+	}
+	if last := getChanges(t, w, url.Values{"since": {page2.Cursor}}); len(last.Changes) != 0 || last.Cursor != page2.Cursor {
+		t.Errorf("after the last change = %+v", last)
+	}
+
+	if all := getChanges(t, w, url.Values{"since": {"2000-01-01T00:00:00Z"}}); len(all.Changes) != 4 {
+		t.Errorf("since 2000 = %q", changeActions(all.Changes))
+	}
+	if none := getChanges(t, w, url.Values{"since": {"2999-01-01T00:00:00Z"}}); len(none.Changes) != 0 {
+		t.Errorf("since 2999 = %q", changeActions(none.Changes))
+	}
+
+	for _, query := range []string{"limit=0", "limit=some", "since=yesterday"} {
+		resp, body := w.get("/api/changes?" + query)
+		wantStatus(t, resp, body, http.StatusBadRequest)
+	}
+	resp, body := w.api(http.MethodPost, "/api/changes", "")
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func TestChangeJournalBound(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &journal.size, 2)
+
+	for i := range 5 {
+		if err := journal.record("P"+strconv.Itoa(i), "create", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(changesFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(b, []byte("\n")); lines > 3 {
+		t.Errorf("journal file has %d lines, want it compacted", lines)
+	}
+
+	// Clients further behind than the journal goes have to resync.
+	for _, since := range []string{"", "2000-01-01T00:00:00Z", encodeCursor(1)} {
+		resp, body := w.get("/api/changes?since=" + url.QueryEscape(since))
+		wantStatus(t, resp, body, http.StatusGone)
+	}
+	res := getChanges(t, w, url.Values{"since": {encodeCursor(3)}})
+	if want := []string{"P3 create", "P4 create"}; !slices.Equal(changeActions(res.Changes), want) {
+		t.Errorf("changes since 3 = %q, want %q", changeActions(res.Changes), want)
+	}
+}
+
+func TestChangeJournalPersists(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.seed("Other", "other")
+
+	journal.mu.Lock()
+	journal.events, journal.next = nil, 1
+	journal.mu.Unlock()
+	setGlobal(t, &journal.size, 1)
+	if err := journal.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading keeps the newest events and the sequence numbers going.
+	events, _, err := journal.after(1, 10)
+	if err != nil || len(events) != 1 || events[0].Title != "Other" || events[0].Seq != 2 {
+		t.Fatalf("loaded events = %+v, %v", events, err)
+	}
+	w.seed("Third", "third")
+	if events, _, _ := journal.after(2, 10); len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("event after reloading = %+v", events)
+	}
+}
+
+func TestSetupChangeJournal(t *testing.T) {
+	newTestWiki(t)
+	setGlobal(t, &journal.size, defaultChangeJournalSize)
+
+	t.Setenv("CHANGE_JOURNAL_SIZE", "50")
+	if err := setupChangeJournal(); err != nil || journal.size != 50 {
+		t.Errorf("CHANGE_JOURNAL_SIZE=50 gives %d, %v", journal.size, err)
+	}
+	for _, raw := range []string{"0", "-5", "big"} {
+		t.Setenv("CHANGE_JOURNAL_SIZE", raw)
+		if err := setupChangeJournal(); err == nil {
+			t.Errorf("CHANGE_JOURNAL_SIZE=%q accepted", raw)
+		}
+	}
+}
+
+func TestCursorEncoding(t *testing.T) {
+	if seq, ok := decodeCursor(encodeCursor(42)); !ok || seq != 42 {
+		t.Errorf("cursor round trip = %d, %v", seq, ok)
+	}
+	for _, bad := range []string{"", "!!", encodeCursor(-1), "eDQy"} {
+		if _, ok := decodeCursor(bad); ok {
+			t.Errorf("decodeCursor(%q) accepted", bad)
+		}
+	}
+}
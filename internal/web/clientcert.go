@@ -0,0 +1,122 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+)
+
+// tlsFiles are the server certificate and key from TLS_CERT_FILE and
+// TLS_KEY_FILE. Without them the wiki serves plain HTTP.
+var tlsFiles struct {
+	cert, key string
+}
+
+// adminClientCA holds the CA that admin client certificates must chain to,
+// and the subject common names allowed, if any. Nil when ADMIN_CLIENT_CA is
+// not set.
+var adminClientCA *clientCertPolicy
+
+type clientCertPolicy struct {
+	roots *x509.CertPool
+	names []string
+}
+
+func setupTLS() error {
+	tlsFiles.cert, tlsFiles.key = os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (tlsFiles.cert == "") != (tlsFiles.key == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	caFile := os.Getenv("ADMIN_CLIENT_CA")
+	if caFile == "" {
+		return nil
+	}
+	if tlsFiles.cert == "" {
+		return errors.New("ADMIN_CLIENT_CA needs TLS_CERT_FILE and TLS_KEY_FILE, client certificates only exist over TLS")
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("reading ADMIN_CLIENT_CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("ADMIN_CLIENT_CA %s has no PEM certificates", caFile)
+	}
+
+	adminClientCA = &clientCertPolicy{roots: roots, names: splitList(os.Getenv("ADMIN_CLIENT_CNS"), ",")}
+
+	return nil
+}
+
+// serverTLSConfig asks clients for a certificate without requiring or
+// checking one, so the ordinary pages stay open to browsers without one.
+// The admin routes and actions verify it through adminCertError.
+func serverTLSConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if adminClientCA != nil {
+		cfg.ClientAuth = tls.RequestClientCert
+	}
+	return cfg
+}
+
+// verify checks the certificate the client presented against the CA and the
+// allowed names.
+func (p *clientCertPolicy) verify(state *tls.ConnectionState) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errors.New("no client certificate")
+	}
+
+	leaf := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         p.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(p.names) > 0 && !slices.Contains(p.names, leaf.Subject.CommonName) {
+		return fmt.Errorf("client certificate %q is not allowed", leaf.Subject.CommonName)
+	}
+
+	return nil
+}
+
+// adminCertError reports why r may not use admin powers as far as client
+// certificates go. Without ADMIN_CLIENT_CA every request may; with it only
+// those over a connection that presented a valid certificate.
+func adminCertError(r *http.Request) error {
+	if adminClientCA == nil {
+		return nil
+	}
+	return adminClientCA.verify(r.TLS)
+}
+
+func renderAdminCertError(w http.ResponseWriter, r *http.Request, err error) {
+	renderError(w, r, http.StatusForbidden, "Admin actions need a valid client certificate: "+err.Error()+".")
+}
+
+// requireClientCert guards a route only admins use. The routes are listed in
+// routes(); admin actions on routes others use too check adminCertError
+// themselves.
+func requireClientCert(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := adminCertError(r); err != nil {
+			renderAdminCertError(w, r, err)
+			return
+		}
+
+		next(w, r)
+	}
+}
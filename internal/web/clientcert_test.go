@@ -0,0 +1,317 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// testCert is a certificate with its key, able to sign others when it is a CA.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+type certOptions struct {
+	ca       bool
+	usage    x509.ExtKeyUsage
+	notAfter time.Time
+}
+
+// issue makes a certificate for cn signed by parent, or self-signed when
+// parent is nil.
+func issue(t *testing.T, parent *testCert, cn string, opts certOptions) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.notAfter.IsZero() {
+		opts.notAfter = time.Now().Add(time.Hour)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              opts.notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  opts.ca,
+	}
+	if opts.ca {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{opts.usage}
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func clientCert(cn string, parent *testCert, t *testing.T) *testCert {
+	return issue(t, parent, cn, certOptions{usage: x509.ExtKeyUsageClientAuth})
+}
+
+// chain is what a client presents: the leaf followed by intermediates.
+func chain(certs ...*testCert) *tls.Certificate {
+	c := &tls.Certificate{PrivateKey: certs[0].key, Leaf: certs[0].cert}
+	for _, cert := range certs {
+		c.Certificate = append(c.Certificate, cert.cert.Raw)
+	}
+	return c
+}
+
+func writePEM(t *testing.T, certs ...*testCert) string {
+	t.Helper()
+	var b []byte
+	for _, c := range certs {
+		b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})...)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serveTLS serves the wiki over TLS the way Run does, to a client that
+// presents cert, which may be nil.
+func (w *testWiki) serveTLS(cert *tls.Certificate) *testWiki {
+	w.t.Helper()
+	ts := httptest.NewUnstartedServer(newHandler(testServer, routes()))
+	ts.TLS = serverTLSConfig()
+	ts.StartTLS()
+	w.t.Cleanup(ts.Close)
+
+	client := ts.Client()
+	if cert != nil {
+		client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testWiki{Server: ts, t: w.t, dir: w.dir}
+}
+
+func TestAdminClientCert(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	admin := w.login("root", roleAdmin)
+
+	rootCA := issue(t, nil, "Wiki Admin CA", certOptions{ca: true})
+	intermediate := issue(t, rootCA, "Wiki Admin Intermediate", certOptions{ca: true})
+	otherCA := issue(t, nil, "Some Other CA", certOptions{ca: true})
+	pool := x509.NewCertPool()
+	pool.AddCert(rootCA.cert)
+	setGlobal(t, &adminClientCA, &clientCertPolicy{roots: pool})
+
+	tests := []struct {
+		name   string
+		cert   *tls.Certificate
+		status int
+		msg    string
+	}{
+		{"accepted", chain(clientCert("ops", rootCA, t)), http.StatusOK, ""},
+		{"accepted through an intermediate", chain(clientCert("ops", intermediate, t), intermediate), http.StatusOK, ""},
+		{"absent", nil, http.StatusForbidden, "no client certificate"},
+		{"wrong CA", chain(clientCert("ops", otherCA, t)), http.StatusForbidden, "unknown authority"},
+		{"intermediate not sent", chain(clientCert("ops", intermediate, t)), http.StatusForbidden, "unknown authority"},
+		{"self-signed", chain(issue(t, nil, "ops", certOptions{usage: x509.ExtKeyUsageClientAuth})), http.StatusForbidden, "unknown authority"},
+		{"server certificate", chain(issue(t, rootCA, "ops", certOptions{usage: x509.ExtKeyUsageServerAuth})), http.StatusForbidden, "certificate specifies an incompatible key usage"},
+		{"expired", chain(issue(t, rootCA, "ops", certOptions{usage: x509.ExtKeyUsageClientAuth, notAfter: time.Now().Add(-time.Minute)})), http.StatusForbidden, "expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw := w.serveTLS(tt.cert)
+
+			resp, body := tw.get("/admin/ipblocks", admin)
+			wantStatus(t, resp, body, tt.status)
+			if !strings.Contains(body, tt.msg) {
+				t.Errorf("response does not explain %q:\n%s", tt.msg, body)
+			}
+
+			// The ordinary pages never ask for the certificate.
+			resp, body = tw.get("/view/Home")
+			wantStatus(t, resp, body, http.StatusOK)
+		})
+	}
+
+	// Without HTTPS there is no certificate to check.
+	plain := httptest.NewServer(newHandler(testServer, routes()))
+	defer plain.Close()
+	resp, body := (&testWiki{Server: plain, t: t, dir: w.dir}).get("/admin/ipblocks", admin)
+	wantStatus(t, resp, body, http.StatusForbidden)
+}
+
+func TestAdminClientCertNames(t *testing.T) {
+	w := newTestWiki(t)
+	admin := w.login("root", roleAdmin)
+
+	ca := issue(t, nil, "Wiki Admin CA", certOptions{ca: true})
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	setGlobal(t, &adminClientCA, &clientCertPolicy{roots: pool, names: []string{"ops-laptop", "ci"}})
+
+	for cn, status := range map[string]int{
+		"ops-laptop": http.StatusOK,
+		"ci":         http.StatusOK,
+		"intern":     http.StatusForbidden,
+		"OPS-LAPTOP": http.StatusForbidden,
+	} {
+		resp, body := w.serveTLS(chain(clientCert(cn, ca, t))).get("/admin/ipblocks", admin)
+		if resp.StatusCode != status {
+			t.Errorf("CN %q = %d, want %d\n%s", cn, resp.StatusCode, status, body)
+		}
+	}
+}
+
+// A valid certificate does not replace the admin login.
+func TestAdminClientCertStillNeedsAdmin(t *testing.T) {
+	w := newTestWiki(t)
+	ca := issue(t, nil, "Wiki Admin CA", certOptions{ca: true})
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	setGlobal(t, &adminClientCA, &clientCertPolicy{roots: pool})
+
+	resp, body := w.serveTLS(chain(clientCert("ops", ca, t))).get("/admin/ipblocks", w.login("bob", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+}
+
+// Admin actions on routes outside /admin/ need the certificate as well.
+func TestAdminClientCertActions(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.seed("Rules", "rules")
+	w.seed("Gone", "gone")
+	if err := deletePage(&page.Page{Title: "Gone"}); err != nil {
+		t.Fatal(err)
+	}
+	setProtection(t, "Rules", protectionAdmins)
+	admin := w.login("root", roleAdmin)
+
+	ca := issue(t, nil, "Wiki Admin CA", certOptions{ca: true})
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	setGlobal(t, &adminClientCA, &clientCertPolicy{roots: pool})
+
+	trashed, err := listTrash()
+	if err != nil || len(trashed) != 1 {
+		t.Fatalf("trash = %v, %v", trashed, err)
+	}
+	actions := []struct {
+		path string
+		form url.Values
+	}{
+		{"/protect/Home", url.Values{"protection": {protectionAdmins}}},
+		{"/trash", url.Values{"name": {trashed[0].Name}, "action": {"purge"}}},
+		{"/save/Rules", url.Values{"title": {"Rules"}, "body": {"changed"}}},
+	}
+
+	without := w.serveTLS(nil)
+	for _, a := range actions {
+		resp, body := without.post(a.path, a.form, admin)
+		wantStatus(t, resp, body, http.StatusForbidden)
+	}
+	if pageProtection("Home") != protectionOpen {
+		t.Error("protection changed without a certificate")
+	}
+	if trashed, _ := listTrash(); len(trashed) != 1 {
+		t.Error("trash purged without a certificate")
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	r.AddCookie(admin)
+	if apiIsAdmin(r) {
+		t.Error("admin session counts as admin in the API without a certificate")
+	}
+
+	with := w.serveTLS(chain(clientCert("ops", ca, t)))
+	for _, a := range actions {
+		resp, body := with.post(a.path, a.form, admin)
+		wantStatus(t, resp, body, http.StatusFound)
+	}
+	if pageProtection("Home") != protectionAdmins {
+		t.Error("protection not changed with a certificate")
+	}
+}
+
+func TestSetupTLS(t *testing.T) {
+	setGlobal(t, &adminClientCA, nil)
+	setGlobal(t, &tlsFiles, tlsFiles)
+	ca := issue(t, nil, "Wiki Admin CA", certOptions{ca: true})
+	caFile := writePEM(t, ca)
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("no pem here"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name          string
+		cert, key, ca string
+		err           string
+	}{
+		{"cert without key", "server.pem", "", "", "must be set together"},
+		{"key without cert", "", "server.key", "", "must be set together"},
+		{"client CA without TLS", "", "", caFile, "needs TLS_CERT_FILE"},
+		{"missing CA file", "server.pem", "server.key", caFile + ".missing", "reading ADMIN_CLIENT_CA"},
+		{"CA file without certificates", "server.pem", "server.key", empty, "no PEM certificates"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TLS_CERT_FILE", tt.cert)
+			t.Setenv("TLS_KEY_FILE", tt.key)
+			t.Setenv("ADMIN_CLIENT_CA", tt.ca)
+			if err := setupTLS(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("setupTLS = %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+
+	t.Setenv("TLS_CERT_FILE", "server.pem")
+	t.Setenv("TLS_KEY_FILE", "server.key")
+	t.Setenv("ADMIN_CLIENT_CA", caFile)
+	t.Setenv("ADMIN_CLIENT_CNS", "ops-laptop, ci")
+	if err := setupTLS(); err != nil {
+		t.Fatal(err)
+	}
+	if adminClientCA == nil || len(adminClientCA.names) != 2 || adminClientCA.names[1] != "ci" {
+		t.Fatalf("policy = %+v", adminClientCA)
+	}
+	if cfg := serverTLSConfig(); cfg.ClientAuth != tls.RequestClientCert {
+		t.Errorf("ClientAuth = %v, want the certificate requested but not required", cfg.ClientAuth)
+	}
+
+	adminClientCA = nil
+	if cfg := serverTLSConfig(); cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("ClientAuth = %v without ADMIN_CLIENT_CA", cfg.ClientAuth)
+	}
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+const historyUsage = "usage: gowiki history collapse [--dry-run] [title...]"
+
+// sameContent reports whether two revision bodies only differ in trailing
+// whitespace, which is what repeated saves of an unchanged page produce.
+func sameContent(a, b []byte) bool {
+	normalize := func(body []byte) []byte {
+		lines := bytes.Split(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")), []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.TrimRight(line, " \t")
+		}
+		return bytes.TrimRight(bytes.Join(lines, []byte("\n")), "\n")
+	}
+
+	return bytes.Equal(normalize(a), normalize(b))
+}
+
+// collapseHistory removes revisions whose body is the same as the revision
+// before them. The earlier revision is kept with its own time, so the
+// history shows when the content first appeared. Ids of the remaining
+// revisions do not change. It returns the number of revisions removed.
+func collapseHistory(title string, dryRun bool) (int, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	revs, err := listRevisions(title)
+	if err != nil || len(revs) < 2 {
+		return 0, err
+	}
+
+	var kept, dropped []revision
+	var prev []byte
+	for i := len(revs) - 1; i >= 0; i-- {
+		body, err := os.ReadFile(revisionFilename(title, revs[i].ID))
+		if err != nil {
+			return 0, err
+		}
+		if len(kept) > 0 && sameContent(prev, body) {
+			dropped = append(dropped, revs[i])
+			continue
+		}
+		kept = append(kept, revs[i])
+		prev = body
+	}
+	if len(dropped) == 0 || dryRun {
+		return len(dropped), nil
+	}
+
+	if last := revs[0].ID; kept[len(kept)-1].ID != last {
+		if err := os.WriteFile(filepath.Join(historyDir(title), historyLastIDName), []byte(strconv.Itoa(last)), 0600); err != nil {
+			return 0, err
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, rev := range kept {
+		line, err := json.Marshal(rev)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	logName := filepath.Join(historyDir(title), historyLogName)
+	if err := os.WriteFile(logName+".tmp", buf.Bytes(), 0600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(logName+".tmp", logName); err != nil {
+		return 0, err
+	}
+
+	for _, rev := range dropped {
+		if err := os.Remove(revisionFilename(title, rev.ID)); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+
+	return len(dropped), nil
+}
+
+// collapseAllHistory collapses the history of the given pages, or of every
+// page when titles is empty, and returns the number of revisions removed.
+func collapseAllHistory(titles []string, dryRun bool) (int, error) {
+	if len(titles) == 0 {
+		var err error
+		if titles, err = listPages(); err != nil {
+			return 0, err
+		}
+	}
+
+	total := 0
+	for _, title := range titles {
+		if !validTitle(title) {
+			return total, fmt.Errorf("invalid title %q", title)
+		}
+		n, err := collapseHistory(title, dryRun)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", title, err)
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+// History runs the history maintenance subcommands.
+func History(cfg Config, st storage.Storage, args []string, out io.Writer) error {
+	config = cfg
+	store = st
+	if err := setupCommandHooks(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 || args[0] != "collapse" {
+		return errors.New(historyUsage)
+	}
+
+	flags := flag.NewFlagSet("history collapse", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "only report how many revisions would be removed")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	n, err := collapseAllHistory(flags.Args(), *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(out, "would remove %d duplicate revisions\n", n)
+	} else {
+		fmt.Fprintf(out, "removed %d duplicate revisions\n", n)
+	}
+
+	return nil
+}
+
+func collapseHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can clean up page history.")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var titles []string
+	if title := r.FormValue("title"); title != "" {
+		titles = []string{title}
+	}
+	n, err := collapseAllHistory(titles, false)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: fmt.Sprintf("Removed %d duplicate revisions", n)})
+	if len(titles) == 1 {
+		http.Redirect(w, r, pageURL("/history/"+titles[0]), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedRevisions records one revision of title per body, a minute apart.
+func seedRevisions(t *testing.T, title string, bodies ...string) {
+	t.Helper()
+	for i, body := range bodies {
+		rev := revision{Time: changelogStart.Add(time.Duration(i) * time.Minute), Editor: "alice"}
+		if err := recordRevision(title, []byte(body), rev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Write(title, []byte(bodies[len(bodies)-1])); err != nil {
+		t.Fatal(err)
+	}
+	resetState(t)
+}
+
+func revisionIDs(t *testing.T, title string) []int {
+	t.Helper()
+	revs, err := listRevisions(title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, rev := range revs {
+		ids = append(ids, rev.ID)
+	}
+	return ids
+}
+
+func TestCollapseHistory(t *testing.T) {
+	newTestWiki(t)
+	seedRevisions(t, "Home", "one\n", "two\n", "two  \n", "two", "three\n", "three\n")
+
+	if n, err := collapseHistory("Home", true); err != nil || n != 3 {
+		t.Fatalf("dry run = %d, %v, want 3", n, err)
+	}
+	if ids := revisionIDs(t, "Home"); len(ids) != 6 {
+		t.Fatalf("dry run changed the history: %v", ids)
+	}
+
+	if n, err := collapseHistory("Home", false); err != nil || n != 3 {
+		t.Fatalf("collapseHistory = %d, %v, want 3", n, err)
+	}
+	revs, err := listRevisions("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each kept revision is the first with its content, at its own time.
+	for i, want := range []struct {
+		id   int
+		time time.Duration
+	}{{5, 4 * time.Minute}, {2, time.Minute}, {1, 0}} {
+		if revs[i].ID != want.id || !revs[i].Time.Equal(changelogStart.Add(want.time)) {
+			t.Errorf("revision %d = %d at %v, want %d at %v", i, revs[i].ID, revs[i].Time, want.id, changelogStart.Add(want.time))
+		}
+	}
+	if len(revs) != 3 {
+		t.Fatalf("%d revisions left, want 3", len(revs))
+	}
+	for _, id := range []int{3, 4, 6} {
+		if _, err := os.Stat(revisionFilename("Home", id)); !os.IsNotExist(err) {
+			t.Errorf("body of dropped revision %d left behind: %v", id, err)
+		}
+	}
+	if b, err := os.ReadFile(revisionFilename("Home", 2)); err != nil || string(b) != "two\n" {
+		t.Errorf("kept revision 2 = %q, %v", b, err)
+	}
+
+	if n, err := collapseHistory("Home", false); err != nil || n != 0 {
+		t.Errorf("second collapse = %d, %v, want nothing left to do", n, err)
+	}
+
+	// The dropped newest id is not handed out again.
+	if err := recordRevision("Home", []byte("four"), revision{}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := revisionIDs(t, "Home"); !slices.Equal(ids, []int{7, 5, 2, 1}) {
+		t.Errorf("ids after a new edit = %v", ids)
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	newTestWiki(t)
+	// The command registers the save hooks again; keep them to this test.
+	withHooks(t, func(*hookRegistry) {})
+	seedRevisions(t, "Alpha", "a", "a")
+	seedRevisions(t, "Beta", "b", "b", "b")
+
+	var out bytes.Buffer
+	if err := History(config, store, []string{"collapse", "--dry-run"}, &out); err != nil || out.String() != "would remove 3 duplicate revisions\n" {
+		t.Errorf("dry run = %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := History(config, store, []string{"collapse", "Beta"}, &out); err != nil || out.String() != "removed 2 duplicate revisions\n" {
+		t.Errorf("collapse Beta = %q, %v", out.String(), err)
+	}
+	if len(revisionIDs(t, "Alpha")) != 2 || len(revisionIDs(t, "Beta")) != 1 {
+		t.Errorf("Alpha %v, Beta %v", revisionIDs(t, "Alpha"), revisionIDs(t, "Beta"))
+	}
+
+	for _, args := range [][]string{nil, {"squash"}, {"collapse", "../x"}} {
+		if err := History(config, store, args, &out); err == nil {
+			t.Errorf("history %q succeeded", args)
+		}
+	}
+}
+
+func TestCollapseHistoryHandler(t *testing.T) {
+	w := newTestWiki(t)
+	seedRevisions(t, "Home", "home", "home")
+	admin := w.login("root", roleAdmin)
+
+	resp, body := w.post("/admin/history/collapse", url.Values{"title": {"Home"}}, w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = w.get("/admin/history/collapse", admin)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+	if len(revisionIDs(t, "Home")) != 2 {
+		t.Fatal("history collapsed without an admin's POST")
+	}
+
+	resp, body = w.post("/admin/history/collapse", url.Values{"title": {"Home"}}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	if resp.Header.Get("Location") != "/history/Home" || !slices.Equal(revisionIDs(t, "Home"), []int{1}) {
+		t.Errorf("collapse redirected to %q, left %v", resp.Header.Get("Location"), revisionIDs(t, "Home"))
+	}
+	_, body = w.get("/history/Home", append(resp.Cookies(), admin)...)
+	if !strings.Contains(body, "Removed 1 duplicate revisions") {
+		t.Errorf("history does not report the collapse:\n%s", body)
+	}
+}
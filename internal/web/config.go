@@ -0,0 +1,50 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+// Config holds the settings every request depends on. It is read from the
+// environment once at startup; handlers and storage use config instead of
+// calling os.Getenv themselves.
+type Config struct {
+	StoragePath        string
+	ListenAddr         string
+	DevMode            bool
+	TemplateTheme      string
+	AllowAnonymousEdit bool
+	RequireSummary     bool
+	FollowSymlinks     bool
+}
+
+var config = Config{ListenAddr: ":8080", AllowAnonymousEdit: true}
+
+// store holds page content; Run sets it to the backend chosen by main.
+var store storage.Storage
+
+func LoadConfig() (Config, error) {
+	storagePath, err := filepath.Abs(os.Getenv("STORAGE_PATH"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid STORAGE_PATH: %w", err)
+	}
+
+	theme := os.Getenv("TEMPLATE_THEME")
+	if theme != "" && (strings.ContainsAny(theme, "/\\") || strings.HasPrefix(theme, ".")) {
+		return Config{}, fmt.Errorf("invalid TEMPLATE_THEME %q", theme)
+	}
+
+	return Config{
+		StoragePath:        storagePath,
+		ListenAddr:         envOrDefault("LISTEN_ADDR", ":8080"),
+		DevMode:            os.Getenv("DEV_MODE") == "true",
+		TemplateTheme:      theme,
+		AllowAnonymousEdit: os.Getenv("ALLOW_ANONYMOUS_EDIT") != "false",
+		RequireSummary:     os.Getenv("REQUIRE_SUMMARY") == "true",
+		FollowSymlinks:     os.Getenv("STORAGE_FOLLOW_SYMLINKS") == "true",
+	}, nil
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("STORAGE_PATH", "pages")
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:9000")
+	t.Setenv("DEV_MODE", "true")
+	t.Setenv("TEMPLATE_THEME", "dark")
+	t.Setenv("ALLOW_ANONYMOUS_EDIT", "false")
+	t.Setenv("REQUIRE_SUMMARY", "true")
+	t.Setenv("STORAGE_FOLLOW_SYMLINKS", "")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{
+		StoragePath:        filepath.Join(dir, "pages"),
+		ListenAddr:         "127.0.0.1:9000",
+		DevMode:            true,
+		TemplateTheme:      "dark",
+		AllowAnonymousEdit: false,
+		RequireSummary:     true,
+	}
+	if cfg != want {
+		t.Errorf("LoadConfig = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigRejectsThemePaths(t *testing.T) {
+	for _, theme := range []string{"../x", "a/b", `a\b`, ".hidden"} {
+		t.Setenv("TEMPLATE_THEME", theme)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("LoadConfig with TEMPLATE_THEME=%q succeeded", theme)
+		}
+	}
+}
+
+// The handlers read the settings from config only, so changing the
+// environment after startup has no effect.
+func TestHandlersUseConfig(t *testing.T) {
+	w := newTestWikiWith(t, Config{AllowAnonymousEdit: true, RequireSummary: true})
+	t.Setenv("REQUIRE_SUMMARY", "false")
+	t.Setenv("STORAGE_PATH", t.TempDir())
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"text"}})
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"text"}, "summary": {"why"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, err := os.Stat(filepath.Join(config.StoragePath, "Home.txt")); err != nil {
+		t.Errorf("page was not saved under config.StoragePath: %v", err)
+	}
+}
+
+func TestHandlersFollowConfig(t *testing.T) {
+	save := url.Values{"title": {"Home"}, "body": {"text"}}
+	for _, tt := range []struct {
+		name   string
+		cfg    Config
+		status int
+	}{
+		{"defaults", Config{}, http.StatusUnauthorized},
+		{"anonymous edits", Config{AllowAnonymousEdit: true}, http.StatusFound},
+		{"summaries required", Config{AllowAnonymousEdit: true, RequireSummary: true}, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWikiWith(t, tt.cfg)
+			resp, body := w.post("/save/Home", save)
+			wantStatus(t, resp, body, tt.status)
+		})
+	}
+
+	dir := filepath.Join(t.TempDir(), "pages")
+	if err := os.Mkdir(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWikiWith(t, Config{StoragePath: dir, AllowAnonymousEdit: true})
+	resp, body := w.post("/save/Home", save)
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, err := os.ReadFile(filepath.Join(dir, "Home.txt")); err != nil || string(b) != "text" {
+		t.Errorf("page in the configured storage path = %q, %v", b, err)
+	}
+}
+
+func TestFollowSymlinksConfig(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "shared.txt")
+	if err := os.WriteFile(outside, []byte("shared page"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, follow := range []bool{false, true} {
+		w := newTestWikiWith(t, Config{FollowSymlinks: follow})
+		if err := os.Symlink(outside, filepath.Join(w.dir, "Shared.txt")); err != nil {
+			t.Fatal(err)
+		}
+		resp, body := w.get("/raw/Shared")
+		if got := resp.StatusCode == http.StatusOK && body == "shared page"; got != follow {
+			t.Errorf("FollowSymlinks %v: /raw/Shared = %d %q", follow, resp.StatusCode, body)
+		}
+	}
+}
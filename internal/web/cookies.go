@@ -0,0 +1,132 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	flashCookieName    = "flash"
+	flashCookieMaxSize = 3072
+	flashCookieMaxAge  = time.Minute
+)
+
+type flash struct {
+	Level string `json:"level"`
+	Text  string `json:"text"`
+}
+
+var secretKey []byte
+
+func setupSecretKey() error {
+	if key := os.Getenv("SECRET_KEY"); key != "" {
+		secretKey = []byte(key)
+		return nil
+	}
+
+	secretKey = make([]byte, 32)
+	_, err := rand.Read(secretKey)
+	return err
+}
+
+func signValue(value []byte) string {
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(value)
+
+	return base64.RawURLEncoding.EncodeToString(value) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyValue(signed string) ([]byte, error) {
+	payload, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, errors.New("malformed signed value")
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(value)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	return value, nil
+}
+
+func readFlashes(r *http.Request) []flash {
+	c, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return nil
+	}
+
+	value, err := verifyValue(c.Value)
+	if err != nil {
+		return nil
+	}
+
+	var flashes []flash
+	if err := json.Unmarshal(value, &flashes); err != nil {
+		return nil
+	}
+
+	return flashes
+}
+
+// addFlash stacks messages on top of any not yet shown and stores them for the next render.
+// The oldest messages are dropped when the cookie would grow past flashCookieMaxSize.
+func addFlash(w http.ResponseWriter, r *http.Request, flashes ...flash) {
+	flashes = append(readFlashes(r), flashes...)
+
+	for len(flashes) > 0 {
+		value, err := json.Marshal(flashes)
+		if err != nil {
+			return
+		}
+
+		signed := signValue(value)
+		if len(signed) <= flashCookieMaxSize {
+			http.SetCookie(w, &http.Cookie{
+				Name:     flashCookieName,
+				Value:    signed,
+				Path:     pageURL("/"),
+				MaxAge:   int(flashCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			return
+		}
+
+		flashes = flashes[1:]
+	}
+}
+
+func consumeFlashes(w http.ResponseWriter, r *http.Request) []flash {
+	flashes := readFlashes(r)
+	if _, err := r.Cookie(flashCookieName); err == nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:     flashCookieName,
+			Path:     pageURL("/"),
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return flashes
+}
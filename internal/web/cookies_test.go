@@ -0,0 +1,98 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func flashCookie(resp *http.Response) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == flashCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestFlashAfterRedirect(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Old", "old")
+	w.seed("Gone", "gone")
+
+	for _, tt := range []struct {
+		path string
+		form url.Values
+		want string
+	}{
+		{"/save/Home", url.Values{"title": {"Home"}, "body": {"home"}}, "Page Home saved"},
+		{"/delete/Gone", w.csrf(), "Page Gone deleted"},
+		{"/rename/Old", url.Values{"newTitle": {"New"}}, "Page Old renamed to New"},
+	} {
+		resp, body := w.post(tt.path, tt.form)
+		wantStatus(t, resp, body, http.StatusFound)
+		c := flashCookie(resp)
+		if c == nil {
+			t.Errorf("%s set no flash cookie", tt.path)
+			continue
+		}
+		if !c.HttpOnly || c.MaxAge <= 0 {
+			t.Errorf("%s flash cookie = %+v, want HttpOnly and short-lived", tt.path, c)
+		}
+
+		// The next render shows the message once and clears the cookie.
+		resp, body = w.get("/", c)
+		if !strings.Contains(body, `class="flash flash-success">`+tt.want) {
+			t.Errorf("%s: %q not shown after the redirect:\n%s", tt.path, tt.want, body)
+		}
+		if cleared := flashCookie(resp); cleared == nil || cleared.MaxAge >= 0 {
+			t.Errorf("%s: flash cookie not cleared after it was shown: %+v", tt.path, cleared)
+		}
+	}
+}
+
+func TestFlashesStackAndStayCapped(t *testing.T) {
+	setGlobal(t, &secretKey, []byte("test key"))
+
+	stack := func(c *http.Cookie, f flash) *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		addFlash(rec, req, f)
+		return flashCookie(rec.Result())
+	}
+	read := func(c *http.Cookie) []flash {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		return readFlashes(req)
+	}
+
+	c := stack(nil, flash{Level: "success", Text: "first"})
+	c = stack(c, flash{Level: "error", Text: "second"})
+	if got := read(c); len(got) != 2 || got[0].Text != "first" || got[1].Text != "second" {
+		t.Errorf("stacked flashes = %+v", got)
+	}
+
+	long := strings.Repeat("x", 1000)
+	for range 10 {
+		c = stack(c, flash{Level: "success", Text: long})
+	}
+	c = stack(c, flash{Level: "success", Text: "newest"})
+	if len(c.Value) > flashCookieMaxSize {
+		t.Errorf("flash cookie is %d bytes, over the cap of %d", len(c.Value), flashCookieMaxSize)
+	}
+	got := read(c)
+	if len(got) == 0 || got[len(got)-1].Text != "newest" || got[0].Text == "first" {
+		t.Errorf("capped flashes drop the newest instead of the oldest: %d left", len(got))
+	}
+
+	// A cookie that was not signed by the wiki is ignored.
+	forged := &http.Cookie{Name: flashCookieName, Value: strings.Replace(c.Value, ".", "x.", 1)}
+	if got := read(forged); got != nil {
+		t.Errorf("forged flash cookie read as %+v", got)
+	}
+}
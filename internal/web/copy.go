@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// copyPage saves the body of src as a new page. Tags live in the front
+// matter and come along with it; history and view counts belong to the
+// source and are not copied, and the copy gets its own first revision. With
+// copyMeta the protection level is copied as well. The wiki has no
+// attachments, so there is nothing else to copy.
+func copyPage(src, display, editor string, copyMeta bool) (*page.Page, error) {
+	display = strings.Join(strings.Fields(display), " ")
+	title := slugTitle(display)
+	if err := validateTitle(title); err != nil {
+		return nil, &formError{http.StatusBadRequest, err.Error()}
+	}
+	if _, err := statPage(title); err == nil {
+		return nil, &formError{http.StatusConflict, "Page " + title + " already exists"}
+	}
+
+	p, err := loadPage(src)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &page.Page{Title: title, Body: p.Body, Editor: editor, Summary: "Copied from " + src}
+	if err := savePage(cp); err != nil {
+		return nil, err
+	}
+	if copyMeta {
+		if level := pageProtection(src); level != protectionOpen {
+			meta, err := loadMeta(title)
+			if err != nil {
+				return cp, err
+			}
+			meta.Protection = level
+			if err := saveMeta(title, meta); err != nil {
+				return cp, err
+			}
+		}
+	}
+	if err := setDisplayTitle(title, display); err != nil {
+		return cp, err
+	}
+
+	return cp, nil
+}
+
+func copyHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := admitWrite(r); err != nil {
+		if status, ok := saveErrorStatus(err); ok {
+			setRetryAfter(w, err)
+			renderError(w, r, status, err.Error())
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cp, err := copyPage(param, r.FormValue("newTitle"), currentUser(r), r.FormValue("copy_meta") == "on")
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if status, ok := saveErrorStatus(err); ok {
+		renderError(w, r, status, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Page " + param + " copied to " + cp.Title})
+	http.Redirect(w, r, pageURL("/edit/"+cp.Title), http.StatusFound)
+}
+
+func apiCopyHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if heldForReview(r) {
+		writeAPIError(w, http.StatusForbidden, reviewOnlyMessage)
+		return
+	}
+	if err := admitWrite(r); err != nil {
+		status, ok := saveErrorStatus(err)
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		setRetryAfter(w, err)
+		writeAPIError(w, status, err.Error())
+		return
+	}
+
+	var req struct {
+		NewTitle string `json:"newTitle"`
+		CopyMeta bool   `json:"copyMeta"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "body must be {\"newTitle\": <title>}")
+		return
+	}
+	if newTitle := slugTitle(strings.Join(strings.Fields(req.NewTitle), " ")); !apiCanEdit(r, newTitle) {
+		writeAPIError(w, http.StatusForbidden, "not allowed to create "+newTitle)
+		return
+	}
+	if req.CopyMeta && !apiCanEdit(r, title) {
+		writeAPIError(w, http.StatusForbidden, "not allowed to copy the protection of "+title)
+		return
+	}
+
+	cp, err := copyPage(title, req.NewTitle, "", req.CopyMeta)
+	if errors.Is(err, os.ErrNotExist) {
+		writeAPIError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	if status, ok := saveErrorStatus(err); ok {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiPage{Title: cp.Title, Body: string(cp.Body)})
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const copySource = "---\ntags: [guide, setup]\n---\n# Setup\nInstall it.\n"
+
+func TestCopyPage(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Setup", "# Setup\n")
+	w.seed("Setup", copySource)
+	views.add("Setup")
+	views.add("Setup")
+	alice := w.login("alice", roleEditor)
+
+	before := time.Now().Add(-time.Second)
+	resp, body := w.post("/copy/Setup", url.Values{"newTitle": {"  Setup  on Mac "}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if resp.Header.Get("Location") != "/edit/Setup-on-Mac" {
+		t.Errorf("copy redirects to %q, want the new page's editor", resp.Header.Get("Location"))
+	}
+
+	p, err := loadPage("Setup-on-Mac")
+	if err != nil || string(p.Body) != copySource {
+		t.Fatalf("copy = %q, %v, want the source body with its front matter", p.Body, err)
+	}
+	if displayTitle("Setup-on-Mac") != "Setup on Mac" {
+		t.Errorf("display title = %q", displayTitle("Setup-on-Mac"))
+	}
+	// The copy starts its own history and view count.
+	revs, err := listRevisions("Setup-on-Mac")
+	if err != nil || len(revs) != 1 {
+		t.Fatalf("copy history = %+v, %v", revs, err)
+	}
+	if rev := revs[0]; rev.Editor != "alice" || rev.Summary != "Copied from Setup" || rev.Time.Before(before) {
+		t.Errorf("first revision of the copy = %+v", rev)
+	}
+	if n := views.count("Setup-on-Mac"); n != 0 {
+		t.Errorf("copy has %d views", n)
+	}
+	if revs, _ := listRevisions("Setup"); len(revs) != 2 || views.count("Setup") != 2 {
+		t.Errorf("source changed: %d revisions, %d views", len(revs), views.count("Setup"))
+	}
+
+	_, body = w.get("/edit/Setup-on-Mac", append(resp.Cookies(), alice)...)
+	if !strings.Contains(body, "Page Setup copied to Setup-on-Mac") {
+		t.Errorf("editor does not confirm the copy:\n%s", body)
+	}
+
+	for _, tt := range []struct {
+		path, newTitle string
+		status         int
+	}{
+		{"/copy/Setup", "Setup on Mac", http.StatusConflict},
+		{"/copy/Setup", "Setup", http.StatusConflict},
+		{"/copy/Setup", "   ", http.StatusBadRequest},
+		{"/copy/Missing", "Other", http.StatusNotFound},
+	} {
+		resp, body := w.post(tt.path, url.Values{"newTitle": {tt.newTitle}}, alice)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s to %q = %d, want %d\n%s", tt.path, tt.newTitle, resp.StatusCode, tt.status, body)
+		}
+	}
+	if p, _ := loadPage("Setup"); string(p.Body) != copySource {
+		t.Errorf("rejected copy overwrote the source: %q", p.Body)
+	}
+
+	resp, body = w.get("/copy/Setup", alice)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func TestAPICopyPage(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Setup", copySource)
+
+	resp, body := w.api(http.MethodPost, "/api/pages/Setup/copy", `{"newTitle": "Setup copy"}`)
+	wantStatus(t, resp, body, http.StatusCreated)
+	var got apiPage
+	if err := json.Unmarshal([]byte(body), &got); err != nil || got.Title != "Setup-copy" || got.Body != copySource {
+		t.Errorf("API copy = %s, %v", body, err)
+	}
+	if p, err := loadPage("Setup-copy"); err != nil || string(p.Body) != copySource {
+		t.Errorf("copied page = %q, %v", p.Body, err)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/pages/Setup/copy", `{"newTitle": "Setup copy"}`, http.StatusConflict},
+		{http.MethodPost, "/api/pages/Setup/copy", `{"newTitle": "  "}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/pages/Setup/copy", `newTitle=x`, http.StatusBadRequest},
+		{http.MethodPost, "/api/pages/Missing/copy", `{"newTitle": "Other"}`, http.StatusNotFound},
+		{http.MethodGet, "/api/pages/Setup/copy", ``, http.StatusMethodNotAllowed},
+	} {
+		resp, body := w.api(tt.method, tt.path, tt.body)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s %s = %d, want %d\n%s", tt.method, tt.path, tt.body, resp.StatusCode, tt.status, body)
+		}
+	}
+}
+
+func setProtection(t *testing.T, title, level string) {
+	t.Helper()
+	meta, err := loadMeta(title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Protection = level
+	if err := saveMeta(title, meta); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The protection of the source is only kept when asked for.
+func TestCopyPageMeta(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Policy", copySource)
+	setProtection(t, "Policy", protectionEditors)
+	w.seed("Charter", "charter")
+	setProtection(t, "Charter", protectionAdmins)
+	alice, admin := w.login("alice", roleEditor), w.login("root", roleAdmin)
+
+	_, body := w.get("/view/Policy", alice)
+	if !strings.Contains(body, `name="copy_meta"`) {
+		t.Errorf("protected page does not offer to keep the protection:\n%s", body)
+	}
+
+	for _, tt := range []struct {
+		src, dst, copyMeta string
+		cookie             *http.Cookie
+		want               string
+	}{
+		{"Policy", "Plain", "", alice, protectionOpen},
+		{"Policy", "Kept", "on", alice, protectionEditors},
+		{"Charter", "Charter-copy", "on", admin, protectionAdmins},
+	} {
+		resp, body := w.post("/copy/"+tt.src, url.Values{"newTitle": {tt.dst}, "copy_meta": {tt.copyMeta}}, tt.cookie)
+		wantStatus(t, resp, body, http.StatusFound)
+		if got := pageProtection(tt.dst); got != tt.want {
+			t.Errorf("%s copied to %s with copy_meta=%q is %s, want %s", tt.src, tt.dst, tt.copyMeta, got, tt.want)
+		}
+		src, _ := loadPage(tt.src)
+		if p, err := loadPage(tt.dst); err != nil || string(p.Body) != string(src.Body) {
+			t.Errorf("%s = %q, %v, want the body of %s", tt.dst, p.Body, err, tt.src)
+		}
+	}
+
+	// Editors cannot copy what they could not edit.
+	resp, body := w.post("/copy/Charter", url.Values{"newTitle": {"Mine"}}, alice)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = w.post("/copy/Policy", url.Values{"newTitle": {"Kept"}, "copy_meta": {"on"}}, alice)
+	wantStatus(t, resp, body, http.StatusConflict)
+
+	// Without API auth the protection cannot be copied, only the body.
+	resp, body = w.api(http.MethodPost, "/api/pages/Policy/copy", `{"newTitle": "Api", "copyMeta": true}`)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = w.api(http.MethodPost, "/api/pages/Policy/copy", `{"newTitle": "Api"}`)
+	wantStatus(t, resp, body, http.StatusCreated)
+	if pageProtection("Api") != protectionOpen {
+		t.Errorf("API copy is %s", pageProtection("Api"))
+	}
+}
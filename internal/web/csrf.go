@@ -0,0 +1,53 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+const csrfFieldName = "csrf"
+
+const errCSRFMessage = "The form has expired, reload the page and try again"
+
+// csrfToken ties a form to the session it was rendered for, so another site
+// cannot submit it on the strength of the session cookie. Anonymous visitors
+// carry no credentials and all share one token.
+func csrfToken(r *http.Request) string {
+	s, _ := currentSession(r)
+
+	mac := hmac.New(sha256.New, secretKey)
+	fmt.Fprintf(mac, "csrf\x00%s\x00%d", s.User, s.Expires)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func validCSRF(r *http.Request) bool {
+	got, err := base64.RawURLEncoding.DecodeString(r.PostFormValue(csrfFieldName))
+	if err != nil {
+		return false
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(csrfToken(r))
+
+	return hmac.Equal(got, want)
+}
+
+// requireForm lets fn run only for a POST carrying the CSRF token of the
+// current session, for actions that would otherwise be reachable from a link.
+func requireForm(fn func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request, string) {
+	return func(w http.ResponseWriter, r *http.Request, param string) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validCSRF(r) {
+			renderError(w, r, http.StatusForbidden, errCSRFMessage)
+			return
+		}
+
+		fn(w, r, param)
+	}
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Undo and delete change the page, so they take a POST with the token of the
+// session that rendered the form rather than a link another site could follow.
+func TestUndoDeleteNeedForm(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "first")
+	w.seed("Home", "second")
+	alice := w.login("alice", roleEditor)
+	bob := w.login("bob", roleEditor)
+
+	_, body := w.get("/view/Home", alice)
+	for _, action := range []string{"/delete/Home", "/undo/Home"} {
+		if !strings.Contains(body, `action="`+action+`" method="POST"`) {
+			t.Errorf("view has no POST form for %s:\n%s", action, body)
+		}
+	}
+	if token := w.csrf(alice).Get(csrfFieldName); !strings.Contains(body, `value="`+token+`"`) {
+		t.Errorf("view forms do not carry the session token:\n%s", body)
+	}
+
+	for _, path := range []string{"/delete/Home", "/undo/Home"} {
+		resp, body := w.get(path, alice)
+		wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+		if allow := resp.Header.Get("Allow"); allow != http.MethodPost {
+			t.Errorf("GET %s Allow = %q", path, allow)
+		}
+
+		for name, form := range map[string]url.Values{
+			"no token":            {},
+			"bad token":           {csrfFieldName: {"forged"}},
+			"another session":     w.csrf(bob),
+			"the anonymous token": w.csrf(),
+		} {
+			resp, body := w.post(path, form, alice)
+			if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, errCSRFMessage) {
+				t.Errorf("POST %s with %s = %d\n%s", path, name, resp.StatusCode, body)
+			}
+		}
+	}
+	if b, err := store.Read("Home"); err != nil || string(b) != "second" {
+		t.Fatalf("page after refused requests = %q, %v", b, err)
+	}
+
+	resp, body := w.post("/undo/Home", w.csrf(alice), alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, _ := store.Read("Home"); string(b) != "first" {
+		t.Errorf("page after undo = %q", b)
+	}
+	resp, body = w.post("/delete/Home", w.csrf(alice), alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, err := store.Stat("Home"); err == nil {
+		t.Error("Home still exists after delete")
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const templatesDir = "templates"
+
+var templateFuncs = template.FuncMap{
+	"datetime": formatTime,
+	"base":     func() string { return basePath },
+}
+
+// loadTemplates parses every .html file in dir. With a theme, files in
+// dir/<theme> replace the ones of the same name, so a theme only needs the
+// templates it changes. Files are parsed one by one so that errors name the
+// template that failed.
+func loadTemplates(dir, theme string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("loading templates: %w", err)
+		}
+		return nil, fmt.Errorf("loading templates: no .html files in %s", dir)
+	}
+
+	if theme != "" {
+		themeDir := filepath.Join(dir, theme)
+		if fi, err := os.Stat(themeDir); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("loading templates: theme %q not found in %s", theme, dir)
+		}
+		overrides, err := filepath.Glob(filepath.Join(themeDir, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		for _, override := range overrides {
+			for i, file := range files {
+				if filepath.Base(file) == filepath.Base(override) {
+					files[i] = override
+				}
+			}
+			if !slices.Contains(files, override) {
+				files = append(files, override)
+			}
+		}
+	}
+
+	// The root is never executed. Naming it after a file would let that
+	// file's definition clobber it.
+	t := template.New("")
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading template %s: %w", file, err)
+		}
+		if _, err := t.New(filepath.Base(file)).Funcs(templateFuncs).Parse(string(b)); err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", file, err)
+		}
+	}
+
+	return t, nil
+}
+
+type server struct {
+	config       Config
+	templatesDir string
+
+	mu        sync.Mutex
+	templates *template.Template
+}
+
+func newServer(cfg Config, dir string) (*server, error) {
+	t, err := loadTemplates(dir, cfg.TemplateTheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return &server{config: cfg, templatesDir: dir, templates: t}, nil
+}
+
+type serverKey struct{}
+
+// withServer makes s available to renderTemplate through the request.
+func withServer(s *server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serverKey{}, s)))
+	})
+}
+
+func serverFrom(r *http.Request) (*server, bool) {
+	s, ok := r.Context().Value(serverKey{}).(*server)
+	return s, ok
+}
+
+// currentTemplates returns the template set to render with. In dev mode the
+// templates are reparsed on every call; when that fails the last good set is
+// kept and the parse error is returned alongside it so it can be shown.
+func (s *server) currentTemplates() (*template.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.config.DevMode {
+		return s.templates, nil
+	}
+
+	t, err := loadTemplates(s.templatesDir, s.config.TemplateTheme)
+	if err != nil {
+		slog.Error("error reloading templates, serving the last good set", "err", err)
+		return s.templates, err
+	}
+
+	s.templates = t
+	return s.templates, nil
+}
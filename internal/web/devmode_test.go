@@ -0,0 +1,113 @@
+package web
+
+import (
+	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	tmpl, err := loadTemplates("../../templates", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"base.html", "view.html", "edit.html", "index.html"} {
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("template %q not defined", name)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := loadTemplates(missing, ""); !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), missing) {
+		t.Errorf("missing directory: %v", err)
+	}
+	if _, err := loadTemplates(t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "no .html files") {
+		t.Errorf("empty directory: %v", err)
+	}
+	if _, err := newServer(Config{}, missing); err == nil {
+		t.Error("newServer accepted a missing template directory")
+	}
+}
+
+func TestLoadTemplatesMalformed(t *testing.T) {
+	for name, text := range map[string]string{
+		"unclosed.html": `{{define "view"}}{{if .Title}}{{end}}`,
+		"function.html": `{{define "view"}}{{nosuchfunc .Title}}{{end}}`,
+		"action.html":   `{{define "view"}}{{.Title}{{end}}`,
+	} {
+		dir := t.TempDir()
+		for file, text := range map[string]string{"base.html": `{{define "base"}}{{template "view" .}}{{end}}`, name: text} {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(text), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := loadTemplates(dir, "")
+		if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, name)) {
+			t.Errorf("%s: error %v does not name the file", name, err)
+		}
+	}
+}
+
+// serveTheme serves w's wiki with the templates of dir and theme.
+func serveTheme(t *testing.T, w *testWiki, dir, theme string) *testWiki {
+	t.Helper()
+	srv, err := newServer(Config{TemplateTheme: theme}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(newHandler(srv, routes()))
+	t.Cleanup(ts.Close)
+	return &testWiki{Server: ts, t: t, dir: w.dir}
+}
+
+func TestTemplateThemes(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home text")
+
+	_, full := w.get("/view/Home")
+	_, minimal := serveTheme(t, w, "../../templates", "minimal").get("/view/Home")
+	if strings.Contains(full, "Georgia") || !strings.Contains(minimal, "font-family: Georgia") {
+		t.Errorf("minimal theme base not used:\n%s", minimal)
+	}
+	// Templates the theme does not have come from the default set.
+	if !strings.Contains(minimal, "home text") || !strings.Contains(minimal, `/history/Home">History</a>`) {
+		t.Errorf("minimal theme lost the default view:\n%s", minimal)
+	}
+
+	// A theme can also add templates of its own.
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"base.html":        `<main>{{.Content}}</main>`,
+		"view.html":        `default view`,
+		"index.html":       `default index`,
+		"kiosk/view.html":  `kiosk view {{template "badge.html"}}`,
+		"kiosk/badge.html": `[kiosk]`,
+	} {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kiosk := serveTheme(t, w, dir, "kiosk")
+	if _, body := kiosk.get("/view/Home"); body != "<main>kiosk view [kiosk]</main>" {
+		t.Errorf("kiosk view = %q", body)
+	}
+	if _, body := kiosk.get("/"); body != "<main>default index</main>" {
+		t.Errorf("kiosk index = %q", body)
+	}
+	if _, body := serveTheme(t, w, dir, "").get("/view/Home"); body != "<main>default view</main>" {
+		t.Errorf("view without a theme = %q", body)
+	}
+
+	if _, err := loadTemplates(dir, "neon"); err == nil || !strings.Contains(err.Error(), `theme "neon" not found`) {
+		t.Errorf("unknown theme: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+
+	maxDiffEdits = 5000
+)
+
+type diffOp struct {
+	Kind string
+	Text string
+}
+
+type diffSegment struct {
+	Text    string
+	Changed bool
+}
+
+type diffLine struct {
+	Kind     string
+	Text     string
+	Segments []diffSegment
+}
+
+type diffData struct {
+	Title string
+	From  int
+	To    int
+	Words bool
+	Lines []diffLine
+}
+
+var wordToken = regexp.MustCompile(`\s+|[\p{L}\p{N}_]+|[^\s\p{L}\p{N}_]`)
+
+// diffTokens computes a shortest edit script with Myers' algorithm. Inputs
+// needing more than maxDiffEdits edits are reported as a full replacement.
+func diffTokens(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b, offset)
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	for _, s := range a {
+		ops = append(ops, diffOp{diffDelete, s})
+	}
+	for _, s := range b {
+		ops = append(ops, diffOp{diffInsert, s})
+	}
+
+	return ops
+}
+
+func backtrackDiff(trace [][]int, a, b []string, offset int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{diffEqual, a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{diffInsert, b[prevY]})
+			} else {
+				ops = append(ops, diffOp{diffDelete, a[prevX]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffPage compares two versions line by line. With words set, each run of
+// deleted lines directly followed by inserted lines is paired up and the pairs
+// get word-level segments so only the changed words are highlighted.
+func diffPage(from, to string, words bool) []diffLine {
+	ops := diffTokens(splitLines(from), splitLines(to))
+
+	lines := make([]diffLine, 0, len(ops))
+	for i := 0; i < len(ops); {
+		if !words || ops[i].Kind != diffDelete {
+			lines = append(lines, diffLine{Kind: ops[i].Kind, Text: ops[i].Text})
+			i++
+			continue
+		}
+
+		var deleted, inserted []string
+		for ; i < len(ops) && ops[i].Kind == diffDelete; i++ {
+			deleted = append(deleted, ops[i].Text)
+		}
+		for ; i < len(ops) && ops[i].Kind == diffInsert; i++ {
+			inserted = append(inserted, ops[i].Text)
+		}
+
+		var oldLines, newLines []diffLine
+		for j, text := range deleted {
+			line := diffLine{Kind: diffDelete, Text: text}
+			if j < len(inserted) {
+				oldSegs, newSegs := diffWords(text, inserted[j])
+				line.Segments = oldSegs
+				newLines = append(newLines, diffLine{Kind: diffInsert, Text: inserted[j], Segments: newSegs})
+			}
+			oldLines = append(oldLines, line)
+		}
+		for _, text := range inserted[min(len(deleted), len(inserted)):] {
+			newLines = append(newLines, diffLine{Kind: diffInsert, Text: text})
+		}
+
+		lines = append(lines, oldLines...)
+		lines = append(lines, newLines...)
+	}
+
+	return lines
+}
+
+func diffWords(from, to string) (oldSegs, newSegs []diffSegment) {
+	for _, op := range diffTokens(wordToken.FindAllString(from, -1), wordToken.FindAllString(to, -1)) {
+		switch op.Kind {
+		case diffEqual:
+			oldSegs = appendSegment(oldSegs, op.Text, false)
+			newSegs = appendSegment(newSegs, op.Text, false)
+		case diffDelete:
+			oldSegs = appendSegment(oldSegs, op.Text, true)
+		case diffInsert:
+			newSegs = appendSegment(newSegs, op.Text, true)
+		}
+	}
+
+	return oldSegs, newSegs
+}
+
+func appendSegment(segs []diffSegment, text string, changed bool) []diffSegment {
+	if n := len(segs); n > 0 && segs[n-1].Changed == changed {
+		segs[n-1].Text += text
+		return segs
+	}
+
+	return append(segs, diffSegment{Text: text, Changed: changed})
+}
+
+// diffHandler shows the changes between two revisions, by default the latest
+// revision against the one before it.
+func diffHandler(w http.ResponseWriter, r *http.Request, param string) {
+	revs, err := listRevisions(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(revs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	to := revs[0].ID
+	if raw := r.FormValue("to"); raw != "" {
+		if to, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+	}
+	// Revision ids can have gaps after duplicates were collapsed, so the
+	// default is the closest older revision rather than to-1.
+	from := 0
+	for _, rev := range revs {
+		if rev.ID < to {
+			from = rev.ID
+			break
+		}
+	}
+	if raw := r.FormValue("from"); raw != "" {
+		if from, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var fromBody []byte
+	if from > 0 {
+		if _, fromBody, err = findRevision(param, from); err != nil && !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	_, toBody, err := findRevision(param, to)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	words := r.FormValue("words") == "1"
+	data := pageData{
+		Title: "Changes to " + param,
+		Content: &diffData{
+			Title: param,
+			From:  from,
+			To:    to,
+			Words: words,
+			Lines: diffPage(string(fromBody), string(toBody), words),
+		},
+	}
+
+	renderTemplate(w, r, data, "diff")
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiffTokens(t *testing.T) {
+	got := diffTokens([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	want := []diffOp{
+		{diffEqual, "a"},
+		{diffDelete, "b"},
+		{diffInsert, "x"},
+		{diffEqual, "c"},
+		{diffEqual, "d"},
+		{diffInsert, "e"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTokens = %v, want %v", got, want)
+	}
+
+	if got := diffTokens(nil, []string{"a"}); !reflect.DeepEqual(got, []diffOp{{diffInsert, "a"}}) {
+		t.Errorf("diff from nothing = %v", got)
+	}
+	if got := diffTokens([]string{"a"}, nil); !reflect.DeepEqual(got, []diffOp{{diffDelete, "a"}}) {
+		t.Errorf("diff to nothing = %v", got)
+	}
+}
+
+// Past maxDiffEdits the inputs are reported as replaced wholesale.
+func TestDiffTokensLimit(t *testing.T) {
+	var a, b []string
+	for i := range maxDiffEdits {
+		a = append(a, "a"+strconv.Itoa(i))
+		b = append(b, "b"+strconv.Itoa(i))
+	}
+	ops := diffTokens(a, b)
+	if len(ops) != 2*maxDiffEdits || ops[0].Kind != diffDelete || ops[len(ops)-1].Kind != diffInsert {
+		t.Errorf("%d ops, first %v, last %v", len(ops), ops[0], ops[len(ops)-1])
+	}
+}
+
+func TestDiffPageWords(t *testing.T) {
+	from := "# Title\nThe quick brown fox jumps.\nunchanged\n"
+	to := "# Title\r\nThe quick red fox jumps.\r\nunchanged\r\nadded line\r\n"
+
+	// Line by line the changed line is replaced as a whole.
+	lines := diffPage(from, to, false)
+	wantLines := []diffLine{
+		{Kind: diffEqual, Text: "# Title"},
+		{Kind: diffDelete, Text: "The quick brown fox jumps."},
+		{Kind: diffInsert, Text: "The quick red fox jumps."},
+		{Kind: diffEqual, Text: "unchanged"},
+		{Kind: diffInsert, Text: "added line"},
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("line diff = %+v", lines)
+	}
+
+	// Word by word only the changed word is marked.
+	lines = diffPage(from, to, true)
+	wantLines[1].Segments = []diffSegment{{"The quick ", false}, {"brown", true}, {" fox jumps.", false}}
+	wantLines[2].Segments = []diffSegment{{"The quick ", false}, {"red", true}, {" fox jumps.", false}}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("word diff = %+v", lines)
+	}
+}
+
+func TestDiffWordsUnpaired(t *testing.T) {
+	lines := diffPage("one old\ntwo old\n", "one new\n", true)
+	want := []diffLine{
+		{Kind: diffDelete, Text: "one old", Segments: []diffSegment{{"one ", false}, {"old", true}}},
+		{Kind: diffDelete, Text: "two old"},
+		{Kind: diffInsert, Text: "one new", Segments: []diffSegment{{"one ", false}, {"new", true}}},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("word diff = %+v", lines)
+	}
+
+	// Punctuation and non-latin words are tokens of their own.
+	oldSegs, newSegs := diffWords("Привет, мир!", "Привет; мир!")
+	if !reflect.DeepEqual(oldSegs, []diffSegment{{"Привет", false}, {",", true}, {" мир!", false}}) ||
+		!reflect.DeepEqual(newSegs, []diffSegment{{"Привет", false}, {";", true}, {" мир!", false}}) {
+		t.Errorf("diffWords = %+v, %+v", oldSegs, newSegs)
+	}
+}
+
+func TestDiffHandler(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "The quick brown fox.\nsame\n")
+	w.seed("Home", "The quick red <fox>.\nsame\n")
+
+	resp, body := w.get("/diff/Home?words=1")
+	wantStatus(t, resp, body, http.StatusOK)
+	for _, want := range []string{
+		`<div class="diff-delete">- The quick <mark>brown</mark> fox.</div>`,
+		`<div class="diff-insert">+ The quick <mark>red</mark> <mark>&lt;</mark>fox<mark>&gt;</mark>.</div>`,
+		`<div class="diff-equal">  same</div>`,
+		`href="/diff/Home?from=1&to=2">Line diff`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("word diff lacks %s:\n%s", want, body)
+		}
+	}
+
+	_, body = w.get("/diff/Home")
+	if strings.Contains(body, "<mark>") || !strings.Contains(body, `<div class="diff-delete">- The quick brown fox.</div>`) {
+		t.Errorf("line diff:\n%s", body)
+	}
+
+	// The first revision is compared with an empty page.
+	_, body = w.get("/diff/Home?to=1")
+	if !strings.Contains(body, "Revision 0 → 1") || !strings.Contains(body, `<div class="diff-insert">+ The quick brown fox.</div>`) {
+		t.Errorf("diff of the first revision:\n%s", body)
+	}
+
+	for path, status := range map[string]int{
+		"/diff/Home?to=9":   http.StatusNotFound,
+		"/diff/Home?to=x":   http.StatusBadRequest,
+		"/diff/Home?from=x": http.StatusBadRequest,
+		"/diff/Missing":     http.StatusNotFound,
+	} {
+		resp, body := w.get(path)
+		if resp.StatusCode != status {
+			t.Errorf("%s = %d, want %d\n%s", path, resp.StatusCode, status, body)
+		}
+	}
+}
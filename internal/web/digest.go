@@ -0,0 +1,298 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	digestOff     = "off"
+	digestInstant = "instant"
+	digestDaily   = "daily"
+	digestWeekly  = "weekly"
+)
+
+var digestFrequencies = []string{digestOff, digestInstant, digestDaily, digestWeekly}
+
+type digestEntry struct {
+	Title     string    `json:"title"`
+	Time      time.Time `json:"time"`
+	Editor    string    `json:"editor,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+	SizeDelta int       `json:"size_delta"`
+	Minor     bool      `json:"minor,omitempty"`
+	Deleted   bool      `json:"deleted,omitempty"`
+}
+
+type digestConfig struct {
+	smtpAddr     string
+	smtpFrom     string
+	smtpUser     string
+	smtpPassword string
+	location     *time.Location
+	hour         int
+	weekday      time.Weekday
+}
+
+var (
+	digest   *digestConfig
+	digestMu sync.Mutex
+)
+
+// setupDigest enables digest mails when SMTP_ADDR is set. DIGEST_TZ, DIGEST_HOUR
+// and DIGEST_WEEKDAY choose when the daily and weekly digests go out.
+func setupDigest() error {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	c := &digestConfig{
+		smtpAddr:     addr,
+		smtpFrom:     os.Getenv("SMTP_FROM"),
+		smtpUser:     os.Getenv("SMTP_USER"),
+		smtpPassword: os.Getenv("SMTP_PASSWORD"),
+		location:     displayLocation,
+		hour:         8,
+		weekday:      time.Monday,
+	}
+	if c.smtpFrom == "" {
+		return fmt.Errorf("SMTP_FROM is required when SMTP_ADDR is set")
+	}
+
+	if tz := os.Getenv("DIGEST_TZ"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid DIGEST_TZ %q: %w", tz, err)
+		}
+		c.location = loc
+	}
+	if raw := os.Getenv("DIGEST_HOUR"); raw != "" {
+		h, err := strconv.Atoi(raw)
+		if err != nil || h < 0 || h > 23 {
+			return fmt.Errorf("invalid DIGEST_HOUR %q", raw)
+		}
+		c.hour = h
+	}
+	if raw := os.Getenv("DIGEST_WEEKDAY"); raw != "" {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(d.String(), raw) {
+				c.weekday, found = d, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid DIGEST_WEEKDAY %q", raw)
+		}
+	}
+
+	digest = c
+	return nil
+}
+
+func digestFilename(user string) string {
+	return filepath.Join(config.StoragePath, ".digest", url.PathEscape(user)+".json")
+}
+
+func loadDigestEntries(user string) ([]digestEntry, error) {
+	b, err := os.ReadFile(digestFilename(user))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []digestEntry
+	return entries, json.Unmarshal(b, &entries)
+}
+
+func saveDigestEntries(user string, entries []digestEntry) error {
+	fn := digestFilename(user)
+	if len(entries) == 0 {
+		err := os.Remove(fn)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, b, 0600)
+}
+
+// notifiedOf reports whether u hears about the change at all: minor edits
+// only reach watchers who opted in to them.
+func notifiedOf(u *userRecord, entry digestEntry) bool {
+	return !entry.Minor || u.Preferences.NotifyMinor
+}
+
+// latestChange describes the newest revision of title.
+func latestChange(title string) (digestEntry, bool) {
+	revs, err := listRevisions(title)
+	if err != nil || len(revs) == 0 {
+		return digestEntry{}, false
+	}
+	entry := digestEntry{Title: title, Time: revs[0].Time, Editor: revs[0].Editor, Summary: revs[0].Summary, SizeDelta: revs[0].Size, Minor: revs[0].Minor}
+	if len(revs) > 1 {
+		entry.SizeDelta -= revs[1].Size
+	}
+
+	return entry, true
+}
+
+// queueDigestEntries records a change of title for every watcher who gets digests.
+// Entries are kept on disk so a restart does not lose the period's changes.
+// Minor edits are only recorded for watchers who asked for them.
+func queueDigestEntries(title string) {
+	if digest == nil {
+		return
+	}
+
+	if entry, ok := latestChange(title); ok {
+		queueDigestEntry(entry)
+	}
+}
+
+func queueDigestEntry(entry digestEntry) {
+	names, err := listUsers()
+	if err != nil {
+		slog.Error("error listing users", "err", err)
+		return
+	}
+
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	for _, name := range names {
+		u, err := loadUser(name)
+		if err != nil || u.Preferences.Digest == digestOff || u.Preferences.Digest == digestInstant || u.Preferences.Digest == "" || name == entry.Editor {
+			continue
+		}
+		if _, ok := u.Watches[entry.Title]; !ok || !notifiedOf(u, entry) {
+			continue
+		}
+
+		entries, err := loadDigestEntries(name)
+		if err == nil {
+			err = saveDigestEntries(name, append(entries, entry))
+		}
+		if err != nil {
+			slog.Error("error queueing digest entry", "user", name, "err", err)
+		}
+	}
+}
+
+func (c *digestConfig) nextRun(now time.Time) time.Time {
+	now = now.In(c.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), c.hour, 0, 0, 0, c.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+func runDigestScheduler() {
+	for {
+		next := digest.nextRun(time.Now())
+		time.Sleep(time.Until(next))
+
+		if err := sendDigests(next); err != nil {
+			slog.Error("error sending digests", "err", err)
+		}
+	}
+}
+
+// sendDigests mails every user whose digest period ends at now, skipping users
+// without queued changes. Weekly digests go out on the configured weekday.
+func sendDigests(now time.Time) error {
+	names, err := listUsers()
+	if err != nil {
+		return err
+	}
+
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	for _, name := range names {
+		u, err := loadUser(name)
+		if err != nil || u.Preferences.Email == "" {
+			continue
+		}
+		switch u.Preferences.Digest {
+		case digestDaily:
+		case digestWeekly:
+			if now.In(digest.location).Weekday() != digest.weekday {
+				continue
+			}
+		default:
+			continue
+		}
+
+		entries, err := loadDigestEntries(name)
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+
+		if err := digest.send(u.Preferences.Email, entries); err != nil {
+			slog.Error("error sending digest", "user", name, "err", err)
+			continue
+		}
+		if err := saveDigestEntries(name, nil); err != nil {
+			slog.Error("error clearing digest", "user", name, "err", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *digestConfig) send(to string, entries []digestEntry) error {
+	var body strings.Builder
+	subject := fmt.Sprintf("Wiki changes: %d pages", len(entries))
+	if len(entries) == 1 {
+		subject = "Wiki change: " + entries[0].Title
+	}
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", c.smtpFrom, to, subject)
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	for _, e := range entries {
+		editor := e.Editor
+		if editor == "" {
+			editor = "anonymous"
+		}
+		if e.Deleted {
+			fmt.Fprintf(&body, "%s  %s was deleted\r\n", formatTimeIn(e.Time, c.location), e.Title)
+			continue
+		}
+		fmt.Fprintf(&body, "%s  %s by %s (%+d bytes)\r\n", formatTimeIn(e.Time, c.location), e.Title, editor, e.SizeDelta)
+		if e.Summary != "" {
+			fmt.Fprintf(&body, "    %s\r\n", e.Summary)
+		}
+		fmt.Fprintf(&body, "    %s\r\n", absoluteURL("/view/"+e.Title))
+	}
+
+	var auth smtp.Auth
+	if c.smtpUser != "" {
+		host, _, _ := strings.Cut(c.smtpAddr, ":")
+		auth = smtp.PlainAuth("", c.smtpUser, c.smtpPassword, host)
+	}
+
+	return smtp.SendMail(c.smtpAddr, auth, c.smtpFrom, []string{to}, []byte(body.String()))
+}
@@ -0,0 +1,217 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSink is an SMTP server that accepts every mail and hands over the
+// messages it received.
+func smtpSink(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(textproto.NewConn(conn), mails)
+		}
+	}()
+
+	return ln.Addr().String(), mails
+}
+
+func serveSMTP(conn *textproto.Conn, mails chan<- string) {
+	defer conn.Close()
+	conn.PrintfLine("220 sink")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "DATA":
+			conn.PrintfLine("354 go ahead")
+			b, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mails <- string(b)
+			conn.PrintfLine("250 queued")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("250 ok")
+		}
+	}
+}
+
+// withMail points the digest and change mails at an SMTP sink.
+func withMail(t *testing.T) <-chan string {
+	t.Helper()
+	addr, mails := smtpSink(t)
+	setGlobal(t, &digest, &digestConfig{smtpAddr: addr, smtpFrom: "wiki@example.com", location: time.UTC, hour: 8, weekday: time.Monday})
+	return mails
+}
+
+// receivedMails collects the recipients of the mails that arrive within a
+// short wait.
+func receivedMails(mails <-chan string) []string {
+	var to []string
+	for {
+		select {
+		case m := <-mails:
+			for _, line := range strings.Split(m, "\n") {
+				if rcpt, ok := strings.CutPrefix(strings.TrimSpace(line), "To: "); ok {
+					to = append(to, rcpt)
+				}
+			}
+		case <-time.After(200 * time.Millisecond):
+			return to
+		}
+	}
+}
+
+func setPreferences(t *testing.T, user string, fn func(p *preferences)) {
+	t.Helper()
+	if err := updateUser(user, func(u *userRecord) { fn(&u.Preferences) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func digestTitles(t *testing.T, user string) []string {
+	t.Helper()
+	entries, err := loadDigestEntries(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, e := range entries {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestSetupDigest(t *testing.T) {
+	setGlobal(t, &digest, nil)
+	t.Setenv("SMTP_ADDR", "")
+	if err := setupDigest(); err != nil || digest != nil {
+		t.Fatalf("setupDigest without SMTP_ADDR = %v, %v", digest, err)
+	}
+
+	t.Setenv("SMTP_ADDR", "mail.example.com:25")
+	t.Setenv("SMTP_FROM", "wiki@example.com")
+	t.Setenv("DIGEST_TZ", "Europe/Moscow")
+	t.Setenv("DIGEST_HOUR", "18")
+	t.Setenv("DIGEST_WEEKDAY", "friday")
+	if err := setupDigest(); err != nil {
+		t.Fatal(err)
+	}
+	if digest.location.String() != "Europe/Moscow" || digest.hour != 18 || digest.weekday != time.Friday {
+		t.Errorf("digest config = %+v", digest)
+	}
+
+	for key, value := range map[string]string{
+		"SMTP_FROM":      "",
+		"DIGEST_TZ":      "Mars/Olympus",
+		"DIGEST_HOUR":    "24",
+		"DIGEST_WEEKDAY": "someday",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if err := setupDigest(); err == nil {
+				t.Errorf("%s=%q accepted", key, value)
+			}
+		})
+	}
+}
+
+func TestDigestNextRun(t *testing.T) {
+	zone := time.FixedZone("UTC+3", 3*60*60)
+	c := &digestConfig{location: zone, hour: 8}
+
+	for _, tt := range []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 3, 2, 4, 59, 0, 0, time.UTC), time.Date(2026, 3, 2, 8, 0, 0, 0, zone)},
+		{time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 8, 0, 0, 0, zone)},
+		// Already the next day where the digest is scheduled.
+		{time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 8, 0, 0, 0, zone)},
+	} {
+		if got := c.nextRun(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextRun(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	mails := withMail(t)
+
+	for name, freq := range map[string]string{"daily": digestDaily, "weekly": digestWeekly} {
+		c := w.login(name, roleEditor)
+		form := validPreferences()
+		form.Set("digest", freq)
+		form.Set("email", name+"@example.com")
+		resp, body := w.post("/preferences", form, c)
+		wantStatus(t, resp, body, http.StatusFound)
+		w.post("/watch/Home", nil, c)
+	}
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"home page"}, "summary": {"expand"}}, w.login("carol", roleEditor))
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := receivedMails(mails); len(got) != 0 {
+		t.Fatalf("digest users were mailed at once: %q", got)
+	}
+	// The queue is on disk, so it outlives the process.
+	for _, name := range []string{"daily", "weekly"} {
+		if got := digestTitles(t, name); !slices.Equal(got, []string{"Home"}) {
+			t.Errorf("%s queue = %q", name, got)
+		}
+	}
+
+	tuesday := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+	if err := sendDigests(tuesday); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mails:
+		if !strings.Contains(m, "To: daily@example.com") || !strings.Contains(m, "Home by carol (+5 bytes)") || !strings.Contains(m, "expand") {
+			t.Errorf("daily digest:\n%s", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daily digest not sent")
+	}
+	if got := receivedMails(mails); len(got) != 0 {
+		t.Errorf("mails sent outside the weekly digest day: %q", got)
+	}
+	if got := digestTitles(t, "daily"); len(got) != 0 {
+		t.Errorf("daily queue after sending = %q", got)
+	}
+
+	// A period without changes sends nothing, and the weekday sends the rest.
+	monday := tuesday.AddDate(0, 0, 6)
+	if err := sendDigests(monday); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivedMails(mails); !slices.Equal(got, []string{"weekly@example.com"}) {
+		t.Errorf("mails on the weekly digest day = %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	draftSessionCookieName = "draft_session"
+	draftMaxAge            = 30 * 24 * time.Hour
+)
+
+type draftInfo struct {
+	Title    string
+	Modified time.Time
+}
+
+type draftsData struct {
+	Drafts []draftInfo
+}
+
+// draftOwner identifies whose drafts a request sees: the logged-in user, or
+// for anonymous visitors a random id kept in a signed cookie. With create set
+// a missing anonymous id is issued, otherwise "" is returned for it.
+func draftOwner(w http.ResponseWriter, r *http.Request, create bool) string {
+	if user := currentUser(r); user != "" {
+		return "user:" + user
+	}
+
+	if c, err := r.Cookie(draftSessionCookieName); err == nil {
+		if id, err := verifyValue(c.Value); err == nil {
+			return "anon:" + string(id)
+		}
+	}
+	if !create {
+		return ""
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     draftSessionCookieName,
+		Value:    signValue([]byte(id)),
+		Path:     pageURL("/"),
+		MaxAge:   int(draftMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return "anon:" + id
+}
+
+func draftDir(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return filepath.Join(config.StoragePath, ".drafts", hex.EncodeToString(sum[:16]))
+}
+
+func draftFilename(owner, title string) string {
+	return filepath.Join(draftDir(owner), title+".txt")
+}
+
+func saveDraft(owner, title string, body []byte) error {
+	if err := os.MkdirAll(draftDir(owner), 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(draftFilename(owner, title), body, 0600)
+}
+
+func loadDraft(owner, title string) ([]byte, time.Time, bool) {
+	if owner == "" {
+		return nil, time.Time{}, false
+	}
+
+	fn := draftFilename(owner, title)
+	info, err := os.Stat(fn)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	body, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	return body, info.ModTime(), true
+}
+
+func removeDraft(owner, title string) error {
+	if owner == "" {
+		return nil
+	}
+
+	err := os.Remove(draftFilename(owner, title))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func listDrafts(owner string) ([]draftInfo, error) {
+	if owner == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(draftDir(owner))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var drafts []draftInfo
+	for _, entry := range entries {
+		title, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		drafts = append(drafts, draftInfo{Title: title, Modified: info.ModTime()})
+	}
+
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].Modified.After(drafts[j].Modified)
+	})
+
+	return drafts, nil
+}
+
+// moveDrafts moves every owner's draft of a renamed page to the new title. A
+// draft already kept under the new title wins.
+func moveDrafts(from, to string) {
+	files, err := filepath.Glob(filepath.Join(config.StoragePath, ".drafts", "*", from+".txt"))
+	if err != nil {
+		slog.Error("error listing drafts", "title", from, "err", err)
+		return
+	}
+
+	for _, file := range files {
+		target := filepath.Join(filepath.Dir(file), to+".txt")
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(file, target); err != nil {
+			slog.Error("error moving draft", "title", from, "err", err)
+		}
+	}
+}
+
+// removeStaleDrafts deletes drafts nobody touched for draftMaxAge, for all owners.
+func removeStaleDrafts(now time.Time) error {
+	files, err := filepath.Glob(filepath.Join(config.StoragePath, ".drafts", "*", "*.txt"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || now.Sub(info.ModTime()) < draftMaxAge {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func draftHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner := draftOwner(w, r, true)
+	if owner == "" {
+		http.Error(w, "Could not start a draft session", http.StatusInternalServerError)
+		return
+	}
+
+	body := []byte(r.FormValue("body"))
+	if len(body) > maxPageSize {
+		http.Error(w, "Draft is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := saveDraft(owner, param, body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Draft of " + param + " saved"})
+	http.Redirect(w, r, pageURL("/edit/"+param), http.StatusFound)
+}
+
+func discardHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if err := removeDraft(draftOwner(w, r, false), param); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Draft of " + param + " discarded"})
+	http.Redirect(w, r, pageURL("/edit/"+param), http.StatusFound)
+}
+
+func draftsHandler(w http.ResponseWriter, r *http.Request) {
+	drafts, err := listDrafts(draftOwner(w, r, false))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := pageData{
+		Title:   "My drafts",
+		Content: &draftsData{Drafts: drafts},
+	}
+
+	renderTemplate(w, r, data, "drafts")
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func (w *testWiki) draft(title, body string, cookies ...*http.Cookie) *http.Response {
+	w.t.Helper()
+	resp, respBody := w.post("/draft/"+title, url.Values{"body": {body}}, cookies...)
+	if resp.StatusCode != http.StatusFound {
+		w.t.Fatalf("draft = %d\n%s", resp.StatusCode, respBody)
+	}
+	return resp
+}
+
+// editBody returns what the edit form offers for title.
+func (w *testWiki) editBody(title string, cookies ...*http.Cookie) string {
+	w.t.Helper()
+	resp, body := w.get("/edit/"+title, cookies...)
+	if resp.StatusCode != http.StatusOK {
+		w.t.Fatalf("edit = %d\n%s", resp.StatusCode, body)
+	}
+	return body
+}
+
+func TestDraftsPerUser(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "saved text")
+	alice := w.login("alice", roleEditor)
+	bob := w.login("bob", roleEditor)
+
+	var wg sync.WaitGroup
+	for _, user := range []struct {
+		name   string
+		cookie *http.Cookie
+	}{{"alice", alice}, {"bob", bob}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 10 {
+				w.post("/draft/Home", url.Values{"body": {user.name + " draft " + string(rune('0'+i))}}, user.cookie)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for name, cookie := range map[string]*http.Cookie{"alice": alice, "bob": bob} {
+		other := map[string]string{"alice": "bob", "bob": "alice"}[name]
+		body := w.editBody("Home", cookie)
+		if !strings.Contains(body, name+" draft 9") || strings.Contains(body, other+" draft") || !strings.Contains(body, "Showing your draft") {
+			t.Errorf("%s's edit form:\n%s", name, body)
+		}
+		_, list := w.get("/drafts", cookie)
+		if !strings.Contains(list, `href="/edit/Home"`) {
+			t.Errorf("%s's drafts do not list Home:\n%s", name, list)
+		}
+	}
+
+	// Saving clears the saver's draft only.
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"alice saved"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if body := w.editBody("Home", alice); strings.Contains(body, "Showing your draft") || !strings.Contains(body, "alice saved") {
+		t.Errorf("alice's draft survived her save:\n%s", body)
+	}
+	if body := w.editBody("Home", bob); !strings.Contains(body, "bob draft 9") {
+		t.Errorf("alice's save removed bob's draft:\n%s", body)
+	}
+	if _, list := w.get("/drafts", alice); !strings.Contains(list, "You have no drafts") {
+		t.Errorf("alice's drafts after saving:\n%s", list)
+	}
+
+	resp, body = w.post("/discard/Home", nil, bob)
+	wantStatus(t, resp, body, http.StatusFound)
+	if body := w.editBody("Home", bob); strings.Contains(body, "bob draft") {
+		t.Errorf("bob's draft survived discarding:\n%s", body)
+	}
+}
+
+// Anonymous visitors get a draft session cookie of their own.
+func TestDraftsAnonymous(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "saved text")
+
+	resp := w.draft("Home", "anonymous draft")
+	var session *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == draftSessionCookieName {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly {
+		t.Fatalf("draft set no HttpOnly session cookie: %v", resp.Cookies())
+	}
+
+	if body := w.editBody("Home", session); !strings.Contains(body, "anonymous draft") {
+		t.Errorf("draft not offered to its session:\n%s", body)
+	}
+	if body := w.editBody("Home"); strings.Contains(body, "anonymous draft") {
+		t.Errorf("draft offered to another visitor:\n%s", body)
+	}
+	forged := &http.Cookie{Name: draftSessionCookieName, Value: session.Value + "x"}
+	if body := w.editBody("Home", forged); strings.Contains(body, "anonymous draft") {
+		t.Errorf("draft offered to a forged session:\n%s", body)
+	}
+	if body := w.editBody("Home", w.login("alice", roleEditor)); strings.Contains(body, "anonymous draft") {
+		t.Errorf("anonymous draft offered to a user:\n%s", body)
+	}
+
+	// The same session keeps its id across drafts.
+	for _, c := range w.draft("Other", "second", session).Cookies() {
+		if c.Name == draftSessionCookieName {
+			t.Error("a second draft issued a new session")
+		}
+	}
+	if _, list := w.get("/drafts", session); !strings.Contains(list, "/edit/Home") || !strings.Contains(list, "/edit/Other") {
+		t.Errorf("anonymous drafts:\n%s", list)
+	}
+}
+
+func TestRemoveStaleDrafts(t *testing.T) {
+	w := newTestWiki(t)
+	alice := w.login("alice", roleEditor)
+	bob := w.login("bob", roleEditor)
+	w.draft("Old", "old", alice)
+	w.draft("Old", "old", bob)
+	w.draft("Fresh", "fresh", alice)
+
+	now := time.Now()
+	stale := now.Add(-draftMaxAge - time.Hour)
+	for _, owner := range []string{"user:alice", "user:bob"} {
+		if err := os.Chtimes(draftFilename(owner, "Old"), stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removeStaleDrafts(now); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(config.StoragePath, ".drafts", "*", "*.txt"))
+	if len(files) != 1 || filepath.Base(files[0]) != "Fresh.txt" {
+		t.Errorf("drafts left = %q, want only Fresh", files)
+	}
+}
+
+// Renaming a page carries every owner's draft along.
+func TestRenameMovesDrafts(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "saved")
+	alice := w.login("alice", roleEditor)
+	bob := w.login("bob", roleEditor)
+	w.draft("Home", "alice draft", alice)
+	w.draft("Home", "bob draft", bob)
+
+	resp, body := w.post("/rename/Home", url.Values{"newTitle": {"Start"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	for name, cookie := range map[string]*http.Cookie{"alice": alice, "bob": bob} {
+		if body := w.editBody("Start", cookie); !strings.Contains(body, name+" draft") {
+			t.Errorf("%s's draft did not follow the rename:\n%s", name, body)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+func TestEditViewDeleteFlow(t *testing.T) {
+	w := newTestWiki(t)
+
+	resp, body := w.get("/")
+	wantStatus(t, resp, body, http.StatusOK)
+
+	resp, body = w.get("/view/Home")
+	if resp.StatusCode != http.StatusFound || !strings.HasSuffix(resp.Header.Get("Location"), "/edit/Home") {
+		t.Fatalf("viewing a missing page = %d to %q, want a redirect to its edit form", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, body = w.get("/edit/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, `action="/save/Home"`) {
+		t.Errorf("edit form does not post to /save/Home:\n%s", body)
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"# Welcome\n\nSee [About](/view/About)."}, "summary": {"first"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if loc := resp.Header.Get("Location"); loc != "/view/Home" {
+		t.Errorf("save redirected to %q, want /view/Home", loc)
+	}
+
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "<h1") || !strings.Contains(body, "Welcome") || !strings.Contains(body, `href="/view/About"`) {
+		t.Errorf("view does not show the rendered page:\n%s", body)
+	}
+
+	resp, body = w.get("/raw/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != "# Welcome\n\nSee [About](/view/About)." {
+		t.Errorf("raw = %q", body)
+	}
+
+	resp, body = w.get("/")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "/view/Home") {
+		t.Errorf("index does not list the saved page:\n%s", body)
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"# Welcome back"}, "summary": {"second"}, "base": {"1"}})
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resp, body = w.get("/history/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "first") || !strings.Contains(body, "second") {
+		t.Errorf("history does not list both revisions:\n%s", body)
+	}
+
+	resp, body = w.get("/diff/Home?from=1&to=2")
+	wantStatus(t, resp, body, http.StatusOK)
+
+	resp, body = w.post("/delete/Home", w.csrf())
+	if resp.StatusCode >= 400 {
+		t.Fatalf("delete = %d\n%s", resp.StatusCode, body)
+	}
+	if _, err := os.Stat(filepath.Join(w.dir, "Home.txt")); !os.IsNotExist(err) {
+		t.Errorf("page file still exists after delete: %v", err)
+	}
+}
+
+func TestSaveRejectsInvalidInput(t *testing.T) {
+	w := newTestWiki(t)
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"binary\x00data"}})
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	resp, body = w.get("/raw/..%2F..%2Fetc%2Fpasswd")
+	if resp.StatusCode == http.StatusOK || strings.Contains(resp.Header.Get("Location"), "..") {
+		t.Errorf("raw of a traversal title = %d to %q\n%s", resp.StatusCode, resp.Header.Get("Location"), body)
+	}
+	if _, err := os.Stat(filepath.Join(w.dir, "Home.txt")); !os.IsNotExist(err) {
+		t.Errorf("invalid save wrote the page: %v", err)
+	}
+}
+
+func TestAnonymousEdit(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+
+	if _, body := w.get("/view/Home"); !strings.Contains(body, "/edit/Home") {
+		t.Errorf("anonymous editing on, view has no edit link:\n%s", body)
+	}
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"anonymous"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, body := w.get("/raw/Home"); body != "anonymous" {
+		t.Errorf("anonymous save = %q", body)
+	}
+}
+
+type authFunc func(username, password string) (string, error)
+
+func (f authFunc) Authenticate(username, password string) (string, error) {
+	return f(username, password)
+}
+
+func TestAnonymousEditDisabled(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	config.AllowAnonymousEdit = false
+
+	// Anonymous visitors get a read-only UI.
+	for _, path := range []string{"/view/Home", "/"} {
+		if _, body := w.get(path); strings.Contains(body, "/edit/") || strings.Contains(body, "/delete/") {
+			t.Errorf("%s offers editing to an anonymous visitor:\n%s", path, body)
+		}
+	}
+	for _, path := range []string{"/edit/Home", "/delete/Home"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusUnauthorized)
+	}
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"x"}})
+	wantStatus(t, resp, body, http.StatusUnauthorized)
+	resp, body = w.api(http.MethodPut, "/api/pages/Home", `{"body":"x"}`)
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous API save = %d\n%s", resp.StatusCode, body)
+	}
+	if _, body := w.get("/raw/Home"); body != "home" {
+		t.Errorf("rejected saves changed the page: %q", body)
+	}
+
+	// With an authenticator configured the edit form sends visitors to log in.
+	setGlobal(t, &authenticator, authBackend(authFunc(func(string, string) (string, error) {
+		return "", errInvalidCredentials
+	})))
+	resp, body = w.get("/edit/Home")
+	wantStatus(t, resp, body, http.StatusFound)
+	if loc := resp.Header.Get("Location"); loc != "/login" {
+		t.Errorf("edit redirects to %q, want /login", loc)
+	}
+
+	alice := w.login("alice", roleEditor)
+	if _, body := w.get("/view/Home", alice); !strings.Contains(body, "/edit/Home") {
+		t.Errorf("editor's view has no edit link:\n%s", body)
+	}
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"x"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+}
+
+func TestAPIPages(t *testing.T) {
+	w := newTestWiki(t)
+
+	resp, body := w.api(http.MethodPut, "/api/pages/Notes", `{"body":"some notes","summary":"api"}`, w.login("alice", roleEditor))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT = %d\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = w.get("/api/pages")
+	wantStatus(t, resp, body, http.StatusOK)
+	var list struct{ Pages []string }
+	if err := json.Unmarshal([]byte(body), &list); err != nil || len(list.Pages) != 1 || list.Pages[0] != "Notes" {
+		t.Errorf("page list = %s, %v", body, err)
+	}
+
+	resp, body = w.get("/api/pages/Notes")
+	wantStatus(t, resp, body, http.StatusOK)
+	var p struct{ Title, Body string }
+	if err := json.Unmarshal([]byte(body), &p); err != nil || p.Body != "some notes" {
+		t.Errorf("page = %s, %v", body, err)
+	}
+	if resp.Header.Get("ETag") == "" {
+		t.Error("page has no ETag")
+	}
+
+	resp, body = w.get("/api/pages/Missing")
+	wantStatus(t, resp, body, http.StatusNotFound)
+
+	resp, body = w.api(http.MethodDelete, "/api/pages", "")
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func TestSearch(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Deploy", "How we deploy the service to production.")
+	w.seed("Lunch", "Where to eat.")
+	rebuildIndexes()
+
+	resp, body := w.get("/search?q=deploy")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "/view/Deploy") || strings.Contains(body, "/view/Lunch") {
+		t.Errorf("search results:\n%s", body)
+	}
+
+	resp, body = w.get("/api/search?q=deploy")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, `"Deploy"`) {
+		t.Errorf("API search results: %s", body)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "first")
+
+	resp, body := w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if strings.Contains(body, "/undo/Home") {
+		t.Error("view offers undo for a page without a previous version")
+	}
+	resp, body = w.post("/undo/Home", w.csrf())
+	wantStatus(t, resp, body, http.StatusNotFound)
+
+	w.seed("Home", "second")
+	resp, body = w.get("/view/Home")
+	if !strings.Contains(body, "/undo/Home") {
+		t.Error("view does not offer undo after an edit")
+	}
+	resp, body = w.post("/undo/Home", w.csrf())
+	wantStatus(t, resp, body, http.StatusFound)
+	if loc := resp.Header.Get("Location"); loc != "/view/Home" {
+		t.Errorf("undo redirected to %q", loc)
+	}
+	if b, err := store.Read("Home"); err != nil || string(b) != "first" {
+		t.Errorf("page after undo = %q, %v, want the previous body", b, err)
+	}
+
+	// Undo goes back one level only.
+	resp, body = w.post("/undo/Home", w.csrf())
+	wantStatus(t, resp, body, http.StatusNotFound)
+	if b, _ := store.Read("Home"); string(b) != "first" {
+		t.Errorf("page after a second undo = %q", b)
+	}
+
+	revs, err := listRevisions("Home")
+	if err != nil || len(revs) != 3 || revs[0].Summary != "Undid last edit" {
+		t.Errorf("history after undo = %+v, %v", revs, err)
+	}
+
+	resp, body = w.post("/undo/Missing", w.csrf())
+	wantStatus(t, resp, body, http.StatusNotFound)
+}
+
+func TestDownload(t *testing.T) {
+	w := newTestWiki(t)
+	source := "# Notes\r\n\r\ntrailing spaces  \n\tтабы и ёлки\n\n"
+	resp, body := w.post("/save/Release-notes", url.Values{"title": {"Release notes"}, "body": {source}})
+	wantStatus(t, resp, body, http.StatusFound)
+	w.seed("Home", "home")
+
+	resp, body = w.get("/download/Release-notes")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != source {
+		t.Errorf("download = %q, want the stored bytes %q", body, source)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/markdown; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename="Release-notes.md"; filename*=UTF-8''Release%20notes.md` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	resp, body = w.get("/download/Home")
+	if body != "home" || resp.Header.Get("Content-Disposition") != `attachment; filename="Home.md"` {
+		t.Errorf("download = %q, Content-Disposition %q", body, resp.Header.Get("Content-Disposition"))
+	}
+
+	resp, _ = w.get("/raw/Home")
+	if resp.Header.Get("Content-Disposition") != "" {
+		t.Error("/raw is sent as an attachment")
+	}
+
+	resp, body = w.get("/download/Missing")
+	wantStatus(t, resp, body, http.StatusNotFound)
+}
+
+func TestHumanSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KB",
+		1536:            "1.5 KB",
+		1024*1024 - 1:   "1024.0 KB",
+		5 * 1024 * 1024: "5.0 MB",
+		3 << 30:         "3.0 GB",
+	} {
+		if got := (pageInfo{Size: size}).HumanSize(); got != want {
+			t.Errorf("HumanSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestIndexSizes(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Small", "tiny")
+	w.seed("Big", strings.Repeat("x", 3000))
+	w.seed("Mid", strings.Repeat("x", 1536))
+	w.seed("Same", "abcd")
+
+	order := func(body string) []string {
+		var titles []string
+		for _, m := range regexp.MustCompile(`<a href="/view/([^"]+)">`).FindAllStringSubmatch(body, -1) {
+			titles = append(titles, m[1])
+		}
+		return titles
+	}
+
+	resp, body := w.get("/")
+	wantStatus(t, resp, body, http.StatusOK)
+	for _, want := range []string{"<span>4 B</span>", "<span>2.9 KB</span>", "<span>1.5 KB</span>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("index lacks size %s:\n%s", want, body)
+		}
+	}
+	if got := order(body); !slices.Equal(got, []string{"Big", "Mid", "Same", "Small"}) {
+		t.Errorf("default order = %q", got)
+	}
+
+	// Largest first by default; equal sizes keep title order.
+	_, body = w.get("/?sort=size")
+	if got := order(body); !slices.Equal(got, []string{"Big", "Mid", "Same", "Small"}) {
+		t.Errorf("sort=size = %q", got)
+	}
+	_, body = w.get("/?sort=size&order=asc")
+	if got := order(body); !slices.Equal(got, []string{"Same", "Small", "Mid", "Big"}) {
+		t.Errorf("sort=size&order=asc = %q", got)
+	}
+	_, body = w.get("/?sort=size&per_page=2&page=2")
+	if got := order(body); !slices.Equal(got, []string{"Same", "Small"}) {
+		t.Errorf("second page by size = %q", got)
+	}
+}
+
+// With the page files in a directory of their own, saving, undo, delete,
+// restore and rename only work if every page access goes through the store.
+func TestPagesGoThroughStore(t *testing.T) {
+	w := newTestWiki(t)
+	pagesDir := t.TempDir()
+	store = storage.NewFileStore(pagesDir)
+	alice := w.login("alice", roleEditor)
+
+	for _, body := range []string{"first", "second"} {
+		resp, b := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {body}}, alice)
+		wantStatus(t, resp, b, http.StatusFound)
+	}
+	resp, body := w.post("/undo/Home", w.csrf(alice), alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, body := w.get("/raw/Home"); body != "first" {
+		t.Errorf("Home after undo = %q", body)
+	}
+
+	resp, body = w.post("/delete/Home", w.csrf(alice), alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	trashed, err := listTrash()
+	if err != nil || len(trashed) != 1 {
+		t.Fatalf("trash = %v, %v", trashed, err)
+	}
+	resp, body = w.post("/trash", url.Values{"name": {trashed[0].Name}, "action": {"restore"}}, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resp, body = w.post("/rename/Home", url.Values{"newTitle": {"Start"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, body := w.get("/raw/Start"); body != "first" {
+		t.Errorf("Start after rename = %q", body)
+	}
+
+	if titles, err := store.List(); err != nil || !slices.Equal(titles, []string{"Start"}) {
+		t.Errorf("pages in the store = %q, %v", titles, err)
+	}
+	if stray, _ := filepath.Glob(filepath.Join(w.dir, "*.txt")); len(stray) != 0 {
+		t.Errorf("page files written past the store: %q", stray)
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// pageEncoding is the encoding assumed for stored pages that are not valid
+// UTF-8, typically files imported from older systems. Nil means pages are
+// always read as UTF-8.
+var pageEncoding encoding.Encoding
+
+func setupPageEncoding() error {
+	name := os.Getenv("PAGE_ENCODING")
+	if name == "" {
+		return nil
+	}
+
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return fmt.Errorf("invalid PAGE_ENCODING: %w", err)
+	}
+	pageEncoding = enc
+
+	return nil
+}
+
+// lookupEncoding resolves names such as "windows-1251" or "koi8-r". UTF-8
+// resolves to nil because nothing needs decoding.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	if n, _ := htmlindex.Name(enc); n == "utf-8" {
+		return nil, nil
+	}
+
+	return enc, nil
+}
+
+// requestEncoding returns the encoding chosen with ?encoding= for this
+// request, falling back to PAGE_ENCODING.
+func requestEncoding(r *http.Request) (encoding.Encoding, error) {
+	name := r.FormValue("encoding")
+	if name == "" {
+		return pageEncoding, nil
+	}
+
+	return lookupEncoding(name)
+}
+
+// toUTF8 decodes body from enc unless it is already valid UTF-8, so pages
+// that were saved through the wiki are never transcoded twice.
+func toUTF8(body []byte, enc encoding.Encoding) ([]byte, error) {
+	if enc == nil || utf8.Valid(body) {
+		return body, nil
+	}
+
+	return enc.NewDecoder().Bytes(body)
+}
+
+// utf8Prefix reports whether b is valid UTF-8, ignoring a rune cut off at
+// the end.
+func utf8Prefix(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0 && !utf8.Valid(b); i++ {
+		b = b[:len(b)-1]
+	}
+
+	return utf8.Valid(b)
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const cyrillicText = "# Привет\n\nЁжик в тумане, №1."
+
+func windows1251(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := charmap.Windows1251.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestLookupEncoding(t *testing.T) {
+	for _, name := range []string{"windows-1251", "cp1251", "KOI8-R"} {
+		if enc, err := lookupEncoding(name); err != nil || enc == nil {
+			t.Errorf("lookupEncoding(%q) = %v, %v", name, enc, err)
+		}
+	}
+	for _, name := range []string{"utf-8", "UTF8"} {
+		if enc, err := lookupEncoding(name); err != nil || enc != nil {
+			t.Errorf("lookupEncoding(%q) = %v, %v, want no decoding", name, enc, err)
+		}
+	}
+	if _, err := lookupEncoding("latin-42"); err == nil {
+		t.Error("unknown encoding accepted")
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	got, err := toUTF8(windows1251(t, cyrillicText), charmap.Windows1251)
+	if err != nil || string(got) != cyrillicText {
+		t.Errorf("toUTF8 = %q, %v", got, err)
+	}
+	// Pages already in UTF-8 are left alone.
+	if got, _ := toUTF8([]byte(cyrillicText), charmap.Windows1251); string(got) != cyrillicText {
+		t.Errorf("UTF-8 page transcoded to %q", got)
+	}
+	raw := windows1251(t, cyrillicText)
+	if got, _ := toUTF8(raw, nil); string(got) != string(raw) {
+		t.Error("page transcoded without an encoding")
+	}
+}
+
+func TestWindows1251Pages(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &pageEncoding, nil)
+	if err := store.Write("Legacy", windows1251(t, cyrillicText)); err != nil {
+		t.Fatal(err)
+	}
+	w.seed("Modern", cyrillicText)
+
+	resp, body := w.get("/raw/Legacy?encoding=windows-1251")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != cyrillicText {
+		t.Errorf("?encoding=windows-1251 raw = %q", body)
+	}
+	resp, body = w.get("/raw/Legacy?encoding=latin-42")
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	t.Setenv("PAGE_ENCODING", "windows-1251")
+	if err := setupPageEncoding(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/view/Legacy", "/edit/Legacy", "/view/Modern"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusOK)
+		if !strings.Contains(body, "Ёжик в тумане, №1.") {
+			t.Errorf("%s does not show the decoded text:\n%s", path, body)
+		}
+	}
+	for _, title := range []string{"Legacy", "Modern"} {
+		if _, body := w.get("/raw/" + title); body != cyrillicText {
+			t.Errorf("/raw/%s = %q", title, body)
+		}
+	}
+	// ?encoding=utf-8 turns decoding off for one request.
+	if _, body := w.get("/raw/Legacy?encoding=utf-8"); body != string(windows1251(t, cyrillicText)) {
+		t.Errorf("/raw/Legacy?encoding=utf-8 = %q", body)
+	}
+
+	t.Setenv("PAGE_ENCODING", "latin-42")
+	if err := setupPageEncoding(); err == nil {
+		t.Error("PAGE_ENCODING=latin-42 accepted")
+	}
+}
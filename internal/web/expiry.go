@@ -0,0 +1,85 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func expiryInterval() (time.Duration, error) {
+	raw := os.Getenv("EXPIRY_INTERVAL")
+	if raw == "" {
+		return time.Minute, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid EXPIRY_INTERVAL %q", raw)
+	}
+
+	return d, nil
+}
+
+func runExpiryJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if readOnly.Load() {
+			continue
+		}
+		if err := removeExpiredPages(now); err != nil {
+			slog.Error("error removing expired pages", "err", err)
+		}
+	}
+}
+
+// retentionInterval is how often stale drafts and old trash are cleaned up.
+// It runs apart from the expiry janitor, so EXPIRY_INTERVAL=0 leaves
+// TRASH_RETENTION in force.
+const retentionInterval = time.Hour
+
+func runRetentionJanitor() {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if readOnly.Load() {
+			continue
+		}
+		if err := removeStaleDrafts(now); err != nil {
+			slog.Error("error removing stale drafts", "err", err)
+		}
+		if err := purgeTrash(now); err != nil {
+			slog.Error("error purging trash", "err", err)
+		}
+	}
+}
+
+func removeExpiredPages(now time.Time) error {
+	titles, err := listPages()
+	if err != nil {
+		return err
+	}
+
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+
+		expires, ok := p.ExpiresAt()
+		if !ok || now.Before(expires) {
+			continue
+		}
+
+		if err := deletePage(p); err != nil {
+			slog.Error("error removing expired page", "title", title, "err", err)
+			continue
+		}
+		slog.Info("removed expired page", "title", title, "expired", expires)
+	}
+
+	return nil
+}
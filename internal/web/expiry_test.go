@@ -0,0 +1,57 @@
+package web
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRemoveExpiredPages(t *testing.T) {
+	w := newTestWiki(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	w.seed("Expired", "---\nexpires: 2026-03-01 11:59\n---\ngone")
+	w.seed("DueNow", "---\nexpires: 2026-03-01 12:00\n---\ngone")
+	w.seed("Later", "---\nexpires: 2026-03-02\n---\nstays")
+	w.seed("Forever", "stays")
+	w.seed("Unreadable", "---\nexpires: soon\n---\nstays")
+
+	if err := removeExpiredPages(now); err != nil {
+		t.Fatal(err)
+	}
+	titles, err := listPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Forever", "Later", "Unreadable"}; !slices.Equal(titles, want) {
+		t.Errorf("pages = %q, want %q", titles, want)
+	}
+
+	// Expired pages go to the trash like any deleted page.
+	trashed, err := listTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inTrash []string
+	for _, p := range trashed {
+		inTrash = append(inTrash, p.Title)
+	}
+	slices.Sort(inTrash)
+	if !slices.Equal(inTrash, []string{"DueNow", "Expired"}) {
+		t.Errorf("trash = %q", inTrash)
+	}
+}
+
+func TestExpiryInterval(t *testing.T) {
+	for raw, want := range map[string]time.Duration{"": time.Minute, "30s": 30 * time.Second, "0": 0} {
+		t.Setenv("EXPIRY_INTERVAL", raw)
+		if d, err := expiryInterval(); err != nil || d != want {
+			t.Errorf("EXPIRY_INTERVAL=%q = %v, %v, want %v", raw, d, err, want)
+		}
+	}
+	for _, raw := range []string{"hourly", "-1m"} {
+		t.Setenv("EXPIRY_INTERVAL", raw)
+		if _, err := expiryInterval(); err == nil {
+			t.Errorf("EXPIRY_INTERVAL=%q accepted", raw)
+		}
+	}
+}
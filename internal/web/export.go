@@ -0,0 +1,220 @@
+package web
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const exportZipUsage = "usage: gowiki export zip [--tag=<tag>] [--prefix=<prefix>] <file>"
+
+var titlePrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// exportFilter selects the pages of an export. Both set means a page has to
+// match both.
+type exportFilter struct {
+	Tag    string `json:"tag,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+func parseExportFilter(tag, prefix string) (exportFilter, error) {
+	f := exportFilter{Tag: strings.ToLower(strings.TrimSpace(tag)), Prefix: strings.TrimSpace(prefix)}
+	if strings.ContainsAny(f.Tag, ",[]\"'") {
+		return exportFilter{}, fmt.Errorf("invalid tag %q", tag)
+	}
+	if f.Prefix != "" && !titlePrefixPattern.MatchString(f.Prefix) {
+		return exportFilter{}, fmt.Errorf("invalid prefix %q", prefix)
+	}
+
+	return f, nil
+}
+
+func (f exportFilter) empty() bool {
+	return f == exportFilter{}
+}
+
+func (f exportFilter) apply(infos []pageInfo) []pageInfo {
+	if f.empty() {
+		return infos
+	}
+
+	var out []pageInfo
+	for _, info := range infos {
+		if f.Prefix != "" && !strings.HasPrefix(info.Title, f.Prefix) {
+			continue
+		}
+		if f.Tag != "" && !slices.Contains(pageTags.of(info.Title), f.Tag) {
+			continue
+		}
+		out = append(out, info)
+	}
+
+	return out
+}
+
+type exportManifest struct {
+	Exported time.Time     `json:"exported"`
+	Filter   *exportFilter `json:"filter,omitempty"`
+	Pages    []string      `json:"pages"`
+}
+
+// writeExport writes the pages and a manifest.json to a zip archive. flush
+// runs after every page.
+func writeExport(w io.Writer, infos []pageInfo, filter exportFilter, flush func()) error {
+	manifest := exportManifest{Exported: time.Now().UTC(), Pages: []string{}}
+	if !filter.empty() {
+		manifest.Filter = &filter
+	}
+
+	// zw.Flush alone leaves the page's compressed data in the deflate
+	// writer until the next entry starts.
+	zw := zip.NewWriter(w)
+	var deflate *flate.Writer
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		var err error
+		deflate, err = flate.NewWriter(out, flate.DefaultCompression)
+		return deflate, err
+	})
+	for _, info := range infos {
+		if err := exportPage(zw, info); err != nil {
+			return fmt.Errorf("%s: %w", info.Title, err)
+		}
+		if err := deflate.Flush(); err != nil {
+			return err
+		}
+		if err := zw.Flush(); err != nil {
+			return err
+		}
+		manifest.Pages = append(manifest.Pages, info.Title)
+		flush()
+	}
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// exportHandler streams the pages as a zip archive, all of them or those
+// selected by ?tag= and ?prefix=. Headers are flushed before the first page
+// and the archive after each one, so nothing is buffered for the whole wiki
+// and clients can show progress.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExportFilter(r.FormValue("tag"), r.FormValue("prefix"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	infos, err := listPageInfos()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos = filter.apply(infos)
+
+	name := "wiki-" + time.Now().Format("20060102") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if len(infos) == 0 {
+		w.Header().Set("X-Export-Warning", "no pages match the filter")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	if err := writeExport(w, infos, filter, flush); err != nil {
+		// The status line is already sent; all we can do is cut the
+		// archive short so the client sees it as corrupt.
+		slog.Error("error exporting pages", "err", err)
+	}
+}
+
+func exportZip(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export zip", flag.ContinueOnError)
+	flags.SetOutput(out)
+	tag := flags.String("tag", "", "only export pages with this tag")
+	prefix := flags.String("prefix", "", "only export pages whose title starts with this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(exportZipUsage)
+	}
+
+	filter, err := parseExportFilter(*tag, *prefix)
+	if err != nil {
+		return err
+	}
+	if filter.Tag != "" {
+		pageTags.rebuild()
+	}
+
+	infos, err := listPageInfos()
+	if err != nil {
+		return err
+	}
+	infos = filter.apply(infos)
+
+	f, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, infos, filter, func() {}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if len(infos) == 0 {
+		fmt.Fprintln(out, "warning: no pages match the filter")
+	}
+	fmt.Fprintf(out, "exported %d pages to %s\n", len(infos), flags.Arg(0))
+	return nil
+}
+
+func exportPage(zw *zip.Writer, info pageInfo) error {
+	body, _, err := openPage(info.Title, pageEncoding)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     info.Title + ".md",
+		Method:   zip.Deflate,
+		Modified: info.Modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, body)
+	return err
+}
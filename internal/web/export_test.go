@@ -0,0 +1,234 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+// blockingStore holds up opening one page until release is closed.
+type blockingStore struct {
+	storage.Storage
+	title   string
+	opened  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Open(title string) (storage.File, error) {
+	if title == s.title {
+		close(s.opened)
+		<-s.release
+	}
+	return s.Storage.Open(title)
+}
+
+func readExport(t *testing.T, b []byte) (map[string]string, exportManifest) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("export is not a valid zip: %v", err)
+	}
+	files := map[string]string{}
+	var manifest exportManifest
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if f.Name == "manifest.json" {
+			if err := json.Unmarshal(content, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		files[f.Name] = string(content)
+	}
+	return files, manifest
+}
+
+func TestExportStreams(t *testing.T) {
+	w := newTestWiki(t)
+	alpha := strings.Repeat("Alpha page, streamed before Zeta is read.\n", 200)
+	w.seed("Alpha", alpha)
+	w.seed("Zeta", "last page")
+
+	blocking := &blockingStore{Storage: store, title: "Zeta", opened: make(chan struct{}), release: make(chan struct{})}
+	store = blocking
+	var release sync.Once
+	t.Cleanup(func() { release.Do(func() { close(blocking.release) }) })
+
+	resp, err := w.Client().Get(w.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The headers and Alpha arrive while the export is stuck on Zeta.
+	select {
+	case <-blocking.opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export never reached the second page")
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" ||
+		!regexp.MustCompile(`^attachment; filename="wiki-\d{8}\.zip"$`).MatchString(resp.Header.Get("Content-Disposition")) {
+		t.Errorf("export headers: %d %v", resp.StatusCode, resp.Header)
+	}
+	first := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := io.ReadAtLeast(resp.Body, buf, len(buf))
+		first <- buf[:n]
+	}()
+	var head []byte
+	select {
+	case head = <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no part of the archive arrived before the export finished")
+	}
+	if !bytes.HasPrefix(head, []byte("PK\x03\x04")) || !bytes.Contains(head, []byte("Alpha.md")) {
+		t.Errorf("archive starts with %q", head)
+	}
+
+	release.Do(func() { close(blocking.release) })
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, manifest := readExport(t, append(head, rest...))
+	if files["Alpha.md"] != alpha || files["Zeta.md"] != "last page" || len(files) != 2 {
+		t.Errorf("exported files: %q", slices.Sorted(maps.Keys(files)))
+	}
+	if !slices.Equal(manifest.Pages, []string{"Alpha", "Zeta"}) || manifest.Filter != nil {
+		t.Errorf("manifest = %+v", manifest)
+	}
+}
+
+func TestParseExportFilter(t *testing.T) {
+	for _, tt := range []struct {
+		tag, prefix string
+		want        exportFilter
+	}{
+		{"", "", exportFilter{}},
+		{" Docs ", "", exportFilter{Tag: "docs"}},
+		{"how to", "Team-", exportFilter{Tag: "how to", Prefix: "Team-"}},
+	} {
+		if got, err := parseExportFilter(tt.tag, tt.prefix); err != nil || got != tt.want {
+			t.Errorf("parseExportFilter(%q, %q) = %+v, %v, want %+v", tt.tag, tt.prefix, got, err, tt.want)
+		}
+	}
+	for _, tt := range [][2]string{{"a,b", ""}, {"[docs]", ""}, {`"docs"`, ""}, {"", "../x"}, {"", "Team/"}, {"", "a b"}} {
+		if f, err := parseExportFilter(tt[0], tt[1]); err == nil {
+			t.Errorf("parseExportFilter(%q, %q) = %+v, want an error", tt[0], tt[1], f)
+		}
+	}
+}
+
+func seedExportFilterPages(w *testWiki) {
+	w.seed("Team-Docs", "---\ntags: [Docs]\n---\ndocs")
+	w.seed("Team-Notes", "---\ntags: notes\n---\nnotes")
+	w.seed("Other-Docs", "---\ntags: docs, misc\n---\nother")
+	w.seed("Team", "no tags")
+	rebuildIndexes()
+}
+
+func TestExportFilters(t *testing.T) {
+	w := newTestWiki(t)
+	seedExportFilterPages(w)
+
+	for _, tt := range []struct {
+		query  string
+		want   []string
+		filter *exportFilter
+	}{
+		{"", []string{"Other-Docs", "Team", "Team-Docs", "Team-Notes"}, nil},
+		{"?tag=DOCS", []string{"Other-Docs", "Team-Docs"}, &exportFilter{Tag: "docs"}},
+		{"?prefix=Team-", []string{"Team-Docs", "Team-Notes"}, &exportFilter{Prefix: "Team-"}},
+		{"?tag=docs&prefix=Team", []string{"Team-Docs"}, &exportFilter{Tag: "docs", Prefix: "Team"}},
+	} {
+		resp, body := w.get("/export" + tt.query)
+		wantStatus(t, resp, body, http.StatusOK)
+		if resp.Header.Get("X-Export-Warning") != "" {
+			t.Errorf("%s: warning %q", tt.query, resp.Header.Get("X-Export-Warning"))
+		}
+		files, manifest := readExport(t, []byte(body))
+		var want []string
+		for _, title := range tt.want {
+			want = append(want, title+".md")
+		}
+		slices.Sort(want)
+		if got := slices.Sorted(maps.Keys(files)); !slices.Equal(got, want) || !slices.Equal(manifest.Pages, tt.want) {
+			t.Errorf("export%s has %v, manifest %v, want %v", tt.query, got, manifest.Pages, tt.want)
+		}
+		if (manifest.Filter == nil) != (tt.filter == nil) || tt.filter != nil && *manifest.Filter != *tt.filter {
+			t.Errorf("export%s manifest filter = %+v, want %+v", tt.query, manifest.Filter, tt.filter)
+		}
+	}
+
+	// Nothing matching is still a valid, empty archive.
+	resp, body := w.get("/export?tag=notes&prefix=Other")
+	wantStatus(t, resp, body, http.StatusOK)
+	if resp.Header.Get("X-Export-Warning") != "no pages match the filter" {
+		t.Errorf("empty export warning = %q", resp.Header.Get("X-Export-Warning"))
+	}
+	if files, manifest := readExport(t, []byte(body)); len(files) != 0 || len(manifest.Pages) != 0 {
+		t.Errorf("empty export has %v, manifest %v", files, manifest.Pages)
+	}
+
+	resp, body = w.get("/export?prefix=../x")
+	wantStatus(t, resp, body, http.StatusBadRequest)
+}
+
+func TestExportZipCommand(t *testing.T) {
+	w := newTestWiki(t)
+	seedExportFilterPages(w)
+	// The command registers the save hooks again; keep them to this test.
+	withHooks(t, func(*hookRegistry) {})
+
+	fn := filepath.Join(t.TempDir(), "docs.zip")
+	var out bytes.Buffer
+	if err := Export(config, store, []string{"zip", "--tag=docs", "--prefix=Team", fn}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "exported 1 pages to "+fn+"\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, manifest := readExport(t, b); len(files) != 1 || files["Team-Docs.md"] == "" || manifest.Filter == nil || manifest.Filter.Tag != "docs" {
+		t.Errorf("archive has %v, filter %+v", slices.Collect(maps.Keys(files)), manifest.Filter)
+	}
+
+	out.Reset()
+	if err := Export(config, store, []string{"zip", "--prefix=Nothing", fn}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "warning: no pages match the filter\nexported 0 pages") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	for _, args := range [][]string{{"zip"}, {"zip", "--tag=a,b", fn}, {"zip", "a.zip", "b.zip"}} {
+		if err := Export(config, store, args, &out); err == nil {
+			t.Errorf("export %q succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+const exportUsage = "usage: gowiki export hugo [--since=<time>] <outdir>\n       gowiki export zip [--tag=<tag>] [--prefix=<prefix>] <file>"
+
+var markdownLinkPattern = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// Export runs the "gowiki export" command with the given settings, reading
+// pages from st and writing the report to out.
+func Export(cfg Config, st storage.Storage, args []string, out io.Writer) error {
+	config = cfg
+	store = st
+	if err := setupCommandHooks(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New(exportUsage)
+	}
+
+	switch args[0] {
+	case "hugo":
+		return exportHugo(args[1:], out)
+	case "zip":
+		return exportZip(args[1:], out)
+	default:
+		return fmt.Errorf("unknown export format %q\n%s", args[0], exportUsage)
+	}
+}
+
+func parseSince(raw string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since %q, expected RFC 3339 or YYYY-MM-DD", raw)
+}
+
+// exportHugo writes every page to <outdir>/content/<Title>.md with Hugo front
+// matter. The wiki has no attachments, so nothing is written under static/.
+func exportHugo(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export hugo", flag.ContinueOnError)
+	flags.SetOutput(out)
+	sinceFlag := flags.String("since", "", "only export pages modified after this time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(exportUsage)
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		t, err := parseSince(*sinceFlag)
+		if err != nil {
+			return err
+		}
+		since = t
+	}
+
+	infos, err := scanPageInfos()
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		exists[info.Title] = true
+	}
+
+	dir := filepath.Join(flags.Arg(0), "content")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	exported := 0
+	for _, info := range infos {
+		if !info.Modified.After(since) {
+			continue
+		}
+
+		p, err := loadPage(info.Title)
+		if err != nil {
+			fmt.Fprintf(out, "failed %s: %v\n", info.Title, err)
+			continue
+		}
+
+		content := hugoFrontMatter(info, page.ParseFrontMatter(p.Body)) +
+			rewriteHugoLinks(string(page.StripFrontMatter(p.Body)), func(title string) bool { return exists[title] })
+		if err := os.WriteFile(filepath.Join(dir, info.Title+".md"), []byte(content), 0640); err != nil {
+			return err
+		}
+		exported++
+	}
+
+	fmt.Fprintf(out, "exported %d of %d pages to %s\n", exported, len(infos), dir)
+	return nil
+}
+
+func hugoFrontMatter(info pageInfo, meta map[string]string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("title: " + strconv.Quote(info.Title) + "\n")
+	b.WriteString("date: " + info.Modified.UTC().Format(time.RFC3339) + "\n")
+
+	if tags := parseTags(meta["tags"]); len(tags) > 0 {
+		quoted := make([]string, len(tags))
+		for i, tag := range tags {
+			quoted[i] = strconv.Quote(tag)
+		}
+		b.WriteString("tags: [" + strings.Join(quoted, ", ") + "]\n")
+	}
+
+	draft, _ := strconv.ParseBool(meta["draft"])
+	b.WriteString("draft: " + strconv.FormatBool(draft) + "\n")
+	b.WriteString("---\n")
+
+	return b.String()
+}
+
+// rewriteHugoLinks points links between pages at Hugo's relref shortcode.
+// Links to pages that do not exist would fail the Hugo build, so they are
+// replaced by their text.
+func rewriteHugoLinks(body string, exists func(title string) bool) string {
+	return markdownLinkPattern.ReplaceAllStringFunc(body, func(m string) string {
+		parts := markdownLinkPattern.FindStringSubmatch(m)
+		if parts[1] == "!" {
+			return m
+		}
+
+		dest, fragment, _ := strings.Cut(parts[3], "#")
+		title, ok := linkTarget(dest)
+		if !ok {
+			return m
+		}
+		if !exists(title) {
+			return parts[2]
+		}
+
+		ref := title
+		if fragment != "" {
+			ref += "#" + fragment
+		}
+		return "[" + parts[2] + `]({{< relref "` + ref + `" >}})`
+	})
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewriteHugoLinks(t *testing.T) {
+	exists := func(title string) bool {
+		return title == "Home" || title == "Team-Docs" || title == "Team-Docs-Setup"
+	}
+	for in, want := range map[string]string{
+		"[home](/view/Home)":                   `[home]({{< relref "Home" >}})`,
+		"[docs](Team-Docs) and [x](Home)":      `[docs]({{< relref "Team-Docs" >}}) and [x]({{< relref "Home" >}})`,
+		"[setup](/view/Team-Docs-Setup#step)":  `[setup]({{< relref "Team-Docs-Setup#step" >}})`,
+		"[old](/view/Home?rev=2)":              `[old]({{< relref "Home" >}})`,
+		"[gone](/view/Team-Docs-Missing)":      "gone",
+		"see [gone](Missing#top).":             "see gone.",
+		"![logo](/view/Home)":                  "![logo](/view/Home)",
+		"[ext](https://example.com/view/Home)": "[ext](https://example.com/view/Home)",
+		"[nested](/view/Team/Docs)":            "[nested](/view/Team/Docs)",
+		"[style](/static/style.css)":           "[style](/static/style.css)",
+	} {
+		if got := rewriteHugoLinks(in, exists); got != want {
+			t.Errorf("rewriteHugoLinks(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportHugo(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "---\ntags: [Docs, \"how to\"]\n---\n# Home\nSee [setup](Team-Docs-Setup) and [gone](Missing).\n")
+	w.seed("Team-Docs-Setup", "---\ndraft: true\n---\nBack [home](/view/Home).\n")
+	w.seed("Old", "old")
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	touchPage(t, "Old", old)
+
+	outdir := t.TempDir()
+	var out bytes.Buffer
+	if err := exportHugo([]string{outdir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "exported 3 of 3 pages") {
+		t.Errorf("report: %s", out.String())
+	}
+
+	read := func(title string) string {
+		b, err := os.ReadFile(filepath.Join(outdir, "content", title+".md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	home := read("Home")
+	if !strings.HasPrefix(home, "---\ntitle: \"Home\"\ndate: ") ||
+		!strings.Contains(home, "tags: [\"Docs\", \"how to\"]\ndraft: false\n---\n# Home\n") ||
+		!strings.Contains(home, `See [setup]({{< relref "Team-Docs-Setup" >}}) and gone.`) {
+		t.Errorf("Home.md:\n%s", home)
+	}
+	if setup := read("Team-Docs-Setup"); !strings.Contains(setup, "draft: true\n---\nBack [home]({{< relref \"Home\" >}}).") {
+		t.Errorf("Team-Docs-Setup.md:\n%s", setup)
+	}
+	if got := read("Old"); !strings.Contains(got, "date: 2020-01-02T03:04:05Z\n") {
+		t.Errorf("Old.md:\n%s", got)
+	}
+
+	// --since leaves out the pages that did not change.
+	outdir = t.TempDir()
+	out.Reset()
+	if err := exportHugo([]string{"--since=2021-01-01", outdir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "content", "Old.md")); !os.IsNotExist(err) {
+		t.Errorf("--since exported Old: %v", err)
+	}
+	if !strings.Contains(out.String(), "exported 2 of 3 pages") {
+		t.Errorf("--since report: %s", out.String())
+	}
+
+	for _, args := range [][]string{{"--since=yesterday", outdir}, {}, {"a", "b"}} {
+		if err := exportHugo(args, &out); err == nil {
+			t.Errorf("export hugo %q accepted", args)
+		}
+	}
+}
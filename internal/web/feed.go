@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+// feedEntries is how many recent changes the Atom feed lists.
+const feedEntries = 50
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Author  string   `xml:"author>name"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v any) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+// sitemapHandler lists every page with the absolute URL of its view, from
+// BASE_URL when it is set.
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	infos, err := listPageInfos()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sm := sitemap{URLs: make([]sitemapURL, 0, len(infos))}
+	for _, info := range infos {
+		sm.URLs = append(sm.URLs, sitemapURL{
+			Loc:     requestURL(r, "/view/"+info.Title),
+			LastMod: info.Modified.UTC().Format(time.RFC3339),
+		})
+	}
+
+	writeXML(w, "application/xml; charset=utf-8", sm)
+}
+
+// feedHandler serves the recent changes as an Atom feed, without minor edits
+// for ?hideminor=1.
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	hideMinor := r.FormValue("hideminor") == "1"
+	changes, err := recentChanges(feedEntries, hideMinor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	self := requestURL(r, "/feed.xml")
+	if hideMinor {
+		self += "?hideminor=1"
+	}
+	updated := time.Now()
+	if len(changes) > 0 {
+		updated = changes[0].Modified
+	}
+	feed := atomFeed{
+		Title:   "Recent changes",
+		ID:      requestURL(r, "/"),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: self, Rel: "self"},
+			{Href: requestURL(r, "/changelog"), Rel: "alternate"},
+		},
+	}
+	for _, c := range changes {
+		author := c.Editor
+		if author == "" {
+			author = "anonymous"
+		}
+		link := requestURL(r, "/view/"+c.Title)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   c.Title,
+			Link:    atomLink{Href: link},
+			ID:      link + "#" + c.Modified.UTC().Format(time.RFC3339Nano),
+			Updated: c.Modified.UTC().Format(time.RFC3339),
+			Author:  author,
+			Summary: c.Summary,
+		})
+	}
+
+	writeXML(w, "application/atom+xml; charset=utf-8", feed)
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readSitemap(t *testing.T, w *testWiki, path string) []string {
+	t.Helper()
+	resp, body := w.get(path)
+	wantStatus(t, resp, body, http.StatusOK)
+	var sm sitemap
+	if err := xml.Unmarshal([]byte(body), &sm); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	var locs []string
+	for _, u := range sm.URLs {
+		locs = append(locs, u.Loc)
+	}
+	slices.Sort(locs)
+	return locs
+}
+
+func readFeed(t *testing.T, w *testWiki, path string) atomFeed {
+	t.Helper()
+	resp, body := w.get(path)
+	wantStatus(t, resp, body, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("feed Content-Type = %q", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal([]byte(body), &feed); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	return feed
+}
+
+func TestSitemapAndFeedBaseURL(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.seed("Notes", "notes")
+	resetState(t)
+
+	// Without BASE_URL the URLs follow the request.
+	if got := readSitemap(t, w, "/sitemap.xml"); !slices.Equal(got, []string{w.URL + "/view/Home", w.URL + "/view/Notes"}) {
+		t.Errorf("sitemap locs = %q", got)
+	}
+
+	setBaseURL(t, "https://wiki.example.com")
+	if got := readSitemap(t, w, "/sitemap.xml"); !slices.Equal(got, []string{"https://wiki.example.com/view/Home", "https://wiki.example.com/view/Notes"}) {
+		t.Errorf("sitemap locs = %q", got)
+	}
+
+	feed := readFeed(t, w, "/feed.xml")
+	if feed.ID != "https://wiki.example.com/" || len(feed.Links) != 2 || feed.Links[0].Href != "https://wiki.example.com/feed.xml" {
+		t.Errorf("feed = %+v", feed)
+	}
+	var links []string
+	for _, e := range feed.Entries {
+		links = append(links, e.Link.Href)
+	}
+	slices.Sort(links)
+	if !slices.Equal(links, []string{"https://wiki.example.com/view/Home", "https://wiki.example.com/view/Notes"}) {
+		t.Errorf("feed links = %q", links)
+	}
+
+	_, body := w.get("/view/Home")
+	if !strings.Contains(body, `<meta property="og:url" content="https://wiki.example.com/view/Home">`) ||
+		!strings.Contains(body, `<meta property="og:title" content="View Home">`) {
+		t.Errorf("view lacks OpenGraph tags:\n%s", body)
+	}
+	if _, body := w.get("/edit/Home"); strings.Contains(body, "og:url") {
+		t.Errorf("edit form has OpenGraph tags:\n%s", body)
+	}
+}
+
+func TestSitemapAndFeedBasePath(t *testing.T) {
+	w := mountAt(t, "/wiki")
+	setBaseURL(t, "https://example.com")
+	w.seed("Home", "home")
+	resetState(t)
+
+	if got := readSitemap(t, w, "/wiki/sitemap.xml"); !slices.Equal(got, []string{"https://example.com/wiki/view/Home"}) {
+		t.Errorf("sitemap locs = %q", got)
+	}
+	if feed := readFeed(t, w, "/wiki/feed.xml"); len(feed.Entries) != 1 || feed.Entries[0].Link.Href != "https://example.com/wiki/view/Home" {
+		t.Errorf("feed entries = %+v", feed.Entries)
+	}
+}
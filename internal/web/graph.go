@@ -0,0 +1,233 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const defaultGraphMaxNodes = 2000
+
+// graphMaxNodes refuses graphs that would be too large to send or draw.
+var graphMaxNodes = defaultGraphMaxNodes
+
+type graphNode struct {
+	Title string `json:"title"`
+	Size  int64  `json:"size"`
+	Views int64  `json:"views"`
+}
+
+// graphEdge points from the linking page to the linked one. Type is always
+// "link" for now; it is part of the shape so other kinds can be added.
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+type linkGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func setupGraph() error {
+	raw := os.Getenv("GRAPH_MAX_NODES")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid GRAPH_MAX_NODES %q", raw)
+	}
+	graphMaxNodes = n
+
+	return nil
+}
+
+func (v *viewCounter) count(title string) int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.totals[title] + v.pending[title]
+}
+
+// buildGraph returns every page and the links between them from the link
+// index. With a root, only pages at most depth links away, in either
+// direction, are included.
+func buildGraph(root string, depth int) linkGraph {
+	infos, _ := listPageInfos()
+	sizes := make(map[string]int64, len(infos))
+	for _, info := range infos {
+		sizes[info.Title] = info.Size
+	}
+
+	pageLinks.mu.RLock()
+	links := make(map[string][]string, len(pageLinks.links))
+	for source, targets := range pageLinks.links {
+		links[source] = slices.Clone(targets)
+	}
+	pageLinks.mu.RUnlock()
+
+	include := map[string]bool{}
+	if root == "" {
+		for title := range sizes {
+			include[title] = true
+		}
+	} else {
+		neighbours := map[string][]string{}
+		for source, targets := range links {
+			for _, target := range targets {
+				neighbours[source] = append(neighbours[source], target)
+				neighbours[target] = append(neighbours[target], source)
+			}
+		}
+
+		include[root] = true
+		frontier := []string{root}
+		for i := 0; i < depth && len(frontier) > 0; i++ {
+			var next []string
+			for _, title := range frontier {
+				for _, n := range neighbours[title] {
+					if _, ok := sizes[n]; ok && !include[n] {
+						include[n] = true
+						next = append(next, n)
+					}
+				}
+			}
+			frontier = next
+		}
+	}
+
+	g := linkGraph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	for title := range include {
+		g.Nodes = append(g.Nodes, graphNode{Title: title, Size: sizes[title], Views: views.count(title)})
+		for _, target := range links[title] {
+			if include[target] {
+				g.Edges = append(g.Edges, graphEdge{Source: title, Target: target, Type: "link"})
+			}
+		}
+	}
+	slices.SortFunc(g.Nodes, func(a, b graphNode) int { return strings.Compare(a.Title, b.Title) })
+	slices.SortFunc(g.Edges, func(a, b graphEdge) int {
+		if c := strings.Compare(a.Source, b.Source); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Target, b.Target)
+	})
+
+	return g
+}
+
+func apiGraphHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	format := r.FormValue("format")
+	if format != "" && format != "json" && format != "graphml" {
+		writeAPIError(w, http.StatusBadRequest, "format must be json or graphml")
+		return
+	}
+
+	root, depth := r.FormValue("root"), 1
+	if root != "" {
+		if !validTitle(root) {
+			writeAPIError(w, http.StatusBadRequest, "invalid root")
+			return
+		}
+		if _, err := statPage(root); err != nil {
+			writeAPIError(w, http.StatusNotFound, "page not found")
+			return
+		}
+	}
+	if raw := r.FormValue("depth"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "depth must not be negative")
+			return
+		}
+		depth = n
+	}
+
+	g := buildGraph(root, depth)
+	if len(g.Nodes) > graphMaxNodes {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("graph has %d nodes, more than the limit of %d; use root and depth to export a part of it", len(g.Nodes), graphMaxNodes))
+		return
+	}
+
+	if format == "graphml" {
+		writeGraphML(w, g)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func writeGraphML(w http.ResponseWriter, g linkGraph) {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "size", For: "node", Name: "size", Type: "long"},
+			{ID: "views", For: "node", Name: "views", Type: "long"},
+			{ID: "type", For: "edge", Name: "type", Type: "string"},
+		},
+	}
+	doc.Graph.ID = "wiki"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.Title, Data: []graphMLData{
+			{Key: "size", Value: strconv.FormatInt(n.Size, 10)},
+			{Key: "views", Value: strconv.FormatInt(n.Views, 10)},
+		}})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.Source, Target: e.Target, Data: []graphMLData{{Key: "type", Value: e.Type}}})
+	}
+
+	w.Header().Set("Content-Type", "application/graphml+xml")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(doc)
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func seedGraph(w *testWiki) {
+	w.seed("A", "[b](/view/B) [missing](/view/Missing)")
+	w.seed("B", "[c](/view/C)")
+	w.seed("C", "end")
+	w.seed("D", "orphan")
+	rebuildIndexes()
+}
+
+func getGraph(t *testing.T, w *testWiki, query string) linkGraph {
+	t.Helper()
+	resp, body := w.get("/api/graph" + query)
+	wantStatus(t, resp, body, http.StatusOK)
+	var g linkGraph
+	if err := json.Unmarshal([]byte(body), &g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func graphTitles(g linkGraph) []string {
+	var titles []string
+	for _, n := range g.Nodes {
+		titles = append(titles, n.Title)
+	}
+	return titles
+}
+
+func TestAPIGraph(t *testing.T) {
+	w := newTestWiki(t)
+	seedGraph(w)
+	views.mu.Lock()
+	views.pending["A"] = 3
+	views.mu.Unlock()
+
+	// Orphans are nodes too; links to missing pages are not edges.
+	g := getGraph(t, w, "")
+	if !slices.Equal(graphTitles(g), []string{"A", "B", "C", "D"}) {
+		t.Errorf("nodes = %+v", g.Nodes)
+	}
+	if g.Nodes[0].Size != int64(len("[b](/view/B) [missing](/view/Missing)")) || g.Nodes[0].Views != 3 {
+		t.Errorf("node A = %+v", g.Nodes[0])
+	}
+	if want := []graphEdge{{"A", "B", "link"}, {"B", "C", "link"}}; !slices.Equal(g.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, want)
+	}
+
+	// Links are followed in both directions from the root.
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"?root=C", []string{"B", "C"}},
+		{"?root=C&depth=0", []string{"C"}},
+		{"?root=C&depth=2", []string{"A", "B", "C"}},
+		{"?root=D&depth=5", []string{"D"}},
+	} {
+		if got := graphTitles(getGraph(t, w, tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s nodes = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if g := getGraph(t, w, "?root=C"); !slices.Equal(g.Edges, []graphEdge{{"B", "C", "link"}}) {
+		t.Errorf("?root=C edges = %+v", g.Edges)
+	}
+
+	for _, tt := range []struct {
+		query  string
+		status int
+	}{
+		{"?root=Missing", http.StatusNotFound},
+		{"?root=../etc", http.StatusBadRequest},
+		{"?depth=-1", http.StatusBadRequest},
+		{"?depth=deep", http.StatusBadRequest},
+		{"?format=csv", http.StatusBadRequest},
+	} {
+		resp, body := w.get("/api/graph" + tt.query)
+		wantStatus(t, resp, body, tt.status)
+	}
+	resp, body := w.api(http.MethodPost, "/api/graph", "")
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func TestAPIGraphNodeLimit(t *testing.T) {
+	w := newTestWiki(t)
+	seedGraph(w)
+	setGlobal(t, &graphMaxNodes, 3)
+
+	resp, body := w.get("/api/graph")
+	wantStatus(t, resp, body, http.StatusRequestEntityTooLarge)
+	// A part of the graph under the limit is still served.
+	if got := graphTitles(getGraph(t, w, "?root=B")); len(got) != 3 {
+		t.Errorf("?root=B nodes = %q", got)
+	}
+}
+
+func TestAPIGraphML(t *testing.T) {
+	w := newTestWiki(t)
+	seedGraph(w)
+
+	resp, body := w.get("/api/graph?format=graphml&root=B")
+	wantStatus(t, resp, body, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/graphml+xml" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var doc graphML
+	if err := xml.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	if doc.Graph.EdgeDefault != "directed" || len(doc.Keys) != 3 || len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("graphml:\n%s", body)
+	}
+	if n := doc.Graph.Nodes[2]; n.ID != "C" || n.Data[0].Key != "size" || n.Data[0].Value != "3" {
+		t.Errorf("node = %+v", n)
+	}
+	if e := doc.Graph.Edges[0]; e.Source != "A" || e.Target != "B" || e.Data[0].Value != "link" {
+		t.Errorf("edge = %+v", e)
+	}
+}
+
+func TestSetupGraph(t *testing.T) {
+	setGlobal(t, &graphMaxNodes, defaultGraphMaxNodes)
+
+	t.Setenv("GRAPH_MAX_NODES", "10")
+	if err := setupGraph(); err != nil || graphMaxNodes != 10 {
+		t.Errorf("GRAPH_MAX_NODES=10 gives %d, %v", graphMaxNodes, err)
+	}
+	for _, raw := range []string{"0", "-1", "many"} {
+		t.Setenv("GRAPH_MAX_NODES", raw)
+		if err := setupGraph(); err == nil {
+			t.Errorf("GRAPH_MAX_NODES=%q accepted", raw)
+		}
+	}
+}
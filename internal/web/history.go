@@ -0,0 +1,271 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+const (
+	historyLogName          = "log.jsonl"
+	historyLastIDName       = "last"
+	defaultMaxSummaryLength = 200
+)
+
+type revision struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Editor  string    `json:"editor,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	Minor   bool      `json:"minor,omitempty"`
+	Size    int       `json:"size"`
+}
+
+type historyData struct {
+	Title     string
+	Revisions []revision
+}
+
+type revisionData struct {
+	Title     string
+	Revision  revision
+	Body      []byte
+	CanRevert bool
+}
+
+var historyMu sync.Mutex
+
+func historyDir(title string) string {
+	return filepath.Join(config.StoragePath, ".history", title)
+}
+
+func revisionFilename(title string, id int) string {
+	return filepath.Join(historyDir(title), strconv.Itoa(id)+".txt")
+}
+
+// capSummary trims the summary to defaultMaxSummaryLength runes.
+func capSummary(summary string) string {
+	summary = strings.Join(strings.Fields(summary), " ")
+	if utf8.RuneCountInString(summary) <= defaultMaxSummaryLength {
+		return summary
+	}
+
+	runes := []rune(summary)
+	return string(runes[:defaultMaxSummaryLength])
+}
+
+// recordRevision appends a revision with the editor, summary and minor flag
+// of rev to the page history. Revision ids are sequential per page and never
+// reused, so they stay stable across restarts.
+func recordRevision(title string, body []byte, rev revision) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	revs, err := listRevisions(title)
+	if err != nil {
+		return err
+	}
+
+	rev.ID = 1
+	if rev.Time.IsZero() {
+		rev.Time = time.Now().UTC()
+	}
+	rev.Summary = capSummary(rev.Summary)
+	rev.Size = len(body)
+	if len(revs) > 0 {
+		rev.ID = revs[0].ID + 1
+	}
+	// Collapsing duplicates may have dropped the newest ids, which must not
+	// be handed out again.
+	if b, err := os.ReadFile(filepath.Join(historyDir(title), historyLastIDName)); err == nil {
+		if last, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && last >= rev.ID {
+			rev.ID = last + 1
+		}
+	}
+
+	if err := os.MkdirAll(historyDir(title), 0750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(revisionFilename(title, rev.ID), body, 0600); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(historyDir(title), historyLogName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// listRevisions returns the page history newest first.
+func listRevisions(title string) ([]revision, error) {
+	f, err := os.Open(filepath.Join(historyDir(title), historyLogName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var revs []revision
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rev revision
+		if err := json.Unmarshal(scanner.Bytes(), &rev); err != nil {
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(revs)-1; i < j; i, j = i+1, j-1 {
+		revs[i], revs[j] = revs[j], revs[i]
+	}
+
+	return revs, nil
+}
+
+func lastRevision(title string) (revision, bool) {
+	revs, err := listRevisions(title)
+	if err != nil || len(revs) == 0 {
+		return revision{}, false
+	}
+
+	return revs[0], true
+}
+
+func findRevision(title string, id int) (revision, []byte, error) {
+	revs, err := listRevisions(title)
+	if err != nil {
+		return revision{}, nil, err
+	}
+
+	for _, rev := range revs {
+		if rev.ID == id {
+			body, err := os.ReadFile(revisionFilename(title, id))
+			return rev, body, err
+		}
+	}
+
+	return revision{}, nil, os.ErrNotExist
+}
+
+func historyHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if raw := r.FormValue("rev"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+
+		rev, body, err := findRevision(param, id)
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := pageData{
+			Title:   "Revision " + raw + " of " + param,
+			Content: &revisionData{Title: param, Revision: rev, Body: body, CanRevert: canEdit(r) && !readOnly.Load()},
+		}
+
+		renderTemplate(w, r, data, "revision")
+		return
+	}
+
+	revs, err := listRevisions(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := pageData{
+		Title:   "History of " + param,
+		Content: &historyData{Title: param, Revisions: revs},
+	}
+
+	renderTemplate(w, r, data, "history")
+}
+
+// revertPage saves the body of an earlier revision as a new revision, so the
+// history keeps everything that happened in between. It goes through the
+// same checks as any other edit; queued reports an anonymous revert held
+// for review, which has no revision yet.
+func revertPage(r *http.Request, title string, id int) (rev revision, queued bool, err error) {
+	_, body, err := findRevision(title, id)
+	if err != nil {
+		return revision{}, false, err
+	}
+
+	p := &page.Page{Title: title, Body: body, Editor: currentUser(r), Summary: "Reverted to revision " + strconv.Itoa(id)}
+	outcome, err := submitEdit(r, title, p, "", true)
+	if err != nil || outcome.queued {
+		return revision{}, outcome.queued, err
+	}
+
+	rev, _ = lastRevision(title)
+	return rev, false, nil
+}
+
+func revertHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("rev"))
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	_, queued, err := revertPage(r, param, id)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if status, ok := saveErrorStatus(err); ok {
+		setRetryAfter(w, err)
+		renderError(w, r, status, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if queued {
+		addFlash(w, r, flash{Level: "success", Text: "Your revert of " + param + " was sent for review"})
+		http.Redirect(w, r, pageURL("/view/"+param), http.StatusFound)
+		return
+	}
+	addFlash(w, r, flash{Level: "success", Text: "Page " + param + " reverted to revision " + strconv.Itoa(id)})
+	http.Redirect(w, r, pageURL("/view/"+param), http.StatusFound)
+}
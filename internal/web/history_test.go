@@ -0,0 +1,89 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEditSummaries(t *testing.T) {
+	w := newTestWiki(t)
+	alice := w.login("alice", roleEditor)
+
+	summary := "<b>fix</b> " + strings.Repeat("ё", defaultMaxSummaryLength)
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"home"}, "summary": {summary}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	rev, ok := lastRevision("Home")
+	if !ok || rev.Editor != "alice" || utf8.RuneCountInString(rev.Summary) != defaultMaxSummaryLength ||
+		!strings.HasPrefix(rev.Summary, "<b>fix</b> ёё") {
+		t.Fatalf("revision = %+v, want the summary capped at %d runes", rev, defaultMaxSummaryLength)
+	}
+
+	for _, path := range []string{"/history/Home", "/changelog"} {
+		_, body := w.get(path)
+		if strings.Contains(body, "<b>fix</b>") || !strings.Contains(body, "&lt;b&gt;fix&lt;/b&gt;") {
+			t.Errorf("%s does not show the summary escaped:\n%s", path, body)
+		}
+	}
+	if feed := readFeed(t, w, "/feed.xml"); len(feed.Entries) != 1 || feed.Entries[0].Summary != rev.Summary {
+		t.Errorf("feed entries = %+v, want the summary", feed.Entries)
+	}
+}
+
+func TestRequireSummary(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	config.RequireSummary = true
+
+	form := url.Values{"title": {"Home"}, "body": {"changed"}}
+	resp, body := w.post("/save/Home", form, w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if !strings.Contains(body, "Please describe your change in the summary") {
+		t.Errorf("missing summary not explained:\n%s", body)
+	}
+	resp, body = w.api(http.MethodPut, "/api/pages/Home", `{"body":"changed"}`)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	form.Set("summary", "reword")
+	resp, body = w.post("/save/Home", form, w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusFound)
+
+	// Admins may leave it out.
+	form.Del("summary")
+	form.Set("body", "admin edit")
+	resp, body = w.post("/save/Home", form, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusFound)
+}
+
+func TestMinorFlagInHistory(t *testing.T) {
+	w := newTestWiki(t)
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"home"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"hom e"}, "minor": {"on"}})
+	wantStatus(t, resp, body, http.StatusFound)
+
+	revs, err := listRevisions("Home")
+	if err != nil || len(revs) != 2 || !revs[0].Minor || revs[1].Minor {
+		t.Fatalf("revisions = %+v, %v, want only the second marked minor", revs, err)
+	}
+	if _, body := w.get("/history/Home"); strings.Count(body, `title="minor edit"`) != 1 {
+		t.Errorf("history does not mark the one minor edit:\n%s", body)
+	}
+	for _, c := range []struct {
+		rev  revision
+		hide bool
+		want bool
+	}{
+		{revs[0], false, true},
+		{revs[0], true, false},
+		{revs[1], true, true},
+	} {
+		if got := listedChange(c.rev, c.hide); got != c.want {
+			t.Errorf("listedChange(minor %v, hideMinor %v) = %v", c.rev.Minor, c.hide, got)
+		}
+	}
+}
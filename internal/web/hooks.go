@@ -0,0 +1,159 @@
+package web
+
+import (
+	"html/template"
+	"log/slog"
+)
+
+type beforeSaveHook func(title string, body []byte) ([]byte, error)
+
+type afterSaveHook func(title string)
+
+type beforeRenderHook func(title string, html template.HTML) (template.HTML, error)
+
+type onDeleteHook func(title string)
+
+type onRenameHook func(from, to string)
+
+// hookRegistry is the compile-time extension point for page lifecycle events.
+// Hooks are registered from registerHooks at startup and run in registration order.
+type hookRegistry struct {
+	beforeSave   []beforeSaveHook
+	afterSave    []afterSaveHook
+	beforeRender []beforeRenderHook
+	onDelete     []onDeleteHook
+	onRename     []onRenameHook
+}
+
+var hooks = &hookRegistry{}
+
+func (h *hookRegistry) BeforeSave(fn beforeSaveHook) {
+	h.beforeSave = append(h.beforeSave, fn)
+}
+
+func (h *hookRegistry) AfterSave(fn afterSaveHook) {
+	h.afterSave = append(h.afterSave, fn)
+}
+
+func (h *hookRegistry) BeforeRender(fn beforeRenderHook) {
+	h.beforeRender = append(h.beforeRender, fn)
+}
+
+func (h *hookRegistry) OnDelete(fn onDeleteHook) {
+	h.onDelete = append(h.onDelete, fn)
+}
+
+// OnRename hooks run after a page moved to a new title. The new page has
+// already gone through the save hooks; the old title is gone, but it is not a
+// deletion, so whatever belonged to it follows the page.
+func (h *hookRegistry) OnRename(fn onRenameHook) {
+	h.onRename = append(h.onRename, fn)
+}
+
+func (h *hookRegistry) runBeforeSave(title string, body []byte) ([]byte, error) {
+	for _, fn := range h.beforeSave {
+		var err error
+		body, err = fn(title, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+func (h *hookRegistry) runAfterSave(title string) {
+	for _, fn := range h.afterSave {
+		fn(title)
+	}
+}
+
+func (h *hookRegistry) runBeforeRender(title string, html template.HTML) (template.HTML, error) {
+	for _, fn := range h.beforeRender {
+		var err error
+		html, err = fn(title, html)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return html, nil
+}
+
+func (h *hookRegistry) runOnDelete(title string) {
+	for _, fn := range h.onDelete {
+		fn(title)
+	}
+}
+
+func (h *hookRegistry) runOnRename(from, to string) {
+	for _, fn := range h.onRename {
+		fn(from, to)
+	}
+}
+
+// setupCommandHooks registers the hooks for the command line subcommands, so
+// pages they write are checked, backed up and journaled like any save.
+func setupCommandHooks() error {
+	if err := setupBlocklist(); err != nil {
+		return err
+	}
+	if err := setupChangeJournal(); err != nil {
+		return err
+	}
+	registerHooks()
+
+	return nil
+}
+
+func registerHooks() {
+	hooks.BeforeSave(func(title string, body []byte) ([]byte, error) {
+		return body, checkBlocklist(body)
+	})
+
+	hooks.BeforeSave(func(title string, body []byte) ([]byte, error) {
+		return body, backupPage(title)
+	})
+
+	hooks.AfterSave(pages.refresh)
+
+	hooks.AfterSave(searchIndexer.enqueue)
+
+	hooks.AfterSave(queueDigestEntries)
+
+	hooks.AfterSave(notifyWatchers)
+
+	hooks.AfterSave(journal.recordSave)
+
+	hooks.OnDelete(pages.refresh)
+
+	hooks.OnDelete(searchIndexer.enqueue)
+
+	hooks.OnDelete(notifyWatchersOfDelete)
+
+	hooks.OnDelete(views.remove)
+
+	hooks.OnDelete(journal.recordDelete)
+
+	hooks.OnDelete(func(title string) {
+		if err := removeBackup(title); err != nil {
+			slog.Error("error removing undo copy", "title", title, "err", err)
+		}
+	})
+
+	hooks.OnRename(func(from, to string) {
+		pages.refresh(from)
+		searchIndexer.enqueue(from)
+		// Sync clients only know titles, for them the old one is gone.
+		journal.recordDelete(from)
+		if err := removeBackup(from); err != nil {
+			slog.Error("error removing undo copy", "title", from, "err", err)
+		}
+	})
+
+	hooks.OnRename(moveWatches)
+
+	hooks.OnRename(moveDrafts)
+
+	hooks.OnRename(views.rename)
+}
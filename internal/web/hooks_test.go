@@ -0,0 +1,148 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func TestHooksRunInOrder(t *testing.T) {
+	h := &hookRegistry{}
+	var calls []string
+	for _, name := range []string{"a", "b", "c"} {
+		h.BeforeSave(func(title string, body []byte) ([]byte, error) {
+			calls = append(calls, "before "+name)
+			return append(body, name...), nil
+		})
+		h.AfterSave(func(title string) { calls = append(calls, "after "+name) })
+		h.BeforeRender(func(title string, html template.HTML) (template.HTML, error) {
+			return html + template.HTML(name), nil
+		})
+		h.OnDelete(func(title string) { calls = append(calls, "delete "+name) })
+		h.OnRename(func(from, to string) { calls = append(calls, "rename "+name+" "+from+">"+to) })
+	}
+
+	body, err := h.runBeforeSave("Home", []byte("x"))
+	if err != nil || string(body) != "xabc" {
+		t.Errorf("runBeforeSave = %q, %v, want each hook to see the previous one's body", body, err)
+	}
+	h.runAfterSave("Home")
+	h.runOnDelete("Home")
+	h.runOnRename("Old", "New")
+	want := []string{"before a", "before b", "before c", "after a", "after b", "after c", "delete a", "delete b", "delete c", "rename a Old>New", "rename b Old>New", "rename c Old>New"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls =\n%q\nwant\n%q", calls, want)
+	}
+
+	if html, err := h.runBeforeRender("Home", "<p>"); err != nil || html != "<p>abc" {
+		t.Errorf("runBeforeRender = %q, %v", html, err)
+	}
+}
+
+func TestHookErrorStopsTheChain(t *testing.T) {
+	errRejected := errors.New("rejected")
+	h := &hookRegistry{}
+	ran := 0
+	h.BeforeSave(func(string, []byte) ([]byte, error) { ran++; return []byte("changed"), nil })
+	h.BeforeSave(func(string, []byte) ([]byte, error) { ran++; return nil, errRejected })
+	h.BeforeSave(func(string, []byte) ([]byte, error) { ran++; return nil, nil })
+	h.BeforeRender(func(string, template.HTML) (template.HTML, error) { return "partial", errRejected })
+
+	if body, err := h.runBeforeSave("Home", []byte("x")); !errors.Is(err, errRejected) || body != nil || ran != 2 {
+		t.Errorf("runBeforeSave = %q, %v after %d hooks, want the error from the second", body, err, ran)
+	}
+	if html, err := h.runBeforeRender("Home", "<p>"); !errors.Is(err, errRejected) || html != "" {
+		t.Errorf("runBeforeRender = %q, %v", html, err)
+	}
+}
+
+// withHooks adds test hooks after the registered ones.
+func withHooks(t *testing.T, add func(h *hookRegistry)) {
+	t.Helper()
+	h := *hooks
+	h.beforeSave = slices.Clip(h.beforeSave)
+	h.afterSave = slices.Clip(h.afterSave)
+	h.beforeRender = slices.Clip(h.beforeRender)
+	add(&h)
+	setGlobal(t, &hooks, &h)
+}
+
+// A failing before-save hook aborts the save: nothing is written and no
+// after-save hook runs.
+func TestSaveAbortedByHook(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "original")
+	afterSave := 0
+	withHooks(t, func(h *hookRegistry) {
+		h.BeforeSave(func(title string, body []byte) ([]byte, error) {
+			if string(body) == "reject me" {
+				return nil, errors.New("rejected by hook")
+			}
+			return append(body, "\n\n(signed)"...), nil
+		})
+		h.AfterSave(func(string) { afterSave++ })
+	})
+
+	err := savePage(&page.Page{Title: "Home", Body: []byte("reject me")})
+	if err == nil || err.Error() != "rejected by hook" {
+		t.Fatalf("save = %v, want the hook's error", err)
+	}
+	if b, _ := store.Read("Home"); string(b) != "original" || afterSave != 0 {
+		t.Errorf("page = %q after a rejected save, %d after-save calls", b, afterSave)
+	}
+	if revs, _ := listRevisions("Home"); len(revs) != 1 {
+		t.Errorf("%d revisions, a rejected save must not record one", len(revs))
+	}
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"accepted"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, _ := store.Read("Home"); string(b) != "accepted\n\n(signed)" || afterSave != 1 {
+		t.Errorf("page = %q, %d after-save calls, want the hook's body stored", b, afterSave)
+	}
+}
+
+// The built-in behaviors run as hooks: the undo copy, the page cache, the
+// search index and the change journal.
+func TestRegisteredHooks(t *testing.T) {
+	w := newTestWiki(t)
+	startIndexer(t, time.Millisecond)
+	w.seed("Home", "old words")
+	w.seed("Home", "fresh words")
+
+	if !hasUndo("Home") {
+		t.Error("save kept no undo copy")
+	}
+	waitFor(t, func() bool { return slices.Equal(searchTitles("fresh"), []string{"Home"}) })
+	if !slices.Contains(pages.titles(), "Home") {
+		t.Error("page cache does not know the saved page")
+	}
+
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	if hasUndo("Home") {
+		t.Error("undo copy left behind after delete")
+	}
+	if slices.Contains(pages.titles(), "Home") {
+		t.Error("page cache still lists the deleted page")
+	}
+	waitFor(t, func() bool { return len(searchTitles("fresh")) == 0 })
+
+	events, _, err := journal.after(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Title+" "+e.Action)
+	}
+	if !slices.Equal(actions, []string{"Home create", "Home update", "Home delete"}) {
+		t.Errorf("journal = %q", actions)
+	}
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/AlexKvashin21/gowiki/internal/mediawiki"
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+const importUsage = "usage: gowiki import mediawiki [--dry-run] [--history] [--overwrite] <dump.xml>"
+
+// importReport counts what happened to every page of an import and prints
+// the reason for each page that did not make it.
+type importReport struct {
+	out      io.Writer
+	dryRun   bool
+	imported int
+	skipped  int
+	failed   int
+}
+
+func (r *importReport) skip(title, reason string) {
+	r.skipped++
+	fmt.Fprintf(r.out, "skipped %q: %s\n", title, reason)
+}
+
+func (r *importReport) fail(title string, err error) {
+	r.failed++
+	fmt.Fprintf(r.out, "failed %q: %v\n", title, err)
+}
+
+func (r *importReport) summary() {
+	verb := "imported"
+	if r.dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(r.out, "%s %d pages, skipped %d, failed %d\n", verb, r.imported, r.skipped, r.failed)
+}
+
+// Import runs the "gowiki import" command with the given settings, writing
+// pages to st and the report to out.
+func Import(cfg Config, st storage.Storage, args []string, out io.Writer) error {
+	config = cfg
+	store = st
+	if err := setupLimits(); err != nil {
+		return err
+	}
+	if err := setupCommandHooks(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New(importUsage + "\n" + importDirUsage)
+	}
+
+	switch args[0] {
+	case "mediawiki":
+		return importMediaWiki(args[1:], out)
+	case "dir":
+		return importDir(args[1:], out)
+	default:
+		return fmt.Errorf("unknown import format %q\n%s\n%s", args[0], importUsage, importDirUsage)
+	}
+}
+
+// mediaWikiPage is the page being imported while its revisions stream by.
+type mediaWikiPage struct {
+	name  string
+	title string
+	skip  bool
+	err   error
+	last  *mediawiki.Revision
+	body  []byte
+}
+
+func importMediaWiki(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import mediawiki", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	history := flags.Bool("history", false, "import every revision instead of only the latest one")
+	overwrite := flags.Bool("overwrite", false, "replace pages that already exist")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report := &importReport{out: out, dryRun: *dryRun}
+	taken := map[string]string{}
+	convert := func(name string) (string, bool) {
+		title := slugTitle(name)
+		return title, validateTitle(title) == nil
+	}
+
+	var current *mediaWikiPage
+	finish := func() {
+		if current == nil || current.skip {
+			return
+		}
+		if current.err != nil {
+			report.fail(current.name, current.err)
+			return
+		}
+		if current.last == nil {
+			report.skip(current.name, "no revisions")
+			return
+		}
+
+		if !*dryRun {
+			err := writeImported(current.title, current.body, func(body []byte) error {
+				if *history {
+					return nil
+				}
+				return recordImportedRevision(current.title, body, current.last)
+			})
+			if err != nil {
+				report.fail(current.name, err)
+				return
+			}
+		}
+		report.imported++
+	}
+
+	dec := mediawiki.NewDecoder(f)
+	for {
+		rev, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			finish()
+			report.summary()
+			return fmt.Errorf("reading dump: %w", err)
+		}
+
+		if current == nil || current.name != rev.Title {
+			finish()
+			current = startMediaWikiPage(rev, taken, *overwrite, report)
+		}
+		if current.skip || current.err != nil {
+			continue
+		}
+
+		body := []byte(mediawiki.ToMarkdown(rev.Text, convert))
+		if err := importedBodyError(body); err != nil {
+			current.err = err
+			continue
+		}
+		if *history && !*dryRun {
+			if err := recordImportedRevision(current.title, body, &rev); err != nil {
+				current.err = err
+				continue
+			}
+		}
+		current.last, current.body = &rev, body
+	}
+	finish()
+	report.summary()
+
+	return nil
+}
+
+// startMediaWikiPage decides whether the page rev belongs to can be imported
+// at all, before any of its revisions are read.
+func startMediaWikiPage(rev mediawiki.Revision, taken map[string]string, overwrite bool, report *importReport) *mediaWikiPage {
+	p := &mediaWikiPage{name: rev.Title, title: slugTitle(rev.Title)}
+
+	switch {
+	case rev.Namespace != 0:
+		report.skip(p.name, "not in the main namespace")
+	case validateTitle(p.title) != nil:
+		report.skip(p.name, "title cannot be converted: "+validateTitle(p.title).Error())
+	case taken[p.title] != "":
+		report.skip(p.name, fmt.Sprintf("converts to %s, already used by %q", p.title, taken[p.title]))
+	default:
+		if _, err := store.Stat(p.title); err == nil && !overwrite {
+			report.skip(p.name, fmt.Sprintf("page %s already exists", p.title))
+		} else {
+			taken[p.title] = p.name
+			return p
+		}
+	}
+
+	p.skip = true
+	return p
+}
+
+func importedBodyError(body []byte) error {
+	if len(body) > maxPageSize {
+		return fmt.Errorf("page is larger than %d bytes", maxPageSize)
+	}
+
+	return page.ValidateBody(body)
+}
+
+// writeImported writes an imported page the way a save would, through the
+// before and after save hooks, with record adding its history in between.
+func writeImported(title string, body []byte, record func(body []byte) error) error {
+	body, err := hooks.runBeforeSave(title, body)
+	if err != nil {
+		return err
+	}
+	if err := store.Write(title, body); err != nil {
+		return err
+	}
+	if err := record(body); err != nil {
+		return err
+	}
+	hooks.runAfterSave(title)
+
+	return nil
+}
+
+func recordImportedRevision(title string, body []byte, rev *mediawiki.Revision) error {
+	return recordRevision(title, body, revision{
+		Time:    rev.Time.UTC(),
+		Editor:  rev.Contributor,
+		Summary: rev.Comment,
+		Minor:   rev.Minor,
+	})
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const importDump = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/">
+  <page>
+    <title>Main Page</title>
+    <ns>0</ns>
+    <revision>
+      <timestamp>2020-01-02T03:04:05Z</timestamp>
+      <contributor><username>Alice</username></contributor>
+      <comment>first</comment>
+      <text>Hello</text>
+    </revision>
+    <revision>
+      <timestamp>2020-02-02T03:04:05Z</timestamp>
+      <contributor><ip>10.0.0.1</ip></contributor>
+      <minor />
+      <text>== Welcome ==
+'''Hello''' from [[Other Page|the other page]]</text>
+    </revision>
+  </page>
+  <page>
+    <title>Talk:Main Page</title>
+    <ns>1</ns>
+    <revision><timestamp>2020-03-02T03:04:05Z</timestamp><text>Discussion</text></revision>
+  </page>
+  <page>
+    <title>Main-Page</title>
+    <ns>0</ns>
+    <revision><timestamp>2020-03-02T03:04:05Z</timestamp><text>Same title</text></revision>
+  </page>
+  <page>
+    <title>Huge</title>
+    <ns>0</ns>
+    <revision><timestamp>2020-03-02T03:04:05Z</timestamp><text>` + "HUGE_TEXT" + `</text></revision>
+  </page>
+</mediawiki>`
+
+func writeDump(t *testing.T, dump string) string {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "dump.xml")
+	if err := os.WriteFile(fn, []byte(dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func runImport(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := importMediaWiki(args, &out); err != nil {
+		t.Fatalf("import %q: %v\n%s", args, err, out.String())
+	}
+	return out.String()
+}
+
+func TestImportMediaWiki(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &maxPageSize, 512)
+	dump := writeDump(t, strings.Replace(importDump, "HUGE_TEXT", strings.Repeat("x", 600), 1))
+
+	out := runImport(t, "--dry-run", dump)
+	if !strings.HasSuffix(out, "would import 1 pages, skipped 2, failed 1\n") {
+		t.Errorf("dry run report:\n%s", out)
+	}
+	if titles, _ := listPages(); len(titles) != 0 {
+		t.Fatalf("dry run wrote %q", titles)
+	}
+
+	out = runImport(t, dump)
+	for _, want := range []string{
+		`skipped "Talk:Main Page": not in the main namespace`,
+		`skipped "Main-Page": converts to Main-Page, already used by "Main Page"`,
+		`failed "Huge": page is larger than 512 bytes`,
+		"imported 1 pages, skipped 2, failed 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if _, raw := w.get("/raw/Main-Page"); raw != "## Welcome\n**Hello** from [the other page](/view/Other-Page)" {
+		t.Errorf("imported page = %q", raw)
+	}
+	revs, err := listRevisions("Main-Page")
+	if err != nil || len(revs) != 1 || revs[0].Editor != "10.0.0.1" || !revs[0].Minor || revs[0].Time.Year() != 2020 {
+		t.Errorf("latest-only history = %+v, %v", revs, err)
+	}
+
+	// Existing pages are kept unless asked otherwise.
+	out = runImport(t, dump)
+	if !strings.Contains(out, `skipped "Main Page": page Main-Page already exists`) || !strings.Contains(out, "imported 0 pages") {
+		t.Errorf("second import report:\n%s", out)
+	}
+	out = runImport(t, "--overwrite", "--history", dump)
+	if !strings.Contains(out, "imported 1 pages") {
+		t.Errorf("overwrite report:\n%s", out)
+	}
+	revs, _ = listRevisions("Main-Page")
+	var editors []string
+	for _, rev := range revs {
+		editors = append(editors, rev.Editor)
+	}
+	if len(revs) != 3 || !strings.Contains(strings.Join(editors, ","), "Alice") {
+		t.Errorf("history after --history = %+v", revs)
+	}
+}
+
+func TestImportMediaWikiErrors(t *testing.T) {
+	newTestWiki(t)
+	var out bytes.Buffer
+	for _, args := range [][]string{nil, {"a.xml", "b.xml"}, {"--bogus", "a.xml"}} {
+		if err := importMediaWiki(args, &out); err == nil {
+			t.Errorf("import %q accepted", args)
+		}
+	}
+	if err := importMediaWiki([]string{filepath.Join(t.TempDir(), "missing.xml")}, &out); err == nil {
+		t.Error("missing dump accepted")
+	}
+
+	// A broken dump keeps what was read before the error.
+	out.Reset()
+	broken := writeDump(t, `<mediawiki><page><title>First</title><ns>0</ns><revision><text>one</text></revision></page><page><title>`)
+	if err := importMediaWiki([]string{broken}, &out); err == nil || !strings.Contains(err.Error(), "reading dump") {
+		t.Errorf("broken dump: %v", err)
+	}
+	if !strings.Contains(out.String(), "imported 1 pages") {
+		t.Errorf("broken dump report:\n%s", out.String())
+	}
+}
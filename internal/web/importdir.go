@@ -0,0 +1,267 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+const (
+	importDirUsage    = "usage: gowiki import dir [--dry-run] [--front-matter=keep|strip] [--on-conflict=skip|overwrite|rename] <path>"
+	importUploadLimit = 10 << 20
+)
+
+// markdownImport turns a tree of .md files into pages. Nested directories
+// become part of the title, so notes/go/intro.md is imported as NotesGoIntro.
+type markdownImport struct {
+	stripFrontMatter bool
+	onConflict       string
+	dryRun           bool
+	write            func(title string, body []byte) error
+}
+
+func validConflictPolicy(policy string) bool {
+	return policy == "skip" || policy == "overwrite" || policy == "rename"
+}
+
+func (m *markdownImport) run(fsys fs.FS, report *importReport) error {
+	taken := map[string]string{}
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			report.fail(name, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if name != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		ext := path.Ext(name)
+		if ext != ".md" && ext != ".markdown" || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			report.skip(name, "symbolic links are not followed")
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			report.skip(name, "not a regular file")
+			return nil
+		}
+
+		m.importFile(fsys, name, taken, report)
+		return nil
+	})
+}
+
+func (m *markdownImport) importFile(fsys fs.FS, name string, taken map[string]string, report *importReport) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		report.fail(name, err)
+		return
+	}
+	if info.Size() > int64(maxPageSize) {
+		report.skip(name, fmt.Sprintf("larger than %d bytes", maxPageSize))
+		return
+	}
+
+	body, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		report.fail(name, err)
+		return
+	}
+	if !utf8.Valid(body) {
+		report.skip(name, "not valid UTF-8")
+		return
+	}
+	if m.stripFrontMatter {
+		body = page.StripFrontMatter(body)
+	}
+	if err := page.ValidateBody(body); err != nil {
+		report.skip(name, err.Error())
+		return
+	}
+
+	title := slugTitle(strings.TrimSuffix(name, path.Ext(name)))
+	if err := validateTitle(title); err != nil {
+		report.skip(name, "title cannot be converted: "+err.Error())
+		return
+	}
+
+	title, ok := m.resolveConflict(name, title, taken, report)
+	if !ok {
+		return
+	}
+	taken[title] = name
+
+	if base := strings.TrimSuffix(path.Base(name), path.Ext(name)); base != title {
+		fmt.Fprintf(report.out, "renamed %q to %s\n", name, title)
+	}
+
+	if !m.dryRun {
+		if err := m.write(title, body); err != nil {
+			report.fail(name, err)
+			return
+		}
+	}
+	report.imported++
+}
+
+// resolveConflict applies the conflict policy when title is already used by
+// an existing page or by another file of the same import. Files of the same
+// import never overwrite each other.
+func (m *markdownImport) resolveConflict(name, title string, taken map[string]string, report *importReport) (string, bool) {
+	exists := func(t string) bool {
+		if _, ok := taken[t]; ok {
+			return true
+		}
+		_, err := store.Stat(t)
+		return err == nil
+	}
+
+	if !exists(title) {
+		return title, true
+	}
+
+	if other, ok := taken[title]; ok && m.onConflict != "rename" {
+		report.skip(name, fmt.Sprintf("converts to %s, already used by %q", title, other))
+		return "", false
+	}
+
+	switch m.onConflict {
+	case "overwrite":
+		return title, true
+	case "rename":
+		for i := 2; ; i++ {
+			candidate := title + strconv.Itoa(i)
+			if validateTitle(candidate) != nil {
+				report.skip(name, fmt.Sprintf("page %s already exists and no free name was found", title))
+				return "", false
+			}
+			if !exists(candidate) {
+				return candidate, true
+			}
+		}
+	default:
+		report.skip(name, fmt.Sprintf("page %s already exists", title))
+		return "", false
+	}
+}
+
+func importDir(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import dir", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	frontMatter := flags.String("front-matter", "keep", "keep or strip front matter")
+	onConflict := flags.String("on-conflict", "skip", "what to do when a page exists: skip, overwrite or rename")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || (*frontMatter != "keep" && *frontMatter != "strip") || !validConflictPolicy(*onConflict) {
+		return errors.New(importDirUsage)
+	}
+
+	root := flags.Arg(0)
+	if fi, err := os.Stat(root); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", root)
+	}
+
+	m := &markdownImport{
+		stripFrontMatter: *frontMatter == "strip",
+		onConflict:       *onConflict,
+		dryRun:           *dryRun,
+		write: func(title string, body []byte) error {
+			return writeImported(title, body, func(body []byte) error {
+				return recordRevision(title, body, revision{Summary: "Imported from " + root})
+			})
+		},
+	}
+
+	report := &importReport{out: out, dryRun: *dryRun}
+	err := m.run(os.DirFS(root), report)
+	report.summary()
+
+	return err
+}
+
+type importData struct {
+	Report string
+}
+
+// importHandler lets admins upload a small zip of Markdown files and imports
+// it with the same rules as gowiki import dir.
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can import pages.")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		renderTemplate(w, r, pageData{Title: "Import pages", Content: &importData{}}, "import")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importUploadLimit)
+	f, header, err := r.FormFile("archive")
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Upload a zip file of at most %d MB.", importUploadLimit>>20))
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Upload failed.")
+		return
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "The upload is not a zip file.")
+		return
+	}
+
+	onConflict := r.FormValue("on_conflict")
+	if !validConflictPolicy(onConflict) {
+		onConflict = "skip"
+	}
+	editor := currentUser(r)
+	m := &markdownImport{
+		stripFrontMatter: r.FormValue("front_matter") == "strip",
+		onConflict:       onConflict,
+		dryRun:           r.FormValue("dry_run") == "on",
+		write: func(title string, body []byte) error {
+			return savePage(&page.Page{Title: title, Body: body, Editor: editor, Summary: "Imported from " + header.Filename})
+		},
+	}
+
+	var out bytes.Buffer
+	report := &importReport{out: &out, dryRun: m.dryRun}
+	if err := m.run(archive, report); err != nil {
+		report.fail(header.Filename, err)
+	}
+	report.summary()
+
+	renderTemplate(w, r, pageData{Title: "Import pages", Content: &importData{Report: out.String()}}, "import")
+}
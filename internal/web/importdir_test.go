@@ -0,0 +1,161 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// markdownTree writes files, keyed by slash-separated path, below a fresh
+// directory.
+func markdownTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, body := range files {
+		fn := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func runImportDir(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := importDir(args, &out); err != nil {
+		t.Fatalf("import dir %q: %v\n%s", args, err, out.String())
+	}
+	return out.String()
+}
+
+func TestImportDir(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "existing")
+	setGlobal(t, &maxPageSize, 64)
+
+	root := markdownTree(t, map[string]string{
+		"notes/go/intro.md":  "# Intro",
+		"Tagged.markdown":    "---\ntags: go\n---\nbody",
+		"Home.md":            "imported home",
+		"bad.md":             "\xff\xfe",
+		"big.md":             strings.Repeat("x", 65),
+		"readme.txt":         "not markdown",
+		".drafts/secret.md":  "hidden",
+		"Dup one.md":         "first",
+		"Dup-one.md":         "second",
+		"notes/go/.skip.md":  "hidden file",
+		"notes/spaces in.md": "spaces",
+	})
+	if err := os.Symlink(filepath.Join(root, "Home.md"), filepath.Join(root, "Link.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runImportDir(t, "--dry-run", root)
+	if titles, _ := store.List(); !slices.Equal(titles, []string{"Home"}) {
+		t.Fatalf("dry run wrote pages: %q\n%s", titles, out)
+	}
+
+	out = runImportDir(t, root)
+	for _, want := range []string{
+		`renamed "notes/go/intro.md" to notes-go-intro`,
+		`skipped "Home.md": page Home already exists`,
+		`skipped "bad.md": not valid UTF-8`,
+		`skipped "big.md": larger than 64 bytes`,
+		`skipped "Link.md": symbolic links are not followed`,
+		`already used by`,
+		"imported 4 pages, skipped 5, failed 0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	titles, _ := store.List()
+	slices.Sort(titles)
+	if want := []string{"Dup-one", "Home", "Tagged", "notes-go-intro", "notes-spaces-in"}; !slices.Equal(titles, want) {
+		t.Errorf("pages = %q, want %q", titles, want)
+	}
+	if b, _ := store.Read("Tagged"); string(b) != "---\ntags: go\n---\nbody" {
+		t.Errorf("front matter not kept: %q", b)
+	}
+	if b, _ := store.Read("Home"); string(b) != "existing" {
+		t.Errorf("existing page overwritten: %q", b)
+	}
+	if rev, ok := lastRevision("notes-go-intro"); !ok || !strings.HasPrefix(rev.Summary, "Imported from ") {
+		t.Errorf("imported page revision = %+v", rev)
+	}
+}
+
+func TestImportDirConflictPolicies(t *testing.T) {
+	w := newTestWiki(t)
+	root := markdownTree(t, map[string]string{"Home.md": "---\ntags: x\n---\nimported"})
+
+	w.seed("Home", "existing")
+	runImportDir(t, "--on-conflict=rename", "--front-matter=strip", root)
+	if b, _ := store.Read("Home2"); string(b) != "imported" {
+		t.Errorf("renamed import = %q", b)
+	}
+	runImportDir(t, "--on-conflict=overwrite", root)
+	if b, _ := store.Read("Home"); !strings.HasSuffix(string(b), "imported") {
+		t.Errorf("overwritten page = %q", b)
+	}
+
+	for _, args := range [][]string{nil, {"--on-conflict=merge", root}, {"--front-matter=drop", root}, {filepath.Join(root, "Home.md")}} {
+		if err := importDir(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("import dir %q accepted", args)
+		}
+	}
+}
+
+func TestImportUpload(t *testing.T) {
+	w := newTestWiki(t)
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, body := range map[string]string{"docs/setup.md": "setup", "bad.md": "\xff"} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(body))
+	}
+	zw.Close()
+
+	upload := func(cookies ...*http.Cookie) (*http.Response, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("archive", "notes.zip")
+		fw.Write(archive.Bytes())
+		mw.Close()
+		req, err := http.NewRequest(http.MethodPost, w.URL+"/admin/import", &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return w.do(req, cookies...)
+	}
+
+	resp, body := upload(w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	resp, body = upload(w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "imported 1 pages, skipped 1, failed 0") {
+		t.Errorf("upload report:\n%s", body)
+	}
+	if b, _ := store.Read("docs-setup"); string(b) != "setup" {
+		t.Errorf("uploaded page = %q", b)
+	}
+	if rev, _ := lastRevision("docs-setup"); rev.Editor != "root" || rev.Summary != "Imported from notes.zip" {
+		t.Errorf("uploaded page revision = %+v", rev)
+	}
+}
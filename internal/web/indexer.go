@@ -0,0 +1,197 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultIndexDebounce = 500 * time.Millisecond
+	indexQueueSize       = 1024
+	// maxIndexBatch flushes a burst that never pauses, such as a big import.
+	maxIndexBatch = 500
+)
+
+// indexer keeps the search, link and tag indexes up to date off the request
+// path. Saves and deletes only queue the title; the worker waits for a burst
+// to settle and then updates every page it touched once.
+type indexer struct {
+	queue    chan indexEvent
+	debounce time.Duration
+	backlog  atomic.Int64
+	lastRun  atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+type indexEvent struct {
+	title   string
+	rebuild bool
+}
+
+var searchIndexer = &indexer{debounce: defaultIndexDebounce}
+
+func setupIndexer() error {
+	raw := os.Getenv("INDEX_DEBOUNCE")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid INDEX_DEBOUNCE %q", raw)
+	}
+	searchIndexer.debounce = d
+
+	return nil
+}
+
+func (ix *indexer) start() {
+	ix.queue = make(chan indexEvent, indexQueueSize)
+	ix.done = make(chan struct{})
+	go ix.run()
+}
+
+// enqueue queues a page to be reindexed. It only blocks when the queue is
+// full.
+func (ix *indexer) enqueue(title string) {
+	ix.send(indexEvent{title: title})
+}
+
+// requestRebuild queues a rebuild of every index from storage.
+func (ix *indexer) requestRebuild() {
+	ix.send(indexEvent{rebuild: true})
+}
+
+func (ix *indexer) send(ev indexEvent) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if ix.closed || ix.queue == nil {
+		return
+	}
+	ix.backlog.Add(1)
+	ix.queue <- ev
+}
+
+// stop applies everything still queued and waits for the worker to exit.
+func (ix *indexer) stop() {
+	ix.mu.Lock()
+	if ix.closed || ix.queue == nil {
+		ix.mu.Unlock()
+		return
+	}
+	ix.closed = true
+	close(ix.queue)
+	ix.mu.Unlock()
+
+	<-ix.done
+}
+
+func (ix *indexer) run() {
+	defer close(ix.done)
+
+	batch := map[string]bool{}
+	rebuild := false
+	events := 0
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	flush := func() {
+		ix.apply(batch, rebuild)
+		ix.backlog.Add(int64(-events))
+		batch, rebuild, events = map[string]bool{}, false, 0
+		fire = nil
+	}
+
+	for {
+		select {
+		case ev, ok := <-ix.queue:
+			if !ok {
+				if events > 0 {
+					flush()
+				}
+				return
+			}
+
+			events++
+			if ev.rebuild {
+				rebuild = true
+			} else {
+				batch[ev.title] = true
+			}
+			if len(batch) >= maxIndexBatch {
+				flush()
+				continue
+			}
+
+			if timer == nil {
+				timer = time.NewTimer(ix.debounce)
+			} else {
+				timer.Reset(ix.debounce)
+			}
+			fire = timer.C
+		case <-fire:
+			flush()
+		}
+	}
+}
+
+func (ix *indexer) apply(titles map[string]bool, rebuild bool) {
+	if rebuild {
+		rebuildIndexes()
+	} else {
+		for title := range titles {
+			plaintexts.update(title)
+			pageLinks.update(title)
+			pageTags.update(title)
+		}
+		// The related pages depend on both the link and the tag index.
+		for title := range titles {
+			relatedPages.invalidate(title)
+		}
+	}
+
+	ix.lastRun.Store(time.Now().UnixNano())
+}
+
+func rebuildIndexes() {
+	plaintexts.rebuild()
+	pageLinks.rebuild()
+	pageTags.rebuild()
+	relatedPages.reset()
+}
+
+type apiStats struct {
+	Pages        int        `json:"pages"`
+	IndexBacklog int64      `json:"indexBacklog"`
+	LastIndexed  *time.Time `json:"lastIndexed,omitempty"`
+}
+
+func apiStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	infos, err := listPageInfos()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	stats := apiStats{Pages: len(infos), IndexBacklog: searchIndexer.backlog.Load()}
+	if n := searchIndexer.lastRun.Load(); n != 0 {
+		t := time.Unix(0, n).UTC()
+		stats.LastIndexed = &t
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
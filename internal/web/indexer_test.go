@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startIndexer runs the search indexer for the test, waiting debounce for a
+// burst to settle.
+func startIndexer(t *testing.T, debounce time.Duration) {
+	t.Helper()
+	setGlobal(t, &searchIndexer.debounce, debounce)
+	searchIndexer.lastRun.Store(0)
+	searchIndexer.start()
+	t.Cleanup(func() {
+		searchIndexer.stop()
+		searchIndexer.mu.Lock()
+		searchIndexer.closed, searchIndexer.queue = false, nil
+		searchIndexer.mu.Unlock()
+	})
+}
+
+func getStats(t *testing.T, w *testWiki) apiStats {
+	t.Helper()
+	resp, body := w.get("/api/stats")
+	wantStatus(t, resp, body, http.StatusOK)
+	var stats apiStats
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestIndexerDebounceAndDrain(t *testing.T) {
+	w := newTestWiki(t)
+	startIndexer(t, time.Hour)
+
+	// Saves are only queued while the burst goes on.
+	for _, body := range []string{"first words", "fresh words"} {
+		w.seed("Home", body)
+	}
+	w.seed("Other", "fresh too")
+	if got := searchTitles("fresh"); len(got) != 0 {
+		t.Errorf("indexed before the debounce: %q", got)
+	}
+	if stats := getStats(t, w); stats.Pages != 2 || stats.IndexBacklog != 3 || stats.LastIndexed != nil {
+		t.Errorf("stats = %+v, want a backlog of 3 and no run yet", stats)
+	}
+
+	// Stopping applies what is still queued.
+	searchIndexer.stop()
+	got := searchTitles("fresh")
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"Home", "Other"}) {
+		t.Errorf("indexed after stop = %q", got)
+	}
+	stats := getStats(t, w)
+	if stats.IndexBacklog != 0 || stats.LastIndexed == nil || time.Since(*stats.LastIndexed) > time.Minute {
+		t.Errorf("stats after stop = %+v", stats)
+	}
+
+	// Later saves are dropped rather than blocking.
+	w.seed("Late", "fresh late")
+	if searchIndexer.backlog.Load() != 0 {
+		t.Error("a save after stop was queued")
+	}
+}
+
+func TestIndexerSettles(t *testing.T) {
+	w := newTestWiki(t)
+	startIndexer(t, 20*time.Millisecond)
+
+	w.seed("Home", "fresh words")
+	waitFor(t, func() bool { return slices.Equal(searchTitles("fresh"), []string{"Home"}) })
+	waitFor(t, func() bool { return searchIndexer.backlog.Load() == 0 })
+
+	// A rebuild picks up pages written behind the wiki's back, the way the
+	// admin refresh does it.
+	if err := store.Write("Sideloaded", []byte("fresh from disk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pages.rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	searchIndexer.requestRebuild()
+	waitFor(t, func() bool { return slices.Contains(searchTitles("fresh"), "Sideloaded") })
+}
+
+func TestIndexerFlushesLongBursts(t *testing.T) {
+	newTestWiki(t)
+	startIndexer(t, time.Hour)
+
+	for i := range maxIndexBatch {
+		searchIndexer.enqueue("Page-" + strconv.Itoa(i))
+	}
+	waitFor(t, func() bool { return searchIndexer.lastRun.Load() != 0 })
+	if n := searchIndexer.backlog.Load(); n != 0 {
+		t.Errorf("backlog = %d after a full batch", n)
+	}
+}
+
+func TestSetupIndexer(t *testing.T) {
+	setGlobal(t, &searchIndexer.debounce, defaultIndexDebounce)
+
+	t.Setenv("INDEX_DEBOUNCE", "2s")
+	if err := setupIndexer(); err != nil || searchIndexer.debounce != 2*time.Second {
+		t.Errorf("INDEX_DEBOUNCE=2s gives %v, %v", searchIndexer.debounce, err)
+	}
+	for _, raw := range []string{"-1s", "soon", "5"} {
+		t.Setenv("INDEX_DEBOUNCE", raw)
+		if err := setupIndexer(); err == nil {
+			t.Errorf("INDEX_DEBOUNCE=%q accepted", raw)
+		}
+	}
+}
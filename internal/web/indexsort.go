@@ -0,0 +1,90 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	indexSortCookieName = "index_sort"
+	indexSortMaxAge     = 365 * 24 * time.Hour
+)
+
+// indexSort is the page list ordering last chosen by the visitor. It is kept
+// in a cookie so anonymous visitors get the same treatment as users.
+type indexSort struct {
+	Sort    string `json:"sort,omitempty"`
+	Order   string `json:"order,omitempty"`
+	PerPage int    `json:"per_page,omitempty"`
+}
+
+// merge applies the fields set in override and fills in defaults, so the
+// result always names a known sort and order.
+func (s indexSort) merge(override indexSort) indexSort {
+	if override.Sort != "" {
+		s.Sort = override.Sort
+		if override.Order == "" {
+			s.Order = ""
+		}
+	}
+	if override.Order != "" {
+		s.Order = override.Order
+	}
+	if override.PerPage != 0 {
+		s.PerPage = override.PerPage
+	}
+
+	switch s.Sort {
+	case "size", "modified":
+	default:
+		s.Sort = "title"
+	}
+	if s.Order != "asc" && s.Order != "desc" {
+		// Largest and newest first, titles alphabetically.
+		s.Order = "desc"
+		if s.Sort == "title" {
+			s.Order = "asc"
+		}
+	}
+
+	return s
+}
+
+func readIndexSort(r *http.Request) indexSort {
+	c, err := r.Cookie(indexSortCookieName)
+	if err != nil {
+		return indexSort{}
+	}
+
+	value, err := verifyValue(c.Value)
+	if err != nil {
+		return indexSort{}
+	}
+
+	var s indexSort
+	if err := json.Unmarshal(value, &s); err != nil {
+		return indexSort{}
+	}
+	if s.PerPage < 0 || s.PerPage > 500 {
+		s.PerPage = 0
+	}
+
+	return s
+}
+
+func rememberIndexSort(w http.ResponseWriter, s indexSort) {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     indexSortCookieName,
+		Value:    signValue(value),
+		Path:     pageURL("/"),
+		MaxAge:   int(indexSortMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIndexSortMerge(t *testing.T) {
+	for _, tt := range []struct {
+		remembered, explicit, want indexSort
+	}{
+		{indexSort{}, indexSort{}, indexSort{"title", "asc", 0}},
+		{indexSort{}, indexSort{Sort: "size"}, indexSort{"size", "desc", 0}},
+		{indexSort{}, indexSort{Sort: "modified"}, indexSort{"modified", "desc", 0}},
+		{indexSort{"size", "asc", 10}, indexSort{}, indexSort{"size", "asc", 10}},
+		{indexSort{"size", "asc", 10}, indexSort{Order: "desc"}, indexSort{"size", "desc", 10}},
+		// A new sort starts from its own default order.
+		{indexSort{"size", "asc", 10}, indexSort{Sort: "title"}, indexSort{"title", "asc", 10}},
+		{indexSort{"size", "asc", 10}, indexSort{PerPage: 20}, indexSort{"size", "asc", 20}},
+		{indexSort{}, indexSort{Sort: "random", Order: "sideways"}, indexSort{"title", "asc", 0}},
+	} {
+		if got := tt.remembered.merge(tt.explicit); got != tt.want {
+			t.Errorf("%+v.merge(%+v) = %+v, want %+v", tt.remembered, tt.explicit, got, tt.want)
+		}
+	}
+}
+
+var indexItemPattern = regexp.MustCompile(`<a href="/view/([^"]+)">`)
+
+func indexOrder(body string) []string {
+	var titles []string
+	for _, m := range indexItemPattern.FindAllStringSubmatch(body, -1) {
+		titles = append(titles, m[1])
+	}
+	return titles
+}
+
+func sortCookie(resp *http.Response) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == indexSortCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestRememberedIndexSort(t *testing.T) {
+	w := newTestWiki(t)
+	for title, size := range map[string]int{"A": 30, "B": 10, "C": 40, "D": 20} {
+		w.seed(title, strings.Repeat("x", size))
+	}
+
+	resp, body := w.get("/?sort=size&order=asc&per_page=3")
+	wantStatus(t, resp, body, http.StatusOK)
+	remembered := sortCookie(resp)
+	if remembered == nil || !remembered.HttpOnly {
+		t.Fatalf("explicit sort set no HttpOnly cookie: %v", resp.Cookies())
+	}
+	if got := indexOrder(body); !slices.Equal(got, []string{"B", "D", "A"}) {
+		t.Errorf("explicit sort = %q", got)
+	}
+
+	resp, body = w.get("/", remembered)
+	if got := indexOrder(body); !slices.Equal(got, []string{"B", "D", "A"}) {
+		t.Errorf("remembered sort = %q", got)
+	}
+	if sortCookie(resp) != nil {
+		t.Error("a request without sort params rewrote the cookie")
+	}
+	if _, body = w.get("/?page=2", remembered); !slices.Equal(indexOrder(body), []string{"C"}) {
+		t.Errorf("second page of the remembered sort = %q", indexOrder(body))
+	}
+
+	// Explicit params win and become the new remembered sort.
+	resp, body = w.get("/?order=desc", remembered)
+	if got := indexOrder(body); !slices.Equal(got, []string{"C", "A", "D"}) {
+		t.Errorf("?order=desc over the cookie = %q", got)
+	}
+	if _, body = w.get("/", sortCookie(resp)); !slices.Equal(indexOrder(body), []string{"C", "A", "D"}) {
+		t.Errorf("updated remembered sort = %q", indexOrder(body))
+	}
+
+	// Without a valid cookie the default applies.
+	for _, c := range []*http.Cookie{nil, {Name: indexSortCookieName, Value: remembered.Value + "x"}} {
+		var cookies []*http.Cookie
+		if c != nil {
+			cookies = append(cookies, c)
+		}
+		if _, body = w.get("/", cookies...); !slices.Equal(indexOrder(body), []string{"A", "B", "C", "D"}) {
+			t.Errorf("cookie %v: order = %q", c, indexOrder(body))
+		}
+	}
+}
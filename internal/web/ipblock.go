@@ -0,0 +1,217 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ipBlock struct {
+	Prefix  netip.Prefix `json:"prefix"`
+	Reason  string       `json:"reason,omitempty"`
+	AddedBy string       `json:"added_by,omitempty"`
+	Expires time.Time    `json:"expires,omitzero"`
+}
+
+func (b ipBlock) expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+type ipBlocklist struct {
+	mu      sync.Mutex
+	static  []netip.Prefix
+	entries []ipBlock
+}
+
+var ipBlocks = &ipBlocklist{}
+
+func ipBlocksFilename() string {
+	return filepath.Join(config.StoragePath, ".ipblocks.json")
+}
+
+// parsePrefix accepts either a CIDR range or a single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func setupIPBlocklist() error {
+	for _, raw := range splitList(os.Getenv("IP_BLOCKLIST"), ",") {
+		p, err := parsePrefix(raw)
+		if err != nil {
+			return fmt.Errorf("invalid IP_BLOCKLIST entry %q: %w", raw, err)
+		}
+		ipBlocks.static = append(ipBlocks.static, p)
+	}
+
+	b, err := os.ReadFile(ipBlocksFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, &ipBlocks.entries)
+}
+
+func (l *ipBlocklist) persist() error {
+	b, err := json.Marshal(l.entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(config.StoragePath, 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(ipBlocksFilename(), b, 0600)
+}
+
+func (l *ipBlocklist) blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, p := range l.static {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	for _, b := range l.entries {
+		if !b.expired(now) && b.Prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *ipBlocklist) add(b ipBlock) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = slices.DeleteFunc(l.entries, func(e ipBlock) bool {
+		return e.Prefix == b.Prefix
+	})
+	l.entries = append(l.entries, b)
+
+	return l.persist()
+}
+
+func (l *ipBlocklist) remove(p netip.Prefix) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = slices.DeleteFunc(l.entries, func(e ipBlock) bool {
+		return e.Prefix == p
+	})
+
+	return l.persist()
+}
+
+func (l *ipBlocklist) list() []ipBlock {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var active []ipBlock
+	for _, b := range l.entries {
+		if !b.expired(now) {
+			active = append(active, b)
+		}
+	}
+
+	return active
+}
+
+// errIPBlocked is what a blocked address gets back from every route that
+// changes pages, the forms and the API alike.
+var errIPBlocked = &formError{http.StatusForbidden, "Edits from your network address are blocked."}
+
+func ipBlocked(r *http.Request) bool {
+	addr := clientIP(r)
+	return addr.IsValid() && ipBlocks.blocked(addr)
+}
+
+type ipBlocksData struct {
+	Static    []netip.Prefix
+	Entries   []ipBlock
+	Error     string
+	RateLimit string
+	Tracked   int
+}
+
+func ipBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can manage the IP blocklist.")
+		return
+	}
+
+	data := &ipBlocksData{Static: ipBlocks.static}
+	if writes != nil {
+		data.RateLimit = fmt.Sprintf("%d saves per %s", writes.limit, writes.window)
+		data.Tracked = writes.tracked()
+	}
+	status := http.StatusOK
+
+	if r.Method == http.MethodPost {
+		if err := updateIPBlocks(r, s.User); err != nil {
+			data.Error = err.Error()
+			status = http.StatusBadRequest
+		} else {
+			http.Redirect(w, r, pageURL("/admin/ipblocks"), http.StatusFound)
+			return
+		}
+	}
+
+	data.Entries = ipBlocks.list()
+	renderTemplate(w, r, pageData{Title: "IP blocklist", Status: status, Content: data}, "ipblocks")
+}
+
+func updateIPBlocks(r *http.Request, admin string) error {
+	p, err := parsePrefix(r.FormValue("prefix"))
+	if err != nil {
+		return fmt.Errorf("invalid address or range %q", r.FormValue("prefix"))
+	}
+
+	if r.FormValue("action") == "remove" {
+		return ipBlocks.remove(p)
+	}
+
+	b := ipBlock{Prefix: p, Reason: r.FormValue("reason"), AddedBy: admin}
+	if raw := r.FormValue("duration"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		b.Expires = time.Now().Add(d).UTC()
+	}
+
+	return ipBlocks.add(b)
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"192.0.2.1", "192.0.2.1/32"},
+		{" 192.0.2.1 ", "192.0.2.1/32"},
+		{"192.0.2.77/24", "192.0.2.0/24"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8:abcd::1/48", "2001:db8:abcd::/48"},
+		{"::ffff:192.0.2.1", "192.0.2.1/32"},
+		{"fe80::1%eth0", "fe80::1/128"},
+	}
+	for _, tt := range tests {
+		p, err := parsePrefix(tt.in)
+		if err != nil || p.String() != tt.want {
+			t.Errorf("parsePrefix(%q) = %v, %v, want %s", tt.in, p, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "192.0.2", "192.0.2.1/33", "2001:db8::/129", "example.com", "192.0.2.1/-1"} {
+		if p, err := parsePrefix(in); err == nil {
+			t.Errorf("parsePrefix(%q) = %v, want an error", in, p)
+		}
+	}
+}
+
+func TestIPBlocklistMatching(t *testing.T) {
+	l := &ipBlocklist{static: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	newTestWiki(t)
+	for _, raw := range []string{"192.0.2.0/24", "2001:db8::/32", "198.51.100.7"} {
+		if err := l.add(ipBlock{Prefix: netip.MustParsePrefix(mustPrefix(t, raw))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.add(ipBlock{Prefix: netip.MustParsePrefix("203.0.113.0/24"), Expires: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.0.2.0", true},
+		{"192.0.2.255", true},
+		{"192.0.3.0", false},
+		{"192.0.1.255", false},
+		{"198.51.100.7", true},
+		{"198.51.100.8", false},
+		{"::ffff:192.0.2.9", true},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db8:ffff:ffff::1", true},
+		{"2001:db9::1", false},
+		{"::1", false},
+		{"203.0.113.5", false},
+	}
+	for _, tt := range tests {
+		if got := l.blocked(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("blocked(%s) = %v, want %v", tt.addr, got, tt.blocked)
+		}
+	}
+
+	if got := len(l.list()); got != 3 {
+		t.Errorf("list has %d active entries, want 3 without the expired one", got)
+	}
+}
+
+func mustPrefix(t *testing.T, raw string) string {
+	t.Helper()
+	p, err := parsePrefix(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.String()
+}
+
+func TestIPBlocklistPersists(t *testing.T) {
+	newTestWiki(t)
+	b := ipBlock{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Reason: "vandalism", AddedBy: "root", Expires: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+	if err := ipBlocks.add(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart reads the entries back from STORAGE_PATH.
+	resetState(t)
+	t.Setenv("IP_BLOCKLIST", "2001:db8::/32, 10.0.0.1")
+	if err := setupIPBlocklist(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := ipBlocks.list()
+	if len(entries) != 1 || entries[0] != b {
+		t.Errorf("entries after reload = %+v, want %+v", entries, b)
+	}
+	if got := ipBlocks.static; len(got) != 2 || got[0].String() != "2001:db8::/32" || got[1].String() != "10.0.0.1/32" {
+		t.Errorf("static entries = %v", got)
+	}
+
+	t.Setenv("IP_BLOCKLIST", "not-an-ip")
+	if err := setupIPBlocklist(); err == nil || !strings.Contains(err.Error(), "IP_BLOCKLIST") {
+		t.Errorf("setupIPBlocklist with a bad entry = %v", err)
+	}
+}
+
+func TestIPBlocksAdmin(t *testing.T) {
+	w := newTestWiki(t)
+	admin := w.login("root", roleAdmin)
+
+	resp, body := w.post("/admin/ipblocks", url.Values{"prefix": {"192.0.2.1"}}, w.login("bob", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	resp, body = w.post("/admin/ipblocks", url.Values{"prefix": {"192.0.2.0/24"}, "reason": {"spam"}, "duration": {"1h"}}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	entries := ipBlocks.list()
+	if len(entries) != 1 || entries[0].AddedBy != "root" || entries[0].Reason != "spam" || time.Until(entries[0].Expires) > time.Hour {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	resp, body = w.post("/admin/ipblocks", url.Values{"prefix": {"nonsense"}}, admin)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	resp, body = w.post("/admin/ipblocks", url.Values{"prefix": {"192.0.2.9"}, "duration": {"-1h"}}, admin)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	resp, body = w.get("/admin/ipblocks", admin)
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "192.0.2.0/24") || !strings.Contains(body, "spam") {
+		t.Errorf("admin page does not list the entry:\n%s", body)
+	}
+
+	resp, body = w.post("/admin/ipblocks", url.Values{"prefix": {"192.0.2.0/24"}, "action": {"remove"}}, admin)
+	wantStatus(t, resp, body, http.StatusFound)
+	if entries := ipBlocks.list(); len(entries) != 0 {
+		t.Errorf("entries after remove = %+v", entries)
+	}
+	if b, err := os.ReadFile(ipBlocksFilename()); err != nil || string(b) != "null" && string(b) != "[]" {
+		t.Errorf("persisted entries after remove = %s, %v", b, err)
+	}
+}
+
+// Blocked addresses can still read, but every way of changing a page is
+// refused, whatever the method.
+func TestIPBlockedWrites(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "original")
+	w.seed("Home", "second")
+	ipBlocks.static = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+	for _, path := range []string{"/view/Home", "/raw/Home", "/history/Home", "/api/pages/Home"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusOK)
+	}
+
+	form := url.Values{"title": {"Home"}, "body": {"vandalism"}}
+	for _, tt := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/save/Home", form.Encode()},
+		{http.MethodGet, "/delete/Home", ""},
+		{http.MethodPost, "/delete/Home", ""},
+		{http.MethodGet, "/undo/Home", ""},
+		{http.MethodPost, "/revert/Home", url.Values{"rev": {"1"}}.Encode()},
+		{http.MethodPost, "/copy/Home", url.Values{"newTitle": {"Copy"}}.Encode()},
+		{http.MethodPost, "/rename/Home", url.Values{"newTitle": {"Moved"}}.Encode()},
+		{http.MethodPut, "/api/pages/Home", `{"body":"vandalism"}`},
+		{http.MethodPost, "/api/pages/Home/revert", `{"revision":1}`},
+		{http.MethodPost, "/api/pages/Home/copy", `{"newTitle":"Copy"}`},
+	} {
+		req, err := http.NewRequest(tt.method, w.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, body := w.do(req)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s from a blocked address = %d, want 403\n%s", tt.method, tt.path, resp.StatusCode, body)
+		}
+	}
+
+	if body, err := store.Read("Home"); err != nil || string(body) != "second" {
+		t.Errorf("page after blocked writes = %q, %v", body, err)
+	}
+	for _, title := range []string{"Copy", "Moved"} {
+		if _, err := store.Stat(title); err == nil {
+			t.Errorf("%s was created from a blocked address", title)
+		}
+	}
+}
+
+func TestIPBlockForwardedFor(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &trustedProxies, nil)
+	ipBlocks.static = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+
+	save := func() *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, w.URL+"/save/Home", strings.NewReader("title=Home&body=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-For", "192.0.2.10")
+		resp, _ := w.do(req)
+		return resp
+	}
+
+	if resp := save(); resp.StatusCode != http.StatusFound {
+		t.Errorf("X-Forwarded-For from an untrusted peer was honored: %d", resp.StatusCode)
+	}
+
+	trustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+	if resp := save(); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For from a trusted proxy was ignored: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,230 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtAuthenticator struct {
+	secret    []byte
+	jwks      *jwksCache
+	issuer    string
+	audience  string
+	roleClaim string
+}
+
+var jwtAuth *jwtAuthenticator
+
+func setupJWTAuth() error {
+	secret := os.Getenv("JWT_SECRET")
+	jwksURL := os.Getenv("JWT_JWKS_URL")
+	if secret == "" && jwksURL == "" {
+		return nil
+	}
+
+	a := &jwtAuthenticator{
+		issuer:    os.Getenv("JWT_ISSUER"),
+		audience:  os.Getenv("JWT_AUDIENCE"),
+		roleClaim: envOrDefault("JWT_ROLE_CLAIM", "role"),
+	}
+	if secret != "" {
+		a.secret = []byte(secret)
+	}
+	if jwksURL != "" {
+		refresh := time.Hour
+		if raw := os.Getenv("JWT_JWKS_REFRESH"); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("invalid JWT_JWKS_REFRESH %q", raw)
+			}
+			refresh = d
+		}
+		a.jwks = &jwksCache{url: jwksURL, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+	}
+
+	jwtAuth = a
+	return nil
+}
+
+// verify checks the token and returns the wiki role from the configured claim,
+// or a short reason category when the token is rejected.
+func (a *jwtAuthenticator) verify(raw string) (string, string) {
+	var methods []string
+	if a.secret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if a.jwks != nil {
+		methods = append(methods, "RS256", "RS384", "RS512", "ES256", "ES384", "ES512")
+	}
+
+	// Tokens must expire; iat and nbf are checked when present, all with the
+	// same leeway for clock skew.
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		opts = append(opts, jwt.WithAudience(a.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, a.key, opts...)
+	switch {
+	case err == nil:
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "", "malformed"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "", "expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "", "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "", "invalid_audience"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "", "invalid_issuer"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "", "missing_claim"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "", "invalid_signature"
+	default:
+		return "", "invalid_token"
+	}
+
+	role, _ := claims[a.roleClaim].(string)
+	if !validRole(role) {
+		return "", "invalid_role"
+	}
+
+	return role, ""
+}
+
+func (a *jwtAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return a.jwks.key(kid)
+}
+
+type jwksCache struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// key returns the public key for kid, refetching the set when it is stale or
+// the kid is unknown, but at most once a minute so bad tokens can't hammer the provider.
+func (c *jwksCache) key(kid string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetched) > c.refresh
+	if (!ok || stale) && time.Since(c.fetched) > time.Minute {
+		if err := c.fetch(); err != nil {
+			if !ok {
+				return nil, err
+			}
+		} else {
+			key, ok = c.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (c *jwksCache) fetch() error {
+	c.fetched = time.Now()
+
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected JWKS status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	c.keys = keys
+	return nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// validClaims are the claims of a token the test authenticator accepts.
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":  "ci-bot",
+		"role": roleEditor,
+		"iss":  "https://id.example.com",
+		"aud":  "wiki",
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+	}
+}
+
+func with(claims jwt.MapClaims, k string, v any) jwt.MapClaims {
+	claims[k] = v
+	return claims
+}
+
+func without(claims jwt.MapClaims, k string) jwt.MapClaims {
+	delete(claims, k)
+	return claims
+}
+
+func TestJWTVerify(t *testing.T) {
+	a := &jwtAuthenticator{secret: []byte("jwt-secret"), issuer: "https://id.example.com", audience: "wiki", roleClaim: "role"}
+	now := time.Now()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256, err := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims()).SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		role   string
+		reason string
+	}{
+		{"valid", signHS256(t, "jwt-secret", validClaims()), roleEditor, ""},
+		{"audience list", signHS256(t, "jwt-secret", with(validClaims(), "aud", []string{"other", "wiki"})), roleEditor, ""},
+		{"within leeway", signHS256(t, "jwt-secret", with(validClaims(), "exp", now.Add(-10*time.Second).Unix())), roleEditor, ""},
+		{"expired", signHS256(t, "jwt-secret", with(validClaims(), "exp", now.Add(-time.Minute).Unix())), "", "expired"},
+		{"no expiry", signHS256(t, "jwt-secret", without(validClaims(), "exp")), "", "missing_claim"},
+		{"not yet valid", signHS256(t, "jwt-secret", with(validClaims(), "nbf", now.Add(time.Hour).Unix())), "", "not_yet_valid"},
+		{"issued in the future", signHS256(t, "jwt-secret", with(validClaims(), "iat", now.Add(time.Hour).Unix())), "", "not_yet_valid"},
+		{"iat within leeway", signHS256(t, "jwt-secret", with(validClaims(), "iat", now.Add(10*time.Second).Unix())), roleEditor, ""},
+		{"wrong secret", signHS256(t, "other-secret", validClaims()), "", "invalid_signature"},
+		{"wrong audience", signHS256(t, "jwt-secret", with(validClaims(), "aud", "billing")), "", "invalid_audience"},
+		{"no audience", signHS256(t, "jwt-secret", without(validClaims(), "aud")), "", "missing_claim"},
+		{"wrong issuer", signHS256(t, "jwt-secret", with(validClaims(), "iss", "https://evil.example.com")), "", "invalid_issuer"},
+		{"no issuer", signHS256(t, "jwt-secret", without(validClaims(), "iss")), "", "missing_claim"},
+		{"unknown role", signHS256(t, "jwt-secret", with(validClaims(), "role", "owner")), "", "invalid_role"},
+		{"no role", signHS256(t, "jwt-secret", without(validClaims(), "role")), "", "invalid_role"},
+		{"malformed", "not.a.jwt", "", "malformed"},
+		{"RS256 without a JWKS", rs256, "", "invalid_signature"},
+		{"alg none", none, "", "invalid_signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, reason := a.verify(tt.token)
+			if role != tt.role || reason != tt.reason {
+				t.Errorf("verify = %q, %q, want %q, %q", role, reason, tt.role, tt.reason)
+			}
+		})
+	}
+
+	// Without an issuer or audience to check, expiry is still required.
+	plain := &jwtAuthenticator{secret: []byte("jwt-secret"), roleClaim: "role"}
+	if role, reason := plain.verify(signHS256(t, "jwt-secret", jwt.MapClaims{"role": roleAdmin})); role != "" || reason != "missing_claim" {
+		t.Errorf("token without exp = %q, %q", role, reason)
+	}
+}
+
+func b64(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// stubJWKS serves the public halves of keys, counting the fetches.
+func stubJWKS(t *testing.T, keys map[string]any) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		var set []map[string]string
+		for kid, k := range keys {
+			switch k := k.(type) {
+			case *rsa.PrivateKey:
+				set = append(set, map[string]string{"kid": kid, "kty": "RSA", "n": b64(k.N), "e": b64(big.NewInt(int64(k.E)))})
+			case *ecdsa.PrivateKey:
+				set = append(set, map[string]string{"kid": kid, "kty": "EC", "crv": "P-256", "x": b64(k.X), "y": b64(k.Y)})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": set})
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &fetches
+}
+
+func signWithKid(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, validClaims())
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]any{"rsa-1": rsaKey, "ec-1": ecKey}
+	provider, fetches := stubJWKS(t, keys)
+	a := &jwtAuthenticator{
+		jwks:      &jwksCache{url: provider.URL, refresh: time.Hour, client: provider.Client()},
+		issuer:    "https://id.example.com",
+		audience:  "wiki",
+		roleClaim: "role",
+	}
+
+	for _, tt := range []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{"rsa", signWithKid(t, jwt.SigningMethodRS256, "rsa-1", rsaKey), ""},
+		{"ec", signWithKid(t, jwt.SigningMethodES256, "ec-1", ecKey), ""},
+		{"signed by another key", signWithKid(t, jwt.SigningMethodRS256, "rsa-1", otherKey), "invalid_signature"},
+		{"unknown kid", signWithKid(t, jwt.SigningMethodRS256, "rsa-2", otherKey), "invalid_signature"},
+		{"HS256 without a secret", signHS256(t, "", validClaims()), "invalid_signature"},
+	} {
+		if _, reason := a.verify(tt.token); reason != tt.reason {
+			t.Errorf("%s: reason %q, want %q", tt.name, reason, tt.reason)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want once; unknown kids must not refetch within a minute", n)
+	}
+
+	// A rotated key is picked up once the minute has passed.
+	keys["rsa-2"] = otherKey
+	a.jwks.mu.Lock()
+	a.jwks.fetched = time.Now().Add(-2 * time.Minute)
+	a.jwks.mu.Unlock()
+	if role, reason := a.verify(signWithKid(t, jwt.SigningMethodRS256, "rsa-2", otherKey)); reason != "" || role != roleEditor {
+		t.Errorf("rotated key = %q, %q", role, reason)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want a refetch for the new kid", n)
+	}
+
+	// Known keys keep working while the provider is down.
+	provider.Close()
+	a.jwks.mu.Lock()
+	a.jwks.fetched = time.Now().Add(-2 * time.Hour)
+	a.jwks.mu.Unlock()
+	if _, reason := a.verify(signWithKid(t, jwt.SigningMethodRS256, "rsa-1", rsaKey)); reason != "" {
+		t.Errorf("cached key rejected while the JWKS is unreachable: %q", reason)
+	}
+}
+
+func TestSetupJWTAuth(t *testing.T) {
+	setGlobal(t, &jwtAuth, nil)
+
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_JWKS_URL", "")
+	if err := setupJWTAuth(); err != nil || jwtAuth != nil {
+		t.Errorf("JWT auth enabled without settings: %v", err)
+	}
+
+	t.Setenv("JWT_JWKS_URL", "https://id.example.com/jwks.json")
+	t.Setenv("JWT_JWKS_REFRESH", "sometimes")
+	if err := setupJWTAuth(); err == nil {
+		t.Error("setupJWTAuth accepted a bad JWT_JWKS_REFRESH")
+	}
+
+	t.Setenv("JWT_JWKS_REFRESH", "10m")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+	t.Setenv("JWT_ROLE_CLAIM", "wiki_role")
+	if err := setupJWTAuth(); err != nil || jwtAuth.jwks.refresh != 10*time.Minute || string(jwtAuth.secret) != "jwt-secret" || jwtAuth.roleClaim != "wiki_role" {
+		t.Errorf("setupJWTAuth = %v, %+v", err, jwtAuth)
+	}
+}
+
+// JWTs and static tokens both open the API, and every rejection is a JSON
+// 401 naming the reason.
+func TestAPIAuth(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	setGlobal(t, &apiTokens, map[string]string{"static-token": roleReader})
+	setGlobal(t, &jwtAuth, &jwtAuthenticator{secret: []byte("jwt-secret"), audience: "wiki", roleClaim: "role"})
+
+	get := func(token string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, w.URL+"/api/pages/Home", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return w.do(req)
+	}
+
+	for _, token := range []string{"static-token", signHS256(t, "jwt-secret", validClaims())} {
+		resp, body := get(token)
+		wantStatus(t, resp, body, http.StatusOK)
+	}
+
+	for _, tt := range []struct {
+		name, token, reason string
+	}{
+		{"no token", "", "missing_token"},
+		{"unknown static token", "guess", "malformed"},
+		{"expired", signHS256(t, "jwt-secret", with(validClaims(), "exp", time.Now().Add(-time.Hour).Unix())), "expired"},
+		{"wrongly signed", signHS256(t, "other", validClaims()), "invalid_signature"},
+		{"wrong audience", signHS256(t, "jwt-secret", with(validClaims(), "aud", "billing")), "invalid_audience"},
+	} {
+		resp, body := get(tt.token)
+		var res map[string]string
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Content-Type") != "application/json" || json.Unmarshal([]byte(body), &res) != nil || res["reason"] != tt.reason || res["error"] != "unauthorized" {
+			t.Errorf("%s: %d %s, want a JSON 401 with reason %q", tt.name, resp.StatusCode, body, tt.reason)
+		}
+	}
+
+	// Without a JWT configured an unknown token is simply invalid.
+	jwtAuth = nil
+	resp, body := get(signHS256(t, "jwt-secret", validClaims()))
+	if resp.StatusCode != http.StatusUnauthorized || !json.Valid([]byte(body)) {
+		t.Errorf("JWT with JWT auth off = %d %s", resp.StatusCode, body)
+	}
+	resp, body = get("static-token")
+	wantStatus(t, resp, body, http.StatusOK)
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapConn is the part of *ldap.Conn the backend uses, so it can be replaced by a stub.
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+type ldapUser struct {
+	dn      string
+	groups  []string
+	expires time.Time
+}
+
+type ldapBackend struct {
+	dial         func() (ldapConn, error)
+	bindDN       string
+	bindPassword string
+	userDN       string
+	baseDN       string
+	userFilter   string
+	groupAttr    string
+	roleGroups   map[string][]string
+	cacheTTL     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]ldapUser
+}
+
+func newLDAPBackend() (*ldapBackend, error) {
+	b := &ldapBackend{
+		bindDN:       os.Getenv("LDAP_BIND_DN"),
+		bindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		userDN:       os.Getenv("LDAP_USER_DN_TEMPLATE"),
+		baseDN:       os.Getenv("LDAP_BASE_DN"),
+		userFilter:   envOrDefault("LDAP_USER_FILTER", "(uid=%s)"),
+		groupAttr:    envOrDefault("LDAP_GROUP_ATTR", "memberOf"),
+		roleGroups: map[string][]string{
+			roleAdmin:  splitList(os.Getenv("LDAP_ADMIN_GROUPS"), ";"),
+			roleEditor: splitList(os.Getenv("LDAP_EDITOR_GROUPS"), ";"),
+			roleReader: splitList(os.Getenv("LDAP_READER_GROUPS"), ";"),
+		},
+		cacheTTL: 5 * time.Minute,
+		cache:    map[string]ldapUser{},
+	}
+
+	if b.userDN == "" && (b.baseDN == "" || b.bindDN == "") {
+		return nil, errors.New("LDAP_USER_DN_TEMPLATE or LDAP_BIND_DN with LDAP_BASE_DN is required when LDAP_URL is set")
+	}
+
+	if raw := os.Getenv("LDAP_CACHE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LDAP_CACHE_TTL %q", raw)
+		}
+		b.cacheTTL = ttl
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: os.Getenv("LDAP_INSECURE_SKIP_VERIFY") == "true"}
+	if path := os.Getenv("LDAP_CA_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading LDAP_CA_FILE: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("LDAP_CA_FILE contains no certificates")
+		}
+	}
+
+	url := os.Getenv("LDAP_URL")
+	startTLS := os.Getenv("LDAP_START_TLS") == "true"
+	b.dial = func() (ldapConn, error) {
+		conn, err := ldap.DialURL(url, ldap.DialWithTLSConfig(tlsConfig))
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(10 * time.Second)
+
+		if startTLS {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+
+		return conn, nil
+	}
+
+	return b, nil
+}
+
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func (b *ldapBackend) Authenticate(username, password string) (string, error) {
+	// An empty password would be an unauthenticated bind, which most servers accept.
+	if username == "" || password == "" {
+		return "", errInvalidCredentials
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errAuthUnavailable, err)
+	}
+	defer conn.Close()
+
+	user, cached := b.cached(username)
+	if !cached {
+		user, err = b.lookup(conn, username, password)
+		if err != nil {
+			return "", err
+		}
+	} else if err := conn.Bind(user.dn, password); err != nil {
+		return "", ldapError(err)
+	}
+
+	role, ok := b.role(user.groups)
+	if !ok {
+		return "", errInvalidCredentials
+	}
+
+	if !cached {
+		user.expires = time.Now().Add(b.cacheTTL)
+		b.mu.Lock()
+		b.cache[strings.ToLower(username)] = user
+		b.mu.Unlock()
+	}
+
+	return role, nil
+}
+
+func (b *ldapBackend) cached(username string) (ldapUser, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := strings.ToLower(username)
+	user, ok := b.cache[key]
+	if ok && time.Now().After(user.expires) {
+		delete(b.cache, key)
+		return ldapUser{}, false
+	}
+
+	return user, ok
+}
+
+// lookup verifies the password and reads the user's groups, either by binding
+// directly with the DN template or by searching with the service account first.
+func (b *ldapBackend) lookup(conn ldapConn, username, password string) (ldapUser, error) {
+	escaped := ldap.EscapeFilter(username)
+	req := ldap.NewSearchRequest(
+		b.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 10, false,
+		fmt.Sprintf(b.userFilter, escaped), []string{b.groupAttr}, nil,
+	)
+
+	if b.userDN != "" {
+		dn := fmt.Sprintf(b.userDN, ldap.EscapeDN(username))
+		if err := conn.Bind(dn, password); err != nil {
+			return ldapUser{}, ldapError(err)
+		}
+		req.BaseDN = dn
+		req.Scope = ldap.ScopeBaseObject
+		req.Filter = "(objectClass=*)"
+	} else if err := conn.Bind(b.bindDN, b.bindPassword); err != nil {
+		return ldapUser{}, fmt.Errorf("%w: service account bind failed: %v", errAuthUnavailable, err)
+	}
+
+	res, err := conn.Search(req)
+	if err != nil {
+		return ldapUser{}, ldapError(err)
+	}
+	if len(res.Entries) != 1 {
+		return ldapUser{}, errInvalidCredentials
+	}
+
+	entry := res.Entries[0]
+	if b.userDN == "" {
+		if err := conn.Bind(entry.DN, password); err != nil {
+			return ldapUser{}, ldapError(err)
+		}
+	}
+
+	return ldapUser{dn: entry.DN, groups: entry.GetAttributeValues(b.groupAttr)}, nil
+}
+
+// role picks the highest role whose groups the user belongs to. Without any
+// reader groups configured every authenticated user may read.
+func (b *ldapBackend) role(groups []string) (string, bool) {
+	for _, role := range []string{roleAdmin, roleEditor, roleReader} {
+		for _, want := range b.roleGroups[role] {
+			for _, group := range groups {
+				if strings.EqualFold(group, want) {
+					return role, true
+				}
+			}
+		}
+	}
+
+	if len(b.roleGroups[roleReader]) == 0 {
+		return roleReader, true
+	}
+
+	return "", false
+}
+
+func ldapError(err error) error {
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return errInvalidCredentials
+	}
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return errInvalidCredentials
+	}
+
+	return fmt.Errorf("%w: %v", errAuthUnavailable, err)
+}
@@ -0,0 +1,264 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+type ldapEntry struct {
+	password string
+	groups   []string
+}
+
+// stubDirectory is an in-memory LDAP server keyed by lowercased DN.
+type stubDirectory struct {
+	entries map[string]ldapEntry
+	down    bool
+
+	mu       sync.Mutex
+	searches int
+	binds    []string
+}
+
+func newStubDirectory() *stubDirectory {
+	return &stubDirectory{entries: map[string]ldapEntry{
+		"cn=svc,dc=example,dc=com":              {password: "svc-secret"},
+		"uid=alice,ou=people,dc=example,dc=com": {password: "alice-pw", groups: []string{"cn=Wiki-Admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"}},
+		"uid=bob,ou=people,dc=example,dc=com":   {password: "bob-pw", groups: []string{"cn=wiki-editors,ou=groups,dc=example,dc=com"}},
+		"uid=carol,ou=people,dc=example,dc=com": {password: "carol-pw", groups: []string{"cn=staff,ou=groups,dc=example,dc=com"}},
+	}}
+}
+
+func (d *stubDirectory) dial() (ldapConn, error) {
+	if d.down {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("dial tcp 192.0.2.1:636: connect: connection refused"))
+	}
+	return stubConn{d}, nil
+}
+
+type stubConn struct{ d *stubDirectory }
+
+func (c stubConn) Bind(dn, password string) error {
+	c.d.mu.Lock()
+	c.d.binds = append(c.d.binds, dn)
+	c.d.mu.Unlock()
+
+	e, ok := c.d.entries[strings.ToLower(dn)]
+	if !ok || e.password != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (c stubConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.d.mu.Lock()
+	c.d.searches++
+	c.d.mu.Unlock()
+
+	res := &ldap.SearchResult{}
+	add := func(dn string) {
+		res.Entries = append(res.Entries, ldap.NewEntry(dn, map[string][]string{"memberOf": c.d.entries[strings.ToLower(dn)].groups}))
+	}
+
+	if req.Scope == ldap.ScopeBaseObject {
+		if _, ok := c.d.entries[strings.ToLower(req.BaseDN)]; !ok {
+			return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+		}
+		add(req.BaseDN)
+		return res, nil
+	}
+
+	uid, ok := strings.CutPrefix(req.Filter, "(uid=")
+	if !ok {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, errors.New("unsupported filter "+req.Filter))
+	}
+	dn := "uid=" + strings.TrimSuffix(uid, ")") + ",ou=people," + req.BaseDN
+	if _, ok := c.d.entries[strings.ToLower(dn)]; ok {
+		add(dn)
+	}
+	return res, nil
+}
+
+func (stubConn) Close() error { return nil }
+
+func newTestLDAPBackend(t *testing.T, dir *stubDirectory, env map[string]string) *ldapBackend {
+	t.Helper()
+	t.Setenv("LDAP_URL", "ldaps://ldap.example.com")
+	t.Setenv("LDAP_BASE_DN", "dc=example,dc=com")
+	t.Setenv("LDAP_ADMIN_GROUPS", "cn=wiki-admins,ou=groups,dc=example,dc=com")
+	t.Setenv("LDAP_EDITOR_GROUPS", "cn=wiki-editors,ou=groups,dc=example,dc=com")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	b, err := newLDAPBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.dial = dir.dial
+	return b
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"dn template":     {"LDAP_USER_DN_TEMPLATE": "uid=%s,ou=people,dc=example,dc=com"},
+		"search and bind": {"LDAP_BIND_DN": "cn=svc,dc=example,dc=com", "LDAP_BIND_PASSWORD": "svc-secret"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := newTestLDAPBackend(t, newStubDirectory(), env)
+
+			for _, tt := range []struct {
+				user, password string
+				role           string
+				err            error
+			}{
+				{"alice", "alice-pw", roleAdmin, nil},
+				{"bob", "bob-pw", roleEditor, nil},
+				// No reader groups configured: any directory user may read.
+				{"carol", "carol-pw", roleReader, nil},
+				{"alice", "wrong", "", errInvalidCredentials},
+				{"nobody", "pw", "", errInvalidCredentials},
+				{"alice", "", "", errInvalidCredentials},
+				{"", "alice-pw", "", errInvalidCredentials},
+				{"*", "alice-pw", "", errInvalidCredentials},
+			} {
+				role, err := b.Authenticate(tt.user, tt.password)
+				if role != tt.role || !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+					t.Errorf("Authenticate(%q, %q) = %q, %v, want %q, %v", tt.user, tt.password, role, err, tt.role, tt.err)
+				}
+			}
+		})
+	}
+}
+
+func TestLDAPReaderGroups(t *testing.T) {
+	b := newTestLDAPBackend(t, newStubDirectory(), map[string]string{
+		"LDAP_USER_DN_TEMPLATE": "uid=%s,ou=people,dc=example,dc=com",
+		"LDAP_READER_GROUPS":    "cn=readers,ou=groups,dc=example,dc=com",
+	})
+	if role, err := b.Authenticate("carol", "carol-pw"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("carol outside every group got %q, %v", role, err)
+	}
+	if role, err := b.Authenticate("bob", "bob-pw"); err != nil || role != roleEditor {
+		t.Errorf("bob = %q, %v", role, err)
+	}
+}
+
+// The groups are cached, but the password is checked on every login.
+func TestLDAPCache(t *testing.T) {
+	dir := newStubDirectory()
+	b := newTestLDAPBackend(t, dir, map[string]string{"LDAP_USER_DN_TEMPLATE": "uid=%s,ou=people,dc=example,dc=com"})
+
+	for range 3 {
+		if role, err := b.Authenticate("Alice", "alice-pw"); err != nil || role != roleAdmin {
+			t.Fatalf("Authenticate = %q, %v", role, err)
+		}
+	}
+	if dir.searches != 1 || len(dir.binds) != 3 {
+		t.Errorf("%d searches and %d binds for three logins, want 1 and 3", dir.searches, len(dir.binds))
+	}
+	if _, err := b.Authenticate("alice", "wrong"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("a cached user logged in with a wrong password: %v", err)
+	}
+
+	// Group changes are seen once the entry expires.
+	e := dir.entries["uid=alice,ou=people,dc=example,dc=com"]
+	e.groups = []string{"cn=wiki-editors,ou=groups,dc=example,dc=com"}
+	dir.entries["uid=alice,ou=people,dc=example,dc=com"] = e
+	b.mu.Lock()
+	user := b.cache["alice"]
+	user.expires = time.Now().Add(-time.Second)
+	b.cache["alice"] = user
+	b.mu.Unlock()
+
+	if role, err := b.Authenticate("alice", "alice-pw"); err != nil || role != roleEditor {
+		t.Errorf("after expiry = %q, %v, want the new editor role", role, err)
+	}
+	if dir.searches != 2 {
+		t.Errorf("%d searches, want a fresh lookup after expiry", dir.searches)
+	}
+}
+
+func TestLDAPUnavailable(t *testing.T) {
+	dir := newStubDirectory()
+	b := newTestLDAPBackend(t, dir, map[string]string{"LDAP_BIND_DN": "cn=svc,dc=example,dc=com", "LDAP_BIND_PASSWORD": "svc-secret"})
+
+	dir.down = true
+	if _, err := b.Authenticate("alice", "alice-pw"); !errors.Is(err, errAuthUnavailable) || errors.Is(err, errInvalidCredentials) {
+		t.Errorf("unreachable server = %v, want errAuthUnavailable", err)
+	}
+
+	dir.down = false
+	b.bindPassword = "rotated"
+	if _, err := b.Authenticate("alice", "alice-pw"); !errors.Is(err, errAuthUnavailable) {
+		t.Errorf("broken service account = %v, want errAuthUnavailable", err)
+	}
+}
+
+func TestNewLDAPBackend(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		err  string
+	}{
+		{"no way to find users", map[string]string{"LDAP_BASE_DN": ""}, "LDAP_USER_DN_TEMPLATE"},
+		{"search without service account", map[string]string{}, "LDAP_BIND_DN"},
+		{"bad ttl", map[string]string{"LDAP_USER_DN_TEMPLATE": "uid=%s", "LDAP_CACHE_TTL": "soon"}, "LDAP_CACHE_TTL"},
+		{"empty ca file", map[string]string{"LDAP_USER_DN_TEMPLATE": "uid=%s", "LDAP_CA_FILE": caFile}, "no certificates"},
+		{"missing ca file", map[string]string{"LDAP_USER_DN_TEMPLATE": "uid=%s", "LDAP_CA_FILE": caFile + ".missing"}, "LDAP_CA_FILE"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LDAP_URL", "ldaps://ldap.example.com")
+			t.Setenv("LDAP_BASE_DN", "dc=example,dc=com")
+			t.Setenv("LDAP_BIND_DN", "")
+			t.Setenv("LDAP_USER_DN_TEMPLATE", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := newLDAPBackend(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("newLDAPBackend = %v, want an error about %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLDAPLogin(t *testing.T) {
+	w := newTestWiki(t)
+	dir := newStubDirectory()
+	setGlobal(t, &authenticator, authBackend(newTestLDAPBackend(t, dir, map[string]string{"LDAP_USER_DN_TEMPLATE": "uid=%s,ou=people,dc=example,dc=com"})))
+
+	resp, body := w.post("/login", url.Values{"username": {"bob"}, "password": {"bob-pw"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if s, err := sessionOf(resp); err != nil || s.User != "bob" || s.Role != roleEditor {
+		t.Errorf("session = %+v, %v", s, err)
+	}
+
+	resp, body = w.post("/login", url.Values{"username": {"bob"}, "password": {"nope"}})
+	wantStatus(t, resp, body, http.StatusUnauthorized)
+	if !strings.Contains(body, errInvalidCredentials.Error()) || !strings.Contains(body, `value="bob"`) {
+		t.Errorf("bad credentials page:\n%s", body)
+	}
+
+	dir.down = true
+	resp, body = w.post("/login", url.Values{"username": {"bob"}, "password": {"bob-pw"}})
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if !strings.Contains(body, "backend is unavailable") || strings.Contains(body, "connection refused") {
+		t.Errorf("unavailable page:\n%s", body)
+	}
+	if _, err := sessionOf(resp); err == nil {
+		t.Error("a failed login set a session")
+	}
+}
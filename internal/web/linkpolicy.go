@@ -0,0 +1,143 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/idna"
+)
+
+const (
+	linkPolicyAllow = "allow"
+	linkPolicyDeny  = "deny"
+)
+
+// linkPolicy controls external links in rendered pages. In allow mode only
+// links to the listed domains stay clickable; in deny mode only the listed
+// domains are stripped. Other links just get rel="nofollow noopener".
+type linkPolicy struct {
+	mode    string
+	domains []string
+}
+
+var externalLinks linkPolicy
+
+func setupLinkPolicy() error {
+	mode := os.Getenv("LINK_POLICY")
+	switch mode {
+	case "", linkPolicyAllow, linkPolicyDeny:
+	default:
+		return fmt.Errorf("invalid LINK_POLICY %q", mode)
+	}
+
+	var domains []string
+	for _, d := range splitList(os.Getenv("LINK_DOMAINS"), ",") {
+		ascii, err := normalizeDomain(d)
+		if err != nil {
+			return fmt.Errorf("invalid LINK_DOMAINS entry %q: %w", d, err)
+		}
+		domains = append(domains, ascii)
+	}
+
+	externalLinks = linkPolicy{mode: mode, domains: domains}
+
+	return nil
+}
+
+// normalizeDomain lowercases a host and converts IDNs to punycode, so that
+// "пример.рф" and "xn--e1afmkfd.xn--p1ai" compare equal.
+func normalizeDomain(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	return idna.Lookup.ToASCII(host)
+}
+
+func (lp linkPolicy) listed(host string) bool {
+	ascii, err := normalizeDomain(host)
+	if err != nil {
+		return false
+	}
+
+	return slices.ContainsFunc(lp.domains, func(d string) bool {
+		return ascii == d || strings.HasSuffix(ascii, "."+d)
+	})
+}
+
+func (lp linkPolicy) allows(host string) bool {
+	switch lp.mode {
+	case linkPolicyAllow:
+		return lp.listed(host)
+	case linkPolicyDeny:
+		return !lp.listed(host)
+	default:
+		return true
+	}
+}
+
+func externalHost(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	return u.Hostname(), true
+}
+
+// apply rewrites <a> tags in already sanitized HTML. Links that the policy
+// rejects are replaced by their text followed by the visible URL.
+func (lp linkPolicy) apply(src []byte) []byte {
+	var out bytes.Buffer
+	z := nethtml.NewTokenizer(bytes.NewReader(src))
+	stripped := ""
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			return out.Bytes()
+		}
+
+		tok := z.Token()
+		if tok.DataAtom.String() != "a" {
+			out.Write(z.Raw())
+			continue
+		}
+
+		if tt == nethtml.EndTagToken {
+			if stripped != "" {
+				out.WriteString(" (" + html.EscapeString(stripped) + ")")
+				stripped = ""
+				continue
+			}
+			out.Write(z.Raw())
+			continue
+		}
+
+		href := ""
+		for _, attr := range tok.Attr {
+			if attr.Key == "href" {
+				href = attr.Val
+			}
+		}
+		host, external := externalHost(href)
+		if !external {
+			out.Write(z.Raw())
+			continue
+		}
+
+		if !lp.allows(host) {
+			stripped = href
+			continue
+		}
+
+		tok.Attr = slices.DeleteFunc(tok.Attr, func(a nethtml.Attribute) bool {
+			return a.Key == "rel"
+		})
+		tok.Attr = append(tok.Attr, nethtml.Attribute{Key: "rel", Val: "nofollow noopener"})
+		out.WriteString(tok.String())
+	}
+}
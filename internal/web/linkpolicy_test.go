@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLinkPolicyDomains(t *testing.T) {
+	t.Setenv("LINK_POLICY", linkPolicyAllow)
+	t.Setenv("LINK_DOMAINS", "Example.com, пример.рф, xn--mller-kva.de")
+	setGlobal(t, &externalLinks, linkPolicy{})
+	if err := setupLinkPolicy(); err != nil {
+		t.Fatal(err)
+	}
+
+	for host, want := range map[string]bool{
+		"example.com":            true,
+		"EXAMPLE.COM.":           true,
+		"docs.example.com":       true,
+		"a.b.example.com":        true,
+		"notexample.com":         false,
+		"example.com.evil.io":    false,
+		"example.org":            false,
+		"пример.рф":              true,
+		"xn--e1afmkfd.xn--p1ai":  true,
+		"вики.пример.рф":         true,
+		"ПРИМЕР.РФ":              true,
+		"другой.рф":              false,
+		"müller.de":              true,
+		"shop.müller.de":         true,
+		"mueller.de":             false,
+		"bad..host":              false,
+		"xn--e1afmkfd.xn--p1ai.": true,
+	} {
+		if got := externalLinks.allows(host); got != want {
+			t.Errorf("allows(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	// Deny mode strips the same hosts the allowlist would have kept.
+	deny := linkPolicy{mode: linkPolicyDeny, domains: externalLinks.domains}
+	if deny.allows("sub.пример.рф") || !deny.allows("example.org") {
+		t.Error("deny mode does not match subdomains and IDNs")
+	}
+
+	t.Setenv("LINK_POLICY", "block")
+	if err := setupLinkPolicy(); err == nil {
+		t.Error("LINK_POLICY=block accepted")
+	}
+}
+
+func TestLinkPolicyApply(t *testing.T) {
+	src := `<p><a href="https://docs.example.com/a" rel="me">docs</a> ` +
+		`<a href="https://spam.io/buy?x=1&amp;y=2">buy <em>now</em></a> ` +
+		`<a href="/view/Home">home</a></p>`
+
+	for _, tt := range []struct {
+		policy linkPolicy
+		want   string
+	}{
+		{linkPolicy{}, `<p><a href="https://docs.example.com/a" rel="nofollow noopener">docs</a> ` +
+			`<a href="https://spam.io/buy?x=1&amp;y=2" rel="nofollow noopener">buy <em>now</em></a> ` +
+			`<a href="/view/Home">home</a></p>`},
+		{linkPolicy{mode: linkPolicyAllow, domains: []string{"example.com"}}, `<p><a href="https://docs.example.com/a" rel="nofollow noopener">docs</a> ` +
+			`buy <em>now</em> (https://spam.io/buy?x=1&amp;y=2) ` +
+			`<a href="/view/Home">home</a></p>`},
+		{linkPolicy{mode: linkPolicyDeny, domains: []string{"example.com"}}, `<p>docs (https://docs.example.com/a) ` +
+			`<a href="https://spam.io/buy?x=1&amp;y=2" rel="nofollow noopener">buy <em>now</em></a> ` +
+			`<a href="/view/Home">home</a></p>`},
+	} {
+		if got := string(tt.policy.apply([]byte(src))); got != tt.want {
+			t.Errorf("%+v:\n got %s\nwant %s", tt.policy, got, tt.want)
+		}
+	}
+}
+
+// The policy applies when pages are rendered, so changing it affects pages
+// saved before the change.
+func TestLinkPolicyRendering(t *testing.T) {
+	w := newTestWiki(t)
+	source := "See [docs](https://docs.example.com/) and <https://пример.рф/>.\n"
+	w.seed("Links", source)
+
+	setGlobal(t, &externalLinks, linkPolicy{})
+	_, body := w.get("/view/Links")
+	if strings.Count(body, `rel="nofollow noopener"`) != 2 {
+		t.Errorf("links without the policy:\n%s", body)
+	}
+
+	externalLinks = linkPolicy{mode: linkPolicyAllow, domains: []string{"xn--e1afmkfd.xn--p1ai"}}
+	// The autolinked IDN is percent-encoded in the href and still matches.
+	resp, body := w.get("/view/Links")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "docs (https://docs.example.com/)") || strings.Contains(body, `href="https://docs.example.com/"`) ||
+		!strings.Contains(body, `rel="nofollow noopener">https://пример.рф/</a>`) {
+		t.Errorf("links with the allowlist:\n%s", body)
+	}
+	if _, raw := w.get("/raw/Links"); raw != source {
+		t.Errorf("source changed to %q", raw)
+	}
+}
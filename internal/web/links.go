@@ -0,0 +1,137 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// linkIndex maps every page to the wiki pages it links to, so inbound links
+// can be answered without rereading every page.
+type linkIndex struct {
+	mu    sync.RWMutex
+	links map[string][]string
+}
+
+var pageLinks = &linkIndex{links: map[string][]string{}}
+
+// linkTarget returns the page title a link destination points at, if it is a
+// link to another page of this wiki.
+func linkTarget(dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+
+	title := u.Path
+	if rest, ok := strings.CutPrefix(title, "/view/"); ok {
+		title = rest
+	} else if strings.Contains(title, "/") {
+		return "", false
+	}
+
+	return title, validTitle(title)
+}
+
+func extractLinks(body []byte) []string {
+	source := page.StripFrontMatter(body)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	var targets []string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering {
+			if title, ok := linkTarget(string(link.Destination)); ok && !slices.Contains(targets, title) {
+				targets = append(targets, title)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return targets
+}
+
+func (l *linkIndex) update(title string) {
+	p, err := loadPage(title)
+	if err != nil {
+		l.remove(title)
+		return
+	}
+
+	targets := extractLinks(p.Body)
+
+	l.mu.Lock()
+	l.links[title] = targets
+	l.mu.Unlock()
+}
+
+func (l *linkIndex) remove(title string) {
+	l.mu.Lock()
+	delete(l.links, title)
+	l.mu.Unlock()
+}
+
+func (l *linkIndex) rebuild() {
+	titles, err := listPages()
+	if err != nil {
+		slog.Error("error building link index", "err", err)
+		return
+	}
+
+	fresh := &linkIndex{links: map[string][]string{}}
+	for _, title := range titles {
+		fresh.update(title)
+	}
+
+	l.mu.Lock()
+	l.links = fresh.links
+	l.mu.Unlock()
+}
+
+func (l *linkIndex) outlinks(title string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return slices.Clone(l.links[title])
+}
+
+// backlinks returns the sorted titles of pages linking to title.
+func (l *linkIndex) backlinks(title string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	sources := []string{}
+	for source, targets := range l.links {
+		if source != title && slices.Contains(targets, title) {
+			sources = append(sources, source)
+		}
+	}
+	slices.Sort(sources)
+
+	return sources
+}
+
+func apiBacklinksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	title := strings.TrimPrefix(r.URL.Path, "/api/backlinks/")
+	if !validTitle(title) {
+		writeAPIError(w, http.StatusBadRequest, "invalid title")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"title":     title,
+		"backlinks": pageLinks.backlinks(title),
+	})
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExtractLinks(t *testing.T) {
+	body := "---\nsee: [Hidden](/view/Hidden)\n---\n" +
+		"[one](/view/Home) [two](Other) [again](/view/Home?rev=2#top)\n" +
+		"[external](https://example.com/view/Far) [static](/static/style.css) [bad](/view/../etc)\n"
+	if got := extractLinks([]byte(body)); !reflect.DeepEqual(got, []string{"Home", "Other"}) {
+		t.Errorf("extractLinks = %q", got)
+	}
+}
+
+func (w *testWiki) backlinks(title string) []string {
+	w.t.Helper()
+	resp, body := w.get("/api/backlinks/" + title)
+	if resp.StatusCode != http.StatusOK {
+		w.t.Fatalf("backlinks(%s) = %d\n%s", title, resp.StatusCode, body)
+	}
+	var got struct {
+		Title     string    `json:"title"`
+		Backlinks *[]string `json:"backlinks"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil || got.Backlinks == nil || got.Title != title {
+		w.t.Fatalf("backlinks(%s) response %s: %v", title, body, err)
+	}
+	return *got.Backlinks
+}
+
+func TestAPIBacklinks(t *testing.T) {
+	w := newTestWiki(t)
+	startIndexer(t, time.Millisecond)
+	w.seed("Home", "[myself](/view/Home) and [out](Lonely)")
+	w.seed("Beta", "[home](Home)")
+	w.seed("Alpha", "back to [Home](/view/Home)")
+	w.seed("Gamma", "[elsewhere](/view/Beta)")
+	w.seed("Lonely", "no links")
+	pageLinks.rebuild()
+
+	// Sorted, without the page's link to itself.
+	if got := w.backlinks("Home"); !slices.Equal(got, []string{"Alpha", "Beta"}) {
+		t.Errorf("backlinks(Home) = %q", got)
+	}
+	if got := w.backlinks("Gamma"); len(got) != 0 {
+		t.Errorf("backlinks(Gamma) = %q, want none", got)
+	}
+	if got := w.backlinks("Missing"); len(got) != 0 {
+		t.Errorf("backlinks(Missing) = %q, want none", got)
+	}
+
+	// Edits keep the index current.
+	w.seed("Beta", "no longer linked")
+	waitFor(t, func() bool { return slices.Equal(pageLinks.backlinks("Home"), []string{"Alpha"}) })
+
+	resp, body := w.get("/api/backlinks/..%2Fetc")
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	resp, body = w.api(http.MethodPost, "/api/backlinks/Home", `{}`)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+	if resp.Header.Get("Allow") != http.MethodGet {
+		t.Errorf("Allow = %q", resp.Header.Get("Allow"))
+	}
+}
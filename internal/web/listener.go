@@ -0,0 +1,108 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	unixAddrPrefix        = "unix:"
+	defaultSocketMode     = 0660
+	systemdListenFDsStart = 3
+)
+
+// listen opens the listener for LISTEN_ADDR: a TCP address, or "unix:" and
+// a path for a Unix socket. A socket passed by systemd socket activation
+// takes precedence over both. The description is what the startup log
+// shows.
+func listen(addr string) (net.Listener, string, error) {
+	if l, err := systemdListener(); l != nil || err != nil {
+		return l, "the socket passed by systemd", err
+	}
+
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		l, err := net.Listen("tcp", addr)
+		return l, absoluteURL("/"), err
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, "", err
+	}
+
+	mode := fs.FileMode(defaultSocketMode)
+	if raw := os.Getenv("LISTEN_SOCKET_MODE"); raw != "" {
+		n, err := strconv.ParseUint(raw, 8, 32)
+		if err != nil || n > 0777 {
+			return nil, "", fmt.Errorf("invalid LISTEN_SOCKET_MODE %q, expected octal permissions like 660", raw)
+		}
+		mode = fs.FileMode(n)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, "", err
+	}
+	// The listener removes the socket file again when it is closed.
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, "", err
+	}
+
+	return l, "unix socket " + path, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a wiki that did not
+// shut down cleanly. A socket something still listens on, or a file that is
+// not a socket, is left alone.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+
+	return os.Remove(path)
+}
+
+// systemdListener returns the first socket systemd passed through LISTEN_FDS,
+// or nil when the wiki was not socket activated.
+func systemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+
+	// Child processes must not inherit the sockets.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(systemdListenFDsStart, "systemd-socket")
+	defer f.Close()
+
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("using the socket passed by systemd: %w", err)
+	}
+
+	return l, nil
+}
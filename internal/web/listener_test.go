@@ -0,0 +1,169 @@
+package web
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wiki.sock")
+
+	l, where, err := listen(unixAddrPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if where != "unix socket "+path || l.Addr().Network() != "unix" {
+		t.Errorf("listening on %s, %q", l.Addr().Network(), where)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != defaultSocketMode {
+		t.Errorf("socket mode = %v, %v, want %o", fi.Mode().Perm(), err, defaultSocketMode)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// A second wiki does not take the socket over.
+	if _, _, err := listen(unixAddrPrefix + path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("second listen on a live socket: %v", err)
+	}
+
+	l.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after close: %v", err)
+	}
+}
+
+func TestListenSocketMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wiki.sock")
+
+	t.Setenv("LISTEN_SOCKET_MODE", "600")
+	l, _, err := listen(unixAddrPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	l.Close()
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v, want 600", fi.Mode().Perm(), err)
+	}
+
+	for _, raw := range []string{"999", "1777", "rw-rw----"} {
+		t.Setenv("LISTEN_SOCKET_MODE", raw)
+		if l, _, err := listen(unixAddrPrefix + path); err == nil {
+			l.Close()
+			t.Errorf("LISTEN_SOCKET_MODE=%q accepted", raw)
+		}
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// A wiki that was killed leaves its socket file behind.
+	stale := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if l, _, err := listen(unixAddrPrefix + stale); err != nil {
+		t.Errorf("stale socket not replaced: %v", err)
+	} else {
+		l.Close()
+	}
+
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeStaleSocket(file); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("removeStaleSocket of a plain file: %v", err)
+	}
+	if b, _ := os.ReadFile(file); string(b) != "keep me" {
+		t.Errorf("plain file changed to %q", b)
+	}
+
+	if err := removeStaleSocket(filepath.Join(dir, "missing.sock")); err != nil {
+		t.Errorf("removeStaleSocket of a missing path: %v", err)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	newTestWiki(t)
+	l, _, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Addr().Network() != "tcp" {
+		t.Errorf("listening on %s", l.Addr().Network())
+	}
+}
+
+// TestSystemdListenerHelper is the socket activated process that
+// TestSystemdListener starts.
+func TestSystemdListenerHelper(t *testing.T) {
+	want := os.Getenv("GOWIKI_TEST_SYSTEMD_SOCKET")
+	if want == "" {
+		t.Skip("only run by TestSystemdListener")
+	}
+	// systemd sets LISTEN_PID to the pid it starts, which the parent test
+	// cannot know in advance.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	l, where, err := listen(unixAddrPrefix + filepath.Join(t.TempDir(), "ignored.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Addr().String() != want || where != "the socket passed by systemd" {
+		t.Errorf("listening on %s, %q, want the passed socket %s", l.Addr(), where, want)
+	}
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if v, ok := os.LookupEnv(name); ok {
+			t.Errorf("%s=%q is still set for child processes", name, v)
+		}
+	}
+}
+
+func TestSystemdListener(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a test process")
+	}
+	path := filepath.Join(t.TempDir(), "systemd.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var out bytes.Buffer
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemdListenerHelper$", "-test.v")
+	cmd.Env = append(os.Environ(), "GOWIKI_TEST_SYSTEMD_SOCKET="+path, "LISTEN_FDS=1", "LISTEN_FDNAMES=wiki")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil || !strings.Contains(out.String(), "--- PASS: TestSystemdListenerHelper") {
+		t.Fatalf("helper: %v\n%s", err, out.String())
+	}
+
+	// Without LISTEN_PID naming this process, LISTEN_FDS is not ours.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	if l, err := systemdListener(); l != nil || err != nil {
+		t.Errorf("systemdListener for another pid = %v, %v", l, err)
+	}
+}
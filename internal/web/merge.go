@@ -0,0 +1,184 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+var mergeOnConflict = true
+
+func setupMerge() {
+	mergeOnConflict = os.Getenv("MERGE_ON_CONFLICT") != "false"
+}
+
+// hunk replaces base lines [start, end) with lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+func diffHunks(base, other []string) []hunk {
+	var hunks []hunk
+	i := 0
+	for _, op := range diffTokens(base, other) {
+		n := len(hunks)
+		open := n > 0 && hunks[n-1].end == i && op.Kind != diffEqual
+
+		switch op.Kind {
+		case diffEqual:
+			i++
+			continue
+		case diffDelete:
+			if open {
+				hunks[n-1].end++
+			} else {
+				hunks = append(hunks, hunk{start: i, end: i + 1})
+			}
+			i++
+		case diffInsert:
+			if open {
+				hunks[n-1].lines = append(hunks[n-1].lines, op.Text)
+			} else {
+				hunks = append(hunks, hunk{start: i, end: i, lines: []string{op.Text}})
+			}
+		}
+	}
+
+	return hunks
+}
+
+// applyHunks returns base[start:end] with the hunks, which must lie inside
+// that range, applied.
+func applyHunks(base []string, start, end int, hunks []hunk) []string {
+	var out []string
+	for _, h := range hunks {
+		out = append(out, base[start:h.start]...)
+		out = append(out, h.lines...)
+		start = h.end
+	}
+
+	return append(out, base[start:end]...)
+}
+
+// merge3 is a diff3-style merge of two edits of base. Changes touching the
+// same or adjacent base lines are kept side by side between conflict markers.
+func merge3(base, current, edited string) (string, bool) {
+	baseLines := splitLines(base)
+	ours := diffHunks(baseLines, splitLines(current))
+	theirs := diffHunks(baseLines, splitLines(edited))
+
+	var out []string
+	clean := true
+	pos := 0
+
+	for len(ours) > 0 || len(theirs) > 0 {
+		start, end := len(baseLines), 0
+		if len(ours) > 0 {
+			start, end = ours[0].start, ours[0].end
+		}
+		if len(theirs) > 0 && (len(ours) == 0 || theirs[0].start < start) {
+			start, end = theirs[0].start, theirs[0].end
+		}
+
+		// Grow the region until no hunk on either side overlaps its edge.
+		var a, b []hunk
+		for {
+			grown := false
+			for len(ours) > 0 && ours[0].start <= end && ours[0].end >= start {
+				end = max(end, ours[0].end)
+				a, ours, grown = append(a, ours[0]), ours[1:], true
+			}
+			for len(theirs) > 0 && theirs[0].start <= end && theirs[0].end >= start {
+				end = max(end, theirs[0].end)
+				b, theirs, grown = append(b, theirs[0]), theirs[1:], true
+			}
+			if !grown {
+				break
+			}
+		}
+
+		out = append(out, baseLines[pos:start]...)
+		pos = end
+
+		mine := applyHunks(baseLines, start, end, a)
+		yours := applyHunks(baseLines, start, end, b)
+		switch {
+		case len(b) == 0:
+			out = append(out, mine...)
+		case len(a) == 0, slices.Equal(mine, yours):
+			out = append(out, yours...)
+		default:
+			clean = false
+			out = append(out, "<<<<<<< current")
+			out = append(out, mine...)
+			out = append(out, "=======")
+			out = append(out, yours...)
+			out = append(out, ">>>>>>> your edit")
+		}
+	}
+	out = append(out, baseLines[pos:]...)
+
+	merged := strings.Join(out, "\n")
+	if strings.HasSuffix(edited, "\n") && merged != "" {
+		merged += "\n"
+	}
+
+	return merged, clean
+}
+
+func currentBase(title string) int {
+	rev, _ := lastRevision(title)
+	return rev.ID
+}
+
+// resolveConflict checks whether the page changed since the editor loaded
+// revision base. If so, the edit is merged into the current content; merged
+// reports a clean merge, and a conflicting merge returns a formError with
+// p.Body holding the conflict markers.
+func resolveConflict(param string, base int, p *page.Page) (merged bool, err error) {
+	if p.Title != param {
+		return false, nil
+	}
+
+	rev, ok := lastRevision(param)
+	if !ok || rev.ID == base {
+		return false, nil
+	}
+
+	if !mergeOnConflict {
+		return false, &formError{http.StatusConflict, "Page was changed by someone else while you were editing. Saving again will overwrite their changes."}
+	}
+
+	_, current, err := findRevision(param, rev.ID)
+	if err != nil {
+		return false, err
+	}
+	// A base collapsed out of the history leaves nothing to merge against, so
+	// the two versions are shown side by side instead.
+	var original []byte
+	collapsed := false
+	if base > 0 {
+		_, original, err = findRevision(param, base)
+		if errors.Is(err, os.ErrNotExist) {
+			collapsed = true
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	body, clean := merge3(string(original), string(current), string(p.Body))
+	p.Body = []byte(body)
+	if !clean && collapsed {
+		return false, &formError{http.StatusConflict, "Page was changed by someone else while you were editing, and the revision you started from is no longer in the history. Resolve the marked conflicts and save again."}
+	}
+	if !clean {
+		return false, &formError{http.StatusConflict, "Page was changed by someone else while you were editing. Resolve the marked conflicts and save again."}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+	for _, tt := range []struct {
+		name, current, edited, want string
+		clean                       bool
+	}{
+		{"separate lines", "ONE\ntwo\nthree\nfour\nfive\n", "one\ntwo\nthree\nfour\nFIVE\n", "ONE\ntwo\nthree\nfour\nFIVE\n", true},
+		{"insert and delete", "one\ntwo\nthree\nfour\nfive\nsix\n", "two\nthree\nfour\nfive\n", "two\nthree\nfour\nfive\nsix\n", true},
+		{"same change", "one\n2\nthree\nfour\nfive\n", "one\n2\nthree\nfour\nfive\n", "one\n2\nthree\nfour\nfive\n", true},
+		{"only current changed", "one\ntwo\n3\nfour\nfive\n", base, "one\ntwo\n3\nfour\nfive\n", true},
+		{"same line", "one\ntwo\nTHREE\nfour\nfive\n", "one\ntwo\n3\nfour\nfive\n",
+			"one\ntwo\n<<<<<<< current\nTHREE\n=======\n3\n>>>>>>> your edit\nfour\nfive\n", false},
+		{"adjacent lines", "one\nTWO\nthree\nfour\nfive\n", "one\ntwo\n3\nfour\nfive\n",
+			"one\n<<<<<<< current\nTWO\nthree\n=======\ntwo\n3\n>>>>>>> your edit\nfour\nfive\n", false},
+		{"CRLF edit", "ONE\ntwo\nthree\nfour\nfive\n", "one\r\ntwo\r\nthree\r\nfour\r\nFIVE\r\n", "ONE\ntwo\nthree\nfour\nFIVE\n", true},
+	} {
+		got, clean := merge3(base, tt.current, tt.edited)
+		if got != tt.want || clean != tt.clean {
+			t.Errorf("%s: merge3 = %q, %v, want %q, %v", tt.name, got, clean, tt.want, tt.clean)
+		}
+	}
+
+	// Without a base both versions conflict as a whole.
+	got, clean := merge3("", "theirs\n", "mine\n")
+	if clean || got != "<<<<<<< current\ntheirs\n=======\nmine\n>>>>>>> your edit\n" {
+		t.Errorf("merge3 without a base = %q, %v", got, clean)
+	}
+}
+
+// editFrom saves body as an edit of revision base of Home.
+func (w *testWiki) editFrom(base int, body string, cookies ...*http.Cookie) (*http.Response, string) {
+	return w.post("/save/Home", url.Values{"title": {"Home"}, "body": {body}, "base": {strconv.Itoa(base)}}, cookies...)
+}
+
+func TestSaveMergesConflicts(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "intro\nmiddle\nend\n")
+	base := currentBase("Home")
+	w.seed("Home", "INTRO\nmiddle\nend\n")
+
+	resp, body := w.editFrom(base, "intro\nmiddle\nEND\n")
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, raw := w.get("/raw/Home"); raw != "INTRO\nmiddle\nEND\n" {
+		t.Errorf("merged page = %q", raw)
+	}
+	if _, body := w.get(resp.Header.Get("Location"), resp.Cookies()...); !strings.Contains(body, "merged with changes made while you were editing") {
+		t.Error("clean merge not reported")
+	}
+
+	resp, body = w.editFrom(base, "Intro\nmiddle\nend\n")
+	wantStatus(t, resp, body, http.StatusConflict)
+	markers := html.EscapeString("<<<<<<< current\nINTRO\n=======\nIntro\n>>>>>>> your edit\n")
+	if !strings.Contains(body, markers) || !strings.Contains(body, "Resolve the marked conflicts") {
+		t.Errorf("conflict form:\n%s", body)
+	}
+	if _, raw := w.get("/raw/Home"); raw != "INTRO\nmiddle\nEND\n" {
+		t.Errorf("conflicting edit saved: %q", raw)
+	}
+
+	// Saving from the current revision needs no merge.
+	resp, body = w.editFrom(currentBase("Home"), "fresh\n")
+	wantStatus(t, resp, body, http.StatusFound)
+
+	setGlobal(t, &mergeOnConflict, false)
+	resp, body = w.editFrom(base, "intro\nmiddle\nEND\n")
+	wantStatus(t, resp, body, http.StatusConflict)
+	if !strings.Contains(body, "Saving again will overwrite their changes") {
+		t.Errorf("conflict without merging:\n%s", body)
+	}
+}
+
+// A base collapsed out of the history is merged as if nothing was shared.
+func TestSaveMergeCollapsedBase(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "intro\nend\n")
+	base := currentBase("Home")
+	w.seed("Home", "intro\nEND\n")
+	if err := os.Remove(revisionFilename("Home", base)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := w.editFrom(base, "INTRO\nend\n")
+	wantStatus(t, resp, body, http.StatusConflict)
+	markers := html.EscapeString("<<<<<<< current\nintro\nEND\n=======\nINTRO\nend\n>>>>>>> your edit\n")
+	if !strings.Contains(body, markers) || !strings.Contains(body, "no longer in the history") {
+		t.Errorf("conflict form for a collapsed base:\n%s", body)
+	}
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveWithHeaders(h securityHeaders, path string) http.Header {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	rec := httptest.NewRecorder()
+	withSecurityHeaders(h, next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Header()
+}
+
+func wantHeaders(t *testing.T, name string, got http.Header, want map[string]string) {
+	t.Helper()
+	for k, v := range want {
+		if got.Get(k) != v {
+			t.Errorf("%s: %s = %q, want %q", name, k, got.Get(k), v)
+		}
+		if v == "" && len(got.Values(k)) > 0 {
+			t.Errorf("%s: %s sent though it is off", name, k)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+
+	page := map[string]string{
+		"Content-Security-Policy": defaultCSP + "; " + inlineStyleCSP,
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+	}
+	for _, path := range []string{"/", "/view/Home", "/edit/Home"} {
+		resp, _ := w.get(path)
+		wantHeaders(t, path, resp.Header, page)
+	}
+
+	api := map[string]string{
+		"Content-Security-Policy": apiCSP,
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "",
+		"Referrer-Policy":         "no-referrer",
+	}
+	for _, path := range []string{"/api/openapi.json", "/api/pages/Home"} {
+		resp, _ := w.get(path)
+		wantHeaders(t, path, resp.Header, api)
+	}
+
+	// A handler can tighten the policy for its own response.
+	resp, _ := w.get("/favicon.ico")
+	wantHeaders(t, "favicon", resp.Header, map[string]string{
+		"Content-Security-Policy": "default-src 'none'; style-src 'unsafe-inline'",
+		"X-Content-Type-Options":  "nosniff",
+	})
+}
+
+func TestSecurityHeadersFromEnv(t *testing.T) {
+	t.Setenv("SECURITY_CSP", "default-src 'self' https://cdn.example.com; style-src 'self' https://cdn.example.com")
+	t.Setenv("SECURITY_FRAME_OPTIONS", "")
+	t.Setenv("SECURITY_REFERRER_POLICY", "same-origin")
+	h := securityHeadersFromEnv()
+
+	wantHeaders(t, "page", serveWithHeaders(h, "/view/Home"), map[string]string{
+		"Content-Security-Policy": "default-src 'self' https://cdn.example.com; style-src 'self' https://cdn.example.com",
+		"X-Frame-Options":         "",
+		"Referrer-Policy":         "same-origin",
+		"X-Content-Type-Options":  "nosniff",
+	})
+	// The API set is fixed.
+	wantHeaders(t, "api", serveWithHeaders(h, "/api/pages"), map[string]string{
+		"Content-Security-Policy": apiCSP,
+		"Referrer-Policy":         "no-referrer",
+	})
+
+	t.Setenv("SECURITY_CSP", "")
+	if got := serveWithHeaders(securityHeadersFromEnv(), "/view/Home"); len(got.Values("Content-Security-Policy")) > 0 {
+		t.Errorf("CSP sent though SECURITY_CSP is empty: %q", got.Get("Content-Security-Policy"))
+	}
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type policyError struct {
+	term string
+}
+
+func (e *policyError) Error() string {
+	return fmt.Sprintf("content policy violation: %q is not allowed", e.term)
+}
+
+var blocklist *regexp.Regexp
+
+// setupBlocklist builds the forbidden terms matcher from BLOCKLIST (comma-separated)
+// and BLOCKLIST_FILE (one term per line, # starts a comment).
+func setupBlocklist() error {
+	terms := strings.Split(os.Getenv("BLOCKLIST"), ",")
+
+	if path := os.Getenv("BLOCKLIST_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error reading BLOCKLIST_FILE: %w", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			terms = append(terms, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading BLOCKLIST_FILE: %w", err)
+		}
+	}
+
+	var quoted []string
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) == 0 {
+		blocklist = nil
+		return nil
+	}
+
+	// \b only knows ASCII word characters, so boundaries are spelled out to work for Cyrillic too.
+	blocklist = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(` + strings.Join(quoted, "|") + `)(?:[^\p{L}\p{N}_]|$)`)
+	return nil
+}
+
+func checkBlocklist(body []byte) error {
+	if blocklist == nil {
+		return nil
+	}
+
+	if m := blocklist.FindSubmatch(body); m != nil {
+		return &policyError{term: string(m[1])}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setBlocklist(t *testing.T, terms, file string) {
+	t.Helper()
+	setGlobal(t, &blocklist, nil)
+	t.Setenv("BLOCKLIST", terms)
+	t.Setenv("BLOCKLIST_FILE", file)
+	if err := setupBlocklist(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlocklistMatching(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(file, []byte("# casino spam\nonline casino\n\nкупить  # Russian spam\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setBlocklist(t, " viagra ,, c++ ", file)
+
+	for body, blocked := range map[string]string{
+		"Buy VIAGRA now":              "VIAGRA",
+		"viagra":                      "viagra",
+		"(viagra)":                    "viagra",
+		"best Online  casino":         "",
+		"best Online casino in town":  "Online casino",
+		"Купить дёшево":               "Купить",
+		"learn C++ today":             "C++",
+		"viagras and antiviagra":      "",
+		"покупить":                    "",
+		"viagra_bot is a username":    "",
+		"A perfectly ordinary page.":  "",
+		"casino night, online or not": "",
+	} {
+		err := checkBlocklist([]byte(body))
+		if blocked == "" {
+			if err != nil {
+				t.Errorf("%q rejected: %v", body, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != `content policy violation: "`+blocked+`" is not allowed` {
+			t.Errorf("%q = %v, want %q blocked", body, err, blocked)
+		}
+	}
+}
+
+func TestBlocklistOff(t *testing.T) {
+	setBlocklist(t, " , ", "")
+	if blocklist != nil || checkBlocklist([]byte("anything at all")) != nil {
+		t.Error("an empty BLOCKLIST blocks saves")
+	}
+
+	t.Setenv("BLOCKLIST_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if err := setupBlocklist(); err == nil || !strings.Contains(err.Error(), "BLOCKLIST_FILE") {
+		t.Errorf("missing BLOCKLIST_FILE = %v", err)
+	}
+}
+
+func TestSaveBlockedTerm(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "original")
+	setBlocklist(t, "viagra", "")
+	editor := w.login("alice", roleEditor)
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"cheap Viagra here"}}, editor)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if !strings.Contains(body, "content policy violation") || !strings.Contains(body, "cheap Viagra here") {
+		t.Errorf("edit form does not explain the rejection or lost the text:\n%s", body)
+	}
+
+	resp, body = w.api(http.MethodPut, "/api/pages/Spam", `{"body":"viagra"}`, editor)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	if b, _ := store.Read("Home"); string(b) != "original" {
+		t.Errorf("page = %q after a rejected save", b)
+	}
+	if _, err := store.Read("Spam"); err == nil {
+		t.Error("a rejected API save created the page")
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"a clean page"}}, editor)
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, _ := store.Read("Home"); string(b) != "a clean page" {
+		t.Errorf("page = %q after a clean save", b)
+	}
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// pendingEdit is an anonymous save held back until a moderator approves it.
+// Base is the revision the edit was made against.
+type pendingEdit struct {
+	ID        string    `json:"id"`
+	Param     string    `json:"param"`
+	Base      int       `json:"base,omitempty"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Summary   string    `json:"summary,omitempty"`
+	Minor     bool      `json:"minor,omitempty"`
+	Submitter string    `json:"submitter,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+var (
+	moderateAnonymous bool
+	maxPendingEdits   = 100
+)
+
+func setupModeration() error {
+	moderateAnonymous = os.Getenv("MODERATE_ANONYMOUS") == "true"
+
+	if raw := os.Getenv("MODERATION_QUEUE_MAX"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid MODERATION_QUEUE_MAX %q", raw)
+		}
+		maxPendingEdits = n
+	}
+
+	return nil
+}
+
+func pendingDir() string {
+	return filepath.Join(config.StoragePath, ".pending")
+}
+
+func pendingFilename(id string) string {
+	return filepath.Join(pendingDir(), id+".json")
+}
+
+// heldForReview reports an anonymous request while MODERATE_ANONYMOUS is on.
+// The API has no editor name, so a request is only anonymous when it has
+// neither a session nor an API token.
+func heldForReview(r *http.Request) bool {
+	return moderateAnonymous && currentUser(r) == "" && apiAnonymous(r)
+}
+
+const reviewOnlyMessage = "Anonymous changes are reviewed before they go live and only edits can wait for review, please log in."
+
+// refuseHeldForReview keeps anonymous visitors away from the writes the
+// moderation queue cannot hold back: deletes, renames, copies and undos.
+func refuseHeldForReview(fn func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request, string) {
+	return func(w http.ResponseWriter, r *http.Request, param string) {
+		if heldForReview(r) {
+			renderError(w, r, http.StatusForbidden, reviewOnlyMessage)
+			return
+		}
+
+		fn(w, r, param)
+	}
+}
+
+func canModerate(r *http.Request) bool {
+	s, _ := currentSession(r)
+	return s.Role == roleEditor || s.Role == roleAdmin
+}
+
+func listPendingEdits() ([]*pendingEdit, error) {
+	entries, err := os.ReadDir(pendingDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []*pendingEdit
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		pe, err := loadPendingEdit(id)
+		if err != nil {
+			continue
+		}
+		edits = append(edits, pe)
+	}
+
+	slices.SortFunc(edits, func(a, b *pendingEdit) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return edits, nil
+}
+
+func loadPendingEdit(id string) (*pendingEdit, error) {
+	b, err := os.ReadFile(pendingFilename(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var pe pendingEdit
+	if err := json.Unmarshal(b, &pe); err != nil {
+		return nil, err
+	}
+
+	return &pe, nil
+}
+
+// queueEdit stores p for review instead of saving it.
+func queueEdit(param string, p *page.Page, submitter string) error {
+	edits, err := listPendingEdits()
+	if err != nil {
+		return err
+	}
+	if len(edits) >= maxPendingEdits {
+		return &formError{http.StatusServiceUnavailable, "Too many edits are waiting for review, please try again later"}
+	}
+
+	now := time.Now().UTC()
+	pe := pendingEdit{
+		ID:        strconv.FormatInt(now.UnixNano(), 36),
+		Param:     param,
+		Base:      currentBase(param),
+		Title:     p.Title,
+		Body:      string(p.Body),
+		Summary:   p.Summary,
+		Minor:     p.Minor,
+		Submitter: submitter,
+		Time:      now,
+	}
+
+	b, err := json.Marshal(pe)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(pendingDir(), 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(pendingFilename(pe.ID), b, 0600)
+}
+
+// approvePendingEdit applies the edit through the normal save path, credited
+// to whoever submitted it. Revisions saved since it was queued are kept: the
+// edit is merged on top of them, and refused when the two conflict. The
+// moderator in r must be allowed to change the page as it is protected now.
+func approvePendingEdit(r *http.Request, pe *pendingEdit) error {
+	if level := pageProtection(pe.Param); !allowedByProtection(r, level) {
+		return &formError{http.StatusForbidden, "the page is protected and only " + level + " can approve edits of it"}
+	}
+
+	p := &page.Page{
+		Title:   pe.Title,
+		Body:    []byte(pe.Body),
+		Editor:  pe.Submitter,
+		Summary: pe.Summary,
+		Minor:   pe.Minor,
+	}
+
+	if err := validateSave(pe.Param, p); err != nil {
+		return err
+	}
+	if _, err := resolveConflict(pe.Param, pe.Base, p); err != nil {
+		if status, ok := saveErrorStatus(err); ok && status == http.StatusConflict {
+			return &formError{http.StatusConflict, "the page changed since this edit was submitted and the changes conflict, reject it or edit the page by hand"}
+		}
+		return err
+	}
+	if err := savePage(p); err != nil {
+		return err
+	}
+
+	return os.Remove(pendingFilename(pe.ID))
+}
+
+type pendingItem struct {
+	*pendingEdit
+	Lines []diffLine
+}
+
+func moderationHandler(w http.ResponseWriter, r *http.Request) {
+	if !canModerate(r) {
+		renderError(w, r, http.StatusForbidden, "Only editors can review pending edits.")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		moderatePendingEdit(w, r)
+		return
+	}
+
+	edits, err := listPendingEdits()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]pendingItem, 0, len(edits))
+	for _, pe := range edits {
+		var current string
+		if p, err := loadPage(pe.Param); err == nil {
+			current = string(p.Body)
+		}
+		items = append(items, pendingItem{pe, diffPage(current, pe.Body, true)})
+	}
+
+	renderTemplate(w, r, pageData{Title: "Moderation", Content: items}, "moderation")
+}
+
+func moderatePendingEdit(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if id == "" || strings.ContainsAny(id, "/\\.") {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	pe, err := loadPendingEdit(id)
+	if errors.Is(err, os.ErrNotExist) {
+		renderError(w, r, http.StatusNotFound, "This edit is no longer pending.")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	moderator := currentUser(r)
+	switch r.FormValue("action") {
+	case "approve":
+		if err := approvePendingEdit(r, pe); err != nil {
+			if _, ok := saveErrorStatus(err); !ok {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			addFlash(w, r, flash{Level: "error", Text: "Could not approve edit of " + pe.Title + ": " + err.Error()})
+			http.Redirect(w, r, pageURL("/moderation"), http.StatusFound)
+			return
+		}
+		err = recordAudit(auditEntry{User: moderator, Action: "approve", Title: pe.Title, Detail: pe.ID})
+		addFlash(w, r, flash{Level: "success", Text: "Edit of " + pe.Title + " approved"})
+	case "reject":
+		if err := os.Remove(pendingFilename(pe.ID)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = recordAudit(auditEntry{User: moderator, Action: "reject", Title: pe.Title, Detail: strings.TrimSpace(pe.ID + " " + r.FormValue("reason"))})
+		addFlash(w, r, flash{Level: "success", Text: "Edit of " + pe.Title + " rejected"})
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, pageURL("/moderation"), http.StatusFound)
+}
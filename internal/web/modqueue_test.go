@@ -0,0 +1,216 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAudit(t *testing.T) []auditEntry {
+	t.Helper()
+	f, err := os.Open(filepath.Join(config.StoragePath, ".audit.jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func pendingEdits(t *testing.T) []*pendingEdit {
+	t.Helper()
+	edits, err := listPendingEdits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edits
+}
+
+func TestModerationQueue(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &moderateAnonymous, true)
+	w.seed("Home", "original\n")
+	alice := w.login("alice", roleEditor)
+
+	for _, body := range []string{"first anonymous\n", "second anonymous\n"} {
+		resp, respBody := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {body}, "summary": {"typo"}})
+		wantStatus(t, resp, respBody, http.StatusFound)
+		if resp.Header.Get("Location") != "/" {
+			t.Errorf("queued edit redirects to %q", resp.Header.Get("Location"))
+		}
+	}
+	resp, body := w.api(http.MethodPut, "/api/pages/Home", `{"body":"api anonymous"}`)
+	wantStatus(t, resp, body, http.StatusAccepted)
+	if !strings.Contains(body, `"queued":true`) {
+		t.Errorf("API response for a queued edit: %s", body)
+	}
+
+	// The live page is untouched until a moderator steps in.
+	if _, body := w.get("/raw/Home"); body != "original\n" {
+		t.Errorf("page changed before review: %q", body)
+	}
+	edits := pendingEdits(t)
+	if len(edits) != 3 || edits[0].Body != "first anonymous\n" || edits[0].Submitter != "127.0.0.1" {
+		t.Fatalf("pending edits = %+v", edits)
+	}
+
+	// Signed in editors save directly.
+	resp, body = w.post("/save/Other", url.Values{"title": {"Other"}, "body": {"live"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, err := loadPage("Other"); err != nil {
+		t.Errorf("editor's save was queued: %v", err)
+	}
+
+	resp, body = w.get("/moderation", alice)
+	wantStatus(t, resp, body, http.StatusOK)
+	if strings.Count(body, `name="id"`) != 3 || !strings.Contains(body, "<mark>original</mark>") || !strings.Contains(body, "+ <mark>first anonymous</mark>") {
+		t.Errorf("moderation queue:\n%s", body)
+	}
+	resp, body = w.get("/moderation")
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	resp, body = w.post("/moderation", url.Values{"id": {edits[0].ID}, "action": {"approve"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, body := w.get("/raw/Home"); body != "first anonymous\n" {
+		t.Errorf("approved page = %q", body)
+	}
+	if rev, ok := lastRevision("Home"); !ok || rev.Editor != "127.0.0.1" || rev.Summary != "typo" {
+		t.Errorf("approved revision = %+v, want credited to the submitter", rev)
+	}
+
+	// The second edit was made against the same revision and now conflicts.
+	w.post("/moderation", url.Values{"id": {edits[1].ID}, "action": {"approve"}}, alice)
+	if _, body := w.get("/raw/Home"); body != "first anonymous\n" {
+		t.Errorf("conflicting approval overwrote the page: %q", body)
+	}
+	if len(pendingEdits(t)) != 2 {
+		t.Error("conflicting edit left the queue")
+	}
+
+	resp, body = w.post("/moderation", url.Values{"id": {edits[1].ID}, "action": {"reject"}, "reason": {"spam"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if left := pendingEdits(t); len(left) != 1 || left[0].ID != edits[2].ID {
+		t.Errorf("pending after reject = %+v", left)
+	}
+	audit := readAudit(t)
+	if len(audit) != 2 || audit[0].Action != "approve" || audit[1].Action != "reject" ||
+		audit[1].User != "alice" || audit[1].Detail != edits[1].ID+" spam" {
+		t.Errorf("audit log = %+v", audit)
+	}
+
+	for form, status := range map[string]int{
+		"id=" + edits[1].ID + "&action=approve": http.StatusNotFound,
+		"id=..%2Fsecret&action=approve":         http.StatusBadRequest,
+		"id=" + edits[2].ID + "&action=ignore":  http.StatusBadRequest,
+	} {
+		values, _ := url.ParseQuery(form)
+		if resp, body := w.post("/moderation", values, alice); resp.StatusCode != status {
+			t.Errorf("%s = %d, want %d\n%s", form, resp.StatusCode, status, body)
+		}
+	}
+}
+
+func TestModerationQueueLimit(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &moderateAnonymous, true)
+	setGlobal(t, &maxPendingEdits, 2)
+
+	for i, want := range []int{http.StatusFound, http.StatusFound, http.StatusServiceUnavailable} {
+		resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"edit " + string(rune('a'+i))}})
+		wantStatus(t, resp, body, want)
+		if want == http.StatusServiceUnavailable && !strings.Contains(body, "Too many edits") {
+			t.Errorf("full queue does not explain itself:\n%s", body)
+		}
+	}
+	if len(pendingEdits(t)) != 2 {
+		t.Errorf("%d pending edits, want 2", len(pendingEdits(t)))
+	}
+
+	t.Setenv("MODERATION_QUEUE_MAX", "0")
+	if err := setupModeration(); err == nil {
+		t.Error("MODERATION_QUEUE_MAX=0 accepted")
+	}
+}
+
+// Only edits can wait for review; other writes are refused to anonymous
+// visitors while moderation is on.
+func TestModerationRefusesOtherWrites(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &moderateAnonymous, true)
+	w.seed("Home", "first")
+	w.seed("Home", "second")
+	w.seed("Other", "other")
+
+	for _, tt := range []struct {
+		path string
+		form url.Values
+	}{
+		{"/undo/Home", w.csrf()},
+		{"/delete/Other", w.csrf()},
+		{"/rename/Home", url.Values{"newTitle": {"Spam"}, "update_refs": {"on"}}},
+		{"/copy/Home", url.Values{"newTitle": {"Spam"}}},
+	} {
+		resp, body := w.post(tt.path, tt.form)
+		wantStatus(t, resp, body, http.StatusForbidden)
+	}
+	resp, body := w.api(http.MethodPost, "/api/pages/Home/copy", `{"newTitle":"Spam"}`)
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	if _, body := w.get("/raw/Home"); body != "second" {
+		t.Errorf("Home = %q, want it untouched", body)
+	}
+	for _, title := range []string{"Other", "Home"} {
+		if _, err := loadPage(title); err != nil {
+			t.Errorf("%s: %v", title, err)
+		}
+	}
+	if _, err := loadPage("Spam"); err == nil {
+		t.Error("anonymous rename or copy created Spam")
+	}
+
+	// Signed in editors are not held back.
+	alice := w.login("alice", roleEditor)
+	resp, body = w.post("/undo/Home", w.csrf(alice), alice)
+	wantStatus(t, resp, body, http.StatusFound)
+}
+
+// An edit queued before a page was protected needs a moderator who may
+// change the page now.
+func TestModerationApproveProtected(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &moderateAnonymous, true)
+	w.seed("Rules", "rules")
+	resp, body := w.post("/save/Rules", url.Values{"title": {"Rules"}, "body": {"vandalized"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	setProtection(t, "Rules", protectionAdmins)
+	id := pendingEdits(t)[0].ID
+
+	w.post("/moderation", url.Values{"id": {id}, "action": {"approve"}}, w.login("alice", roleEditor))
+	if _, body := w.get("/raw/Rules"); body != "rules" {
+		t.Errorf("editor approved an edit of an admin-only page: %q", body)
+	}
+	if len(pendingEdits(t)) != 1 {
+		t.Error("refused edit left the queue")
+	}
+
+	w.post("/moderation", url.Values{"id": {id}, "action": {"approve"}}, w.login("root", roleAdmin))
+	if _, body := w.get("/raw/Rules"); body != "vandalized" {
+		t.Errorf("admin approval = %q", body)
+	}
+}
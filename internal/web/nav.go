@@ -0,0 +1,95 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+type navItem struct {
+	Label  string
+	Target string
+}
+
+var defaultNav = []navItem{
+	{"Home", "/"},
+	{"My drafts", "/drafts"},
+}
+
+var (
+	navLinks []navItem
+	navPage  string
+)
+
+// setupNav reads the top navigation from NAV_LINKS ("Label=/target" pairs
+// separated by commas) or from the links on the page named by NAV_PAGE.
+func setupNav() error {
+	for _, pair := range splitList(os.Getenv("NAV_LINKS"), ",") {
+		label, target, ok := strings.Cut(pair, "=")
+		label, target = strings.TrimSpace(label), strings.TrimSpace(target)
+		if !ok || label == "" || target == "" {
+			return fmt.Errorf("invalid NAV_LINKS entry %q", pair)
+		}
+		navLinks = append(navLinks, navItem{label, target})
+	}
+
+	navPage = os.Getenv("NAV_PAGE")
+	if navPage != "" && !validTitle(navPage) {
+		return fmt.Errorf("invalid NAV_PAGE %q", navPage)
+	}
+
+	return nil
+}
+
+// parseNav collects the Markdown links of a page, in order, as nav items.
+func parseNav(body []byte) []navItem {
+	source := page.StripFrontMatter(body)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	var items []navItem
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var label strings.Builder
+		for c := link.FirstChild(); c != nil; c = c.NextSibling() {
+			if t, ok := c.(*ast.Text); ok {
+				label.Write(t.Segment.Value(source))
+			}
+		}
+		if label.Len() > 0 {
+			items = append(items, navItem{label.String(), string(link.Destination)})
+		}
+
+		return ast.WalkSkipChildren, nil
+	})
+
+	return items
+}
+
+func navigation() []navItem {
+	items := defaultNav
+	if len(navLinks) > 0 {
+		items = navLinks
+	}
+	if navPage != "" {
+		if p, err := loadPage(navPage); err == nil {
+			if parsed := parseNav(p.Body); len(parsed) > 0 {
+				items = parsed
+			}
+		}
+	}
+
+	targets := make([]navItem, len(items))
+	for i, item := range items {
+		targets[i] = navItem{item.Label, pageURL(item.Target)}
+	}
+
+	return targets
+}
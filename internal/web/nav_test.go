@@ -0,0 +1,90 @@
+package web
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetupNav(t *testing.T) {
+	setGlobal(t, &navLinks, nil)
+	setGlobal(t, &navPage, "")
+	t.Setenv("NAV_LINKS", "Home=/, Docs = /view/Docs ,Source=https://example.com/src?a=b")
+	t.Setenv("NAV_PAGE", "Menu")
+	if err := setupNav(); err != nil {
+		t.Fatal(err)
+	}
+	want := []navItem{{"Home", "/"}, {"Docs", "/view/Docs"}, {"Source", "https://example.com/src?a=b"}}
+	if !reflect.DeepEqual(navLinks, want) || navPage != "Menu" {
+		t.Errorf("navLinks = %+v, navPage = %q", navLinks, navPage)
+	}
+
+	for _, raw := range []string{"Docs", "=/view/Docs", "Docs="} {
+		navLinks = nil
+		t.Setenv("NAV_LINKS", raw)
+		if err := setupNav(); err == nil {
+			t.Errorf("NAV_LINKS=%q accepted", raw)
+		}
+	}
+	t.Setenv("NAV_LINKS", "")
+	t.Setenv("NAV_PAGE", "../Menu")
+	if err := setupNav(); err == nil {
+		t.Error("NAV_PAGE=../Menu accepted")
+	}
+}
+
+func TestParseNav(t *testing.T) {
+	body := "---\ntitle: menu\n---\n# Menu\n\n- [Start](/)\n- [Recent changes](/changes)\n- [](/empty)\n\nSee also [Docs](Docs).\n"
+	want := []navItem{{"Start", "/"}, {"Recent changes", "/changes"}, {"Docs", "Docs"}}
+	if got := parseNav([]byte(body)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNav = %+v", got)
+	}
+}
+
+func navButton(target, label string) string {
+	return `<button><a href="` + target + `">` + label + `</a></button>`
+}
+
+func TestNavigation(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &navLinks, nil)
+	setGlobal(t, &navPage, "")
+
+	_, body := w.get("/")
+	for _, item := range defaultNav {
+		if !strings.Contains(body, navButton(item.Target, item.Label)) {
+			t.Errorf("default nav lacks %q:\n%s", item.Label, body)
+		}
+	}
+
+	navLinks = []navItem{{"Docs", "/view/Docs"}, {"Source", "https://example.com/src"}}
+	_, body = w.get("/")
+	if !strings.Contains(body, navButton("/view/Docs", "Docs")) || !strings.Contains(body, navButton("https://example.com/src", "Source")) ||
+		strings.Contains(body, "My drafts") {
+		t.Errorf("configured nav:\n%s", body)
+	}
+
+	// The nav page wins once it has links, and edits show up right away.
+	navPage = "Menu"
+	w.seed("Menu", "nothing to link")
+	if _, body = w.get("/"); !strings.Contains(body, navButton("/view/Docs", "Docs")) {
+		t.Errorf("nav page without links replaced NAV_LINKS:\n%s", body)
+	}
+	w.seed("Menu", "- [Changes](/changes)\n- [Help](/view/Help)\n")
+	_, body = w.get("/view/Menu")
+	if !strings.Contains(body, navButton("/changes", "Changes")) || !strings.Contains(body, navButton("/view/Help", "Help")) ||
+		strings.Contains(body, navButton("/view/Docs", "Docs")) {
+		t.Errorf("nav from the nav page:\n%s", body)
+	}
+}
+
+func TestNavigationBasePath(t *testing.T) {
+	w := mountAt(t, "/wiki")
+	setGlobal(t, &navLinks, []navItem{{"Docs", "/view/Docs"}, {"Source", "https://example.com/src"}})
+	setGlobal(t, &navPage, "")
+
+	_, body := w.get("/wiki/")
+	if !strings.Contains(body, navButton("/wiki/view/Docs", "Docs")) || !strings.Contains(body, navButton("https://example.com/src", "Source")) {
+		t.Errorf("nav under a base path:\n%s", body)
+	}
+}
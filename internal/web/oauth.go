@@ -0,0 +1,272 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const oauthStateCookieName = "oauth_state"
+
+type githubOAuth struct {
+	clientID     string
+	clientSecret string
+	allowedUsers []string
+	allowedOrg   string
+	role         string
+	authURL      string
+	tokenURL     string
+	apiURL       string
+	client       *http.Client
+}
+
+var github *githubOAuth
+
+// setupGitHubOAuth enables GitHub login when GITHUB_CLIENT_ID is set. The provider
+// URLs can be overridden to point at GitHub Enterprise or a stub.
+func setupGitHubOAuth() error {
+	clientID := os.Getenv("GITHUB_CLIENT_ID")
+	if clientID == "" {
+		return nil
+	}
+
+	g := &githubOAuth{
+		clientID:     clientID,
+		clientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		allowedOrg:   os.Getenv("GITHUB_ALLOWED_ORG"),
+		role:         envOrDefault("GITHUB_ROLE", roleEditor),
+		authURL:      envOrDefault("GITHUB_AUTH_URL", "https://github.com/login/oauth/authorize"),
+		tokenURL:     envOrDefault("GITHUB_TOKEN_URL", "https://github.com/login/oauth/access_token"),
+		apiURL:       strings.TrimSuffix(envOrDefault("GITHUB_API_URL", "https://api.github.com"), "/"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, user := range strings.Split(os.Getenv("GITHUB_ALLOWED_USERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			g.allowedUsers = append(g.allowedUsers, strings.ToLower(user))
+		}
+	}
+
+	if g.clientSecret == "" {
+		return errors.New("GITHUB_CLIENT_SECRET is required when GITHUB_CLIENT_ID is set")
+	}
+	if len(g.allowedUsers) == 0 && g.allowedOrg == "" {
+		return errors.New("GITHUB_ALLOWED_USERS or GITHUB_ALLOWED_ORG is required when GITHUB_CLIENT_ID is set")
+	}
+	if !validRole(g.role) {
+		return fmt.Errorf("invalid GITHUB_ROLE %q", g.role)
+	}
+
+	github = g
+	return nil
+}
+
+func (g *githubOAuth) redirectURL() string {
+	return absoluteURL("/auth/github/callback")
+}
+
+func githubLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if github == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    signValue([]byte(state)),
+		Path:     pageURL("/auth/github/"),
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   baseURL.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	q := url.Values{}
+	q.Set("client_id", github.clientID)
+	q.Set("redirect_uri", github.redirectURL())
+	q.Set("state", state)
+	if github.allowedOrg != "" {
+		q.Set("scope", "read:org")
+	}
+
+	http.Redirect(w, r, github.authURL+"?"+q.Encode(), http.StatusFound)
+}
+
+func githubCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if github == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookieName, Path: pageURL("/auth/github/"), MaxAge: -1})
+
+	c, err := r.Cookie(oauthStateCookieName)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Login session expired, please try again.")
+		return
+	}
+	state, err := verifyValue(c.Value)
+	if err != nil || !hmac.Equal(state, []byte(r.FormValue("state"))) {
+		renderError(w, r, http.StatusBadRequest, "Login request could not be verified, please try again.")
+		return
+	}
+
+	if msg := r.FormValue("error_description"); msg != "" {
+		renderError(w, r, http.StatusUnauthorized, "GitHub login failed: "+msg)
+		return
+	}
+
+	token, err := github.exchange(r.FormValue("code"))
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Could not complete GitHub login: "+err.Error())
+		return
+	}
+
+	login, err := github.username(token)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Could not fetch GitHub user: "+err.Error())
+		return
+	}
+
+	allowed, err := github.allowed(token, login)
+	if err != nil {
+		renderError(w, r, http.StatusBadGateway, "Could not check GitHub organization membership: "+err.Error())
+		return
+	}
+	if !allowed {
+		renderError(w, r, http.StatusForbidden, "GitHub user "+login+" is not allowed to log in to this wiki.")
+		return
+	}
+
+	if err := setSession(w, login, github.role); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Logged in as " + login})
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
+
+func (g *githubOAuth) exchange(code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing authorization code")
+	}
+
+	form := url.Values{}
+	form.Set("client_id", g.clientID)
+	form.Set("client_secret", g.clientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", g.redirectURL())
+
+	req, err := http.NewRequest(http.MethodPost, g.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := g.do(req, &res); err != nil {
+		return "", err
+	}
+	if res.AccessToken == "" {
+		if res.ErrorDescription != "" {
+			return "", errors.New(res.ErrorDescription)
+		}
+		return "", fmt.Errorf("no access token in response (%s)", res.Error)
+	}
+
+	return res.AccessToken, nil
+}
+
+func (g *githubOAuth) username(token string) (string, error) {
+	req, err := g.apiRequest(token, "/user")
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		Login string `json:"login"`
+	}
+	if err := g.do(req, &res); err != nil {
+		return "", err
+	}
+	if res.Login == "" {
+		return "", errors.New("empty login in response")
+	}
+
+	return res.Login, nil
+}
+
+func (g *githubOAuth) allowed(token, login string) (bool, error) {
+	if slices.Contains(g.allowedUsers, strings.ToLower(login)) {
+		return true, nil
+	}
+	if g.allowedOrg == "" {
+		return false, nil
+	}
+
+	req, err := g.apiRequest(token, "/orgs/"+url.PathEscape(g.allowedOrg)+"/members/"+url.PathEscape(login))
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound, http.StatusFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+func (g *githubOAuth) apiRequest(token, path string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, g.apiURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	return req, nil
+}
+
+func (g *githubOAuth) do(req *http.Request, v interface{}) error {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// stubGitHub is a GitHub that knows one code per user and one org.
+func stubGitHub(t *testing.T, members ...string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasSuffix(r.FormValue("redirect_uri"), "/auth/github/callback") {
+			t.Errorf("redirect_uri = %q", r.FormValue("redirect_uri"))
+		}
+		code := r.FormValue("code")
+		if !strings.HasPrefix(code, "code-") {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code", "error_description": "The code passed is incorrect or expired."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token-" + strings.TrimPrefix(code, "code-")})
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		login, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer token-")
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"login": login})
+	})
+	mux.HandleFunc("GET /orgs/acme/members/{login}", func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range members {
+			if r.PathValue("login") == m {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func setupTestGitHub(t *testing.T, provider *httptest.Server, env map[string]string) {
+	t.Helper()
+	setGlobal(t, &github, nil)
+	t.Setenv("GITHUB_CLIENT_ID", "client")
+	t.Setenv("GITHUB_CLIENT_SECRET", "secret")
+	t.Setenv("GITHUB_AUTH_URL", provider.URL+"/login/oauth/authorize")
+	t.Setenv("GITHUB_TOKEN_URL", provider.URL+"/login/oauth/access_token")
+	t.Setenv("GITHUB_API_URL", provider.URL)
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	if err := setupGitHubOAuth(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// githubLogin starts a login and returns the state the provider was sent
+// and the state cookie the browser would carry back.
+func (w *testWiki) githubLogin() (string, *http.Cookie) {
+	w.t.Helper()
+	resp, body := w.get("/auth/github/login")
+	if resp.StatusCode != http.StatusFound {
+		w.t.Fatalf("login = %d\n%s", resp.StatusCode, body)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	for _, c := range resp.Cookies() {
+		if c.Name == oauthStateCookieName {
+			return loc.Query().Get("state"), c
+		}
+	}
+	w.t.Fatal("login set no state cookie")
+	return "", nil
+}
+
+func sessionOf(resp *http.Response) (session, error) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range resp.Cookies() {
+		r.AddCookie(c)
+	}
+	return readSession(r)
+}
+
+func TestGitHubLoginRedirect(t *testing.T) {
+	w := newTestWiki(t)
+	provider := stubGitHub(t)
+	setupTestGitHub(t, provider, map[string]string{"GITHUB_ALLOWED_ORG": "acme"})
+
+	resp, _ := w.get("/auth/github/login")
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), provider.URL+"/login/oauth/authorize?") {
+		t.Fatalf("login redirects to %q", resp.Header.Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("client_id") != "client" || q.Get("scope") != "read:org" || len(q.Get("state")) < 20 || !strings.HasSuffix(q.Get("redirect_uri"), "/auth/github/callback") {
+		t.Errorf("authorize query = %v", q)
+	}
+
+	state, _ := w.githubLogin()
+	if state == q.Get("state") {
+		t.Error("two logins got the same state")
+	}
+}
+
+func TestGitHubCallback(t *testing.T) {
+	w := newTestWiki(t)
+	setupTestGitHub(t, stubGitHub(t, "orgmember"), map[string]string{
+		"GITHUB_ALLOWED_USERS": "Octocat",
+		"GITHUB_ALLOWED_ORG":   "acme",
+		"GITHUB_ROLE":          roleAdmin,
+	})
+
+	callback := func(code, state string, cookies ...*http.Cookie) (*http.Response, string) {
+		return w.get("/auth/github/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), cookies...)
+	}
+
+	for _, user := range []string{"octocat", "orgmember"} {
+		state, cookie := w.githubLogin()
+		resp, body := callback("code-"+user, state, cookie)
+		wantStatus(t, resp, body, http.StatusFound)
+		if resp.Header.Get("Location") != "/" {
+			t.Errorf("%s: callback redirects to %q", user, resp.Header.Get("Location"))
+		}
+		if s, err := sessionOf(resp); err != nil || s.User != user || s.Role != roleAdmin {
+			t.Errorf("%s: session = %+v, %v", user, s, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		code   string
+		state  func(string) string
+		cookie bool
+		status int
+		msg    string
+	}{
+		{"not allowed", "code-stranger", nil, true, http.StatusForbidden, "stranger is not allowed"},
+		{"bad code", "wrong", nil, true, http.StatusBadGateway, "The code passed is incorrect"},
+		{"missing code", "", nil, true, http.StatusBadGateway, "missing authorization code"},
+		{"forged state", "code-octocat", func(string) string { return "forged" }, true, http.StatusBadRequest, "could not be verified"},
+		{"no state cookie", "code-octocat", nil, false, http.StatusBadRequest, "Login session expired"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			state, cookie := w.githubLogin()
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+			var cookies []*http.Cookie
+			if tt.cookie {
+				cookies = append(cookies, cookie)
+			}
+			resp, body := callback(tt.code, state, cookies...)
+			wantStatus(t, resp, body, tt.status)
+			if !strings.Contains(body, tt.msg) || !strings.Contains(body, "<html") {
+				t.Errorf("error page does not explain %q:\n%s", tt.msg, body)
+			}
+			if _, err := sessionOf(resp); err == nil {
+				t.Error("a failed login set a session")
+			}
+		})
+	}
+
+	// A state cookie from another login does not verify this one's state.
+	state, _ := w.githubLogin()
+	_, other := w.githubLogin()
+	resp, body := callback("code-octocat", state, other)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+
+	state, cookie := w.githubLogin()
+	resp, body = w.get("/auth/github/callback?"+url.Values{"state": {state}, "error_description": {"The user has denied your application access."}}.Encode(), cookie)
+	wantStatus(t, resp, body, http.StatusUnauthorized)
+	if !strings.Contains(body, "denied your application") {
+		t.Errorf("provider error not shown:\n%s", body)
+	}
+}
+
+func TestGitHubProviderUnreachable(t *testing.T) {
+	w := newTestWiki(t)
+	provider := stubGitHub(t)
+	setupTestGitHub(t, provider, map[string]string{"GITHUB_ALLOWED_ORG": "acme"})
+	github.apiURL = provider.URL + "/broken"
+
+	state, cookie := w.githubLogin()
+	resp, body := w.get("/auth/github/callback?"+url.Values{"code": {"code-octocat"}, "state": {state}}.Encode(), cookie)
+	wantStatus(t, resp, body, http.StatusBadGateway)
+}
+
+func TestGitHubDisabled(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &github, nil)
+	for _, path := range []string{"/auth/github/login", "/auth/github/callback"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusNotFound)
+	}
+}
+
+func TestSetupGitHubOAuth(t *testing.T) {
+	setGlobal(t, &github, nil)
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+	}{
+		{"no secret", map[string]string{"GITHUB_CLIENT_SECRET": "", "GITHUB_ALLOWED_USERS": "octocat"}},
+		{"no allowlist", map[string]string{"GITHUB_CLIENT_SECRET": "secret", "GITHUB_ALLOWED_USERS": " , "}},
+		{"bad role", map[string]string{"GITHUB_CLIENT_SECRET": "secret", "GITHUB_ALLOWED_USERS": "octocat", "GITHUB_ROLE": "owner"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_CLIENT_ID", "client")
+			t.Setenv("GITHUB_ROLE", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if err := setupGitHubOAuth(); err == nil || github != nil {
+				t.Errorf("setupGitHubOAuth = %v", err)
+			}
+		})
+	}
+
+	t.Setenv("GITHUB_CLIENT_ID", "")
+	if err := setupGitHubOAuth(); err != nil || github != nil {
+		t.Errorf("GitHub login enabled without GITHUB_CLIENT_ID: %v", err)
+	}
+}
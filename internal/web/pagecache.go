@@ -0,0 +1,150 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// pageCache is the sorted list of pages served by listPages. It is kept
+// fresh by the save and delete hooks and, for changes made outside the wiki,
+// by watching STORAGE_PATH.
+type pageCache struct {
+	mu     sync.RWMutex
+	loaded bool
+	pages  []pageInfo
+}
+
+var pages = &pageCache{}
+
+func (c *pageCache) ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.loaded
+}
+
+func (c *pageCache) titles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	titles := make([]string, len(c.pages))
+	for i, p := range c.pages {
+		titles[i] = p.Title
+	}
+
+	return titles
+}
+
+func (c *pageCache) infos() []pageInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return slices.Clone(c.pages)
+}
+
+func (c *pageCache) rebuild() error {
+	infos, err := scanPageInfos()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(infos, func(a, b pageInfo) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+
+	c.mu.Lock()
+	c.pages = infos
+	c.loaded = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+// refresh re-reads a single page from disk, adding, updating or dropping it.
+func (c *pageCache) refresh(title string) {
+	fi, err := statPage(title)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, found := slices.BinarySearchFunc(c.pages, title, func(p pageInfo, t string) int {
+		return strings.Compare(p.Title, t)
+	})
+
+	switch {
+	case err != nil && found:
+		c.pages = slices.Delete(c.pages, i, i+1)
+	case err != nil:
+	case found:
+		c.pages[i] = pageInfo{Title: title, Modified: fi.ModTime(), Size: fi.Size()}
+	default:
+		c.pages = slices.Insert(c.pages, i, pageInfo{Title: title, Modified: fi.ModTime(), Size: fi.Size()})
+	}
+}
+
+// watchStorage picks up pages added, changed or removed outside the wiki.
+func (c *pageCache) watchStorage() error {
+	dir := config.StoragePath
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if title, ok := strings.CutSuffix(filepath.Base(event.Name), ".txt"); ok {
+					c.refresh(title)
+					searchIndexer.enqueue(title)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("error watching storage", "err", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func refreshPagesHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can refresh the page list.")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := pages.rebuild(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	searchIndexer.requestRebuild()
+
+	addFlash(w, r, flash{Level: "success", Text: "Page list refreshed, the search index is being rebuilt"})
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
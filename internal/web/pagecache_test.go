@@ -0,0 +1,165 @@
+package web
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+// countingStore counts the listings and stats that reach the storage.
+type countingStore struct {
+	storage.Storage
+	lists, stats atomic.Int64
+}
+
+func (s *countingStore) List() ([]string, error) {
+	s.lists.Add(1)
+	return s.Storage.List()
+}
+
+func (s *countingStore) Stat(title string) (fs.FileInfo, error) {
+	s.stats.Add(1)
+	return s.Storage.Stat(title)
+}
+
+func TestIndexServedFromCache(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Alpha", "a")
+	w.seed("Beta", "b")
+
+	counting := &countingStore{Storage: store}
+	store = counting
+
+	resp, body := w.get("/")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "/view/Alpha") || !strings.Contains(body, "/view/Beta") {
+		t.Errorf("index does not list the pages:\n%s", body)
+	}
+	if n := counting.lists.Load() + counting.stats.Load(); n != 0 {
+		t.Errorf("index made %d storage calls, want none", n)
+	}
+}
+
+func TestPageCacheHooks(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Beta", "b")
+	w.seed("Alpha", "a")
+
+	if got := pages.titles(); !slices.Equal(got, []string{"Alpha", "Beta"}) {
+		t.Fatalf("titles after saves = %v, want sorted [Alpha Beta]", got)
+	}
+
+	w.seed("Alpha", "longer body")
+	if infos := pages.infos(); infos[0].Size != int64(len("longer body")) {
+		t.Errorf("size after an update = %d", infos[0].Size)
+	}
+
+	if err := deletePage(&page.Page{Title: "Beta"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pages.titles(); !slices.Equal(got, []string{"Alpha"}) {
+		t.Errorf("titles after a delete = %v, want [Alpha]", got)
+	}
+}
+
+func TestPageCacheManualRefresh(t *testing.T) {
+	w := newTestWiki(t)
+	if err := os.WriteFile(filepath.Join(w.dir, "Outside.txt"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := w.post("/admin/refresh", url.Values{}, w.login("bob", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	resp, body = w.post("/admin/refresh", url.Values{}, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := pages.titles(); !slices.Equal(got, []string{"Outside"}) {
+		t.Errorf("titles after refresh = %v, want [Outside]", got)
+	}
+}
+
+func TestPageCacheWatchesStorage(t *testing.T) {
+	w := newTestWiki(t)
+	if err := pages.watchStorage(); err != nil {
+		t.Skip("fsnotify unavailable:", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(w.dir, "Outside.txt"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return slices.Contains(pages.titles(), "Outside") })
+
+	if err := os.Remove(filepath.Join(w.dir, "Outside.txt")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return !slices.Contains(pages.titles(), "Outside") })
+}
+
+// waitFor polls cond for up to two seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met in time")
+}
+
+func seedPages(b *testing.B, n int) {
+	for i := range n {
+		if err := store.Write(fmt.Sprintf("Page-%04d", i), []byte("body")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := pages.rebuild(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkIndexHandler(b *testing.B) {
+	newTestWiki(b)
+	seedPages(b, 1000)
+	handler := newHandler(testServer, routes())
+
+	b.ReportAllocs()
+	for b.Loop() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			b.Fatal(rec.Code)
+		}
+	}
+}
+
+func BenchmarkListPageInfos(b *testing.B) {
+	newTestWiki(b)
+	seedPages(b, 1000)
+
+	b.Run("cached", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := listPageInfos(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := scanPageInfos(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultPasswordMinLength  = 10
+	defaultPasswordMinClasses = 3
+)
+
+var (
+	passwordMinLength  = defaultPasswordMinLength
+	passwordMinClasses = defaultPasswordMinClasses
+)
+
+func setupPasswordPolicy() error {
+	for _, limit := range []struct {
+		key   string
+		value *int
+		max   int
+	}{
+		{"PASSWORD_MIN_LENGTH", &passwordMinLength, 72},
+		{"PASSWORD_MIN_CLASSES", &passwordMinClasses, 4},
+	} {
+		raw := os.Getenv(limit.key)
+		if raw == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > limit.max {
+			return fmt.Errorf("invalid %s %q", limit.key, raw)
+		}
+		*limit.value = n
+	}
+
+	return nil
+}
+
+// checkPasswordStrength enforces the minimum length and the number of
+// character classes (lowercase, uppercase, digits, other) in a password.
+func checkPasswordStrength(password string) error {
+	if len([]rune(password)) < passwordMinLength {
+		return fmt.Errorf("password must be at least %d characters long", passwordMinLength)
+	}
+	// bcrypt ignores everything past 72 bytes.
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes long")
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < passwordMinClasses {
+		return fmt.Errorf("password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", passwordMinClasses)
+	}
+
+	return nil
+}
+
+func setPassword(name, role, password string) error {
+	if err := checkPasswordStrength(password); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return updateUser(name, func(u *userRecord) {
+		u.PasswordHash = string(hash)
+		if role != "" {
+			u.Role = role
+		}
+		if u.Role == "" {
+			u.Role = roleEditor
+		}
+	})
+}
+
+// localBackend checks passwords stored by "gowiki passwd" in the user records.
+type localBackend struct{}
+
+func (localBackend) Authenticate(username, password string) (string, error) {
+	if username == "" {
+		return "", errInvalidCredentials
+	}
+
+	u, err := loadUser(username)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errAuthUnavailable, err)
+	}
+	if u.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return "", errInvalidCredentials
+	}
+
+	return u.Role, nil
+}
+
+// Passwd runs the "gowiki passwd" command with the given settings.
+func Passwd(cfg Config, args []string, stdin io.Reader) error {
+	config = cfg
+	if err := setupPasswordPolicy(); err != nil {
+		return err
+	}
+
+	return runPasswd(args, stdin)
+}
+
+// runPasswd implements "gowiki passwd <user> [role]", reading the new
+// password from the first line of stdin.
+func runPasswd(args []string, stdin io.Reader) error {
+	if len(args) < 1 || len(args) > 2 || args[0] == "" {
+		return errors.New("usage: gowiki passwd <user> [reader|editor|admin]")
+	}
+
+	role := ""
+	if len(args) == 2 {
+		role = args[1]
+		if !validRole(role) {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	fmt.Fprint(os.Stderr, "New password: ")
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("reading password: %w", err)
+	}
+
+	return setPassword(args[0], role, strings.TrimRight(line, "\r\n"))
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	setGlobal(t, &passwordMinLength, defaultPasswordMinLength)
+	setGlobal(t, &passwordMinClasses, defaultPasswordMinClasses)
+
+	for password, want := range map[string]string{
+		"Sh0rt!":                        "at least 10 characters",
+		"alllowercaseletters":           "at least 3 of",
+		"lowercase1234567":              "at least 3 of",
+		"Lower and UPPER":               "",
+		"correct-horse-7":               "",
+		"Пароль-надёжный":               "",
+		"пароль12345":                   "at least 3 of",
+		"Aa1" + strings.Repeat("x", 70): "at most 72 bytes",
+	} {
+		err := checkPasswordStrength(password)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("checkPasswordStrength(%q) = %v, want %q", password, err, want)
+		}
+	}
+
+	// Length counts characters, not bytes.
+	passwordMinClasses = 0
+	if err := checkPasswordStrength("ёёёёёёёёёё"); err != nil {
+		t.Errorf("ten Cyrillic letters: %v", err)
+	}
+}
+
+func TestSetupPasswordPolicy(t *testing.T) {
+	setGlobal(t, &passwordMinLength, defaultPasswordMinLength)
+	setGlobal(t, &passwordMinClasses, defaultPasswordMinClasses)
+	t.Setenv("PASSWORD_MIN_LENGTH", "16")
+	t.Setenv("PASSWORD_MIN_CLASSES", "4")
+	if err := setupPasswordPolicy(); err != nil || passwordMinLength != 16 || passwordMinClasses != 4 {
+		t.Errorf("policy = %d, %d, %v", passwordMinLength, passwordMinClasses, err)
+	}
+	for key, raw := range map[string]string{"PASSWORD_MIN_LENGTH": "73", "PASSWORD_MIN_CLASSES": "5"} {
+		t.Setenv(key, raw)
+		if err := setupPasswordPolicy(); err == nil {
+			t.Errorf("%s=%s accepted", key, raw)
+		}
+		t.Setenv(key, "")
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	newTestWiki(t)
+
+	if err := setPassword("alice", "", "weakpass"); err == nil {
+		t.Fatal("weak password accepted")
+	}
+	if u, err := loadUser("alice"); err == nil && u.PasswordHash != "" {
+		t.Error("weak password was stored")
+	}
+
+	const password = "Correct-Horse-7"
+	if err := setPassword("alice", "", password); err != nil {
+		t.Fatal(err)
+	}
+	u, err := loadUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(u.PasswordHash, password) || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		t.Errorf("stored hash %q does not verify the password", u.PasswordHash)
+	}
+	if u.Role != roleEditor {
+		t.Errorf("role = %q, want the editor default", u.Role)
+	}
+
+	if role, err := (localBackend{}).Authenticate("alice", password); err != nil || role != roleEditor {
+		t.Errorf("Authenticate = %q, %v", role, err)
+	}
+	for _, creds := range [][2]string{{"alice", "correct-horse-7"}, {"alice", ""}, {"", password}} {
+		if _, err := (localBackend{}).Authenticate(creds[0], creds[1]); !errors.Is(err, errInvalidCredentials) {
+			t.Errorf("Authenticate(%q, %q) = %v", creds[0], creds[1], err)
+		}
+	}
+}
+
+func TestRunPasswd(t *testing.T) {
+	newTestWiki(t)
+
+	if err := runPasswd([]string{"root", "admin"}, strings.NewReader("Admin-Pass-2024\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if role, err := (localBackend{}).Authenticate("root", "Admin-Pass-2024"); err != nil || role != roleAdmin {
+		t.Errorf("Authenticate = %q, %v", role, err)
+	}
+	// Changing the password keeps the role.
+	if err := runPasswd([]string{"root"}, strings.NewReader("Another-Pass-2025")); err != nil {
+		t.Fatal(err)
+	}
+	if role, err := (localBackend{}).Authenticate("root", "Another-Pass-2025"); err != nil || role != roleAdmin {
+		t.Errorf("after the change Authenticate = %q, %v", role, err)
+	}
+
+	for _, args := range [][]string{nil, {""}, {"root", "owner"}, {"a", "b", "c"}} {
+		if err := runPasswd(args, strings.NewReader("Admin-Pass-2024\n")); err == nil {
+			t.Errorf("passwd %q accepted", args)
+		}
+	}
+	if err := runPasswd([]string{"root"}, strings.NewReader("short\n")); err == nil || !strings.Contains(err.Error(), "at least") {
+		t.Errorf("weak password from stdin: %v", err)
+	}
+	if err := runPasswd([]string{"root"}, strings.NewReader("")); err == nil {
+		t.Error("empty stdin accepted")
+	}
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// plaintextCache keeps a de-Markdowned copy of every page for search snippets,
+// and an inverted index from analyzed terms to the pages using them.
+type plaintextCache struct {
+	mu    sync.RWMutex
+	pages map[string]string
+	index map[string]map[string]int
+	terms map[string][]string
+}
+
+var plaintexts = &plaintextCache{pages: map[string]string{}, index: map[string]map[string]int{}, terms: map[string][]string{}}
+
+func toPlaintext(body []byte) string {
+	source := page.StripFrontMatter(body)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	var buf bytes.Buffer
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock && buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			buf.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(n.Value)
+		case *ast.AutoLink:
+			buf.Write(n.URL(source))
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				buf.Write(line.Value(source))
+			}
+		case *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func (c *plaintextCache) get(title string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, ok := c.pages[title]
+	return s, ok
+}
+
+func (c *plaintextCache) update(title string) {
+	p, err := loadPage(title)
+	if err != nil {
+		c.remove(title)
+		return
+	}
+
+	s := toPlaintext(p.Body)
+	// The title is indexed too, so pages are found by their title alone.
+	counts := map[string]int{}
+	for _, term := range searchAnalyzer.terms(title + " " + s) {
+		counts[term]++
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.unindex(title)
+	c.pages[title] = s
+	for term, n := range counts {
+		if c.index[term] == nil {
+			c.index[term] = map[string]int{}
+		}
+		c.index[term][title] = n
+		c.terms[title] = append(c.terms[title], term)
+	}
+}
+
+func (c *plaintextCache) remove(title string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.unindex(title)
+	delete(c.pages, title)
+}
+
+func (c *plaintextCache) unindex(title string) {
+	for _, term := range c.terms[title] {
+		delete(c.index[term], title)
+		if len(c.index[term]) == 0 {
+			delete(c.index, term)
+		}
+	}
+	delete(c.terms, title)
+}
+
+// postings returns how often each page uses the term.
+func (c *plaintextCache) postings(term string) map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[string]int, len(c.index[term]))
+	for title, n := range c.index[term] {
+		counts[title] = n
+	}
+
+	return counts
+}
+
+// rebuild indexes every page into a fresh cache and swaps it in, so searches
+// never see a half built index.
+func (c *plaintextCache) rebuild() {
+	titles, err := listPages()
+	if err != nil {
+		slog.Error("error building plaintext cache", "err", err)
+		return
+	}
+
+	fresh := &plaintextCache{pages: map[string]string{}, index: map[string]map[string]int{}, terms: map[string][]string{}}
+	for _, title := range titles {
+		fresh.update(title)
+	}
+
+	c.mu.Lock()
+	c.pages, c.index, c.terms = fresh.pages, fresh.index, fresh.terms
+	c.mu.Unlock()
+}
+
+func (c *plaintextCache) titles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	titles := make([]string, 0, len(c.pages))
+	for title := range c.pages {
+		titles = append(titles, title)
+	}
+
+	return titles
+}
+
+func (c *plaintextCache) count() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.pages)
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func TestToPlaintext(t *testing.T) {
+	for source, want := range map[string]string{
+		"# Title\n\nSome *emphasis* and **bold** text.":            "Title Some emphasis and bold text.",
+		"A [link](/view/Other) and ![alt text](/logo).":            "A link and alt text.",
+		"- one\n- two\n\n1. three":                                 "one two three",
+		"> quoted\n> lines":                                        "quoted lines",
+		"Inline `code` and\n\n```go\nfunc main() {}\n```":          "Inline code and func main() {}",
+		"| a | b |\n|---|---|\n| 1 | 2 |":                          "a b 1 2",
+		"Visit <https://example.com> now":                          "Visit https://example.com now",
+		"before <span>inside</span> after\n\n<div>\nblock\n</div>": "before inside after",
+		"---\ntags: secret\n---\nbody only":                        "body only",
+		"~~struck~~ and a\nsoft break":                             "struck and a soft break",
+	} {
+		if got := toPlaintext([]byte(source)); got != want {
+			t.Errorf("toPlaintext(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+// The cache follows the page through saves and deletes.
+func TestPlaintextCacheUpdates(t *testing.T) {
+	w := newTestWiki(t)
+	startIndexer(t, time.Millisecond)
+	cached := func(want string) func() bool {
+		return func() bool {
+			s, ok := plaintexts.get("Home")
+			return ok && s == want
+		}
+	}
+
+	w.seed("Home", "# Old heading\n\n*first* version")
+	waitFor(t, cached("Old heading first version"))
+
+	w.seed("Home", "[second](/view/Other) **version**")
+	waitFor(t, cached("second version"))
+	if got := plaintexts.postings("first"); len(got) != 0 {
+		t.Errorf("postings(first) = %v after the page changed", got)
+	}
+
+	resp, body := w.get("/search?q=second")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "version") || strings.Contains(body, "**version**") {
+		t.Errorf("search snippet does not come from the new text:\n%s", body)
+	}
+
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { _, ok := plaintexts.get("Home"); return !ok })
+	if plaintexts.count() != 0 {
+		t.Errorf("%d cached pages after the only page was deleted", plaintexts.count())
+	}
+}
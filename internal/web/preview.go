@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const previewLength = 300
+
+type apiPreview struct {
+	Title    string    `json:"title"`
+	Excerpt  string    `json:"excerpt"`
+	Modified time.Time `json:"modified"`
+	Tags     []string  `json:"tags"`
+}
+
+// excerpt cuts text to at most n runes, at a word boundary when there is one.
+func excerpt(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+
+	cut := string([]rune(text)[:n])
+	if i := strings.LastIndexByte(cut, ' '); i > n/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// apiPreviewHandler returns what a hover card over a wiki link shows. It is
+// called a lot, so it answers from the caches and lets clients keep the
+// result until the page changes.
+func apiPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	title := strings.TrimPrefix(r.URL.Path, "/api/preview/")
+	if !validTitle(title) {
+		writeAPIError(w, http.StatusBadRequest, "invalid title")
+		return
+	}
+
+	fi, err := statPage(title)
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-cache")
+		writeAPIError(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	etag := pageETag(fi)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=300, must-revalidate")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	text, ok := plaintexts.get(title)
+	if !ok {
+		p, err := loadPage(title)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		text = toPlaintext(p.Body)
+	}
+	tags := pageTags.of(title)
+	if tags == nil {
+		tags = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, apiPreview{
+		Title:    displayTitle(title),
+		Excerpt:  excerpt(text, previewLength),
+		Modified: fi.ModTime().UTC(),
+		Tags:     tags,
+	})
+}
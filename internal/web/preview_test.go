@@ -0,0 +1,92 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	for _, tt := range []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly ten", 11, "exactly ten"},
+		{"cut at a word boundary", 12, "cut at a…"},
+		{"averyverylongword", 8, "averyver…"},
+		{"ёжик в тумане", 8, "ёжик в…"},
+	} {
+		if got := excerpt(tt.text, tt.n); got != tt.want {
+			t.Errorf("excerpt(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+	if got := excerpt(long, previewLength); utf8.RuneCountInString(got) > previewLength+1 {
+		t.Errorf("excerpt is %d runes long", utf8.RuneCountInString(got))
+	}
+}
+
+func TestAPIPreview(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "---\ntags: Go, wiki\n---\n# Welcome\n\nThe **home** page. "+strings.Repeat("more text ", 50))
+	w.seed("Plain", "no tags")
+	rebuildIndexes()
+
+	resp, body := w.get("/api/preview/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	var p apiPreview
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := statPage("Home")
+	if p.Title != "Home" || !strings.HasPrefix(p.Excerpt, "Welcome") || strings.Contains(p.Excerpt, "**") ||
+		!strings.HasSuffix(p.Excerpt, "…") || !p.Modified.Equal(fi.ModTime()) || strings.Join(p.Tags, ",") != "go,wiki" {
+		t.Errorf("preview = %+v", p)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag != pageETag(fi) || resp.Header.Get("Cache-Control") != "private, max-age=300, must-revalidate" {
+		t.Errorf("ETag %q, Cache-Control %q", etag, resp.Header.Get("Cache-Control"))
+	}
+	req, err := http.NewRequest(http.MethodGet, w.URL+"/api/preview/Home", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	resp, body = w.do(req)
+	wantStatus(t, resp, body, http.StatusNotModified)
+	if body != "" {
+		t.Errorf("304 has a body: %q", body)
+	}
+
+	// A change makes the cached copy stale.
+	w.seed("Home", "changed")
+	req.Header.Set("If-None-Match", etag)
+	resp, body = w.do(req)
+	wantStatus(t, resp, body, http.StatusOK)
+
+	// Tags are always a list.
+	if _, body := w.get("/api/preview/Plain"); !strings.Contains(body, `"tags":[]`) {
+		t.Errorf("preview without tags:\n%s", body)
+	}
+	if err := setDisplayTitle("Plain", "The plain page"); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := w.get("/api/preview/Plain"); !strings.Contains(body, `"title":"The plain page"`) {
+		t.Errorf("preview does not use the display title:\n%s", body)
+	}
+
+	resp, body = w.get("/api/preview/Missing")
+	wantStatus(t, resp, body, http.StatusNotFound)
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("missing page: %v\n%s", resp.Header, body)
+	}
+	resp, body = w.get("/api/preview/Bad_Title")
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	resp, body = w.api(http.MethodPost, "/api/preview/Home", "")
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
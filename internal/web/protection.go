@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+const (
+	protectionOpen    = "open"
+	protectionEditors = "editors"
+	protectionAdmins  = "admins"
+)
+
+var protectionLevels = []string{protectionOpen, protectionEditors, protectionAdmins}
+
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user,omitempty"`
+	Action string    `json:"action"`
+	Title  string    `json:"title"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+func metaFilename(title string) string {
+	return filepath.Join(config.StoragePath, ".meta", title+".json")
+}
+
+// loadMeta reads the page metadata kept next to the page. It is stored apart
+// from the body so editors cannot change it by editing the page.
+func loadMeta(title string) (page.Meta, error) {
+	var meta page.Meta
+
+	b, err := os.ReadFile(metaFilename(title))
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+
+	return meta, json.Unmarshal(b, &meta)
+}
+
+func saveMeta(title string, meta page.Meta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	fn := metaFilename(title)
+	if err := os.MkdirAll(filepath.Dir(fn), 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, b, 0600)
+}
+
+func pageProtection(title string) string {
+	meta, err := loadMeta(title)
+	if err != nil || meta.Protection == "" {
+		return protectionOpen
+	}
+
+	return meta.Protection
+}
+
+// allowedByProtection reports whether the request may change a page with the
+// given protection level. Open pages follow the normal edit rules.
+func allowedByProtection(r *http.Request, level string) bool {
+	s, _ := currentSession(r)
+
+	switch level {
+	case protectionEditors:
+		return s.Role == roleEditor || s.Role == roleAdmin
+	case protectionAdmins:
+		return s.Role == roleAdmin && adminCertError(r) == nil
+	default:
+		return canEdit(r)
+	}
+}
+
+func renderProtected(w http.ResponseWriter, r *http.Request, title, level string) {
+	msg := "Page " + title + " is protected and can only be changed by editors."
+	if level == protectionAdmins {
+		msg = "Page " + title + " is protected and can only be changed by admins."
+	}
+
+	renderError(w, r, http.StatusForbidden, msg)
+}
+
+func recordAudit(entry auditEntry) error {
+	entry.Time = time.Now().UTC()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(config.StoragePath, 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(config.StoragePath, ".audit.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func protectHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can change page protection.")
+		return
+	}
+
+	level := r.FormValue("protection")
+	if !slices.Contains(protectionLevels, level) {
+		http.Error(w, "Unknown protection level", http.StatusBadRequest)
+		return
+	}
+
+	meta, err := loadMeta(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	previous := meta.Protection
+	if previous == "" {
+		previous = protectionOpen
+	}
+	meta.Protection = level
+
+	if err := saveMeta(param, meta); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := recordAudit(auditEntry{User: s.User, Action: "protect", Title: param, Detail: previous + " -> " + level}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Protection of " + param + " set to " + level})
+	http.Redirect(w, r, pageURL("/edit/"+param), http.StatusFound)
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPageProtection(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	alice, root := w.login("alice", roleEditor), w.login("root", roleAdmin)
+
+	if _, body := w.get("/edit/Home", alice); strings.Contains(body, "/protect/Home") {
+		t.Error("the edit page offers protection to an editor")
+	}
+	if _, body := w.get("/edit/Home", root); !strings.Contains(body, "/protect/Home") {
+		t.Error("the edit page does not offer protection to an admin")
+	}
+
+	resp, body := w.post("/protect/Home", url.Values{"protection": {protectionAdmins}}, alice)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = w.post("/protect/Home", url.Values{"protection": {"everyone"}}, root)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	resp, body = w.post("/protect/Home", url.Values{"protection": {protectionAdmins}}, root)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	audit := readAudit(t)
+	if len(audit) != 1 || audit[0].User != "root" || audit[0].Action != "protect" || audit[0].Title != "Home" || audit[0].Detail != "open -> admins" {
+		t.Errorf("audit = %+v", audit)
+	}
+	if _, body := w.get("/view/Home", alice); !strings.Contains(body, "admins only") {
+		t.Errorf("view shows no protection badge:\n%s", body)
+	}
+
+	// Every write is refused before it happens.
+	for _, tt := range []struct {
+		path string
+		form url.Values
+	}{
+		{"/save/Home", url.Values{"title": {"Home"}, "body": {"changed"}}},
+		{"/delete/Home", w.csrf(alice)},
+		{"/rename/Home", url.Values{"newTitle": {"Moved"}}},
+	} {
+		resp, body := w.post(tt.path, tt.form, alice)
+		wantStatus(t, resp, body, http.StatusForbidden)
+		if !strings.Contains(body, "can only be changed by admins") {
+			t.Errorf("%s refusal does not explain the protection:\n%s", tt.path, body)
+		}
+	}
+	if _, body := w.get("/raw/Home"); body != "home" {
+		t.Errorf("protected page changed to %q", body)
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"by root"}}, root)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	// The protection moves with the page.
+	resp, body = w.post("/rename/Home", url.Values{"newTitle": {"Start"}}, root)
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := pageProtection("Start"); got != protectionAdmins {
+		t.Errorf("protection after rename = %q", got)
+	}
+}
+
+func TestEditorsOnlyProtection(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Policy", "policy")
+	setProtection(t, "Policy", protectionEditors)
+
+	form := url.Values{"title": {"Policy"}, "body": {"changed"}}
+	resp, body := w.post("/save/Policy", form)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	if !strings.Contains(body, "can only be changed by editors") {
+		t.Errorf("anonymous refusal does not explain the protection:\n%s", body)
+	}
+	resp, body = w.post("/save/Policy", form, w.login("reader", roleReader))
+	wantStatus(t, resp, body, http.StatusForbidden)
+
+	resp, body = w.post("/save/Policy", form, w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusFound)
+}
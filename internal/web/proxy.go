@@ -0,0 +1,220 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// trustedProxies are the peers whose Forwarded and X-Forwarded-* headers are
+// believed. Requests from anyone else are taken at face value, whatever
+// headers they carry.
+var trustedProxies []netip.Prefix
+
+// trustUnixPeers trusts whoever connects over the Unix socket, which has no
+// address to match. It is set by a "unix" entry in TRUSTED_PROXIES.
+var trustUnixPeers bool
+
+// unixListener is set when the wiki listens on a Unix socket, where the
+// peer of every request is the local reverse proxy.
+var unixListener bool
+
+// clientInfo is where a request really came from once trusted proxies are
+// accounted for.
+type clientInfo struct {
+	IP     netip.Addr
+	Scheme string
+	Host   string
+}
+
+type clientInfoKey struct{}
+
+// proxyHop is one entry of a forwarding chain: the address a proxy received
+// the request from, and the scheme and host it was asked for.
+type proxyHop struct {
+	addr   netip.Addr
+	scheme string
+	host   string
+}
+
+// setupTrustedProxies reads TRUSTED_PROXIES as a list of addresses, CIDR
+// ranges and "unix" for peers on the Unix socket. The older TRUST_PROXY=true
+// still trusts every peer.
+func setupTrustedProxies() error {
+	for _, raw := range splitList(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if strings.EqualFold(raw, "unix") {
+			trustUnixPeers = true
+			continue
+		}
+		p, err := parsePrefix(raw)
+		if err != nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", raw, err)
+		}
+		trustedProxies = append(trustedProxies, p)
+	}
+	if os.Getenv("TRUST_PROXY") == "true" {
+		trustedProxies = append(trustedProxies, netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0"))
+		trustUnixPeers = true
+	}
+
+	return nil
+}
+
+func trustedProxy(addr netip.Addr) bool {
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// withClientInfo works out the client of every request once, so the rate
+// limiter, the logs and the URLs the wiki builds all agree on it.
+func withClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := resolveClient(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info)))
+	})
+}
+
+func requestClient(r *http.Request) clientInfo {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info
+	}
+	return resolveClient(r)
+}
+
+// clientIP returns the address of the client.
+func clientIP(r *http.Request) netip.Addr {
+	return requestClient(r).IP
+}
+
+// requestURL is the absolute URL of path as the client sees the wiki. An
+// explicit BASE_URL always wins over what the request says.
+func requestURL(r *http.Request, path string) string {
+	if baseURLSet {
+		return absoluteURL(path)
+	}
+
+	info := requestClient(r)
+	u := *baseURL
+	u.Scheme, u.Host, u.Path = info.Scheme, info.Host, pageURL(path)
+	return u.String()
+}
+
+func resolveClient(r *http.Request) clientInfo {
+	info := clientInfo{IP: peerAddr(r), Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+	if !trustedPeer(info.IP) {
+		return info
+	}
+
+	hops := forwardedHops(r)
+	// Walk back from the nearest proxy; the first address that is not one
+	// of ours is the client. Anything further left could be made up by it.
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if hop.scheme == "http" || hop.scheme == "https" {
+			info.Scheme = hop.scheme
+		}
+		if hop.host != "" {
+			info.Host = hop.host
+		}
+		if !hop.addr.IsValid() {
+			break
+		}
+		info.IP = hop.addr
+		if !trustedProxy(hop.addr) {
+			break
+		}
+	}
+
+	return info
+}
+
+// trustedPeer reports whether the headers of the direct peer are believed.
+// Over a Unix socket the peer has no address at all.
+func trustedPeer(peer netip.Addr) bool {
+	if !peer.IsValid() {
+		return unixListener && trustUnixPeers
+	}
+	return trustedProxy(peer)
+}
+
+func peerAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// forwardedHops reads the chain from the standard Forwarded header, or from
+// X-Forwarded-For, -Proto and -Host when there is none. The scheme and host
+// lists are matched to the addresses from the right; a single value belongs
+// to the nearest proxy.
+func forwardedHops(r *http.Request) []proxyHop {
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		var hops []proxyHop
+		for _, element := range splitList(strings.Join(values, ","), ",") {
+			var hop proxyHop
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				value = strings.Trim(value, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hop.addr = parseForwardedFor(value)
+				case "proto":
+					hop.scheme = strings.ToLower(value)
+				case "host":
+					hop.host = value
+				}
+			}
+			hops = append(hops, hop)
+		}
+		return hops
+	}
+
+	addrs := splitList(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	schemes := splitList(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+	hosts := splitList(strings.Join(r.Header.Values("X-Forwarded-Host"), ","), ",")
+
+	hops := make([]proxyHop, len(addrs))
+	for i, raw := range addrs {
+		hops[i].addr = parseForwardedFor(raw)
+	}
+	for i, j := len(schemes)-1, len(hops)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		hops[j].scheme = strings.ToLower(schemes[i])
+	}
+	for i, j := len(hosts)-1, len(hops)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		hops[j].host = hosts[i]
+	}
+
+	return hops
+}
+
+// parseForwardedFor accepts "192.0.2.1", "192.0.2.1:4711" and
+// "[2001:db8::1]:4711". Obfuscated and "unknown" identifiers give the zero
+// address.
+func parseForwardedFor(raw string) netip.Addr {
+	raw = strings.TrimSpace(raw)
+	if addrPort, err := netip.ParseAddrPort(raw); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveClient(t *testing.T) {
+	setGlobal(t, &trustedProxies, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8:f::/48")})
+	setGlobal(t, &trustUnixPeers, false)
+	setGlobal(t, &unixListener, false)
+
+	for _, tt := range []struct {
+		name    string
+		peer    string
+		headers map[string][]string
+		want    clientInfo
+	}{
+		{"no proxy", "198.51.100.7:4711", nil,
+			clientInfo{netip.MustParseAddr("198.51.100.7"), "http", "wiki.internal"}},
+		{"spoofed by an untrusted peer", "203.0.113.5:4711", map[string][]string{
+			"X-Forwarded-For": {"192.0.2.1"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.example"},
+			"Forwarded": {"for=192.0.2.1;proto=https;host=evil.example"},
+		}, clientInfo{netip.MustParseAddr("203.0.113.5"), "http", "wiki.internal"}},
+		{"trusted proxy", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"198.51.100.7"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"wiki.example.com"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "https", "wiki.example.com"}},
+		// The client may send its own X-Forwarded-For; only the part added
+		// by our proxies counts.
+		{"multi-hop chain", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"192.0.2.1, 198.51.100.7, 10.0.0.2"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "http", "wiki.internal"}},
+		{"chain over several headers", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"192.0.2.1", "198.51.100.7:5555"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "http", "wiki.internal"}},
+		{"chain of trusted proxies", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+		}, clientInfo{netip.MustParseAddr("10.0.0.3"), "http", "wiki.internal"}},
+		{"scheme of the nearest proxy", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"198.51.100.7, 10.0.0.2"}, "X-Forwarded-Proto": {"https"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "https", "wiki.internal"}},
+		{"unknown scheme ignored", "10.0.0.1:4711", map[string][]string{
+			"X-Forwarded-For": {"198.51.100.7"}, "X-Forwarded-Proto": {"gopher"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "http", "wiki.internal"}},
+		{"Forwarded wins over X-Forwarded-For", "[2001:db8:f::1]:4711", map[string][]string{
+			"Forwarded":       {`for=192.0.2.1, for="[2001:db8::7]:4711";proto=HTTPS;host=wiki.example.com`},
+			"X-Forwarded-For": {"198.51.100.9"},
+		}, clientInfo{netip.MustParseAddr("2001:db8::7"), "https", "wiki.example.com"}},
+		{"obfuscated hop", "10.0.0.1:4711", map[string][]string{
+			"Forwarded": {"for=192.0.2.1, for=_hidden"},
+		}, clientInfo{netip.MustParseAddr("10.0.0.1"), "http", "wiki.internal"}},
+		{"IPv4-mapped peer", "[::ffff:10.0.0.1]:4711", map[string][]string{
+			"X-Forwarded-For": {"198.51.100.7"},
+		}, clientInfo{netip.MustParseAddr("198.51.100.7"), "http", "wiki.internal"}},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://wiki.internal/view/Home", nil)
+		r.RemoteAddr = tt.peer
+		for key, values := range tt.headers {
+			r.Header[key] = values
+		}
+		if got := resolveClient(r); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// A Unix socket peer has no address; it is trusted only when the wiki
+// listens on a socket and TRUSTED_PROXIES has "unix".
+func TestResolveClientUnixSocket(t *testing.T) {
+	setGlobal(t, &trustedProxies, nil)
+	setGlobal(t, &trustUnixPeers, false)
+	setGlobal(t, &unixListener, true)
+
+	r := httptest.NewRequest(http.MethodGet, "http://wiki.internal/", nil)
+	r.RemoteAddr = "@"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := resolveClient(r); got.IP.IsValid() {
+		t.Errorf("untrusted socket peer = %v", got.IP)
+	}
+	trustUnixPeers = true
+	if got := resolveClient(r); got.IP != netip.MustParseAddr("198.51.100.7") {
+		t.Errorf("trusted socket peer = %v", got.IP)
+	}
+	unixListener = false
+	if got := resolveClient(r); got.IP.IsValid() {
+		t.Errorf("addressless peer trusted without a Unix listener: %v", got.IP)
+	}
+}
+
+// The client the proxy reports is the one the logs and built URLs use.
+func TestTrustedProxyRequests(t *testing.T) {
+	w, buf := logWrites(t, newTestWiki(t))
+	w.seed("Home", "home")
+	setGlobal(t, &trustedProxies, []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")})
+
+	req, _ := http.NewRequest(http.MethodGet, w.URL+"/view/Home", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "wiki.example.com")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	_, body := w.do(req)
+	if links := canonicalLinks(body); len(links) != 1 || links[0] != "https://wiki.example.com/view/Home" {
+		t.Errorf("canonical links = %v", links)
+	}
+
+	save := func() {
+		req, _ := http.NewRequest(http.MethodPost, w.URL+"/save/Home", strings.NewReader("title=Home&body=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-For", "192.0.2.1, 198.51.100.7")
+		w.do(req)
+	}
+	save()
+	trustedProxies = nil
+	save()
+	entries := readWriteLog(t, buf.Bytes())
+	if len(entries) != 2 || entries[0].IP != "198.51.100.7" || entries[1].IP != "127.0.0.1" {
+		t.Errorf("write log = %+v", entries)
+	}
+}
+
+func TestSetupTrustedProxies(t *testing.T) {
+	setGlobal(t, &trustedProxies, nil)
+	setGlobal(t, &trustUnixPeers, false)
+	t.Setenv("TRUST_PROXY", "")
+	t.Setenv("TRUSTED_PROXIES", "10.1.2.3/8, 192.0.2.1 ,UNIX, 2001:db8::/32")
+	if err := setupTrustedProxies(); err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	if !slices.Equal(trustedProxies, want) || !trustUnixPeers {
+		t.Errorf("trusted = %v, unix %v", trustedProxies, trustUnixPeers)
+	}
+
+	trustedProxies, trustUnixPeers = nil, false
+	t.Setenv("TRUSTED_PROXIES", "")
+	t.Setenv("TRUST_PROXY", "true")
+	if err := setupTrustedProxies(); err != nil || !trustedProxy(netip.MustParseAddr("203.0.113.5")) ||
+		!trustedProxy(netip.MustParseAddr("2001:db8::1")) || !trustUnixPeers {
+		t.Errorf("TRUST_PROXY=true does not trust every peer: %v", err)
+	}
+
+	for _, raw := range []string{"10.0.0.0/33", "proxy.example.com", "10.0.0.1-10.0.0.9"} {
+		t.Setenv("TRUST_PROXY", "")
+		t.Setenv("TRUSTED_PROXIES", raw)
+		if err := setupTrustedProxies(); err == nil {
+			t.Errorf("TRUSTED_PROXIES=%q accepted", raw)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+// qrForMissing controls QR codes for pages that do not exist: when set they
+// point at the edit page, otherwise the request gets a 404.
+var qrForMissing bool
+
+func setupQR() {
+	qrForMissing = os.Getenv("QR_MISSING_PAGES") == "create"
+}
+
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+	title, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/qr/"), ".png")
+	if !ok || !validTitle(title) {
+		http.NotFound(w, r)
+		return
+	}
+
+	size := defaultQRSize
+	if raw := r.FormValue("size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+		size = min(max(n, minQRSize), maxQRSize)
+	}
+
+	target := requestURL(r, "/view/"+title)
+	if _, err := loadPage(title); err != nil {
+		if !qrForMissing {
+			http.NotFound(w, r)
+			return
+		}
+		target = requestURL(r, "/edit/"+title)
+	}
+
+	sum := sha256.Sum256([]byte(target + "\x00" + strconv.Itoa(size)))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	png, err := qrcode.Encode(target, qrcode.Medium, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Write(png)
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// wantQR checks that the response is a size×size PNG of the QR code for
+// target.
+func wantQR(t *testing.T, resp *http.Response, body, target string, size int) {
+	t.Helper()
+	wantStatus(t, resp, body, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	img, err := png.Decode(bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+		t.Errorf("image is %v, want %dx%d", b, size, size)
+	}
+
+	q, err := qrcode.New(target, qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every pixel has to show the module it is scaled from.
+	modules := q.Bitmap()
+	scale := float64(len(modules)) / float64(size)
+	for y := range size {
+		for x := range size {
+			r, _, _, _ := img.At(x, y).RGBA()
+			if dark := r < 0x8000; dark != modules[int(float64(y)*scale)][int(float64(x)*scale)] {
+				t.Fatalf("pixel (%d, %d) differs from the code for %q", x, y, target)
+			}
+		}
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	w := newTestWiki(t)
+	setBaseURL(t, "https://wiki.example.com")
+	w.seed("Home", "home")
+
+	resp, body := w.get("/qr/Home.png")
+	wantQR(t, resp, body, "https://wiki.example.com/view/Home", defaultQRSize)
+	etag := resp.Header.Get("ETag")
+	if etag == "" || resp.Header.Get("Cache-Control") != "public, max-age=31536000" {
+		t.Errorf("cache headers: %v", resp.Header)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, w.URL+"/qr/Home.png", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, body = w.do(req)
+	wantStatus(t, resp, body, http.StatusNotModified)
+
+	for query, size := range map[string]int{"?size=300": 300, "?size=5000": maxQRSize, "?size=1": minQRSize} {
+		resp, body := w.get("/qr/Home.png" + query)
+		wantQR(t, resp, body, "https://wiki.example.com/view/Home", size)
+		if resp.Header.Get("ETag") == etag {
+			t.Errorf("%s shares the ETag of the default size", query)
+		}
+	}
+
+	for path, status := range map[string]int{
+		"/qr/Home.png?size=big": http.StatusBadRequest,
+		"/qr/Home":              http.StatusNotFound,
+		"/qr/..%2Fetc.png":      http.StatusNotFound,
+		"/qr/Missing.png":       http.StatusNotFound,
+	} {
+		if resp, body := w.get(path); resp.StatusCode != status {
+			t.Errorf("%s = %d, want %d\n%s", path, resp.StatusCode, status, body)
+		}
+	}
+
+	// Missing pages can get a code for creating them instead.
+	setGlobal(t, &qrForMissing, true)
+	resp, body = w.get("/qr/Missing.png")
+	wantQR(t, resp, body, "https://wiki.example.com/edit/Missing", defaultQRSize)
+}
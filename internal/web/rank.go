@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// recencyHalfLife is the page age at which the recency boost halves.
+const recencyHalfLife = 30 * 24 * time.Hour
+
+// rankWeights blend the three parts of a search score. Each part is scaled to
+// 0..1 over the results first, so the weights compare like for like.
+type rankWeights struct {
+	Relevance  float64
+	Recency    float64
+	Popularity float64
+}
+
+var searchWeights = rankWeights{Relevance: 1, Recency: 0.2, Popularity: 0.2}
+
+// scoreParts are the unweighted parts of a search score, shown to admins in
+// the API with debug=1.
+type scoreParts struct {
+	Relevance  float64 `json:"relevance"`
+	Recency    float64 `json:"recency"`
+	Popularity float64 `json:"popularity"`
+}
+
+func setupSearchRanking() error {
+	for _, w := range []struct {
+		key    string
+		weight *float64
+	}{
+		{"SEARCH_WEIGHT_RELEVANCE", &searchWeights.Relevance},
+		{"SEARCH_WEIGHT_RECENCY", &searchWeights.Recency},
+		{"SEARCH_WEIGHT_POPULARITY", &searchWeights.Popularity},
+	} {
+		raw := os.Getenv(w.key)
+		if raw == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) {
+			return fmt.Errorf("invalid %s %q, expected a number not below 0", w.key, raw)
+		}
+		*w.weight = f
+	}
+
+	return nil
+}
+
+// tfidf weighs a term used tf times in a page, when df of n pages use it.
+func tfidf(tf, df, n int) float64 {
+	if tf == 0 || df == 0 {
+		return 0
+	}
+	return (1 + math.Log(float64(tf))) * math.Log(1+float64(n)/float64(df))
+}
+
+// rankResults turns the raw TF-IDF of the results into their final score,
+// adding how recently each page changed and how often it is viewed.
+func rankResults(results []searchResult, now time.Time) {
+	if len(results) == 0 {
+		return
+	}
+
+	modified := map[string]time.Time{}
+	if infos, err := listPageInfos(); err != nil {
+		slog.Error("error reading page times for search ranking", "err", err)
+	} else {
+		for _, info := range infos {
+			modified[info.Title] = info.Modified
+		}
+	}
+
+	var maxRelevance, maxViews float64
+	viewCounts := make([]float64, len(results))
+	for i, res := range results {
+		maxRelevance = max(maxRelevance, res.parts.Relevance)
+		viewCounts[i] = math.Log1p(float64(max(views.count(res.Title), 0)))
+		maxViews = max(maxViews, viewCounts[i])
+	}
+
+	for i := range results {
+		parts := &results[i].parts
+		if maxRelevance > 0 {
+			parts.Relevance /= maxRelevance
+		}
+		if t, ok := modified[results[i].Title]; ok {
+			age := max(now.Sub(t), 0)
+			parts.Recency = math.Exp2(-float64(age) / float64(recencyHalfLife))
+		}
+		if maxViews > 0 {
+			parts.Popularity = viewCounts[i] / maxViews
+		}
+
+		results[i].score = searchWeights.Relevance*parts.Relevance +
+			searchWeights.Recency*parts.Recency +
+			searchWeights.Popularity*parts.Popularity
+	}
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTfidf(t *testing.T) {
+	if tfidf(0, 3, 10) != 0 || tfidf(2, 0, 10) != 0 {
+		t.Error("no occurrences must score 0")
+	}
+	if !(tfidf(1, 1, 10) < tfidf(4, 1, 10)) {
+		t.Error("more uses of a term must score higher")
+	}
+	if !(tfidf(1, 9, 10) < tfidf(1, 1, 10)) {
+		t.Error("a term in fewer pages must score higher")
+	}
+}
+
+type rankedResult struct {
+	Title  string
+	Score  float64
+	Scores *scoreParts
+}
+
+func (w *testWiki) ranked(query string, cookies ...*http.Cookie) []rankedResult {
+	w.t.Helper()
+	resp, body := w.get("/api/search?"+query, cookies...)
+	if resp.StatusCode != http.StatusOK {
+		w.t.Fatalf("search %s = %d\n%s", query, resp.StatusCode, body)
+	}
+	var res struct{ Results []rankedResult }
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		w.t.Fatal(err)
+	}
+	return res.Results
+}
+
+func rankedTitles(results []rankedResult) []string {
+	var titles []string
+	for _, r := range results {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+var searchResultLink = regexp.MustCompile(`<div>\s*<a href="/view/([^"]+)">`)
+
+// A recently edited, often viewed page outranks a stale one that matches
+// just as well.
+func TestSearchRanking(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Alpha-Stale", "how to deploy the service")
+	w.seed("Zulu-Fresh", "how to deploy the service")
+	w.seed("Unrelated", "nothing to see")
+	touchPage(t, "Alpha-Stale", time.Now().Add(-365*24*time.Hour))
+	resetState(t)
+	for range 10 {
+		views.add("Zulu-Fresh")
+	}
+
+	want := []string{"Zulu-Fresh", "Alpha-Stale"}
+	results := w.ranked("q=deploy")
+	if got := rankedTitles(results); !slices.Equal(got, want) {
+		t.Fatalf("ranking = %v, want %v", got, want)
+	}
+	if !(results[0].Score > results[1].Score) || results[0].Scores != nil {
+		t.Errorf("results = %+v", results)
+	}
+
+	// The search page lists the results in the same order.
+	_, body := w.get("/search?q=deploy")
+	var html []string
+	for _, m := range searchResultLink.FindAllStringSubmatch(body, -1) {
+		html = append(html, m[1])
+	}
+	if !slices.Equal(html, want) {
+		t.Errorf("search page order = %v, want %v", html, want)
+	}
+
+	// Admins can see the parts of each score.
+	admin := w.login("root", roleAdmin)
+	results = w.ranked("q=deploy&debug=1", admin)
+	fresh, stale := results[0].Scores, results[1].Scores
+	if fresh == nil || stale == nil {
+		t.Fatalf("debug results have no scores: %+v", results)
+	}
+	if fresh.Relevance != stale.Relevance || fresh.Relevance != 1 {
+		t.Errorf("equal matches have relevance %v and %v", fresh.Relevance, stale.Relevance)
+	}
+	if !(fresh.Recency > 0.99 && stale.Recency < 0.01) || fresh.Popularity != 1 || stale.Popularity != 0 {
+		t.Errorf("fresh %+v, stale %+v", fresh, stale)
+	}
+	if resp, body := w.get("/api/search?q=deploy&debug=1", w.login("alice", roleEditor)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("debug scores shown to an editor: %d\n%s", resp.StatusCode, body)
+	}
+
+	// With only relevance counting the tie falls back to title order.
+	setGlobal(t, &searchWeights, rankWeights{Relevance: 1})
+	if got := rankedTitles(w.ranked("q=deploy")); !slices.Equal(got, []string{"Alpha-Stale", "Zulu-Fresh"}) {
+		t.Errorf("ranking by relevance only = %v", got)
+	}
+	// Each boost alone is enough to break it.
+	for _, weights := range []rankWeights{{Relevance: 1, Recency: 1}, {Relevance: 1, Popularity: 1}} {
+		searchWeights = weights
+		if got := rankedTitles(w.ranked("q=deploy")); !slices.Equal(got, want) {
+			t.Errorf("ranking with %+v = %v", weights, got)
+		}
+	}
+}
+
+func TestSetupSearchRanking(t *testing.T) {
+	setGlobal(t, &searchWeights, searchWeights)
+	t.Setenv("SEARCH_WEIGHT_RECENCY", "0.5")
+	t.Setenv("SEARCH_WEIGHT_POPULARITY", "0")
+	if err := setupSearchRanking(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (rankWeights{Relevance: 1, Recency: 0.5}); searchWeights != want {
+		t.Errorf("weights = %+v, want %+v", searchWeights, want)
+	}
+
+	for _, raw := range []string{"-1", "heavy", "Inf"} {
+		t.Setenv("SEARCH_WEIGHT_RELEVANCE", raw)
+		if err := setupSearchRanking(); err == nil {
+			t.Errorf("SEARCH_WEIGHT_RELEVANCE=%q accepted", raw)
+		}
+	}
+}
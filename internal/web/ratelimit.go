@@ -0,0 +1,188 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTrackedIPs bounds the memory used by the write limiter. When it is
+// reached, clients without recent writes are forgotten first.
+const maxTrackedIPs = 10000
+
+type writeHistory struct {
+	hits       []time.Time
+	violations []time.Time
+}
+
+type writeLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	banAfter    int
+	banDuration time.Duration
+	clients     map[netip.Addr]*writeHistory
+}
+
+var writes *writeLimiter
+
+func setupWriteLimit() error {
+	raw := os.Getenv("WRITE_RATE_LIMIT")
+	if raw == "" {
+		return nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return fmt.Errorf("invalid WRITE_RATE_LIMIT %q", raw)
+	}
+
+	window, err := time.ParseDuration(envOrDefault("WRITE_RATE_WINDOW", "5m"))
+	if err != nil || window <= 0 {
+		return fmt.Errorf("invalid WRITE_RATE_WINDOW %q", os.Getenv("WRITE_RATE_WINDOW"))
+	}
+
+	banAfter, err := strconv.Atoi(envOrDefault("WRITE_BAN_AFTER", "3"))
+	if err != nil || banAfter < 0 {
+		return fmt.Errorf("invalid WRITE_BAN_AFTER %q", os.Getenv("WRITE_BAN_AFTER"))
+	}
+
+	banDuration, err := time.ParseDuration(envOrDefault("WRITE_BAN_DURATION", "1h"))
+	if err != nil || banDuration <= 0 {
+		return fmt.Errorf("invalid WRITE_BAN_DURATION %q", os.Getenv("WRITE_BAN_DURATION"))
+	}
+
+	writes = &writeLimiter{
+		limit:       limit,
+		window:      window,
+		banAfter:    banAfter,
+		banDuration: banDuration,
+		clients:     make(map[netip.Addr]*writeHistory),
+	}
+
+	return nil
+}
+
+func trimBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+
+	return times[i:]
+}
+
+// allow records a write attempt at now and reports whether it fits in the
+// sliding window. When it does not, retry says how long until it would.
+// Repeated violations within the window make ban true.
+func (l *writeLimiter) allow(addr netip.Addr, now time.Time) (ok bool, retry time.Duration, ban bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+
+	h, found := l.clients[addr]
+	if !found {
+		if len(l.clients) >= maxTrackedIPs {
+			l.prune(cutoff)
+		}
+		h = &writeHistory{}
+		l.clients[addr] = h
+	}
+
+	h.hits = trimBefore(h.hits, cutoff)
+	h.violations = trimBefore(h.violations, cutoff)
+
+	if len(h.hits) < l.limit {
+		h.hits = append(h.hits, now)
+		return true, 0, false
+	}
+
+	h.violations = append(h.violations, now)
+	retry = h.hits[0].Add(l.window).Sub(now)
+
+	if l.banAfter > 0 && len(h.violations) >= l.banAfter {
+		delete(l.clients, addr)
+		return false, retry, true
+	}
+
+	return false, retry, false
+}
+
+func (l *writeLimiter) prune(cutoff time.Time) {
+	for addr, h := range l.clients {
+		if len(h.hits) == 0 || !h.hits[len(h.hits)-1].After(cutoff) {
+			delete(l.clients, addr)
+		}
+	}
+
+	for addr := range l.clients {
+		if len(l.clients) < maxTrackedIPs {
+			break
+		}
+		delete(l.clients, addr)
+	}
+}
+
+func (l *writeLimiter) tracked() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.clients)
+}
+
+// rateLimitError is returned while a client is over its write limit.
+type rateLimitError struct {
+	retry time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return "You are saving too often. Please wait a few minutes and try again."
+}
+
+// admitWrite applies the IP blocklist and the write rate limit to a request
+// that changes a page. Clients that keep hitting the limit are blocked for a
+// while.
+func admitWrite(r *http.Request) error {
+	if ipBlocked(r) {
+		return errIPBlocked
+	}
+
+	addr := clientIP(r)
+	if writes == nil || !addr.IsValid() {
+		return nil
+	}
+
+	now := time.Now()
+	ok, retry, ban := writes.allow(addr, now)
+	if ok {
+		return nil
+	}
+	if !ban {
+		return &rateLimitError{retry}
+	}
+
+	b := ipBlock{
+		Prefix:  netip.PrefixFrom(addr, addr.BitLen()),
+		Reason:  "automatic: repeated write rate limit violations",
+		Expires: now.Add(writes.banDuration).UTC(),
+	}
+	if err := ipBlocks.add(b); err != nil {
+		return err
+	}
+
+	return errIPBlocked
+}
+
+// setRetryAfter tells a rate limited client when to come back.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var limited *rateLimitError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(limited.retry.Seconds())+1))
+	}
+}
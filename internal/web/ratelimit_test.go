@@ -0,0 +1,203 @@
+package web
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(limit int, window time.Duration, banAfter int) *writeLimiter {
+	return &writeLimiter{
+		limit:       limit,
+		window:      window,
+		banAfter:    banAfter,
+		banDuration: time.Hour,
+		clients:     map[netip.Addr]*writeHistory{},
+	}
+}
+
+var testAddr = netip.MustParseAddr("192.0.2.1")
+
+func TestWriteLimiterSlidingWindow(t *testing.T) {
+	l := newTestLimiter(3, time.Minute, 0)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A burst straddling the window boundary: a write drops out of the
+	// window exactly one window after it was made.
+	steps := []struct {
+		at    time.Duration
+		ok    bool
+		retry time.Duration
+	}{
+		{0, true, 0},
+		{50 * time.Second, true, 0},
+		{59 * time.Second, true, 0},
+		{59*time.Second + 500*time.Millisecond, false, 500 * time.Millisecond},
+		{time.Minute, true, 0},
+		{time.Minute + 2*time.Millisecond, false, 50*time.Second - 2*time.Millisecond},
+		{110*time.Second - time.Millisecond, false, time.Millisecond},
+		{110 * time.Second, true, 0},
+		{110*time.Second + time.Millisecond, false, 9*time.Second - time.Millisecond},
+	}
+
+	for _, s := range steps {
+		ok, retry, ban := l.allow(testAddr, start.Add(s.at))
+		if ok != s.ok || ban || retry != s.retry {
+			t.Errorf("write at %v: ok %v, retry %v, ban %v, want ok %v, retry %v", s.at, ok, retry, ban, s.ok, s.retry)
+		}
+	}
+}
+
+func TestWriteLimiterClientsAreSeparate(t *testing.T) {
+	l := newTestLimiter(1, time.Minute, 0)
+	now := time.Now()
+
+	if ok, _, _ := l.allow(testAddr, now); !ok {
+		t.Fatal("first write refused")
+	}
+	if ok, _, _ := l.allow(netip.MustParseAddr("2001:db8::1"), now); !ok {
+		t.Error("a second client shares the first one's limit")
+	}
+	if ok, _, _ := l.allow(testAddr, now); ok {
+		t.Error("second write of the first client allowed")
+	}
+}
+
+func TestWriteLimiterBan(t *testing.T) {
+	l := newTestLimiter(1, time.Minute, 3)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l.allow(testAddr, now)
+	for i := 1; i <= 2; i++ {
+		if ok, _, ban := l.allow(testAddr, now.Add(time.Duration(i)*time.Second)); ok || ban {
+			t.Fatalf("violation %d: ok %v, ban %v, want refused without a ban", i, ok, ban)
+		}
+	}
+	if _, _, ban := l.allow(testAddr, now.Add(3*time.Second)); !ban {
+		t.Fatal("third violation did not ban")
+	}
+	if l.tracked() != 0 {
+		t.Error("a banned client is still tracked")
+	}
+
+	// Violations spread wider than the window never add up to a ban.
+	l = newTestLimiter(1, time.Minute, 2)
+	for i := range 5 {
+		at := now.Add(time.Duration(i) * 61 * time.Second)
+		if ok, _, _ := l.allow(testAddr, at); !ok {
+			t.Fatalf("round %d: write refused after the window passed", i)
+		}
+		if _, _, ban := l.allow(testAddr, at.Add(time.Second)); ban {
+			t.Fatalf("round %d: banned for violations more than a window apart", i)
+		}
+	}
+}
+
+func TestWriteLimiterBoundsMemory(t *testing.T) {
+	l := newTestLimiter(1, time.Minute, 0)
+	now := time.Now()
+
+	for i := range maxTrackedIPs + 100 {
+		addr := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+		l.allow(addr, now.Add(time.Duration(i)*time.Millisecond))
+		if n := l.tracked(); n > maxTrackedIPs {
+			t.Fatalf("tracking %d clients, limit is %d", n, maxTrackedIPs)
+		}
+	}
+
+	// Once the old writes leave the window, new clients push them out.
+	l.allow(netip.MustParseAddr("192.0.2.200"), now.Add(time.Hour))
+	if n := l.tracked(); n != 1 {
+		t.Errorf("tracking %d clients after the window passed, want only the new one", n)
+	}
+}
+
+func TestWriteLimiterConcurrent(t *testing.T) {
+	l := newTestLimiter(50, time.Minute, 0)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _, _ := l.allow(testAddr, now); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 50 {
+		t.Errorf("%d concurrent writes allowed, want exactly the limit of 50", allowed)
+	}
+}
+
+func TestUndoRateLimited(t *testing.T) {
+	w := newTestWiki(t)
+	writes = newTestLimiter(1, time.Minute, 5)
+	w.seed("Home", "first")
+	w.seed("Home", "second")
+
+	resp, body := w.post("/undo/Home", w.csrf())
+	wantStatus(t, resp, body, http.StatusFound)
+
+	w.seed("Home", "third")
+	resp, body = w.post("/undo/Home", w.csrf())
+	wantStatus(t, resp, body, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("rate limited undo has no Retry-After")
+	}
+	if b, _ := store.Read("Home"); string(b) != "third" {
+		t.Errorf("page after a rate limited undo = %q", b)
+	}
+}
+
+func TestSaveRateLimited(t *testing.T) {
+	w := newTestWiki(t)
+	writes = newTestLimiter(2, time.Minute, 2)
+
+	save := func(i int) (*http.Response, string) {
+		return w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"edit " + strconv.Itoa(i)}})
+	}
+	for i := range 2 {
+		resp, body := save(i)
+		wantStatus(t, resp, body, http.StatusFound)
+	}
+
+	resp, body := save(2)
+	wantStatus(t, resp, body, http.StatusTooManyRequests)
+	if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry < 1 || retry > 61 {
+		t.Errorf("Retry-After = %q", resp.Header.Get("Retry-After"))
+	}
+
+	// The API takes the same path.
+	resp, body = w.api(http.MethodPut, "/api/pages/Home", `{"body":"api edit"}`)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	if !ipBlocked(httptestRequestFrom("127.0.0.1")) {
+		t.Error("repeated violations did not block the address")
+	}
+	resp, body = w.get("/admin/ipblocks", w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "127.0.0.1/32") || !strings.Contains(body, "automatic") {
+		t.Errorf("the automatic block is not on the admin page:\n%s", body)
+	}
+
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+}
+
+func httptestRequestFrom(ip string) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "/save/Home", nil)
+	r.RemoteAddr = ip + ":1234"
+	return r
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+const readOnlyMessage = "The wiki is in read-only mode for maintenance. Please try again later."
+
+// readOnly rejects every change while backups or migrations run. It starts
+// from READ_ONLY and is flipped at runtime by admins or by SIGUSR1.
+var readOnly atomic.Bool
+
+// readOnlyPosts take a POST but change nothing on disk, so they keep working
+// in read-only mode and are left out of the write log.
+var readOnlyPosts = []string{"/login", "/logout", "/preview", "/validate"}
+
+func setupReadOnly() {
+	readOnly.Store(os.Getenv("READ_ONLY") == "true")
+}
+
+func setReadOnly(on bool, by string) {
+	if readOnly.Swap(on) == on {
+		return
+	}
+
+	state := "off"
+	if on {
+		state = "on"
+	}
+	log.Println("Read-only mode " + state + " (" + by + ")")
+}
+
+// watchReadOnlySignal toggles read-only mode on SIGUSR1.
+func watchReadOnlySignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+
+	for range c {
+		setReadOnly(!readOnly.Load(), "SIGUSR1")
+	}
+}
+
+func rejectReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "300")
+	if strings.HasPrefix(r.URL.Path, apiPathPrefix) {
+		writeAPIError(w, http.StatusServiceUnavailable, readOnlyMessage)
+		return
+	}
+	renderError(w, r, http.StatusServiceUnavailable, readOnlyMessage)
+}
+
+// withReadOnly turns away requests that would write while read-only mode is
+// on. Reads keep working; handlers that change pages on GET are covered by
+// requireEdit.
+func withReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if readOnly.Load() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// The switch itself stays open so admins can leave the mode.
+			if !slices.Contains(readOnlyPosts, r.URL.Path) && r.URL.Path != "/admin/readonly" {
+				rejectReadOnly(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func readOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only admins can switch read-only mode.")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	on := r.FormValue("mode") == "on"
+	setReadOnly(on, "by "+s.User)
+	if on {
+		addFlash(w, r, flash{Level: "success", Text: "Read-only mode is on"})
+	} else {
+		addFlash(w, r, flash{Level: "success", Text: "Read-only mode is off"})
+	}
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
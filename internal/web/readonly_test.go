@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func (w *testWiki) setReadOnly(mode string, cookies ...*http.Cookie) *http.Response {
+	w.t.Helper()
+	resp, _ := w.post("/admin/readonly", url.Values{"mode": {mode}}, cookies...)
+	return resp
+}
+
+func TestReadOnlyToggle(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	admin := w.login("root", roleAdmin)
+	editor := w.login("alice", roleEditor)
+	save := url.Values{"title": {"Home"}, "body": {"changed"}}
+
+	resp := w.setReadOnly("on", admin)
+	if resp.StatusCode != http.StatusFound || !readOnly.Load() {
+		t.Fatalf("turning read-only on = %d, readOnly %v", resp.StatusCode, readOnly.Load())
+	}
+
+	resp, body := w.post("/save/Home", save, editor)
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if !strings.Contains(body, readOnlyMessage) || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("rejected save does not explain itself:\n%s", body)
+	}
+	resp, body = w.api(http.MethodPut, "/api/pages/Home", `{"body":"changed"}`, editor)
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if !strings.Contains(body, `"error"`) {
+		t.Errorf("API rejection is not JSON: %s", body)
+	}
+	for _, path := range []string{"/edit/Home", "/delete/Home", "/undo/Home"} {
+		if resp, body := w.get(path, editor); resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("GET %s = %d in read-only mode\n%s", path, resp.StatusCode, body)
+		}
+	}
+	if _, raw := w.get("/raw/Home"); raw != "home" {
+		t.Errorf("page changed in read-only mode: %q", raw)
+	}
+
+	// Reads, and what is needed to get out again, keep working.
+	for _, path := range []string{"/", "/view/Home", "/raw/Home", "/history/Home", "/search?q=home", "/api/pages/Home"} {
+		resp, body := w.get(path, editor)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s = %d in read-only mode\n%s", path, resp.StatusCode, body)
+		}
+		if path == "/" && !strings.Contains(body, "read-only") {
+			t.Errorf("index does not show read-only mode:\n%s", body)
+		}
+	}
+	resp, body = w.post("/preview", url.Values{"body": {"*preview*"}}, editor)
+	wantStatus(t, resp, body, http.StatusOK)
+
+	if resp := w.setReadOnly("off", editor); resp.StatusCode != http.StatusForbidden || !readOnly.Load() {
+		t.Errorf("editor switched read-only off: %d", resp.StatusCode)
+	}
+	if resp := w.setReadOnly("off", admin); resp.StatusCode != http.StatusFound || readOnly.Load() {
+		t.Fatalf("turning read-only off = %d, readOnly %v", resp.StatusCode, readOnly.Load())
+	}
+	resp, body = w.post("/save/Home", save, editor)
+	wantStatus(t, resp, body, http.StatusFound)
+	if _, raw := w.get("/raw/Home"); raw != "changed" {
+		t.Errorf("save after read-only mode = %q", raw)
+	}
+}
+
+func TestReadOnlySignal(t *testing.T) {
+	newTestWiki(t)
+
+	// Keep SIGUSR1 from killing the test until the watcher has it.
+	mine := make(chan os.Signal, 1)
+	signal.Notify(mine, syscall.SIGUSR1)
+	defer signal.Stop(mine)
+	go watchReadOnlySignal()
+
+	for _, want := range []bool{true, false} {
+		for deadline := time.Now().Add(2 * time.Second); readOnly.Load() != want; {
+			if time.Now().After(deadline) {
+				t.Fatalf("SIGUSR1 did not switch read-only mode to %v", want)
+			}
+			syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
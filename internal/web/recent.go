@@ -0,0 +1,65 @@
+package web
+
+import (
+	"sort"
+	"time"
+)
+
+type recentChange struct {
+	Title    string    `json:"title"`
+	Modified time.Time `json:"modified"`
+	Editor   string    `json:"editor,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+	Minor    bool      `json:"minor,omitempty"`
+}
+
+// listedChange reports whether rev shows up in a change listing: with
+// hideMinor, minor edits are left out. Every change listing filters through
+// here so the filtering stays the same everywhere.
+func listedChange(rev revision, hideMinor bool) bool {
+	return !hideMinor || !rev.Minor
+}
+
+// recentChanges lists pages by their latest change, newest first. With
+// hideMinor a page is listed by its latest non-minor revision, and pages that
+// only have minor edits are left out.
+func recentChanges(limit int, hideMinor bool) ([]recentChange, error) {
+	infos, err := listPageInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]recentChange, 0, len(infos))
+	for _, info := range infos {
+		revs, err := listRevisions(info.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(revs) == 0 {
+			changes = append(changes, recentChange{Title: info.Title, Modified: info.Modified})
+			continue
+		}
+
+		for _, rev := range revs {
+			if !listedChange(rev, hideMinor) {
+				continue
+			}
+
+			changes = append(changes, recentChange{
+				Title:    info.Title,
+				Modified: rev.Time,
+				Editor:   rev.Editor,
+				Summary:  rev.Summary,
+				Minor:    rev.Minor,
+			})
+			break
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Modified.After(changes[j].Modified)
+	})
+
+	return changes[:min(limit, len(changes))], nil
+}
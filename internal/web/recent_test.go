@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func (w *testWiki) recent(query string) []recentChange {
+	w.t.Helper()
+	resp, body := w.get("/api/recent"+query, w.login("reader", roleReader))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		w.t.Fatalf("/api/recent%s = %d %s\n%s", query, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+	var res struct {
+		Changes []recentChange `json:"changes"`
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		w.t.Fatal(err)
+	}
+	return res.Changes
+}
+
+func titlesOf(changes []recentChange) []string {
+	var titles []string
+	for _, c := range changes {
+		titles = append(titles, c.Title)
+	}
+	return titles
+}
+
+func TestAPIRecent(t *testing.T) {
+	w := newTestWiki(t)
+	minor := at("Beta", 6*time.Minute, "bob")
+	minor.Minor = true
+	seedHistory(t, []changelogEntry{
+		at("Alpha", 0, "alice"),
+		at("Alpha", 5*time.Minute, "alice"),
+		at("Beta", time.Minute, "bob"),
+		minor,
+		at("Gamma", 2*time.Minute, "carol"),
+		at("Delta", 3*time.Minute, "dave"),
+	})
+
+	changes := w.recent("")
+	if want := []string{"Beta", "Alpha", "Delta", "Gamma"}; !slices.Equal(titlesOf(changes), want) {
+		t.Errorf("recent = %q, want newest first %q", titlesOf(changes), want)
+	}
+	if c := changes[0]; !c.Modified.Equal(changelogStart.Add(6*time.Minute)) || c.Editor != "bob" || !c.Minor || c.Summary != minor.Summary {
+		t.Errorf("latest change = %+v", c)
+	}
+
+	if got := titlesOf(w.recent("?limit=2")); !slices.Equal(got, []string{"Beta", "Alpha"}) {
+		t.Errorf("limit=2 = %q", got)
+	}
+	if got := w.recent("?limit=100"); len(got) != 4 {
+		t.Errorf("limit above the page count = %d changes", len(got))
+	}
+
+	// Without minor edits Beta falls back to its earlier revision.
+	if got := titlesOf(w.recent("?hideminor=1")); !slices.Equal(got, []string{"Alpha", "Delta", "Gamma", "Beta"}) {
+		t.Errorf("hideminor = %q", got)
+	}
+
+	for _, limit := range []string{"0", "-1", "ten"} {
+		resp, body := w.get("/api/recent?limit="+limit, w.login("reader", roleReader))
+		wantStatus(t, resp, body, http.StatusBadRequest)
+	}
+	resp, body := w.api(http.MethodPost, "/api/recent", "", w.login("reader", roleReader))
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func TestAPIRecentLimitCap(t *testing.T) {
+	w := newTestWiki(t)
+	var edits []changelogEntry
+	for i := range maxRecentLimit + 5 {
+		edits = append(edits, at("Page-"+string(rune('a'+i%26))+string(rune('a'+i/26)), time.Duration(i)*time.Second, ""))
+	}
+	seedHistory(t, edits)
+
+	if got := w.recent(""); len(got) != defaultRecentLimit {
+		t.Errorf("default limit = %d changes, want %d", len(got), defaultRecentLimit)
+	}
+	if got := w.recent("?limit=100000"); len(got) != maxRecentLimit {
+		t.Errorf("huge limit = %d changes, want the cap %d", len(got), maxRecentLimit)
+	}
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const defaultRelatedPages = 5
+
+// maxRelatedPages caps the "Related pages" list. Zero hides it.
+var maxRelatedPages = defaultRelatedPages
+
+// relatedCache keeps the computed related pages per page. Entries are
+// dropped whenever a save or delete could change them.
+type relatedCache struct {
+	mu      sync.Mutex
+	entries map[string][]string
+}
+
+var relatedPages = &relatedCache{entries: map[string][]string{}}
+
+func setupRelatedPages() error {
+	raw := os.Getenv("RELATED_PAGES")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid RELATED_PAGES %q", raw)
+	}
+	maxRelatedPages = n
+
+	return nil
+}
+
+func (c *relatedCache) get(title string) []string {
+	if maxRelatedPages == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	related, ok := c.entries[title]
+	c.mu.Unlock()
+	if ok {
+		return related
+	}
+
+	related = computeRelated(title)
+
+	c.mu.Lock()
+	c.entries[title] = related
+	c.mu.Unlock()
+
+	return related
+}
+
+func (c *relatedCache) reset() {
+	c.mu.Lock()
+	c.entries = map[string][]string{}
+	c.mu.Unlock()
+}
+
+// invalidate drops the entry of title and of every page whose list could
+// mention it: pages listing it already, and pages it now shares tags or
+// links with. It runs after the link and tag indexes were updated.
+func (c *relatedCache) invalidate(title string) {
+	affected := []string{title}
+	affected = append(affected, pageLinks.outlinks(title)...)
+	affected = append(affected, pageLinks.backlinks(title)...)
+	for other := range pageTags.sharing(title) {
+		affected = append(affected, other)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range affected {
+		delete(c.entries, t)
+	}
+	for t, related := range c.entries {
+		if slices.Contains(related, title) {
+			delete(c.entries, t)
+		}
+	}
+}
+
+// computeRelated ranks pages by shared tags first, then by links in either
+// direction. Only pages that still exist are listed.
+func computeRelated(title string) []string {
+	score := pageTags.sharing(title)
+	for other := range score {
+		score[other] *= 2
+	}
+	for _, other := range pageLinks.outlinks(title) {
+		score[other]++
+	}
+	for _, other := range pageLinks.backlinks(title) {
+		score[other]++
+	}
+	delete(score, title)
+
+	related := make([]string, 0, len(score))
+	for other := range score {
+		if _, err := statPage(other); err == nil {
+			related = append(related, other)
+		}
+	}
+	slices.SortFunc(related, func(a, b string) int {
+		if c := cmp.Compare(score[b], score[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	return related[:min(len(related), maxRelatedPages)]
+}
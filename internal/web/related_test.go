@@ -0,0 +1,90 @@
+package web
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func seedRelated(w *testWiki) {
+	w.seed("Go", "---\ntags: go, web\n---\n[notes](/view/Notes) [ghost](/view/Ghost)")
+	w.seed("Web", "---\ntags: [Web, go]\n---\nweb")
+	w.seed("Lang", "---\ntags: go\n---\nlanguages")
+	w.seed("Notes", "notes")
+	w.seed("Fan", "a fan of [Go](/view/Go)")
+	w.seed("Lonely", "nobody links here")
+	rebuildIndexes()
+}
+
+func TestRelatedPages(t *testing.T) {
+	w := newTestWiki(t)
+	seedRelated(w)
+
+	// Two shared tags beat one, a tag beats a link, and links in either
+	// direction tie and fall back to the name. Missing pages are left out.
+	if got, want := relatedPages.get("Go"), []string{"Web", "Lang", "Fan", "Notes"}; !slices.Equal(got, want) {
+		t.Errorf("related to Go = %q, want %q", got, want)
+	}
+	if got := relatedPages.get("Lonely"); len(got) != 0 {
+		t.Errorf("related to Lonely = %q", got)
+	}
+
+	if _, body := w.get("/view/Go"); !strings.Contains(body, "Related pages") || !strings.Contains(body, `href="/view/Lang"`) {
+		t.Errorf("view lacks the related pages:\n%s", body)
+	}
+	if _, body := w.get("/view/Lonely"); strings.Contains(body, "Related pages") {
+		t.Errorf("view of a page without signals lists related pages:\n%s", body)
+	}
+
+	setGlobal(t, &maxRelatedPages, 2)
+	relatedPages.reset()
+	if got := relatedPages.get("Go"); !slices.Equal(got, []string{"Web", "Lang"}) {
+		t.Errorf("related to Go capped at 2 = %q", got)
+	}
+	maxRelatedPages = 0
+	if got := relatedPages.get("Go"); got != nil {
+		t.Errorf("related to Go with the list hidden = %q", got)
+	}
+}
+
+func TestRelatedPagesInvalidation(t *testing.T) {
+	w := newTestWiki(t)
+	seedRelated(w)
+	relatedPages.get("Go")
+	relatedPages.get("Lonely")
+
+	// The indexer drops every cached list a save can change.
+	w.seed("Lonely", "---\ntags: go, web\n---\nnow tagged")
+	searchIndexer.apply(map[string]bool{"Lonely": true}, false)
+	if got := relatedPages.get("Go"); !slices.Contains(got, "Lonely") {
+		t.Errorf("related to Go after tagging Lonely = %q", got)
+	}
+	if got, want := relatedPages.get("Lonely"), []string{"Go", "Web", "Lang"}; !slices.Equal(got, want) {
+		t.Errorf("related to Lonely after tagging it = %q, want %q", got, want)
+	}
+
+	if err := deletePage(&page.Page{Title: "Web"}); err != nil {
+		t.Fatal(err)
+	}
+	searchIndexer.apply(map[string]bool{"Web": true}, false)
+	if got := relatedPages.get("Go"); slices.Contains(got, "Web") {
+		t.Errorf("related to Go still lists the deleted page: %q", got)
+	}
+}
+
+func TestSetupRelatedPages(t *testing.T) {
+	setGlobal(t, &maxRelatedPages, defaultRelatedPages)
+
+	t.Setenv("RELATED_PAGES", "0")
+	if err := setupRelatedPages(); err != nil || maxRelatedPages != 0 {
+		t.Errorf("RELATED_PAGES=0 gives %d, %v", maxRelatedPages, err)
+	}
+	for _, raw := range []string{"-1", "five"} {
+		t.Setenv("RELATED_PAGES", raw)
+		if err := setupRelatedPages(); err == nil {
+			t.Errorf("RELATED_PAGES=%q accepted", raw)
+		}
+	}
+}
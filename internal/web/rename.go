@@ -0,0 +1,272 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+type referenceChange struct {
+	Line   int
+	Before string
+	After  string
+}
+
+type referenceUpdate struct {
+	Title   string
+	Changes []referenceChange
+	Error   string
+}
+
+type renameData struct {
+	Title      string
+	NewTitle   string
+	UpdateRefs bool
+	DryRun     bool
+	Renamed    bool
+	Updates    []referenceUpdate
+	Updated    int
+	Failed     int
+}
+
+// referencePatterns match the ways a page can point at title: inline
+// Markdown links, reference definitions and [[Title]] or [[Title|label]].
+// Every pattern has three groups, the first is kept before the new title and
+// the other two after it.
+func referencePatterns(title string) []*regexp.Regexp {
+	t := regexp.QuoteMeta(title)
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(\]\(\s*(?:/view/)?)` + t + `([#?][^)\s]*)?(\s*(?:\s"[^"]*")?\s*\))`),
+		regexp.MustCompile(`(?m)(^\s{0,3}\[[^\]]+\]:\s*(?:/view/)?)` + t + `([#?]\S*)?(\s|$)`),
+		regexp.MustCompile(`(\[\[\s*)` + t + `(\s*)(\||\]\])`),
+	}
+}
+
+// rewriteReferences points every reference to from at to instead and lists
+// the lines that changed. Fenced code blocks are left alone.
+func rewriteReferences(body []byte, from, to string) ([]byte, []referenceChange) {
+	patterns := referencePatterns(from)
+	lines := bytes.Split(body, []byte("\n"))
+
+	var changes []referenceChange
+	inFence := false
+	for i, line := range lines {
+		if trimmed := bytes.TrimSpace(line); bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		updated := line
+		for _, p := range patterns {
+			updated = p.ReplaceAll(updated, []byte("${1}"+to+"${2}${3}"))
+		}
+		if !bytes.Equal(updated, line) {
+			changes = append(changes, referenceChange{Line: i + 1, Before: string(line), After: string(updated)})
+			lines[i] = updated
+		}
+	}
+
+	return bytes.Join(lines, []byte("\n")), changes
+}
+
+// referringPages lists pages that may refer to title: the backlink index
+// covers Markdown links, [[Title]] references are not indexed and are found
+// by reading the pages.
+func referringPages(title string) ([]string, error) {
+	sources := pageLinks.backlinks(title)
+
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range titles {
+		if t == title || slices.Contains(sources, t) {
+			continue
+		}
+		body, err := store.Read(t)
+		if err == nil && bytes.Contains(body, []byte("[["+title)) {
+			sources = append(sources, t)
+		}
+	}
+	slices.Sort(sources)
+
+	return sources, nil
+}
+
+// renamePage moves a page with its history and metadata to a new title and
+// records the move as a revision of the new page.
+func renamePage(from, display, editor string) (*page.Page, error) {
+	display = strings.Join(strings.Fields(display), " ")
+	to := slugTitle(display)
+	if err := validateTitle(to); err != nil {
+		return nil, &formError{http.StatusBadRequest, err.Error()}
+	}
+	if to == from {
+		return nil, &formError{http.StatusBadRequest, "The new title is the same as the old one"}
+	}
+	if _, err := statPage(to); err == nil {
+		return nil, &formError{http.StatusConflict, "Page " + to + " already exists"}
+	}
+
+	p, err := loadPage(from)
+	if err != nil {
+		return nil, err
+	}
+
+	// The history has to move before the save, which records the rename as
+	// its newest revision. If the save fails, everything goes back.
+	historyMu.Lock()
+	err = os.Rename(historyDir(from), historyDir(to))
+	historyMu.Unlock()
+	movedHistory := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	err = os.Rename(metaFilename(from), metaFilename(to))
+	movedMeta := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		undoRename(from, to, movedHistory, false)
+		return nil, err
+	}
+
+	moved := &page.Page{Title: to, Body: p.Body, Editor: editor, Summary: "Renamed from " + from}
+	if err := savePage(moved); err != nil {
+		undoRename(from, to, movedHistory, movedMeta)
+		return nil, err
+	}
+	if err := setDisplayTitle(to, display); err != nil {
+		return moved, err
+	}
+
+	if err := store.Remove(from); err != nil {
+		return moved, err
+	}
+	hooks.runOnRename(from, to)
+
+	return moved, nil
+}
+
+// undoRename moves the history and metadata of a failed rename back to the
+// old title.
+func undoRename(from, to string, history, meta bool) {
+	if history {
+		historyMu.Lock()
+		err := os.Rename(historyDir(to), historyDir(from))
+		historyMu.Unlock()
+		if err != nil {
+			slog.Error("error moving history back after failed rename", "title", from, "err", err)
+		}
+	}
+	if meta {
+		if err := os.Rename(metaFilename(to), metaFilename(from)); err != nil {
+			slog.Error("error moving metadata back after failed rename", "title", from, "err", err)
+		}
+	}
+}
+
+// updateReferences rewrites references to from in the given pages. Pages
+// the renamer may not edit are reported instead of changed.
+func updateReferences(r *http.Request, titles []string, from, to string, dryRun bool) []referenceUpdate {
+	var updates []referenceUpdate
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if err != nil {
+			updates = append(updates, referenceUpdate{Title: title, Error: err.Error()})
+			continue
+		}
+
+		body, changes := rewriteReferences(p.Body, from, to)
+		if len(changes) == 0 {
+			continue
+		}
+		u := referenceUpdate{Title: title, Changes: changes}
+		if level := pageProtection(title); !allowedByProtection(r, level) {
+			u.Error = "page is protected"
+		} else if !dryRun {
+			err := savePage(&page.Page{Title: title, Body: body, Editor: currentUser(r), Summary: "Updated links to " + from + " after rename to " + to})
+			if err != nil {
+				u.Error = err.Error()
+			}
+		}
+		updates = append(updates, u)
+	}
+
+	return updates
+}
+
+func renameHandler(w http.ResponseWriter, r *http.Request, param string) {
+	data := &renameData{Title: param, UpdateRefs: true}
+	if r.Method != http.MethodPost {
+		renderTemplate(w, r, pageData{Title: "Rename " + param, Content: data}, "rename")
+		return
+	}
+
+	data.NewTitle = r.FormValue("newTitle")
+	data.UpdateRefs = r.FormValue("update_refs") == "on"
+	data.DryRun = r.FormValue("dry_run") == "on"
+
+	sources, err := referringPages(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	to := slugTitle(strings.Join(strings.Fields(data.NewTitle), " "))
+	if data.DryRun {
+		if err := validateTitle(to); err != nil {
+			renderError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, err := statPage(to); err == nil {
+			renderError(w, r, http.StatusConflict, "Page "+to+" already exists")
+			return
+		}
+		if data.UpdateRefs {
+			data.Updates = updateReferences(r, append(sources, param), param, to, true)
+		}
+		renderTemplate(w, r, pageData{Title: "Rename " + param, Content: data}, "rename")
+		return
+	}
+
+	moved, err := renamePage(param, data.NewTitle, currentUser(r))
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if status, ok := saveErrorStatus(err); ok {
+		renderError(w, r, status, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !data.UpdateRefs {
+		addFlash(w, r, flash{Level: "success", Text: "Page " + param + " renamed to " + moved.Title})
+		http.Redirect(w, r, pageURL("/view/"+moved.Title), http.StatusFound)
+		return
+	}
+
+	data.Renamed = true
+	data.NewTitle = moved.Title
+	data.Updates = updateReferences(r, append(sources, moved.Title), param, moved.Title, false)
+	for _, u := range data.Updates {
+		if u.Error != "" {
+			data.Failed++
+		} else {
+			data.Updated++
+		}
+	}
+	renderTemplate(w, r, pageData{Title: "Renamed " + param, Content: data}, "rename")
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRewriteReferences(t *testing.T) {
+	body := "See [[Old]] and [[ Old |the old page]].\n" +
+		"[inline](Old), [viewed](/view/Old#setup \"title\") and [other](Older).\n" +
+		"```\n[[Old]] stays in code\n```\n" +
+		"[ref]: /view/Old?x=1\n" +
+		"[[Older]] and Old as a word\n"
+	got, changes := rewriteReferences([]byte(body), "Old", "New")
+
+	want := "See [[New]] and [[ New |the old page]].\n" +
+		"[inline](New), [viewed](/view/New#setup \"title\") and [other](Older).\n" +
+		"```\n[[Old]] stays in code\n```\n" +
+		"[ref]: /view/New?x=1\n" +
+		"[[Older]] and Old as a word\n"
+	if string(got) != want {
+		t.Errorf("rewritten\n%s\nwant\n%s", got, want)
+	}
+	wantChanges := []referenceChange{
+		{1, "See [[Old]] and [[ Old |the old page]].", "See [[New]] and [[ New |the old page]]."},
+		{2, "[inline](Old), [viewed](/view/Old#setup \"title\") and [other](Older).", "[inline](New), [viewed](/view/New#setup \"title\") and [other](Older)."},
+		{6, "[ref]: /view/Old?x=1", "[ref]: /view/New?x=1"},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("changes = %+v", changes)
+	}
+}
+
+func TestRenameUpdatesReferences(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Old", "the page")
+	w.seed("Wiki", "see [[Old|it]]\nunrelated\n")
+	w.seed("Linked", "[old](Old)\n")
+	w.seed("Locked", "[[Old]]\n")
+	setProtection(t, "Locked", protectionAdmins)
+	w.seed("Plain", "nothing here\n")
+	rebuildIndexes()
+	alice := w.login("alice", roleEditor)
+
+	resp, body := w.post("/rename/Old", url.Values{"newTitle": {"New"}, "update_refs": {"on"}, "dry_run": {"on"}}, alice)
+	wantStatus(t, resp, body, http.StatusOK)
+	for _, want := range []string{
+		`1: <span class="diff-delete">see [[Old|it]]</span>` + "\n" + `1: <span class="diff-insert">see [[New|it]]</span>`,
+		`<a href="/view/Linked">Linked</a>`,
+		`<a href="/view/Locked">Locked</a>` + "\n        " + `<span class="flash flash-error">page is protected</span>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dry run lacks %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/view/Plain") {
+		t.Errorf("dry run lists a page without links:\n%s", body)
+	}
+	if _, err := loadPage("New"); err == nil {
+		t.Error("dry run renamed the page")
+	}
+	if p, _ := loadPage("Wiki"); string(p.Body) != "see [[Old|it]]\nunrelated\n" {
+		t.Errorf("dry run changed Wiki: %q", p.Body)
+	}
+
+	resp, body = w.post("/rename/Old", url.Values{"newTitle": {"New"}, "update_refs": {"on"}}, alice)
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "updated links in 2 pages, 1 failed.") {
+		t.Errorf("rename does not report the updates:\n%s", body)
+	}
+	for title, want := range map[string]string{
+		"Wiki":   "see [[New|it]]\nunrelated\n",
+		"Linked": "[old](New)\n",
+		"Locked": "[[Old]]\n",
+		"Plain":  "nothing here\n",
+	} {
+		if p, _ := loadPage(title); string(p.Body) != want {
+			t.Errorf("%s = %q, want %q", title, p.Body, want)
+		}
+	}
+	if rev, ok := lastRevision("Wiki"); !ok || rev.Editor != "alice" || rev.Summary != "Updated links to Old after rename to New" {
+		t.Errorf("link update revision = %+v", rev)
+	}
+
+	// Without update_refs the links are left as they were.
+	resp, body = w.post("/rename/New", url.Values{"newTitle": {"Newer"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if p, _ := loadPage("Wiki"); string(p.Body) != "see [[New|it]]\nunrelated\n" {
+		t.Errorf("plain rename changed Wiki: %q", p.Body)
+	}
+}
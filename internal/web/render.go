@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var markdownOptions = []goldmark.Option{goldmark.WithExtensions(extension.GFM)}
+
+var markdown = goldmark.New(markdownOptions...)
+
+// addMarkdownOptions rebuilds the renderer with extra options, so features
+// set up one after another do not undo each other.
+func addMarkdownOptions(opts ...goldmark.Option) {
+	markdownOptions = append(markdownOptions, opts...)
+	markdown = goldmark.New(markdownOptions...)
+}
+
+var sanitizer = bluemonday.UGCPolicy()
+
+// maxConcurrentRenders caps how many Markdown and template renders run at
+// once. Zero leaves rendering unbounded.
+var maxConcurrentRenders int
+
+var renderSlots chan struct{}
+
+// maxPooledBuffer keeps buffers grown by unusually large pages out of the
+// pool.
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// acquireRender waits for a render slot until ctx is done.
+func acquireRender(ctx context.Context) error {
+	if renderSlots == nil {
+		return nil
+	}
+
+	select {
+	case renderSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func releaseRender() {
+	if renderSlots != nil {
+		<-renderSlots
+	}
+}
+
+const defaultRenderTimeout = 5 * time.Second
+
+// renderTimeout bounds how long rendering one page may take, waiting for a
+// render slot included, so crafted input cannot hang a request. Goldmark
+// cannot be interrupted: a render that runs over keeps its slot until it
+// finishes, but the request gets an error straight away. Zero disables the
+// limit.
+var renderTimeout = defaultRenderTimeout
+
+var errRenderTimeout = errors.New("rendering took too long")
+
+// errRenderBusy answers a template render that found no free slot in time.
+const errRenderBusy = "The wiki is busy, please try again in a moment."
+
+func setupRenderTimeout() error {
+	raw := os.Getenv("RENDER_TIMEOUT")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid RENDER_TIMEOUT %q", raw)
+	}
+	renderTimeout = d
+
+	return nil
+}
+
+// renderPage turns a page body into sanitized HTML. The view and preview share it
+// so that what is previewed is exactly what gets shown after saving.
+func renderPage(ctx context.Context, title string, body []byte) (template.HTML, error) {
+	if renderTimeout == 0 {
+		if err := acquireRender(ctx); err != nil {
+			return "", err
+		}
+		defer releaseRender()
+		return convertPage(title, body)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+	if err := acquireRender(ctx); err != nil {
+		return "", renderContextError(ctx, title, body)
+	}
+
+	type result struct {
+		html template.HTML
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer releaseRender()
+		html, err := convertPage(title, body)
+		done <- result{html, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.html, res.err
+	case <-ctx.Done():
+		return "", renderContextError(ctx, title, body)
+	}
+}
+
+// renderContextError reports why the render of title stopped before it
+// finished.
+func renderContextError(ctx context.Context, title string, body []byte) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Warn("page render timed out", "title", title, "size", len(body), "timeout", renderTimeout)
+		return errRenderTimeout
+	}
+	return ctx.Err()
+}
+
+func convertPage(title string, body []byte) (template.HTML, error) {
+	var buf bytes.Buffer
+	source := transclude(page.StripFrontMatter(body), []string{title})
+	if err := markdown.Convert(source, &buf); err != nil {
+		return "", err
+	}
+
+	html := template.HTML(externalLinks.apply(sanitizer.SanitizeBytes(buf.Bytes())))
+
+	return hooks.runBeforeRender(title, html)
+}
+
+// renderFailed answers a request whose page could not be rendered.
+func renderFailed(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errRenderTimeout) {
+		renderError(w, r, http.StatusServiceUnavailable, "This page took too long to render. Its content may be too large or too complex, try simplifying it.")
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
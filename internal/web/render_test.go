@@ -0,0 +1,395 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveTemplate renders tmpl through srv as a handler would.
+func serveTemplate(srv *server, data pageData, tmpl string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	withServer(srv, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderTemplate(w, r, data, tmpl)
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func writeTemplates(t *testing.T, files map[string]string) *server {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, err := newServer(Config{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestRenderTemplate(t *testing.T) {
+	newTestWiki(t)
+
+	rec := serveTemplate(testServer, pageData{Title: "Changelog", Content: &changelogData{}}, "changelog")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d\n%s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("Content-Length = %s, body is %d bytes", got, rec.Body.Len())
+	}
+	if !strings.Contains(rec.Body.String(), "No edits yet") || !strings.Contains(rec.Body.String(), "<title>") {
+		t.Errorf("page is missing the content or the base layout:\n%s", rec.Body)
+	}
+
+	rec = serveTemplate(testServer, pageData{Title: "Gone", Status: http.StatusNotFound, Content: &changelogData{}}, "changelog")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want the pageData status 404", rec.Code)
+	}
+}
+
+// A template failing after most of the page was rendered must not leave a
+// half-written 200 behind.
+func TestRenderTemplateLateError(t *testing.T) {
+	newTestWiki(t)
+
+	for name, files := range map[string]map[string]string{
+		"base": {
+			"base.html": `<html>` + strings.Repeat("padding ", 10000) + `{{.Content}}{{index .Nav 99}}</html>`,
+			"page.html": `content`,
+		},
+		"content": {
+			"base.html": `<html>{{.Content}}</html>`,
+			"page.html": strings.Repeat("padding ", 10000) + `{{index . 99}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := writeTemplates(t, files)
+			rec := serveTemplate(srv, pageData{Content: []string{}}, "page")
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			if strings.Contains(rec.Body.String(), "padding") {
+				t.Error("response contains the part of the page rendered before the error")
+			}
+			if ct := rec.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/html") {
+				t.Errorf("error response has the page's Content-Type %q", ct)
+			}
+		})
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	putBuffer(getBuffer())
+	allocs := testing.AllocsPerRun(100, func() {
+		b := getBuffer()
+		b.WriteString("some rendered html")
+		putBuffer(b)
+	})
+	if allocs != 0 {
+		t.Errorf("pooled buffer round trip allocates %v times, want 0", allocs)
+	}
+
+	big := getBuffer()
+	big.Grow(maxPooledBuffer + 1)
+	putBuffer(big)
+	for range 10 {
+		if b := getBuffer(); b == big {
+			t.Fatal("a buffer over maxPooledBuffer went back into the pool")
+		}
+	}
+}
+
+func BenchmarkRenderTemplate(b *testing.B) {
+	newTestWiki(b)
+	entries := make([]changelogEntry, 50)
+	for i := range entries {
+		entries[i] = changelogEntry{Title: "Page-" + strconv.Itoa(i), revision: revision{ID: i + 1, Summary: "edit"}}
+	}
+	data := pageData{Title: "Changelog", Content: &changelogData{Entries: entries}}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if rec := serveTemplate(testServer, data, "changelog"); rec.Code != http.StatusOK {
+			b.Fatal(rec.Code)
+		}
+	}
+}
+
+// A template sorting before base.html must still be found by name.
+func TestLoadTemplatesFirstFile(t *testing.T) {
+	srv := writeTemplates(t, map[string]string{
+		"aaa.html":  `first {{.}}`,
+		"base.html": `<html>{{.Content}}</html>`,
+	})
+	rec := serveTemplate(srv, pageData{Content: "page"}, "aaa")
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html>first page</html>" {
+		t.Errorf("rendered %d %q", rec.Code, rec.Body)
+	}
+}
+
+// The preview shows exactly the HTML the page gets once saved.
+func TestPreviewMatchesView(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Other", "other")
+	source := "# Title\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"- [x] done\n- [ ] todo\n\n" +
+		"~~gone~~ and https://example.com and [[Other]] and [missing](/view/Missing)\n\n" +
+		"<script>alert(1)</script><a href=\"javascript:alert(1)\" onclick=\"x()\">click</a>\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"Footnote[^1].\n\n[^1]: The note.\n"
+
+	want, err := renderPage(context.Background(), "Home", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(want), "<script") || strings.Contains(string(want), "javascript:") {
+		t.Fatalf("render is not sanitized:\n%s", want)
+	}
+	article := `<div style="word-break: break-word; width: 100%">` + string(want) + `</div>`
+
+	resp, body := w.post("/preview", url.Values{"title": {"Home"}, "body": {source}})
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, article) {
+		t.Errorf("preview differs from the render:\n%s\nwant\n%s", body, want)
+	}
+
+	w.seed("Home", source)
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, article) {
+		t.Errorf("view differs from the render:\n%s\nwant\n%s", body, want)
+	}
+}
+
+// In dev mode a broken template is reported on the page, which keeps
+// rendering from the last templates that parsed.
+func TestDevModeBrokenTemplate(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home text")
+
+	dir := t.TempDir()
+	files, err := filepath.Glob("../../templates/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, err := newServer(Config{DevMode: true}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := httptest.NewServer(newHandler(srv, routes()))
+	t.Cleanup(dev.Close)
+	w = &testWiki{Server: dev, t: t, dir: w.dir}
+
+	view := filepath.Join(dir, "view.html")
+	original, err := os.ReadFile(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(view, append([]byte("{{if .Title}}\n"), original...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "Template reload failed") || !strings.Contains(body, "view.html") {
+		t.Errorf("page does not report the broken template:\n%s", body)
+	}
+	if !strings.Contains(body, "home text") {
+		t.Errorf("page did not render from the previous templates:\n%s", body)
+	}
+
+	if err := os.WriteFile(view, append([]byte("<p>edited template</p>\n"), original...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if strings.Contains(body, "Template reload failed") || !strings.Contains(body, "edited template") {
+		t.Errorf("fixed template not picked up:\n%s", body)
+	}
+}
+
+// However many views arrive at once, at most MAX_CONCURRENT_RENDERS pages
+// are rendered at a time.
+func TestRenderConcurrencyLimit(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "# home")
+	setGlobal(t, &maxConcurrentRenders, maxConcurrentRenders)
+	setGlobal(t, &renderSlots, renderSlots)
+	t.Setenv("MAX_CONCURRENT_RENDERS", "3")
+	if err := setupLimits(); err != nil {
+		t.Fatal(err)
+	}
+
+	var running, peak atomic.Int32
+	withHooks(t, func(h *hookRegistry) {
+		h.BeforeRender(func(title string, html template.HTML) (template.HTML, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return html, nil
+		})
+	})
+
+	load := func() int32 {
+		peak.Store(0)
+		var wg sync.WaitGroup
+		for range 24 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp, body := w.get("/view/Home"); resp.StatusCode != http.StatusOK {
+					t.Errorf("view = %d\n%s", resp.StatusCode, body)
+				}
+			}()
+		}
+		wg.Wait()
+		return peak.Load()
+	}
+
+	if p := load(); p > 3 || p < 2 {
+		t.Errorf("peak of %d concurrent renders, want at most 3 and some parallelism", p)
+	}
+
+	renderSlots = nil
+	if p := load(); p <= 3 {
+		t.Errorf("peak of %d concurrent renders without a limit, the load is too light to tell", p)
+	}
+}
+
+// A render running past RENDER_TIMEOUT fails the request instead of holding
+// it until the render finishes.
+func TestRenderTimeout(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home text")
+	w.seed("Slow", "slow text")
+	setGlobal(t, &renderTimeout, 50*time.Millisecond)
+
+	release := make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	withHooks(t, func(h *hookRegistry) {
+		h.BeforeRender(func(title string, html template.HTML) (template.HTML, error) {
+			if title == "Slow" {
+				<-release
+			}
+			return html, nil
+		})
+	})
+	t.Cleanup(unblock)
+
+	start := time.Now()
+	resp, body := w.get("/view/Slow")
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if !strings.Contains(body, "This page took too long to render") {
+		t.Errorf("timeout is not explained:\n%s", body)
+	}
+	resp, body = w.post("/preview", url.Values{"title": {"Slow"}, "body": {"draft"}})
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("timed out renders took %v", d)
+	}
+
+	// Other pages render as usual meanwhile.
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := renderPage(ctx, "Slow", []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("render of a cancelled request = %v", err)
+	}
+
+	// Without a limit the render is waited for.
+	renderTimeout = 0
+	time.AfterFunc(100*time.Millisecond, unblock)
+	if html, err := renderPage(context.Background(), "Slow", []byte("done")); err != nil || html != "<p>done</p>\n" {
+		t.Errorf("render without a timeout = %q, %v", html, err)
+	}
+}
+
+// Waiting for a render slot counts against RENDER_TIMEOUT, and a render that
+// timed out holds its slot until it really finishes.
+func TestRenderTimeoutSlots(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home text")
+	w.seed("Slow", "slow text")
+	setGlobal(t, &renderTimeout, 50*time.Millisecond)
+	setGlobal(t, &renderSlots, make(chan struct{}, 1))
+
+	release := make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	withHooks(t, func(h *hookRegistry) {
+		h.BeforeRender(func(title string, html template.HTML) (template.HTML, error) {
+			if title == "Slow" {
+				<-release
+			}
+			return html, nil
+		})
+	})
+	t.Cleanup(unblock)
+
+	renderSlots <- struct{}{}
+	start := time.Now()
+	if _, err := renderPage(context.Background(), "Home", []byte("x")); !errors.Is(err, errRenderTimeout) {
+		t.Errorf("render without a free slot = %v", err)
+	}
+	resp, body := w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("waiting for a slot took %v", d)
+	}
+	<-renderSlots
+
+	resp, body = w.get("/view/Slow")
+	wantStatus(t, resp, body, http.StatusServiceUnavailable)
+	if len(renderSlots) != 1 {
+		t.Error("timed out render gave up its slot while still running")
+	}
+	unblock()
+	waitFor(t, func() bool { return len(renderSlots) == 0 })
+	resp, body = w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+}
+
+func TestSetupRenderTimeout(t *testing.T) {
+	setGlobal(t, &renderTimeout, defaultRenderTimeout)
+	for _, raw := range []string{"forever", "-1s"} {
+		t.Setenv("RENDER_TIMEOUT", raw)
+		if err := setupRenderTimeout(); err == nil {
+			t.Errorf("RENDER_TIMEOUT=%q accepted", raw)
+		}
+	}
+	t.Setenv("RENDER_TIMEOUT", "0")
+	if err := setupRenderTimeout(); err != nil || renderTimeout != 0 {
+		t.Errorf("setupRenderTimeout = %v, %v", err, renderTimeout)
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// allowedHTMLEntry is one item of HTML_ALLOW: an element name, optionally
+// with the attributes it may keep in brackets, e.g. "details[open]".
+var allowedHTMLEntry = regexp.MustCompile(`^([a-z][a-z0-9]*)(?:\[([a-z-]+(?: [a-z-]+)*)\])?$`)
+
+// neverAllowedHTML stays stripped whatever HTML_ALLOW says, since these run
+// scripts or change how the rest of the page is loaded.
+var neverAllowedHTML = []string{"script", "style", "iframe", "frame", "frameset", "object", "embed", "base", "meta", "link", "form", "svg", "math"}
+
+// markdownPolicy only allows what Markdown itself produces.
+func markdownPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowStandardURLs()
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|right|center)$`)).OnElements("th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "em", "strong", "del", "code", "pre",
+		"blockquote", "ul", "ol", "li", "table", "thead", "tbody", "tr", "th", "td")
+
+	return p
+}
+
+// setupSanitizer picks the HTML policy. HTML_POLICY=ugc, the default, allows
+// the usual user content markup; HTML_POLICY=markdown only what Markdown
+// renders. HTML_ALLOW adds elements on top, e.g.
+// "details[open],summary,kbd". RAW_HTML=true passes HTML written in pages to
+// the sanitizer instead of dropping it.
+func setupSanitizer() error {
+	switch policy := os.Getenv("HTML_POLICY"); policy {
+	case "", "ugc":
+		sanitizer = bluemonday.UGCPolicy()
+	case "markdown":
+		sanitizer = markdownPolicy()
+	default:
+		return fmt.Errorf("invalid HTML_POLICY %q, expected ugc or markdown", policy)
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("HTML_ALLOW")); raw != "" {
+		for _, entry := range strings.Split(raw, ",") {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			m := allowedHTMLEntry.FindStringSubmatch(entry)
+			if m == nil {
+				return fmt.Errorf("invalid HTML_ALLOW entry %q", entry)
+			}
+			if slices.Contains(neverAllowedHTML, m[1]) {
+				return fmt.Errorf("HTML_ALLOW cannot allow <%s>", m[1])
+			}
+
+			sanitizer.AllowElements(m[1])
+			if m[2] == "" {
+				continue
+			}
+			attrs := strings.Fields(m[2])
+			for _, attr := range attrs {
+				if strings.HasPrefix(attr, "on") || attr == "style" {
+					return fmt.Errorf("HTML_ALLOW cannot allow the %s attribute", attr)
+				}
+			}
+			sanitizer.AllowAttrs(attrs...).OnElements(m[1])
+		}
+	}
+
+	if os.Getenv("RAW_HTML") == "true" {
+		addMarkdownOptions(goldmark.WithRendererOptions(html.WithUnsafe()))
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// withSanitizer sets the sanitizer up from env for the rest of the test.
+func withSanitizer(t *testing.T, env map[string]string) error {
+	t.Helper()
+	setGlobal(t, &sanitizer, sanitizer)
+	setGlobal(t, &markdownOptions, slices.Clip(markdownOptions))
+	setGlobal(t, &markdown, markdown)
+	for _, key := range []string{"HTML_POLICY", "HTML_ALLOW", "RAW_HTML"} {
+		t.Setenv(key, env[key])
+	}
+	return setupSanitizer()
+}
+
+const rawHTMLPage = "<details open><summary>More</summary>hidden</details>\n\n" +
+	"<p>Press <kbd>Ctrl</kbd> <u>now</u> <span title=\"t\">x</span></p>\n\n" +
+	"<table><tr><td>cell</td></tr></table>\n\n" +
+	"<script>alert(1)</script><a href=\"javascript:alert(1)\" onclick=\"x()\">link</a>\n"
+
+func TestSanitizerPolicies(t *testing.T) {
+	const (
+		table = "<table><tr><td>cell</td></tr></table>\n"
+		// Scripts and event handlers are stripped under every policy.
+		link = "link\n"
+	)
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"raw html dropped", nil, ""},
+		{"ugc", map[string]string{"RAW_HTML": "true"},
+			"<details open=\"\"><summary>More</summary>hidden</details>\n<p>Press Ctrl <u>now</u> <span title=\"t\">x</span></p>\n" + table + link},
+		{"ugc with kbd", map[string]string{"RAW_HTML": "true", "HTML_ALLOW": "kbd"},
+			"<details open=\"\"><summary>More</summary>hidden</details>\n<p>Press <kbd>Ctrl</kbd> <u>now</u> <span title=\"t\">x</span></p>\n" + table + link},
+		{"markdown", map[string]string{"RAW_HTML": "true", "HTML_POLICY": "markdown"},
+			"Morehidden\n<p>Press Ctrl now x</p>\n" + table + link},
+		{"markdown with details", map[string]string{"RAW_HTML": "true", "HTML_POLICY": "markdown", "HTML_ALLOW": "details[open], Summary,kbd"},
+			"<details open=\"\"><summary>More</summary>hidden</details>\n<p>Press <kbd>Ctrl</kbd> now x</p>\n" + table + link},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := withSanitizer(t, tt.env); err != nil {
+				t.Fatal(err)
+			}
+			html, err := renderPage(context.Background(), "Home", []byte(rawHTMLPage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimLeft(string(html), "\n"); got != tt.want {
+				t.Errorf("rendered\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupSanitizerErrors(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"HTML_POLICY": "strict"},
+		{"HTML_ALLOW": "details,<b>"},
+		{"HTML_ALLOW": "script"},
+		{"HTML_ALLOW": "IFRAME[src]"},
+		{"HTML_ALLOW": "span[onclick]"},
+		{"HTML_ALLOW": "span[style]"},
+	} {
+		if err := withSanitizer(t, env); err == nil {
+			t.Errorf("%v accepted", env)
+		}
+	}
+}
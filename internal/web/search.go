@@ -0,0 +1,408 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const snippetLength = 160
+
+type searchResult struct {
+	Title      string
+	Snippet    string
+	Highlights []textRange
+	Marked     template.HTML
+	score      float64
+	parts      scoreParts
+}
+
+// textRange is a [Start, End) byte range, used to mark matches in a snippet
+// without embedding HTML.
+type textRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type searchData struct {
+	Query    string
+	Results  []searchResult
+	Total    int
+	Page     int
+	PrevPage int
+	NextPage int
+}
+
+// parseQuery splits a query into the free words, which go through the
+// analyzer, and the "quoted phrases", which are matched exactly.
+func parseQuery(query string) (free string, phrases []string) {
+	parts := strings.Split(query, `"`)
+	var rest []string
+	for i, part := range parts {
+		// An unclosed quote leaves its text free.
+		if i%2 == 1 && i < len(parts)-1 {
+			if phrase := strings.Join(strings.Fields(strings.ToLower(part)), " "); phrase != "" {
+				phrases = append(phrases, phrase)
+			}
+			continue
+		}
+		rest = append(rest, part)
+	}
+
+	return strings.Join(rest, " "), phrases
+}
+
+func searchPages(query string) []searchResult {
+	free, phrases := parseQuery(query)
+
+	var terms []string
+	seen := map[string]bool{}
+	for _, term := range searchAnalyzer.terms(free) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	// A query of nothing but stop words still finds the words themselves.
+	if len(terms) == 0 && len(phrases) == 0 {
+		phrases = words(free)
+	}
+	if len(terms) == 0 && len(phrases) == 0 {
+		return nil
+	}
+
+	// Pages must use every term, and the postings give the term frequencies.
+	postings := make([]map[string]int, len(terms))
+	for i, term := range terms {
+		postings[i] = plaintexts.postings(term)
+	}
+	var candidates []string
+	if len(terms) > 0 {
+	pages:
+		for title := range postings[0] {
+			for _, counts := range postings[1:] {
+				if counts[title] == 0 {
+					continue pages
+				}
+			}
+			candidates = append(candidates, title)
+		}
+	} else {
+		candidates = plaintexts.titles()
+	}
+
+	total := plaintexts.count()
+	phraseDF := make([]int, len(phrases))
+	var results []searchResult
+	var phraseTF [][]int
+	for _, title := range candidates {
+		text, ok := plaintexts.get(title)
+		if !ok {
+			continue
+		}
+
+		lower := strings.ToLower(text)
+		lowerTitle := strings.ToLower(title)
+		tf := make([]int, len(phrases))
+		found := true
+		for i, phrase := range phrases {
+			tf[i] = strings.Count(lower, phrase)
+			if strings.Contains(lowerTitle, phrase) {
+				tf[i] += 5
+			}
+			if tf[i] == 0 {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		for i := range phrases {
+			phraseDF[i]++
+		}
+
+		relevance := 0.0
+		titleTerms := searchAnalyzer.terms(title)
+		for i, term := range terms {
+			n := postings[i][title]
+			if slices.Contains(titleTerms, term) {
+				n += 5
+			}
+			relevance += tfidf(n, len(postings[i]), total)
+		}
+
+		marks := slices.Concat(phrases, terms)
+		snip := snippet(text, lower, marks)
+		results = append(results, searchResult{
+			Title:      title,
+			Snippet:    snip,
+			Highlights: highlights(snip, phrases, terms),
+			parts:      scoreParts{Relevance: relevance},
+		})
+		phraseTF = append(phraseTF, tf)
+	}
+	for i := range results {
+		for j, n := range phraseTF[i] {
+			results[i].parts.Relevance += tfidf(n, phraseDF[j], total)
+		}
+	}
+
+	rankResults(results, time.Now())
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].Title < results[j].Title
+	})
+
+	return results
+}
+
+const (
+	maxSnippetWindows = 3
+	// snippetSlack is how far a window edge may move to land between words.
+	snippetSlack = 15
+)
+
+// snippet picks up to maxSnippetWindows windows of text that together cover
+// as many query terms as possible, the first one covering the most, and
+// joins them with ellipses. Windows that overlap or nearly touch are merged.
+// Offsets come from the lowercased text, which can differ in length for some
+// runes; then the snippet is simply the start of the text.
+func snippet(text, lower string, terms []string) string {
+	var windows []textRange
+	if len(lower) == len(text) {
+		windows = snippetWindows(lower, terms)
+	}
+	if len(windows) == 0 {
+		windows = []textRange{{0, min(snippetLength, len(text))}}
+	}
+
+	var b strings.Builder
+	for i, win := range windows {
+		win = snapWindow(text, win)
+		if win.Start > 0 && i == 0 {
+			b.WriteString("…")
+		} else if i > 0 {
+			b.WriteString(" … ")
+		}
+		b.WriteString(text[win.Start:win.End])
+		if i == len(windows)-1 && win.End < len(text) {
+			b.WriteString("…")
+		}
+	}
+
+	return b.String()
+}
+
+func snippetWindows(lower string, terms []string) []textRange {
+	type match struct{ pos, term int }
+	var matches []match
+	for t, term := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], term)
+			if j < 0 {
+				break
+			}
+			matches = append(matches, match{i + j, t})
+			i += j + len(term)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+
+	uncovered := map[int]bool{}
+	for _, m := range matches {
+		uncovered[m.term] = true
+	}
+
+	// Matches are counted in the first half of a window, which leaves room
+	// for context on both sides.
+	reach := snippetLength / 2
+	var windows []textRange
+	for len(windows) < maxSnippetWindows && len(uncovered) > 0 {
+		best, bestCount := -1, 0
+		for i, m := range matches {
+			if !uncovered[m.term] {
+				continue
+			}
+			seen := map[int]bool{}
+			for _, n := range matches[i:] {
+				if n.pos >= m.pos+reach {
+					break
+				}
+				if uncovered[n.term] {
+					seen[n.term] = true
+				}
+			}
+			if len(seen) > bestCount {
+				best, bestCount = i, len(seen)
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		from := matches[best].pos
+		for _, n := range matches[best:] {
+			if n.pos >= from+reach {
+				break
+			}
+			delete(uncovered, n.term)
+		}
+		start := max(from-snippetLength/4, 0)
+		windows = append(windows, textRange{start, min(start+snippetLength, len(lower))})
+	}
+
+	// The best window comes first, the rest follow in text order; windows
+	// closer than a few words are merged into one.
+	rest := windows[min(1, len(windows)):]
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Start < rest[j].Start })
+	var merged []textRange
+next:
+	for _, win := range windows {
+		for i, m := range merged {
+			if win.Start <= m.End+snippetSlack && win.End >= m.Start-snippetSlack {
+				merged[i] = textRange{min(m.Start, win.Start), max(m.End, win.End)}
+				continue next
+			}
+		}
+		merged = append(merged, win)
+	}
+
+	return merged
+}
+
+// snapWindow moves the edges of a window onto rune boundaries and, when a
+// space is close, between words.
+func snapWindow(text string, win textRange) textRange {
+	start, end := win.Start, win.End
+	if start > 0 {
+		if i := strings.IndexByte(text[start:min(start+snippetSlack, end)], ' '); i >= 0 {
+			start += i + 1
+		}
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[max(end-snippetSlack, start):end], ' '); i >= 0 {
+			end = max(end-snippetSlack, start) + i
+		}
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	return textRange{start, end}
+}
+
+// markSnippet escapes a snippet and wraps the highlighted ranges in <mark>.
+func markSnippet(snip string, ranges []textRange) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		b.WriteString(template.HTMLEscapeString(snip[last:r.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(snip[r.Start:r.End]))
+		b.WriteString("</mark>")
+		last = r.End
+	}
+	b.WriteString(template.HTMLEscapeString(snip[last:]))
+
+	return template.HTML(b.String())
+}
+
+// highlights finds the exact matches of the lowercased phrases in a snippet,
+// and the words that analyze to one of the terms. Ranges are only reported
+// when lowercasing keeps byte offsets intact.
+func highlights(snip string, phrases, terms []string) []textRange {
+	lower := strings.ToLower(snip)
+	if len(lower) != len(snip) {
+		return nil
+	}
+
+	var ranges []textRange
+	for _, phrase := range phrases {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], phrase)
+			if j < 0 {
+				break
+			}
+			ranges = append(ranges, textRange{i + j, i + j + len(phrase)})
+			i += j + len(phrase)
+		}
+	}
+
+	if len(terms) > 0 {
+		start := -1
+		for i, r := range lower + " " {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				if t := searchAnalyzer.terms(lower[start:i]); len(t) == 1 && slices.Contains(terms, t[0]) {
+					ranges = append(ranges, textRange{start, i})
+				}
+				start = -1
+			}
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.FormValue("q"))
+
+	pageNum := 1
+	if n, err := strconv.Atoi(r.FormValue("page")); err == nil && n > 0 {
+		pageNum = n
+	}
+
+	// Every match is scored so the total is right, only one page is shown.
+	results := searchPages(query)
+	perPage := requestPreferences(r).PerPage
+	start := min((pageNum-1)*perPage, len(results))
+	end := min(start+perPage, len(results))
+
+	shown := results[start:end]
+	for i := range shown {
+		shown[i].Marked = markSnippet(shown[i].Snippet, shown[i].Highlights)
+	}
+
+	content := &searchData{Query: query, Results: shown, Total: len(results), Page: pageNum}
+	if pageNum > 1 {
+		content.PrevPage = pageNum - 1
+	}
+	if end < len(results) {
+		content.NextPage = pageNum + 1
+	}
+
+	data := pageData{
+		Title:   "Search",
+		Content: content,
+	}
+
+	renderTemplate(w, r, data, "search")
+}
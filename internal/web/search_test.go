@@ -0,0 +1,358 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query, free string
+		phrases     []string
+	}{
+		{"deploy guide", "deploy guide", nil},
+		{`"deploy script" now`, "  now", []string{"deploy script"}},
+		{`"  Mixed   Case "`, " ", []string{"mixed case"}},
+		{`"unclosed quote`, ` unclosed quote`, nil},
+		{`a "b" c "d e"`, "a   c  ", []string{"b", "d e"}},
+		{`""`, " ", nil},
+	}
+	for _, tt := range tests {
+		free, phrases := parseQuery(tt.query)
+		if free != tt.free || !slices.Equal(phrases, tt.phrases) {
+			t.Errorf("parseQuery(%q) = %q, %q, want %q, %q", tt.query, free, phrases, tt.free, tt.phrases)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor ", 20)
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "short text is shown whole",
+			text:  "a short page about deploys",
+			terms: []string{"deploy"},
+			want:  "a short page about deploys",
+		},
+		{
+			name:  "no match falls back to the start",
+			text:  filler,
+			terms: []string{"absent"},
+			want:  strings.Repeat("lorem ipsum dolor ", 8) + "lorem ipsum…",
+		},
+		{
+			name:  "window around a match deep in the text",
+			text:  filler + "the deploy happened " + filler,
+			terms: []string{"deploy"},
+			want:  "…ipsum dolor lorem ipsum dolor the deploy happened lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum…",
+		},
+		{
+			name:  "one window covers both nearby terms",
+			text:  filler + "alpha then beta " + filler,
+			terms: []string{"alpha", "beta"},
+			want:  "…lorem ipsum dolor lorem ipsum dolor alpha then beta lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum…",
+		},
+		{
+			name:  "distant terms get windows of their own",
+			text:  "alpha " + filler + filler + "beta " + filler,
+			terms: []string{"beta", "alpha"},
+			want:  "alpha lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem … lorem ipsum dolor lorem ipsum dolor beta lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem ipsum dolor lorem…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippet(tt.text, strings.ToLower(tt.text), tt.terms)
+			if got != tt.want {
+				t.Errorf("snippet =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetProperties(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+	}{
+		{"cyrillic", strings.Repeat("страница про деплой сервера ", 30), []string{"сервер"}},
+		{"emoji without spaces", strings.Repeat("🚀", 200) + "deploy" + strings.Repeat("🎉", 200), []string{"deploy"}},
+		{"accents", strings.Repeat("café déjà vu ", 40) + "naïve deploy", []string{"deploy", "naïve"}},
+		{"lowercasing changes length", "İstanbul " + strings.Repeat("x ", 200) + "deploy", []string{"deploy"}},
+		{"many windows", strings.Repeat("a deploy "+strings.Repeat("z", 200)+" ", 10), []string{"deploy"}},
+		{"overlapping terms", strings.Repeat("xx ", 100) + "deploydeployment", []string{"deploy", "deployment"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippet(tt.text, strings.ToLower(tt.text), tt.terms)
+			if !utf8.ValidString(got) {
+				t.Errorf("snippet split a rune: %q", got)
+			}
+			if n := strings.Count(got, " … ") + 1; n > maxSnippetWindows {
+				t.Errorf("snippet has %d windows, at most %d expected", n, maxSnippetWindows)
+			}
+			for _, part := range strings.Split(strings.Trim(got, "…"), " … ") {
+				if !strings.Contains(tt.text, part) {
+					t.Errorf("snippet part %q is not from the text", part)
+				}
+			}
+		})
+	}
+}
+
+// Plain text is built from the rendered Markdown, so a snippet never shows
+// half of a link's syntax.
+func TestSnippetOfMarkdownLinks(t *testing.T) {
+	body := strings.Repeat("Some text before. ", 10) + "See [the deploy guide](/view/Deploy-Guide \"title\") and ![a diagram](/img/deploy.png) or <https://example.com/deploy>. " + strings.Repeat("More text after. ", 10)
+	text := toPlaintext([]byte(body))
+
+	got := snippet(text, strings.ToLower(text), []string{"deploy"})
+	for _, syntax := range []string{"](", "[the", "![", "/view/"} {
+		if strings.Contains(got, syntax) {
+			t.Errorf("snippet %q contains link syntax %q", got, syntax)
+		}
+	}
+	if !strings.Contains(got, "the deploy guide") {
+		t.Errorf("snippet %q lost the link text", got)
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	tests := []struct {
+		name    string
+		snip    string
+		phrases []string
+		terms   []string
+		want    []textRange
+	}{
+		{"stemmed words", "We deployed it, deploying again.", nil, []string{"deploy"}, []textRange{{3, 11}, {16, 25}}},
+		{"word boundaries", "redeploy deploy", nil, []string{"deploy"}, []textRange{{9, 15}}},
+		{"case", "DEPLOY Deploy", nil, []string{"deploy"}, []textRange{{0, 6}, {7, 13}}},
+		{"phrase", "the deploy script ran", []string{"deploy script"}, nil, []textRange{{4, 17}}},
+		{"phrase and term overlap", "the deploy script ran", []string{"deploy script"}, []string{"script"}, []textRange{{4, 17}}},
+		{"ranges apart stay apart", "the deploy script ran", []string{"deploy script"}, []string{"ran"}, []textRange{{4, 17}, {18, 21}}},
+		{"adjacent ranges merge", "deploy-deploy", []string{"deploy-"}, []string{"deploy"}, []textRange{{0, 13}}},
+		{"cyrillic offsets", "про сервер", nil, []string{"сервер"}, []textRange{{7, 19}}},
+		{"lowercasing changes length", "İ deploy", nil, []string{"deploy"}, nil},
+		{"no match", "nothing here", []string{"absent"}, []string{"absent"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlights(tt.snip, tt.phrases, tt.terms)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("highlights(%q) = %v, want %v", tt.snip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkSnippet(t *testing.T) {
+	tests := []struct {
+		snip   string
+		ranges []textRange
+		want   template.HTML
+	}{
+		{"plain", nil, "plain"},
+		{"a <b> & c", []textRange{{2, 5}}, "a <mark>&lt;b&gt;</mark> &amp; c"},
+		{"deploy and deploy", []textRange{{0, 6}, {11, 17}}, "<mark>deploy</mark> and <mark>deploy</mark>"},
+		{`"quoted"`, []textRange{{1, 7}}, `&#34;<mark>quoted</mark>&#34;`},
+	}
+	for _, tt := range tests {
+		if got := markSnippet(tt.snip, tt.ranges); got != tt.want {
+			t.Errorf("markSnippet(%q, %v) = %q, want %q", tt.snip, tt.ranges, got, tt.want)
+		}
+	}
+}
+
+func TestSearchHighlighting(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Deploy-Guide", "How we deployed the <b>service</b> & what we deploy next.")
+	rebuildIndexes()
+
+	resp, body := w.get("/search?q=deploying")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "<mark>deployed</mark> the service &amp; what we <mark>deploy</mark>") {
+		t.Errorf("search page does not mark the matches:\n%s", body)
+	}
+
+	resp, body = w.get("/api/search?q=deploying")
+	wantStatus(t, resp, body, http.StatusOK)
+	var res struct {
+		Results []struct {
+			Snippet    string
+			Highlights []textRange
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil || len(res.Results) != 1 {
+		t.Fatalf("API results = %s, %v", body, err)
+	}
+	r := res.Results[0]
+	var marked []string
+	for _, h := range r.Highlights {
+		marked = append(marked, r.Snippet[h.Start:h.End])
+	}
+	if !slices.Equal(marked, []string{"deployed", "deploy"}) {
+		t.Errorf("API highlights mark %q in %q", marked, r.Snippet)
+	}
+}
+
+func TestAPISearchOffsets(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Deploy", "deploy")
+	rebuildIndexes()
+
+	for _, tt := range []struct {
+		query  string
+		status int
+	}{
+		{"q=deploy&offset=0", http.StatusOK},
+		{"q=deploy&offset=5", http.StatusOK},
+		{"q=deploy&offset=10000&limit=1000000", http.StatusOK},
+		{"q=deploy&offset=10001", http.StatusBadRequest},
+		{"q=deploy&offset=9223372036854775807", http.StatusBadRequest},
+		{"q=deploy&offset=-1", http.StatusBadRequest},
+		{"q=deploy&limit=0", http.StatusBadRequest},
+		{"q=", http.StatusBadRequest},
+	} {
+		resp, body := w.get("/api/search?" + tt.query)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s = %d, want %d\n%s", tt.query, resp.StatusCode, tt.status, body)
+		}
+	}
+}
+
+func TestAPISearchResults(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Deploy", "How to deploy the wiki. Deploy often.")
+	w.seed("Notes", "We deploy on Fridays")
+	w.seed("Lunch", "sandwiches")
+	for i := range 3 {
+		w.seed("Extra-"+strconv.Itoa(i), "deploy")
+	}
+	rebuildIndexes()
+
+	var res struct {
+		Query   string            `json:"query"`
+		Total   int               `json:"total"`
+		Limit   int               `json:"limit"`
+		Offset  int               `json:"offset"`
+		Results []apiSearchResult `json:"results"`
+	}
+	search := func(query string) {
+		t.Helper()
+		resp, body := w.get("/api/search?" + query)
+		wantStatus(t, resp, body, http.StatusOK)
+		res.Results = nil
+		if err := json.Unmarshal([]byte(body), &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search("q=deploy&limit=2&offset=1")
+	if res.Query != "deploy" || res.Total != 5 || res.Limit != 2 || res.Offset != 1 || len(res.Results) != 2 {
+		t.Fatalf("search window = %+v", res)
+	}
+	search("q=deploy&limit=1000")
+	if res.Limit != maxSearchLimit || len(res.Results) != 5 {
+		t.Errorf("limit above the cap = %d, %d results", res.Limit, len(res.Results))
+	}
+
+	// The API ranks like the HTML search, which lists Deploy first.
+	if _, body := w.get("/search?q=deploy"); !strings.Contains(body, `<a href="/view/`+res.Results[0].Title+`">`) {
+		t.Errorf("API ranks %s first, HTML search:\n%s", res.Results[0].Title, body)
+	}
+	for i, r := range res.Results {
+		if i > 0 && r.Score > res.Results[i-1].Score {
+			t.Errorf("results are not ordered by score: %+v", res.Results)
+		}
+		if r.Modified.IsZero() || r.Scores != nil {
+			t.Errorf("result %+v", r)
+		}
+		if len(r.Highlights) == 0 {
+			t.Errorf("%s has no highlights in %q", r.Title, r.Snippet)
+		}
+		for _, h := range r.Highlights {
+			if h.Start < 0 || h.End > len(r.Snippet) || h.Start >= h.End || !strings.EqualFold(r.Snippet[h.Start:h.End], "deploy") {
+				t.Errorf("%s highlight %+v of %q", r.Title, h, r.Snippet)
+			}
+		}
+	}
+
+	search("q=nothing-matches")
+	if res.Total != 0 || res.Results == nil || len(res.Results) != 0 {
+		t.Errorf("no matches = %+v, want an empty list", res)
+	}
+}
+
+// The total counts every match, not only the page of results shown.
+func TestSearchTotal(t *testing.T) {
+	w := newTestWiki(t)
+	var want []string
+	for i := range 7 {
+		title := "Match-" + strconv.Itoa(i)
+		w.seed(title, "a needle in a haystack")
+		want = append(want, title)
+	}
+	w.seed("Haystack", "only hay")
+	rebuildIndexes()
+
+	alice := w.login("alice", roleEditor)
+	form := validPreferences()
+	form.Set("per_page", "3")
+	resp, body := w.post("/preferences", form, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resultLink := regexp.MustCompile(`<a href="/view/(Match-\d)">`)
+	var seen []string
+	for page, n := range []int{3, 3, 1} {
+		resp, body := w.get("/search?q=needle&page="+strconv.Itoa(page+1), alice)
+		wantStatus(t, resp, body, http.StatusOK)
+		if !strings.Contains(body, "<p>7 results</p>") {
+			t.Errorf("page %d does not report 7 results:\n%s", page+1, body)
+		}
+		links := resultLink.FindAllStringSubmatch(body, -1)
+		if len(links) != n {
+			t.Errorf("page %d shows %d results, want %d", page+1, len(links), n)
+		}
+		for _, m := range links {
+			seen = append(seen, m[1])
+		}
+	}
+	slices.Sort(seen)
+	if !slices.Equal(seen, want) {
+		t.Errorf("pages showed %v, want each match once: %v", seen, want)
+	}
+
+	if _, body := w.get("/search?q=haystack", alice); !strings.Contains(body, "<p>8 results</p>") {
+		t.Errorf("total for a query matching every page:\n%s", body)
+	}
+	if _, body := w.get("/search?q=needle&page=4", alice); resultLink.MatchString(body) {
+		t.Errorf("page past the end shows results:\n%s", body)
+	}
+
+	resp, body = w.get("/api/search?q=needle&limit=2&offset=6")
+	wantStatus(t, resp, body, http.StatusOK)
+	var res struct {
+		Total   int
+		Results []apiSearchResult
+	}
+	if err := json.Unmarshal([]byte(body), &res); err != nil || res.Total != 7 || len(res.Results) != 1 {
+		t.Errorf("API search = %s, %v", body, err)
+	}
+}
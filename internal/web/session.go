@@ -0,0 +1,165 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookieName = "session"
+	sessionMaxAge     = 7 * 24 * time.Hour
+
+	// sessionTouchInterval limits how often the idle expiry is slid forward,
+	// so an active user does not get a fresh cookie on every request.
+	sessionTouchInterval = time.Minute
+)
+
+// sessionIdleTimeout logs a user out after this long without a request. Zero
+// keeps sessions alive until sessionMaxAge.
+var sessionIdleTimeout time.Duration
+
+type session struct {
+	User    string `json:"user"`
+	Role    string `json:"role"`
+	Expires int64  `json:"exp"`
+	Seen    int64  `json:"seen,omitempty"`
+}
+
+func setupSessionIdleTimeout() error {
+	raw := os.Getenv("SESSION_IDLE_TIMEOUT")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid SESSION_IDLE_TIMEOUT %q", raw)
+	}
+	sessionIdleTimeout = d
+
+	return nil
+}
+
+func setSession(w http.ResponseWriter, user, role string) error {
+	now := time.Now()
+	return writeSession(w, session{
+		User:    user,
+		Role:    role,
+		Expires: now.Add(sessionMaxAge).Unix(),
+		Seen:    now.Unix(),
+	})
+}
+
+func writeSession(w http.ResponseWriter, s session) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    signValue(value),
+		Path:     pageURL("/"),
+		MaxAge:   int(time.Until(time.Unix(s.Expires, 0)).Seconds()),
+		HttpOnly: true,
+		Secure:   baseURL.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func clearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     pageURL("/"),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func currentSession(r *http.Request) (session, bool) {
+	s, err := readSession(r)
+	if err != nil || s.idle(time.Now()) {
+		return session{}, false
+	}
+
+	return s, true
+}
+
+// readSession returns the signed session from the cookie, checking only the
+// absolute expiry.
+func readSession(r *http.Request) (session, error) {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return session{}, err
+	}
+
+	value, err := verifyValue(c.Value)
+	if err != nil {
+		return session{}, err
+	}
+
+	var s session
+	if err := json.Unmarshal(value, &s); err != nil {
+		return session{}, err
+	}
+	if time.Now().Unix() > s.Expires {
+		return session{}, fmt.Errorf("session expired")
+	}
+
+	return s, nil
+}
+
+func (s session) idle(now time.Time) bool {
+	return sessionIdleTimeout > 0 && now.Sub(time.Unix(s.Seen, 0)) > sessionIdleTimeout
+}
+
+// withSessionIdle slides the idle expiry of the session on every request and
+// logs out sessions that sat unused past SESSION_IDLE_TIMEOUT.
+func withSessionIdle(next http.Handler) http.Handler {
+	if sessionIdleTimeout == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := readSession(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		switch {
+		case s.idle(now):
+			clearSession(w)
+			if !strings.HasPrefix(r.URL.Path, apiPathPrefix) {
+				addFlash(w, r, flash{Level: "error", Text: "Your session expired after a period of inactivity, please log in again"})
+			}
+		case now.Sub(time.Unix(s.Seen, 0)) >= sessionTouchInterval:
+			s.Seen = now.Unix()
+			if err := writeSession(w, s); err != nil {
+				slog.Error("error refreshing session", "user", s.User, "err", err)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func currentUser(r *http.Request) string {
+	s, _ := currentSession(r)
+	return s.User
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	clearSession(w)
+	addFlash(w, r, flash{Level: "success", Text: "Logged out"})
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idleSession is a session cookie for alice last used idle ago.
+func idleSession(t *testing.T, idle time.Duration) *http.Cookie {
+	t.Helper()
+	now := time.Now()
+	rec := httptest.NewRecorder()
+	s := session{User: "alice", Role: roleEditor, Expires: now.Add(sessionMaxAge).Unix(), Seen: now.Add(-idle).Unix()}
+	if err := writeSession(rec, s); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+// withIdleTimeout serves w's wiki with SESSION_IDLE_TIMEOUT set to d.
+func withIdleTimeout(t *testing.T, w *testWiki, d time.Duration) *testWiki {
+	t.Helper()
+	setGlobal(t, &sessionIdleTimeout, d)
+	ts := httptest.NewServer(newHandler(testServer, routes()))
+	t.Cleanup(ts.Close)
+	ts.Client().CheckRedirect = w.Client().CheckRedirect
+	return &testWiki{Server: ts, t: t, dir: w.dir}
+}
+
+func sessionCookie(resp *http.Response) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	w := withIdleTimeout(t, newTestWiki(t), 30*time.Minute)
+
+	// Used within the window, the session stays valid and its expiry slides.
+	resp, body := w.get("/preferences", idleSession(t, 10*time.Minute))
+	wantStatus(t, resp, body, http.StatusOK)
+	s, err := sessionOf(resp)
+	if err != nil || s.User != "alice" || time.Since(time.Unix(s.Seen, 0)) > time.Minute {
+		t.Errorf("refreshed session = %+v, %v", s, err)
+	}
+	resp, body = w.get("/preferences", idleSession(t, 10*time.Second))
+	wantStatus(t, resp, body, http.StatusOK)
+	if c := sessionCookie(resp); c != nil {
+		t.Errorf("session refreshed again within %v: %v", sessionTouchInterval, c)
+	}
+
+	// Idle past the window it is cleared and the user has to log in again.
+	resp, body = w.get("/preferences", idleSession(t, 31*time.Minute))
+	wantStatus(t, resp, body, http.StatusFound)
+	if resp.Header.Get("Location") != "/login" {
+		t.Errorf("idle session redirects to %q", resp.Header.Get("Location"))
+	}
+	if c := sessionCookie(resp); c == nil || c.MaxAge >= 0 {
+		t.Errorf("idle session cookie not cleared: %v", c)
+	}
+	_, body = w.get("/", resp.Cookies()...)
+	if !strings.Contains(body, "Your session expired after a period of inactivity") {
+		t.Errorf("logout is not explained:\n%s", body)
+	}
+
+	// Idle sessions cannot use the API either, and get no flash there.
+	resp, body = w.api(http.MethodPut, "/api/pages/Home", `{"body":"x"}`, idleSession(t, time.Hour))
+	if c := sessionCookie(resp); c == nil || c.MaxAge >= 0 {
+		t.Errorf("API request with an idle session kept the cookie: %d %s", resp.StatusCode, body)
+	}
+
+	// Without a timeout only the absolute expiry counts.
+	w = withIdleTimeout(t, w, 0)
+	resp, body = w.get("/preferences", idleSession(t, 24*time.Hour))
+	wantStatus(t, resp, body, http.StatusOK)
+}
+
+func TestSetupSessionIdleTimeout(t *testing.T) {
+	setGlobal(t, &sessionIdleTimeout, 0)
+	for _, raw := range []string{"soon", "-5m"} {
+		t.Setenv("SESSION_IDLE_TIMEOUT", raw)
+		if err := setupSessionIdleTimeout(); err == nil {
+			t.Errorf("SESSION_IDLE_TIMEOUT=%q accepted", raw)
+		}
+	}
+	t.Setenv("SESSION_IDLE_TIMEOUT", "45m")
+	if err := setupSessionIdleTimeout(); err != nil || sessionIdleTimeout != 45*time.Minute {
+		t.Errorf("setupSessionIdleTimeout = %v, %v", err, sessionIdleTimeout)
+	}
+}
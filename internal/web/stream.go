@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+	"golang.org/x/text/encoding"
+)
+
+const (
+	defaultLargePageSize = 2 << 20
+	sniffSize            = 4096
+)
+
+// largePageSize is the size above which pages are no longer rendered or
+// loaded into the editor, only offered for download.
+var largePageSize int64 = defaultLargePageSize
+
+func setupLargePages() error {
+	raw := os.Getenv("LARGE_PAGE_SIZE")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid LARGE_PAGE_SIZE %q", raw)
+	}
+	largePageSize = n
+
+	return nil
+}
+
+func statPage(title string) (os.FileInfo, error) {
+	return store.Stat(title)
+}
+
+func isLargePage(title string) (int64, bool) {
+	fi, err := statPage(title)
+	if err != nil {
+		return 0, false
+	}
+
+	return fi.Size(), fi.Size() > largePageSize
+}
+
+type pageReader struct {
+	io.Reader
+	file storage.File
+}
+
+func (p *pageReader) Close() error {
+	return p.file.Close()
+}
+
+// openPage streams a page without reading it into memory. The returned size
+// is -1 when the content is transcoded and its length is unknown up front.
+// Only the beginning of the file is checked for UTF-8, unlike loadPage.
+func openPage(title string, enc encoding.Encoding) (io.ReadCloser, int64, error) {
+	f, err := store.Open(title)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	if enc == nil {
+		return f, fi.Size(), nil
+	}
+
+	br := bufio.NewReaderSize(f, sniffSize)
+	head, _ := br.Peek(sniffSize)
+	if utf8Prefix(head) {
+		return &pageReader{br, f}, fi.Size(), nil
+	}
+
+	return &pageReader{enc.NewDecoder().Reader(br), f}, -1, nil
+}
+
+// pageETag changes whenever the page file does, without reading it.
+func pageETag(fi os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
+}
+
+// notModified sets the validators for fi and answers 304 when the client's
+// copy is current.
+func notModified(w http.ResponseWriter, r *http.Request, fi os.FileInfo) bool {
+	etag := pageETag(fi)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match != etag && match != "*" {
+			return false
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || fi.ModTime().Truncate(time.Second).After(t) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// contentDisposition names an attachment. The plain filename is the ASCII
+// fallback, filename* carries the full name for clients that read RFC 5987.
+func contentDisposition(fallback, name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || strings.ContainsRune(`/\"`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	v := `attachment; filename="` + fallback + `.md"`
+	if name != fallback+".md" {
+		v += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return v
+}
+
+// encodeExtValue percent-encodes everything but the RFC 5987 attr-chars.
+func encodeExtValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestLargePages(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &largePageSize, 64)
+	large := "# Big\n\n" + strings.Repeat("line of text\n", 10)
+	w.seed("Big", large)
+	w.seed("Small", "# Small")
+
+	resp, body := w.get("/view/Big")
+	wantStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, "too large to display") || !strings.Contains(body, `href="/download/Big"`) || strings.Contains(body, "line of text") {
+		t.Errorf("large page view:\n%s", body)
+	}
+	if _, body := w.get("/view/Small"); strings.Contains(body, "too large") || !strings.Contains(body, ">Small</h1>") {
+		t.Errorf("small page not rendered:\n%s", body)
+	}
+
+	resp, body = w.get("/edit/Big")
+	wantStatus(t, resp, body, http.StatusRequestEntityTooLarge)
+	if !strings.Contains(body, "too large to edit in the browser") || strings.Contains(body, "<textarea") {
+		t.Errorf("large page editor:\n%s", body)
+	}
+
+	// The source is always served in full.
+	for _, path := range []string{"/raw/Big", "/download/Big"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusOK)
+		if body != large || resp.Header.Get("Content-Length") != strconv.Itoa(len(large)) {
+			t.Errorf("%s = %d bytes, Content-Length %s", path, len(body), resp.Header.Get("Content-Length"))
+		}
+	}
+}
+
+func TestOpenPage(t *testing.T) {
+	newTestWiki(t)
+	if err := store.Write("Legacy", windows1251(t, cyrillicText)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write("Modern", []byte(cyrillicText)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		title string
+		size  int64
+	}{
+		{"Modern", int64(len(cyrillicText))},
+		// Transcoded pages change length, so it is not known up front.
+		{"Legacy", -1},
+	} {
+		rc, size, err := openPage(tt.title, charmap.Windows1251)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(b) != cyrillicText || size != tt.size {
+			t.Errorf("openPage(%s) = %q, %d, %v, want the UTF-8 text and size %d", tt.title, b, size, err, tt.size)
+		}
+	}
+
+	if _, _, err := openPage("Missing", nil); err == nil {
+		t.Error("openPage of a missing page succeeded")
+	}
+}
+
+func TestSetupLargePages(t *testing.T) {
+	setGlobal(t, &largePageSize, defaultLargePageSize)
+
+	t.Setenv("LARGE_PAGE_SIZE", "1024")
+	if err := setupLargePages(); err != nil || largePageSize != 1024 {
+		t.Errorf("LARGE_PAGE_SIZE=1024 gives %d, %v", largePageSize, err)
+	}
+	for _, raw := range []string{"0", "-1", "1MB"} {
+		t.Setenv("LARGE_PAGE_SIZE", raw)
+		if err := setupLargePages(); err == nil {
+			t.Errorf("LARGE_PAGE_SIZE=%q accepted", raw)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	for _, tt := range []struct {
+		fallback, name, want string
+	}{
+		{"Home", "Home.md", `attachment; filename="Home.md"`},
+		{"Release-notes", "Release notes.md", `attachment; filename="Release-notes.md"; filename*=UTF-8''Release%20notes.md`},
+		{"Pelmeni", "Пельмени.md", `attachment; filename="Pelmeni.md"; filename*=UTF-8''%D0%9F%D0%B5%D0%BB%D1%8C%D0%BC%D0%B5%D0%BD%D0%B8.md`},
+		// Quotes, slashes and control characters never reach the header.
+		{"Odd", "a/b\\c\"d\ne.md", `attachment; filename="Odd.md"; filename*=UTF-8''a_b_c_d_e.md`},
+	} {
+		if got := contentDisposition(tt.fallback, tt.name); got != tt.want {
+			t.Errorf("contentDisposition(%q, %q) = %q, want %q", tt.fallback, tt.name, got, tt.want)
+		}
+	}
+	if got := encodeExtValue("a-b_c.d~e!#$&+^`|%'*() "); got != "a-b_c.d~e!#$&+^`|%25%27%2A%28%29%20" {
+		t.Errorf("encodeExtValue = %q", got)
+	}
+}
+
+func TestDownloadConditional(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	if err := setDisplayTitle("Home", "Дом"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := w.get("/download/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename="Home.md"; filename*=UTF-8''%D0%94%D0%BE%D0%BC.md` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("no validators: %v", resp.Header)
+	}
+
+	conditional := func(method, header, value string) (*http.Response, string) {
+		req, err := http.NewRequest(method, w.URL+"/download/Home", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return w.do(req)
+	}
+	for _, tt := range []struct {
+		header, value string
+		status        int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", "*", http.StatusNotModified},
+		{"If-None-Match", `"stale"`, http.StatusOK},
+		{"If-Modified-Since", modified, http.StatusNotModified},
+		{"If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT", http.StatusOK},
+		{"If-Modified-Since", "yesterday", http.StatusOK},
+	} {
+		resp, body := conditional(http.MethodGet, tt.header, tt.value)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: %s = %d, want %d", tt.header, tt.value, resp.StatusCode, tt.status)
+		}
+		if tt.status == http.StatusNotModified && body != "" {
+			t.Errorf("304 has a body: %q", body)
+		}
+	}
+
+	resp, body = conditional(http.MethodHead, "", "")
+	wantStatus(t, resp, body, http.StatusOK)
+	if body != "" || resp.Header.Get("Content-Length") != "4" || resp.Header.Get("Content-Disposition") == "" {
+		t.Errorf("HEAD = %q, %v", body, resp.Header)
+	}
+}
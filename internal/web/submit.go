@@ -0,0 +1,69 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// editOutcome is what became of a submitted edit.
+type editOutcome struct {
+	queued bool
+	merged bool
+	// confirm is set when abuse rules warned and the editor has to confirm
+	// the edit to save it.
+	confirm bool
+}
+
+// submitEdit is the one path an edit takes from the edit form, the API and
+// reverts alike: the blocklist and rate limit, validation, the required
+// summary, merging with changes made since revision base (when base is set),
+// the abuse rules, and finally the moderation queue for anonymous editors or
+// the save itself. confirmed acknowledges abuse rule warnings.
+func submitEdit(r *http.Request, param string, p *page.Page, base string, confirmed bool) (editOutcome, error) {
+	var out editOutcome
+
+	if err := admitWrite(r); err != nil {
+		return out, err
+	}
+	if err := validateSave(param, p); err != nil {
+		return out, err
+	}
+	if config.RequireSummary && p.Summary == "" && !apiIsAdmin(r) {
+		return out, &formError{http.StatusBadRequest, "Please describe your change in the summary"}
+	}
+	if base != "" {
+		id, _ := strconv.Atoi(base)
+		merged, err := resolveConflict(param, id, p)
+		if err != nil {
+			return out, err
+		}
+		out.merged = merged
+	}
+
+	abuse := checkAbuseRules(p.Body, true)
+	if abuse.block != nil {
+		return out, &formError{http.StatusUnprocessableEntity, abuse.block.Message}
+	}
+	if len(abuse.warnings) > 0 && !confirmed {
+		out.confirm = true
+		return out, &formError{http.StatusUnprocessableEntity, strings.Join(abuse.warnings, "; ")}
+	}
+
+	if heldForReview(r) && p.Editor == "" {
+		if err := queueEdit(param, p, clientIP(r).String()); err != nil {
+			return out, err
+		}
+		out.queued = true
+	} else if err := savePage(p); err != nil {
+		return out, err
+	}
+
+	if len(abuse.flags) > 0 {
+		flagEdit(p, abuse.flags)
+	}
+
+	return out, nil
+}
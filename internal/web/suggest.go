@@ -0,0 +1,132 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSuggestThreshold = 0.75
+	maxSuggestions          = 5
+)
+
+// suggestThreshold is how similar an existing title has to be to a missing
+// one to be offered instead of creating the page. Zero turns it off.
+var suggestThreshold = defaultSuggestThreshold
+
+type suggestion struct {
+	Title string
+	score float64
+}
+
+type missingData struct {
+	Title       string
+	Suggestions []suggestion
+	CanCreate   bool
+}
+
+func setupSuggestions() error {
+	raw := os.Getenv("SUGGEST_THRESHOLD")
+	if raw == "" {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil || f < 0 || f > 1 {
+		return fmt.Errorf("invalid SUGGEST_THRESHOLD %q, expected a number from 0 to 1", raw)
+	}
+	suggestThreshold = f
+
+	return nil
+}
+
+// titleSimilarity is 1 for titles equal up to case and falls towards 0 with
+// the edit distance. A title containing the other one scores at least 0.8,
+// so "Gopher" suggests "GopherNotes".
+func titleSimilarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+
+	score := 1 - float64(editDistance(a, b))/float64(longest)
+	if min(len(a), len(b)) >= 4 && (strings.Contains(a, b) || strings.Contains(b, a)) {
+		score = max(score, 0.8)
+	}
+
+	return score
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func suggestTitles(missing string) []suggestion {
+	if suggestThreshold == 0 {
+		return nil
+	}
+
+	titles, err := listPages()
+	if err != nil {
+		return nil
+	}
+
+	var found []suggestion
+	for _, title := range titles {
+		if score := titleSimilarity(missing, title); score >= suggestThreshold {
+			found = append(found, suggestion{Title: title, score: score})
+		}
+	}
+
+	slices.SortStableFunc(found, func(a, b suggestion) int {
+		if a.score != b.score {
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Title, b.Title)
+	})
+
+	return found[:min(len(found), maxSuggestions)]
+}
+
+// renderMissing answers a view of a page that does not exist with similar
+// titles, if there are any. It is sent as a 404 so the interstitial is never
+// indexed as content.
+func renderMissing(w http.ResponseWriter, r *http.Request, param string, suggestions []suggestion) {
+	data := pageData{
+		Title:  "Page " + param + " does not exist",
+		Status: http.StatusNotFound,
+		Content: &missingData{
+			Title:       param,
+			Suggestions: suggestions,
+			CanCreate:   canEdit(r),
+		},
+	}
+
+	renderTemplate(w, r, data, "missing")
+}
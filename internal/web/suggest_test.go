@@ -0,0 +1,89 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	for _, tt := range []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"Home", "home", 1, 1},
+		{"Gopher", "GopherNotes", 0.8, 0.8},
+		{"Deploy", "Deplyo", 0.6, 0.7},
+		{"Go", "Gone", 0.5, 0.5},
+		{"Lunch", "Deploy", 0, 0.2},
+	} {
+		if got := titleSimilarity(tt.a, tt.b); got < tt.min || got > tt.max {
+			t.Errorf("titleSimilarity(%q, %q) = %v, want %v to %v", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+	if d := editDistance("kitten", "sitting"); d != 3 {
+		t.Errorf("editDistance = %d, want 3", d)
+	}
+}
+
+func TestMissingPageSuggestions(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Deployment", "how to deploy")
+	w.seed("Deployments", "history")
+	w.seed("Lunch", "sandwiches")
+
+	if got := suggestTitles("Deploymen"); !slices.Equal(titlesOfSuggestions(got), []string{"Deployment", "Deployments"}) {
+		t.Errorf("suggestions = %+v", got)
+	}
+
+	resp, body := w.get("/view/Deploymen")
+	wantStatus(t, resp, body, http.StatusNotFound)
+	if !strings.Contains(body, `href="/view/Deployment"`) || strings.Contains(body, `href="/view/Lunch"`) ||
+		!strings.Contains(body, `href="/view/Deploymen?create=1"`) {
+		t.Errorf("interstitial:\n%s", body)
+	}
+
+	// Asked for explicitly, or without close matches, the editor opens.
+	for _, path := range []string{"/view/Deploymen?create=1", "/view/Breakfast"} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusFound)
+		if loc := resp.Header.Get("Location"); !strings.HasPrefix(loc, "/edit/") {
+			t.Errorf("%s redirected to %q", path, loc)
+		}
+	}
+
+	// Visitors who may not create the page are not offered to.
+	config.AllowAnonymousEdit = false
+	if _, body := w.get("/view/Deploymen"); strings.Contains(body, "create=1") {
+		t.Errorf("interstitial offers creating the page to an anonymous visitor:\n%s", body)
+	}
+
+	setGlobal(t, &suggestThreshold, 0)
+	if got := suggestTitles("Deploymen"); got != nil {
+		t.Errorf("suggestions with the threshold at 0 = %+v", got)
+	}
+}
+
+func TestSetupSuggestions(t *testing.T) {
+	setGlobal(t, &suggestThreshold, defaultSuggestThreshold)
+
+	t.Setenv("SUGGEST_THRESHOLD", "0.5")
+	if err := setupSuggestions(); err != nil || suggestThreshold != 0.5 {
+		t.Errorf("SUGGEST_THRESHOLD=0.5 gives %v, %v", suggestThreshold, err)
+	}
+	for _, raw := range []string{"-0.1", "1.5", "high"} {
+		t.Setenv("SUGGEST_THRESHOLD", raw)
+		if err := setupSuggestions(); err == nil {
+			t.Errorf("SUGGEST_THRESHOLD=%q accepted", raw)
+		}
+	}
+}
+
+func titlesOfSuggestions(suggestions []suggestion) []string {
+	var titles []string
+	for _, s := range suggestions {
+		titles = append(titles, s.Title)
+	}
+	return titles
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// tagIndex maps every page to the tags listed in its front matter.
+type tagIndex struct {
+	mu   sync.RWMutex
+	tags map[string][]string
+}
+
+var pageTags = &tagIndex{tags: map[string][]string{}}
+
+// parseTags accepts both "a, b" and "[a, b]" front matter values.
+func parseTags(raw string) []string {
+	raw = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(raw), "["), "]")
+
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.Trim(strings.TrimSpace(tag), `"'`)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func (t *tagIndex) update(title string) {
+	p, err := loadPage(title)
+	if err != nil {
+		t.remove(title)
+		return
+	}
+
+	tags := parseTags(page.ParseFrontMatter(p.Body)["tags"])
+	for i, tag := range tags {
+		tags[i] = strings.ToLower(tag)
+	}
+
+	t.mu.Lock()
+	t.tags[title] = tags
+	t.mu.Unlock()
+}
+
+func (t *tagIndex) remove(title string) {
+	t.mu.Lock()
+	delete(t.tags, title)
+	t.mu.Unlock()
+}
+
+func (t *tagIndex) rebuild() {
+	titles, err := listPages()
+	if err != nil {
+		slog.Error("error building tag index", "err", err)
+		return
+	}
+
+	fresh := &tagIndex{tags: map[string][]string{}}
+	for _, title := range titles {
+		fresh.update(title)
+	}
+
+	t.mu.Lock()
+	t.tags = fresh.tags
+	t.mu.Unlock()
+}
+
+// sharing counts, for every other page, how many tags it has in common with
+// title.
+func (t *tagIndex) sharing(title string) map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	shared := map[string]int{}
+	own := t.tags[title]
+	if len(own) == 0 {
+		return shared
+	}
+	for other, tags := range t.tags {
+		if other == title {
+			continue
+		}
+		for _, tag := range tags {
+			if slices.Contains(own, tag) {
+				shared[other]++
+			}
+		}
+	}
+
+	return shared
+}
+
+func (t *tagIndex) of(title string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return slices.Clone(t.tags[title])
+}
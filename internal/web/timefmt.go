@@ -0,0 +1,50 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultTimeFormat = "2006-01-02 15:04"
+
+// displayLocation and timeFormat decide how every timestamp on the wiki is
+// shown, from TZ and TIME_FORMAT (a Go reference time layout).
+var (
+	displayLocation = time.Local
+	timeFormat      = defaultTimeFormat
+)
+
+// setupTimeFormat checks TZ up front: Go quietly falls back to UTC for a
+// zone it cannot load.
+func setupTimeFormat() error {
+	if tz := os.Getenv("TZ"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid TZ %q: %w", tz, err)
+		}
+		displayLocation = loc
+	}
+
+	if layout := os.Getenv("TIME_FORMAT"); layout != "" {
+		if time.Unix(0, 0).Format(layout) == layout {
+			return fmt.Errorf("invalid TIME_FORMAT %q, expected a Go layout such as %q", layout, defaultTimeFormat)
+		}
+		timeFormat = layout
+	}
+
+	return nil
+}
+
+// formatTime shows t in the display timezone and format. The zero time is
+// shown as nothing.
+func formatTime(t time.Time) string {
+	return formatTimeIn(t, displayLocation)
+}
+
+func formatTimeIn(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format(timeFormat)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	setGlobal(t, &displayLocation, time.FixedZone("JST", 9*60*60))
+	ts := time.Date(2024, 3, 1, 20, 30, 0, 0, time.UTC)
+
+	if got := formatTime(ts); got != "2024-03-02 05:30" {
+		t.Errorf("default format = %q", got)
+	}
+	setGlobal(t, &timeFormat, "02.01.2006 15:04 MST")
+	if got := formatTime(ts); got != "02.03.2024 05:30 JST" {
+		t.Errorf("custom format = %q", got)
+	}
+	if got := formatTimeIn(ts, time.UTC); got != "01.03.2024 20:30 UTC" {
+		t.Errorf("formatTimeIn UTC = %q", got)
+	}
+	if got := formatTime(time.Time{}); got != "" {
+		t.Errorf("zero time = %q", got)
+	}
+}
+
+// Pages show their timestamps in the configured zone and format.
+func TestTimeFormatPages(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &displayLocation, time.FixedZone("JST", 9*60*60))
+	setGlobal(t, &timeFormat, "Jan 2, 2006 at 15:04 MST")
+	seedHistory(t, []changelogEntry{at("Home", 0, "alice")})
+	touchPage(t, "Home", changelogStart.Add(time.Hour))
+	resetState(t)
+
+	for path, want := range map[string]string{
+		"/history/Home": "Mar 1, 2024 at 18:00 JST",
+		"/changelog":    "Mar 1, 2024 at 18:00 JST",
+		"/":             "Mar 1, 2024 at 19:00 JST",
+	} {
+		resp, body := w.get(path)
+		wantStatus(t, resp, body, http.StatusOK)
+		if !strings.Contains(body, want) {
+			t.Errorf("%s does not show %q:\n%s", path, want, body)
+		}
+	}
+}
+
+func TestSetupTimeFormat(t *testing.T) {
+	setGlobal(t, &displayLocation, displayLocation)
+	setGlobal(t, &timeFormat, timeFormat)
+
+	t.Setenv("TZ", "Asia/Tokyo")
+	t.Setenv("TIME_FORMAT", "2006-01-02T15:04Z07:00")
+	if err := setupTimeFormat(); err != nil {
+		t.Fatal(err)
+	}
+	if got := formatTime(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)); got != "2024-03-01T18:00+09:00" {
+		t.Errorf("formatted = %q", got)
+	}
+
+	for key, raw := range map[string]string{"TZ": "Mars/Olympus", "TIME_FORMAT": "yyyy-mm-dd"} {
+		t.Setenv(key, raw)
+		if err := setupTimeFormat(); err == nil {
+			t.Errorf("%s=%q accepted", key, raw)
+		}
+		t.Setenv(key, "")
+	}
+}
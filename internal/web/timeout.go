@@ -0,0 +1,56 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHandlerTimeout = 30 * time.Second
+	timeoutMessage        = "The wiki took too long to answer this request. Please try again in a moment."
+)
+
+// handlerTimeout bounds how long a handler may take before the client gets a
+// 503. Zero disables the limit.
+var handlerTimeout = defaultHandlerTimeout
+
+// streamingPaths keep their connection as long as the transfer takes.
+// http.TimeoutHandler buffers responses and cannot flush, so it would break
+// them.
+var streamingPaths = []string{"/export", "/download/", "/raw/", "/admin/import"}
+
+func setupHandlerTimeout() error {
+	raw := os.Getenv("HANDLER_TIMEOUT")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid HANDLER_TIMEOUT %q", raw)
+	}
+	handlerTimeout = d
+
+	return nil
+}
+
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout == 0 {
+		return next
+	}
+
+	limited := http.TimeoutHandler(next, timeout, timeoutMessage)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range streamingPaths {
+			if r.URL.Path == path || strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		limited.ServeHTTP(w, r)
+	})
+}
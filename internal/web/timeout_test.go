@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			w.Write([]byte("too late"))
+		}
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	})
+	stream := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("streamed"))
+	}
+	mux.HandleFunc("/export", stream)
+	mux.HandleFunc("/download/", stream)
+	mux.HandleFunc("/exported", stream)
+	h := withTimeout(20*time.Millisecond, mux)
+
+	for _, tt := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/slow", http.StatusServiceUnavailable, timeoutMessage},
+		{"/fast", http.StatusOK, "fast"},
+		{"/export", http.StatusOK, "streamed"},
+		{"/download/Home.md", http.StatusOK, "streamed"},
+		// Only the streaming paths themselves are exempt.
+		{"/exported", http.StatusServiceUnavailable, timeoutMessage},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s = %d %q, want %d %q", tt.path, rec.Code, rec.Body, tt.code, tt.body)
+		}
+	}
+
+	// Without a timeout the slow handler runs to the end.
+	rec := httptest.NewRecorder()
+	withTimeout(0, http.HandlerFunc(stream)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "streamed" {
+		t.Errorf("without a timeout = %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestSetupHandlerTimeout(t *testing.T) {
+	setGlobal(t, &handlerTimeout, defaultHandlerTimeout)
+	for _, raw := range []string{"soon", "-1s"} {
+		t.Setenv("HANDLER_TIMEOUT", raw)
+		if err := setupHandlerTimeout(); err == nil {
+			t.Errorf("HANDLER_TIMEOUT=%q accepted", raw)
+		}
+	}
+	t.Setenv("HANDLER_TIMEOUT", "5s")
+	if err := setupHandlerTimeout(); err != nil || handlerTimeout != 5*time.Second {
+		t.Errorf("setupHandlerTimeout = %v, %v", err, handlerTimeout)
+	}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+const (
+	defaultTranscludeDepth = 3
+	defaultTranscludeMax   = 50
+)
+
+var (
+	// transcludeDepth is how many levels deep {{Page}} includes are
+	// followed. A page included through itself stops at the first repeat.
+	// Zero turns transclusion off.
+	transcludeDepth = defaultTranscludeDepth
+	// transcludeMax is how many includes one render follows in all, so a
+	// few pages that each include many others cannot blow up a render.
+	transcludeMax = defaultTranscludeMax
+)
+
+var transclusion = regexp.MustCompile(`\{\{([^{}|\n]+)\}\}`)
+
+func setupTransclusion() error {
+	for _, s := range []struct {
+		env string
+		v   *int
+	}{
+		{"TRANSCLUDE_DEPTH", &transcludeDepth},
+		{"TRANSCLUDE_MAX", &transcludeMax},
+	} {
+		raw := os.Getenv(s.env)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q", s.env, raw)
+		}
+		*s.v = n
+	}
+
+	return nil
+}
+
+// transclusionBudget is what is left to include in one render: a number of
+// includes, and bytes of included text, which may add up to at most
+// MAX_PAGE_SIZE.
+type transclusionBudget struct {
+	includes int
+	bytes    int
+}
+
+// transclude replaces every {{Title}} in the Markdown source with the body
+// of that page, before rendering. Fenced code blocks and inline code are left
+// alone. stack holds the pages being included, outermost first.
+func transclude(body []byte, stack []string) []byte {
+	return (&transclusionBudget{includes: transcludeMax, bytes: maxPageSize}).expand(body, stack)
+}
+
+func (b *transclusionBudget) expand(body []byte, stack []string) []byte {
+	if transcludeDepth == 0 || !bytes.Contains(body, []byte("{{")) {
+		return body
+	}
+
+	lines := bytes.Split(body, []byte("\n"))
+	inFence := false
+	for i, line := range lines {
+		if trimmed := bytes.TrimSpace(line); bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")) {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !bytes.Contains(line, []byte("{{")) {
+			continue
+		}
+
+		// Odd segments between backticks are inline code.
+		segments := bytes.Split(line, []byte("`"))
+		for j := 0; j < len(segments); j += 2 {
+			segments[j] = transclusion.ReplaceAllFunc(segments[j], func(m []byte) []byte {
+				return b.include(string(m), stack)
+			})
+		}
+		lines[i] = bytes.Join(segments, []byte("`"))
+	}
+
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func (b *transclusionBudget) include(match string, stack []string) []byte {
+	title := strings.TrimSpace(match[2 : len(match)-2])
+	if !validTitle(title) {
+		return []byte(match)
+	}
+
+	switch {
+	case slices.Contains(stack, title):
+		return []byte("*" + title + " includes itself, not included again*")
+	case len(stack) > transcludeDepth:
+		return []byte("*" + title + " is nested too deep to include*")
+	case b.includes == 0:
+		return []byte("*" + title + " not included, the page includes too many others*")
+	}
+
+	p, err := loadPage(title)
+	if err != nil {
+		return []byte("*Missing page: [" + title + "](" + pageURL("/view/"+title) + ")*")
+	}
+
+	included := bytes.TrimSpace(page.StripFrontMatter(p.Body))
+	if len(included) > b.bytes {
+		return []byte("*" + title + " not included, the page includes too much text*")
+	}
+	b.includes--
+	b.bytes -= len(included)
+
+	return b.expand(included, append(stack[:len(stack):len(stack)], title))
+}
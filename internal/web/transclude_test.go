@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTransclude(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Footer", "---\ntags: shared\n---\n  footer text  \n")
+	w.seed("Nested", "before {{Footer}} after")
+	w.seed("Loop", "loop {{Loop}}")
+	w.seed("Ping", "ping {{Pong}}")
+	w.seed("Pong", "pong {{Ping}}")
+	for i, body := range []string{"1 {{Level-2}}", "2 {{Level-3}}", "3 {{Level-4}}", "4"} {
+		w.seed("Level-"+string(rune('1'+i)), body)
+	}
+
+	for _, tt := range []struct{ in, want string }{
+		{"{{Footer}}", "footer text"},
+		{"a {{ Nested }} b", "a before footer text after b"},
+		{"```\n{{Footer}}\n```\n`{{Footer}}` {{Footer}}", "```\n{{Footer}}\n```\n`{{Footer}}` footer text"},
+		{"{{Missing}}", "*Missing page: [Missing](/view/Missing)*"},
+		{"{{../x}} {{a|b}} {{}}", "{{../x}} {{a|b}} {{}}"},
+		// Loops stop at the first repeat, chains at the depth limit.
+		{"{{Loop}}", "loop *Loop includes itself, not included again*"},
+		{"{{Ping}}", "ping pong *Ping includes itself, not included again*"},
+		{"{{Home}}", "*Home includes itself, not included again*"},
+		{"{{Level-1}}", "1 2 3 *Level-4 is nested too deep to include*"},
+	} {
+		if got := string(transclude([]byte(tt.in), []string{"Home"})); got != tt.want {
+			t.Errorf("transclude(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	setGlobal(t, &transcludeDepth, 5)
+	if got := string(transclude([]byte("{{Level-1}}"), []string{"Home"})); got != "1 2 3 4" {
+		t.Errorf("with depth 5: %q", got)
+	}
+	transcludeDepth = 0
+	if got := string(transclude([]byte("{{Footer}}"), []string{"Home"})); got != "{{Footer}}" {
+		t.Errorf("with transclusion off: %q", got)
+	}
+
+	setGlobal(t, &basePath, "/wiki")
+	transcludeDepth = defaultTranscludeDepth
+	if got := string(transclude([]byte("{{Missing}}"), []string{"Home"})); got != "*Missing page: [Missing](/wiki/view/Missing)*" {
+		t.Errorf("missing page under BASE_PATH: %q", got)
+	}
+}
+
+// A few pages that each include many others stop at the include limit and
+// the size budget instead of multiplying.
+func TestTranscludeBudget(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Leaf", strings.Repeat("x", 10<<10))
+	w.seed("Fan-1", strings.Repeat("{{Leaf}}", 20))
+	w.seed("Fan-2", strings.Repeat("{{Fan-1}}", 20))
+	got := string(transclude([]byte(strings.Repeat("{{Fan-2}}", 20)), []string{"Home"}))
+	if leaves := strings.Count(got, strings.Repeat("x", 10<<10)); leaves == 0 || leaves > transcludeMax {
+		t.Errorf("%d leaves included, want at most %d", leaves, transcludeMax)
+	}
+	if len(got) > maxPageSize+1<<10 || !strings.Contains(got, "*Fan-1 not included, the page includes too many others*") {
+		t.Errorf("expanded to %d bytes", len(got))
+	}
+
+	setGlobal(t, &maxPageSize, 25<<10)
+	got = string(transclude([]byte("{{Fan-1}}"), []string{"Home"}))
+	if leaves := strings.Count(got, strings.Repeat("x", 10<<10)); leaves != 2 || !strings.Contains(got, "*Leaf not included, the page includes too much text*") {
+		t.Errorf("%d leaves within a 25 KiB budget:\n%.200s", leaves, got)
+	}
+}
+
+func TestTransclusionRendering(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Notice", "**Read the [[Rules]] first.**")
+	w.seed("Home", "# Home\n\n{{Notice}}\n\nand {{Home}}")
+
+	resp, body := w.get("/view/Home")
+	wantStatus(t, resp, body, http.StatusOK)
+	for _, want := range []string{
+		"<p><strong>Read the",
+		"<p>and <em>Home includes itself, not included again</em></p>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("view lacks %s:\n%s", want, body)
+		}
+	}
+	// Changing the included page changes every page that includes it.
+	w.seed("Notice", "Closed for the holidays.")
+	if _, body := w.get("/view/Home"); !strings.Contains(body, "<p>Closed for the holidays.</p>") {
+		t.Errorf("view after editing the included page:\n%s", body)
+	}
+
+	// The source keeps the include, not the included text.
+	if _, raw := w.get("/raw/Home"); !strings.Contains(raw, "{{Notice}}") {
+		t.Errorf("raw = %q", raw)
+	}
+}
+
+func TestSetupTransclusion(t *testing.T) {
+	setGlobal(t, &transcludeDepth, defaultTranscludeDepth)
+	for _, raw := range []string{"deep", "-1"} {
+		t.Setenv("TRANSCLUDE_DEPTH", raw)
+		if err := setupTransclusion(); err == nil {
+			t.Errorf("TRANSCLUDE_DEPTH=%q accepted", raw)
+		}
+	}
+	t.Setenv("TRANSCLUDE_DEPTH", "0")
+	if err := setupTransclusion(); err != nil || transcludeDepth != 0 {
+		t.Errorf("setupTransclusion = %v, %d", err, transcludeDepth)
+	}
+
+	setGlobal(t, &transcludeMax, defaultTranscludeMax)
+	t.Setenv("TRANSCLUDE_MAX", "many")
+	if err := setupTransclusion(); err == nil {
+		t.Error("TRANSCLUDE_MAX=many accepted")
+	}
+	t.Setenv("TRANSCLUDE_MAX", "10")
+	if err := setupTransclusion(); err != nil || transcludeMax != 10 {
+		t.Errorf("setupTransclusion = %v, %d", err, transcludeMax)
+	}
+}
@@ -0,0 +1,228 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+var trashRetention = defaultTrashRetention
+
+// setupTrash reads TRASH_RETENTION as a Go duration or a number of days
+// such as "30d". Zero keeps trashed pages until they are purged by hand.
+func setupTrash() error {
+	raw := os.Getenv("TRASH_RETENTION")
+	if raw == "" {
+		return nil
+	}
+
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid TRASH_RETENTION %q", raw)
+		}
+		trashRetention = time.Duration(n) * 24 * time.Hour
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid TRASH_RETENTION %q", raw)
+	}
+	trashRetention = d
+
+	return nil
+}
+
+type trashedPage struct {
+	Name    string
+	Title   string
+	Deleted time.Time
+}
+
+func trashDir() string {
+	return filepath.Join(config.StoragePath, ".trash")
+}
+
+// moveToTrash copies a page into the trash and removes it from the store.
+// Entries are named <title>.<unix nanoseconds>.txt so a page can be trashed
+// more than once.
+func moveToTrash(title string) error {
+	body, err := store.Read(title)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(trashDir(), 0750); err != nil {
+		return err
+	}
+
+	name := title + "." + strconv.FormatInt(time.Now().UnixNano(), 10) + ".txt"
+	if err := os.WriteFile(filepath.Join(trashDir(), name), body, 0600); err != nil {
+		return err
+	}
+
+	return store.Remove(title)
+}
+
+func parseTrashName(name string) (trashedPage, bool) {
+	rest, ok := strings.CutSuffix(name, ".txt")
+	if !ok {
+		return trashedPage{}, false
+	}
+
+	title, stamp, ok := strings.Cut(rest, ".")
+	if !ok || !validTitle(title) {
+		return trashedPage{}, false
+	}
+	nanos, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return trashedPage{}, false
+	}
+
+	return trashedPage{Name: name, Title: title, Deleted: time.Unix(0, nanos)}, true
+}
+
+func listTrash() ([]trashedPage, error) {
+	entries, err := os.ReadDir(trashDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var trashed []trashedPage
+	for _, entry := range entries {
+		if t, ok := parseTrashName(entry.Name()); ok {
+			trashed = append(trashed, t)
+		}
+	}
+
+	slices.SortFunc(trashed, func(a, b trashedPage) int {
+		return b.Deleted.Compare(a.Deleted)
+	})
+
+	return trashed, nil
+}
+
+func purgeTrash(now time.Time) error {
+	if trashRetention == 0 {
+		return nil
+	}
+
+	trashed, err := listTrash()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range trashed {
+		if now.Sub(t.Deleted) < trashRetention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(trashDir(), t.Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreFromTrash(t trashedPage) error {
+	if _, err := store.Stat(t.Title); err == nil {
+		return &formError{http.StatusConflict, "Page " + t.Title + " already exists"}
+	}
+
+	fn := filepath.Join(trashDir(), t.Name)
+	body, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	if err := store.Write(t.Title, body); err != nil {
+		return err
+	}
+	if err := os.Remove(fn); err != nil {
+		return err
+	}
+	hooks.runAfterSave(t.Title)
+
+	return nil
+}
+
+type trashData struct {
+	Pages     []trashedPage
+	Retention time.Duration
+	CanPurge  bool
+}
+
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	s, _ := currentSession(r)
+	if s.Role != roleEditor && s.Role != roleAdmin {
+		renderError(w, r, http.StatusForbidden, "Only editors can see deleted pages.")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		updateTrash(w, r, s.Role == roleAdmin)
+		return
+	}
+
+	trashed, err := listTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := pageData{
+		Title:   "Trash",
+		Content: &trashData{Pages: trashed, Retention: trashRetention, CanPurge: s.Role == roleAdmin},
+	}
+	renderTemplate(w, r, data, "trash")
+}
+
+func updateTrash(w http.ResponseWriter, r *http.Request, admin bool) {
+	t, ok := parseTrashName(r.FormValue("name"))
+	if !ok {
+		http.Error(w, "Invalid trash entry", http.StatusBadRequest)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "restore":
+		if err := restoreFromTrash(t); err != nil {
+			if _, ok := saveErrorStatus(err); !ok {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			addFlash(w, r, flash{Level: "error", Text: err.Error()})
+			http.Redirect(w, r, pageURL("/trash"), http.StatusFound)
+			return
+		}
+		addFlash(w, r, flash{Level: "success", Text: "Page " + t.Title + " restored"})
+	case "purge":
+		if !admin {
+			renderError(w, r, http.StatusForbidden, "Only admins can purge deleted pages.")
+			return
+		}
+		if err := adminCertError(r); err != nil {
+			renderAdminCertError(w, r, err)
+			return
+		}
+		if err := os.Remove(filepath.Join(trashDir(), t.Name)); err != nil && !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		addFlash(w, r, flash{Level: "success", Text: "Deleted copy of " + t.Title + " purged"})
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, pageURL("/trash"), http.StatusFound)
+}
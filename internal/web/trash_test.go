@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func TestSetupTrash(t *testing.T) {
+	setGlobal(t, &trashRetention, defaultTrashRetention)
+	for raw, want := range map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"0":   0,
+		"0d":  0,
+	} {
+		t.Setenv("TRASH_RETENTION", raw)
+		if err := setupTrash(); err != nil || trashRetention != want {
+			t.Errorf("TRASH_RETENTION=%q: %v, %v", raw, trashRetention, err)
+		}
+	}
+	for _, raw := range []string{"-1d", "-1h", "week", "1.5d"} {
+		t.Setenv("TRASH_RETENTION", raw)
+		if err := setupTrash(); err == nil {
+			t.Errorf("TRASH_RETENTION=%q accepted", raw)
+		}
+	}
+}
+
+// trashAt puts a copy of title in the trash as if it was deleted at when.
+func trashAt(t *testing.T, title string, when time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(trashDir(), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	name := title + "." + strconv.FormatInt(when.UnixNano(), 10) + ".txt"
+	if err := os.WriteFile(filepath.Join(trashDir(), name), []byte(title), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func trashedTitles(t *testing.T) []string {
+	t.Helper()
+	trashed, err := listTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, p := range trashed {
+		titles = append(titles, p.Title)
+	}
+	return titles
+}
+
+func TestPurgeTrash(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &trashRetention, 7*24*time.Hour)
+	now := time.Now()
+	trashAt(t, "Ancient", now.Add(-30*24*time.Hour))
+	trashAt(t, "Week", now.Add(-7*24*time.Hour))
+	trashAt(t, "Recent", now.Add(-6*24*time.Hour))
+	w.seed("Today", "today")
+	if err := deletePage(&page.Page{Title: "Today"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := purgeTrash(now); err != nil {
+		t.Fatal(err)
+	}
+	if got := trashedTitles(t); !slices.Equal(got, []string{"Today", "Recent"}) {
+		t.Errorf("trash after purging = %q, want Today and Recent", got)
+	}
+
+	// Without a retention nothing is purged automatically.
+	trashRetention = 0
+	if err := purgeTrash(now.Add(365 * 24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := trashedTitles(t); len(got) != 2 {
+		t.Errorf("trash with retention 0 = %q", got)
+	}
+}
+
+func TestTrashManualPurge(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := listTrash()
+	if err != nil || len(trashed) != 1 {
+		t.Fatalf("trash = %v, %v", trashed, err)
+	}
+	form := url.Values{"name": {trashed[0].Name}, "action": {"purge"}}
+
+	resp, body := w.post("/trash", form, w.login("alice", roleEditor))
+	wantStatus(t, resp, body, http.StatusForbidden)
+	resp, body = w.post("/trash", form)
+	wantStatus(t, resp, body, http.StatusForbidden)
+	if len(trashedTitles(t)) != 1 {
+		t.Fatal("purged without an admin")
+	}
+
+	resp, body = w.post("/trash", form, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := trashedTitles(t); len(got) != 0 {
+		t.Errorf("trash after a manual purge = %q", got)
+	}
+
+	form.Set("name", "../wiki.txt")
+	resp, body = w.post("/trash", form, w.login("root", roleAdmin))
+	wantStatus(t, resp, body, http.StatusBadRequest)
+}
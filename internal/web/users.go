@@ -0,0 +1,225 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPerPage = 50
+
+var (
+	themes  = []string{"light", "dark"}
+	locales = []string{"en", "ru"}
+)
+
+type preferences struct {
+	Theme      string `json:"theme"`
+	Locale     string `json:"locale"`
+	PerPage    int    `json:"per_page"`
+	WatchEdits bool   `json:"watch_edits"`
+	Email      string `json:"email,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	// NotifyMinor also sends notifications and digest entries for minor
+	// edits of watched pages.
+	NotifyMinor bool `json:"notify_minor,omitempty"`
+}
+
+type watch struct {
+	LastVisited time.Time `json:"last_visited"`
+	Deleted     bool      `json:"deleted,omitempty"`
+}
+
+type userRecord struct {
+	Name         string            `json:"name"`
+	Role         string            `json:"role,omitempty"`
+	PasswordHash string            `json:"password_hash,omitempty"`
+	Preferences  preferences       `json:"preferences"`
+	Watches      map[string]*watch `json:"watches,omitempty"`
+}
+
+var usersMu sync.Mutex
+
+type preferencesData struct {
+	preferences
+	Themes  []string
+	Locales []string
+	Digests []string
+	Errors  []string
+}
+
+func defaultPreferences() preferences {
+	return preferences{Theme: "light", Locale: "en", PerPage: defaultPerPage, Digest: digestOff}
+}
+
+func userFilename(name string) string {
+	return filepath.Join(config.StoragePath, ".users", url.PathEscape(name)+".json")
+}
+
+func loadUser(name string) (*userRecord, error) {
+	u := &userRecord{Name: name, Preferences: defaultPreferences()}
+
+	b, err := os.ReadFile(userFilename(name))
+	if os.IsNotExist(err) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// updateUser applies fn to the stored record of the named user and saves it.
+func updateUser(name string, fn func(u *userRecord)) error {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+
+	u, err := loadUser(name)
+	if err != nil {
+		return err
+	}
+
+	fn(u)
+
+	return u.save()
+}
+
+func listUsers() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(config.StoragePath, ".users", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		name, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func (u *userRecord) save() error {
+	b, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fn := userFilename(u.Name)
+	if err := os.MkdirAll(filepath.Dir(fn), 0750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, b, 0600)
+}
+
+// requestPreferences returns the logged-in user's preferences, or the defaults for anonymous visitors.
+func requestPreferences(r *http.Request) preferences {
+	name := currentUser(r)
+	if name == "" {
+		return defaultPreferences()
+	}
+
+	u, err := loadUser(name)
+	if err != nil {
+		return defaultPreferences()
+	}
+
+	return u.Preferences
+}
+
+func parsePreferences(r *http.Request) (preferences, []string) {
+	var errs []string
+	p := preferences{
+		Theme:       r.FormValue("theme"),
+		Locale:      r.FormValue("locale"),
+		WatchEdits:  r.FormValue("watch_edits") == "on",
+		Email:       strings.TrimSpace(r.FormValue("email")),
+		Digest:      r.FormValue("digest"),
+		NotifyMinor: r.FormValue("notify_minor") == "on",
+	}
+
+	if !slices.Contains(themes, p.Theme) {
+		errs = append(errs, "Unknown theme")
+	}
+	if !slices.Contains(locales, p.Locale) {
+		errs = append(errs, "Unknown language")
+	}
+
+	if !slices.Contains(digestFrequencies, p.Digest) {
+		errs = append(errs, "Unknown digest frequency")
+	}
+	if p.Email != "" {
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			errs = append(errs, "Invalid email address")
+		}
+	} else if p.Digest != digestOff {
+		errs = append(errs, "An email address is required for digests")
+	}
+
+	n, err := strconv.Atoi(r.FormValue("per_page"))
+	if err != nil || n < 1 || n > 500 {
+		errs = append(errs, "Items per page must be a number between 1 and 500")
+	}
+	p.PerPage = n
+
+	return p, errs
+}
+
+func preferencesHandler(w http.ResponseWriter, r *http.Request) {
+	name := currentUser(r)
+	if name == "" {
+		http.Redirect(w, r, pageURL("/login"), http.StatusFound)
+		return
+	}
+
+	u, err := loadUser(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content := &preferencesData{preferences: u.Preferences, Themes: themes, Locales: locales, Digests: digestFrequencies}
+	data := pageData{Title: "Preferences", Content: content}
+
+	if r.Method == http.MethodPost {
+		p, errs := parsePreferences(r)
+		if len(errs) > 0 {
+			content.preferences = p
+			content.Errors = errs
+			data.Status = http.StatusBadRequest
+			renderTemplate(w, r, data, "preferences")
+			return
+		}
+
+		err := updateUser(name, func(u *userRecord) {
+			u.Preferences = p
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		addFlash(w, r, flash{Level: "success", Text: "Preferences saved"})
+		http.Redirect(w, r, pageURL("/preferences"), http.StatusFound)
+		return
+	}
+
+	renderTemplate(w, r, data, "preferences")
+}
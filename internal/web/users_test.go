@@ -0,0 +1,118 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func validPreferences() url.Values {
+	return url.Values{"theme": {"light"}, "locale": {"en"}, "per_page": {"50"}, "digest": {digestOff}}
+}
+
+func indexLinks(body string) int {
+	return strings.Count(body, `<a href="/view/`)
+}
+
+// A saved items-per-page preference becomes the index's page size.
+func TestPreferencesPerPage(t *testing.T) {
+	w := newTestWiki(t)
+	for _, title := range []string{"A", "B", "C", "D", "E"} {
+		w.seed(title, title)
+	}
+	alice := w.login("alice", roleEditor)
+
+	if _, body := w.get("/", alice); indexLinks(body) != 5 {
+		t.Fatalf("index before the preference lists %d pages", indexLinks(body))
+	}
+
+	form := validPreferences()
+	form.Set("per_page", "2")
+	form.Set("theme", "dark")
+	form.Set("locale", "ru")
+	resp, body := w.post("/preferences", form, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	_, body = w.get("/", alice)
+	if indexLinks(body) != 2 || !strings.Contains(body, `href="/?page=2&per_page=2&`) {
+		t.Errorf("index with per_page 2 lists %d pages:\n%s", indexLinks(body), body)
+	}
+	if !strings.Contains(body, `<body class="theme-dark">`) || !strings.Contains(body, `<html lang="ru">`) {
+		t.Error("theme and locale preferences not applied")
+	}
+	if _, body = w.get("/?page=3", alice); indexLinks(body) != 1 || !strings.Contains(body, `href="/view/E"`) {
+		t.Errorf("third index page:\n%s", body)
+	}
+	// An explicit per_page still wins over the preference.
+	if _, body = w.get("/?per_page=4", alice); indexLinks(body) != 4 {
+		t.Errorf("?per_page=4 lists %d pages", indexLinks(body))
+	}
+
+	// Other users keep the default.
+	if _, body = w.get("/", w.login("bob", roleEditor)); indexLinks(body) != 5 {
+		t.Errorf("bob's index lists %d pages", indexLinks(body))
+	}
+	if _, body = w.get("/"); indexLinks(body) != 5 {
+		t.Errorf("anonymous index lists %d pages", indexLinks(body))
+	}
+}
+
+func TestPreferencesValidation(t *testing.T) {
+	w := newTestWiki(t)
+	alice := w.login("alice", roleEditor)
+
+	for _, tt := range []struct {
+		field, value, msg string
+	}{
+		{"theme", "neon", "Unknown theme"},
+		{"locale", "de", "Unknown language"},
+		{"per_page", "0", "between 1 and 500"},
+		{"per_page", "501", "between 1 and 500"},
+		{"per_page", "many", "between 1 and 500"},
+		{"digest", "hourly", "Unknown digest frequency"},
+		{"digest", digestDaily, "email address is required"},
+		{"email", "not an address", "Invalid email address"},
+	} {
+		form := validPreferences()
+		form.Set(tt.field, tt.value)
+		resp, body := w.post("/preferences", form, alice)
+		wantStatus(t, resp, body, http.StatusBadRequest)
+		if !strings.Contains(body, tt.msg) {
+			t.Errorf("%s=%q: form does not explain %q:\n%s", tt.field, tt.value, tt.msg, body)
+		}
+	}
+	if u, err := loadUser("alice"); err != nil || u.Preferences != defaultPreferences() {
+		t.Errorf("rejected preferences were stored: %+v, %v", u.Preferences, err)
+	}
+
+	// Anonymous visitors have no preferences to edit.
+	resp, body := w.get("/preferences")
+	wantStatus(t, resp, body, http.StatusFound)
+	if resp.Header.Get("Location") != "/login" {
+		t.Errorf("anonymous preferences redirect to %q", resp.Header.Get("Location"))
+	}
+}
+
+// Editors who ask to watch their edits get the page on their watchlist.
+func TestPreferencesWatchEdits(t *testing.T) {
+	w := newTestWiki(t)
+	alice := w.login("alice", roleEditor)
+	form := validPreferences()
+	form.Set("watch_edits", "on")
+	resp, body := w.post("/preferences", form, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"home"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if !isWatching("alice", "Home") {
+		t.Error("edited page not watched")
+	}
+
+	bob := w.login("bob", roleEditor)
+	resp, body = w.post("/save/Other", url.Values{"title": {"Other"}, "body": {"other"}}, bob)
+	wantStatus(t, resp, body, http.StatusFound)
+	if isWatching("bob", "Other") {
+		t.Error("page watched without the preference")
+	}
+}
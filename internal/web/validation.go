@@ -0,0 +1,163 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+const (
+	defaultMaxPageSize    = 1 << 20
+	defaultMaxTitleLength = 100
+)
+
+var (
+	maxPageSize    = defaultMaxPageSize
+	maxTitleLength = defaultMaxTitleLength
+)
+
+// formError is a save failure the user can fix by correcting the submitted form.
+type formError struct {
+	status int
+	msg    string
+}
+
+func (e *formError) Error() string {
+	return e.msg
+}
+
+func validateTitle(title string) error {
+	return page.ValidateTitle(title, maxTitleLength)
+}
+
+func validTitle(title string) bool {
+	return validateTitle(title) == nil
+}
+
+func setupLimits() error {
+	for _, limit := range []struct {
+		key   string
+		value *int
+	}{
+		{"MAX_PAGE_SIZE", &maxPageSize},
+		{"MAX_TITLE_LENGTH", &maxTitleLength},
+		{"MAX_CONCURRENT_RENDERS", &maxConcurrentRenders},
+	} {
+		raw := os.Getenv(limit.key)
+		if raw == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s %q", limit.key, raw)
+		}
+		*limit.value = n
+	}
+
+	if maxConcurrentRenders > 0 {
+		renderSlots = make(chan struct{}, maxConcurrentRenders)
+	}
+
+	return nil
+}
+
+func validateSave(param string, p *page.Page) error {
+	if err := validateTitle(p.Title); err != nil {
+		return &formError{http.StatusBadRequest, err.Error()}
+	}
+
+	if err := page.ValidateBody(p.Body); err != nil {
+		return &formError{http.StatusBadRequest, err.Error()}
+	}
+
+	if len(p.Body) > maxPageSize {
+		return &formError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Page is larger than %d bytes", maxPageSize)}
+	}
+
+	if p.Title != param {
+		_, err := store.Stat(p.Title)
+		if err == nil {
+			return &formError{http.StatusConflict, "Page " + p.Title + " already exists"}
+		}
+		if errors.Is(err, page.ErrUnsafeTitle) || errors.Is(err, storage.ErrOutsideRoot) {
+			return &formError{http.StatusBadRequest, err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// saveErrorStatus reports the status for save failures that should be shown on the edit form.
+func saveErrorStatus(err error) (int, bool) {
+	var formErr *formError
+	if errors.As(err, &formErr) {
+		return formErr.status, true
+	}
+
+	var policyErr *policyError
+	if errors.As(err, &policyErr) {
+		return http.StatusBadRequest, true
+	}
+
+	var limited *rateLimitError
+	if errors.As(err, &limited) {
+		return http.StatusTooManyRequests, true
+	}
+
+	if errors.Is(err, syscall.ENOSPC) {
+		return http.StatusInsufficientStorage, true
+	}
+
+	return 0, false
+}
+
+type validationProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validatePage runs every check a save would, without saving anything.
+func validatePage(title string, body []byte) []validationProblem {
+	problems := []validationProblem{}
+
+	if err := validateTitle(title); err != nil {
+		problems = append(problems, validationProblem{"title", err.Error()})
+	}
+	if err := page.ValidateBody(body); err != nil {
+		problems = append(problems, validationProblem{"body", err.Error()})
+	}
+	if len(body) > maxPageSize {
+		problems = append(problems, validationProblem{"body", fmt.Sprintf("Page is larger than %d bytes", maxPageSize)})
+	}
+	if err := checkBlocklist(body); err != nil {
+		problems = append(problems, validationProblem{"body", err.Error()})
+	}
+
+	abuse := checkAbuseRules(body, false)
+	if abuse.block != nil {
+		problems = append(problems, validationProblem{"body", abuse.block.Message})
+	}
+	for _, msg := range abuse.warnings {
+		problems = append(problems, validationProblem{"body", msg})
+	}
+
+	return problems
+}
+
+func validateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	problems := validatePage(slugTitle(r.FormValue("title")), []byte(r.FormValue("body")))
+	writeJSON(w, http.StatusOK, map[string][]validationProblem{"problems": problems})
+}
@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+// A save the editor can fix re-renders the edit form with what they typed;
+// only unexpected failures are a 500.
+func TestSaveErrorsKeepTheForm(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "original")
+	w.seed("Taken", "taken")
+	setGlobal(t, &maxPageSize, 64)
+	withHooks(t, func(h *hookRegistry) {
+		h.BeforeSave(func(title string, body []byte) ([]byte, error) {
+			switch string(body) {
+			case "disk full":
+				return nil, fmt.Errorf("writing page: %w", syscall.ENOSPC)
+			case "broken":
+				return nil, errors.New("storage exploded")
+			}
+			return body, nil
+		})
+	})
+
+	for _, tt := range []struct {
+		name        string
+		title, body string
+		status      int
+		msg         string
+	}{
+		{"title too long", strings.Repeat("a", 101), "my text", http.StatusBadRequest, "at most 100 characters"},
+		{"invalid UTF-8", "Home", "caf\xe9", http.StatusBadRequest, "UTF-8"},
+		{"too large", "Home", strings.Repeat("x", 65), http.StatusRequestEntityTooLarge, "larger than 64 bytes"},
+		{"rename onto an existing page", "Taken", "my text", http.StatusConflict, "Page Taken already exists"},
+		{"storage full", "Home", "disk full", http.StatusInsufficientStorage, "no space left"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := w.post("/save/Home", url.Values{"title": {tt.title}, "body": {tt.body}})
+			wantStatus(t, resp, body, tt.status)
+			if !strings.Contains(body, `class="flash flash-error"`) || !strings.Contains(body, tt.msg) {
+				t.Errorf("edit form does not explain %q:\n%s", tt.msg, body)
+			}
+			if !strings.Contains(body, `value="`+html.EscapeString(tt.title)+`"`) || !strings.Contains(body, html.EscapeString(strings.ToValidUTF8(tt.body, ""))) {
+				t.Errorf("edit form lost the submitted title or body:\n%s", body)
+			}
+		})
+	}
+
+	setGlobal(t, &config.RequireSummary, true)
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"my text"}})
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if !strings.Contains(body, "describe your change") || !strings.Contains(body, "my text") {
+		t.Errorf("missing summary form:\n%s", body)
+	}
+	config.RequireSummary = false
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"broken"}})
+	wantStatus(t, resp, body, http.StatusInternalServerError)
+
+	if b, _ := store.Read("Home"); string(b) != "original" {
+		t.Errorf("page = %q after failed saves", b)
+	}
+}
+
+func TestTitleLengthLimit(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &maxTitleLength, maxTitleLength)
+	setGlobal(t, &maxConcurrentRenders, maxConcurrentRenders)
+	setGlobal(t, &renderSlots, renderSlots)
+	t.Setenv("MAX_TITLE_LENGTH", "12")
+	if err := setupLimits(); err != nil {
+		t.Fatal(err)
+	}
+
+	atLimit, over := strings.Repeat("a", 12), strings.Repeat("b", 13)
+	resp, body := w.post("/save/"+atLimit, url.Values{"title": {atLimit}, "body": {"fits"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, err := store.Read(atLimit); err != nil || string(b) != "fits" {
+		t.Errorf("page at the limit = %q, %v", b, err)
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {over}, "body": {"too long"}})
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if !strings.Contains(body, "at most 12 characters") {
+		t.Errorf("edit form does not explain the limit:\n%s", body)
+	}
+	resp, body = w.api(http.MethodPut, "/api/pages/"+over, `{"body":"too long"}`)
+	wantStatus(t, resp, body, http.StatusBadRequest)
+	if _, err := store.Read(over); err == nil {
+		t.Error("a title over the limit was saved")
+	}
+
+	for _, raw := range []string{"0", "-5", "long"} {
+		t.Setenv("MAX_TITLE_LENGTH", raw)
+		if err := setupLimits(); err == nil {
+			t.Errorf("MAX_TITLE_LENGTH=%q accepted", raw)
+		}
+	}
+}
+
+func TestSaveBodyEncoding(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "original")
+
+	for _, body := range []string{"\xff\xfe\x00\x00", "caf\xe9", "PNG\r\n\x1a\n\x00\x00"} {
+		resp, respBody := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {body}})
+		wantStatus(t, resp, respBody, http.StatusBadRequest)
+		resp, respBody = w.api(http.MethodPut, "/api/pages/Home", `{"body":"`+strings.ReplaceAll(body, "\x00", `\u0000`)+`"}`)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("API save of %q = %d\n%s", body, resp.StatusCode, respBody)
+		}
+	}
+	if b, _ := store.Read("Home"); string(b) != "original" {
+		t.Errorf("page = %q after rejected saves", b)
+	}
+
+	text := "Привет, 世界! Ünïcödé ✓ 🎉\r\n\ttabbed"
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {text}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if b, _ := store.Read("Home"); string(b) != text {
+		t.Errorf("page = %q, want the multibyte text kept", b)
+	}
+	_, body = w.get("/view/Home")
+	if !strings.Contains(body, "Привет, 世界! Ünïcödé ✓ 🎉") {
+		t.Errorf("view does not show the text:\n%s", body)
+	}
+}
+
+func (w *testWiki) validate(title, body string) []validationProblem {
+	w.t.Helper()
+	resp, respBody := w.post("/validate", url.Values{"title": {title}, "body": {body}})
+	if resp.StatusCode != http.StatusOK {
+		w.t.Fatalf("validate = %d\n%s", resp.StatusCode, respBody)
+	}
+	var got struct {
+		Problems []validationProblem `json:"problems"`
+	}
+	if err := json.Unmarshal([]byte(respBody), &got); err != nil || got.Problems == nil {
+		w.t.Fatalf("validate response %s: %v", respBody, err)
+	}
+	return got.Problems
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	w := newTestWiki(t)
+	setGlobal(t, &maxPageSize, 64)
+	setBlocklist(t, "casino", "")
+
+	if got := w.validate("Home", "# Home\n\nA clean page."); len(got) != 0 {
+		t.Errorf("clean page problems = %+v", got)
+	}
+
+	long := strings.Repeat("x", maxTitleLength+1)
+	body := "online casino " + strings.Repeat("padding ", 8) + "\xff"
+	want := []validationProblem{
+		{"title", validateTitle(long).Error()},
+		{"body", "Page body is not valid UTF-8 text"},
+		{"body", fmt.Sprintf("Page is larger than %d bytes", maxPageSize)},
+		{"body", checkBlocklist([]byte(body)).Error()},
+	}
+	if got := w.validate(long, body); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %+v\nwant %+v", got, want)
+	}
+
+	// Nothing is written, and read-only mode does not get in the way.
+	if titles, _ := listPages(); len(titles) != 0 {
+		t.Errorf("validate saved pages %q", titles)
+	}
+	readOnly.Store(true)
+	if got := w.validate("Home", "ok"); len(got) != 0 {
+		t.Errorf("read-only problems = %+v", got)
+	}
+
+	resp, respBody := w.get("/validate")
+	wantStatus(t, resp, respBody, http.StatusMethodNotAllowed)
+}
+
+// Titles that name a path are refused by the save itself, whatever the
+// handler checked before calling it.
+func TestSaveRefusesPathTitles(t *testing.T) {
+	newTestWiki(t)
+
+	for _, title := range []string{"a/b", "..", ".hidden", `a\b`} {
+		if err := savePage(&page.Page{Title: title, Body: []byte("x")}); !errors.Is(err, page.ErrUnsafeTitle) {
+			t.Errorf("savePage(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+		if _, err := undoFilename(title); !errors.Is(err, page.ErrUnsafeTitle) {
+			t.Errorf("undoFilename(%q) = %v, want ErrUnsafeTitle", title, err)
+		}
+	}
+}
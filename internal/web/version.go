@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Set at build time with
+//
+//	go build -ldflags "-X github.com/AlexKvashin21/gowiki/internal/web.version=1.2.0 -X github.com/AlexKvashin21/gowiki/internal/web.commit=$(git rev-parse HEAD) -X github.com/AlexKvashin21/gowiki/internal/web.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = ""
+	commit    = ""
+	buildTime = ""
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// currentBuild fills whatever -ldflags left unset from the VCS stamp the go
+// tool embeds, and falls back to "dev" and "unknown" without one.
+func currentBuild() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildTime: buildTime}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = bi.GoVersion
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+
+	return info
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, currentBuild())
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func getVersion(t *testing.T, w *testWiki) buildInfo {
+	t.Helper()
+	resp, body := w.get("/version")
+	wantStatus(t, resp, body, http.StatusOK)
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+	var info buildInfo
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	return info
+}
+
+func TestVersion(t *testing.T) {
+	w := newTestWiki(t)
+
+	// Test binaries carry no version or VCS stamp.
+	want := buildInfo{Version: "dev", Commit: "unknown", BuildTime: "unknown", GoVersion: runtime.Version()}
+	if got := getVersion(t, w); got != want {
+		t.Errorf("unset build info = %+v, want %+v", got, want)
+	}
+
+	setGlobal(t, &version, "1.2.0")
+	setGlobal(t, &commit, "0123abc")
+	setGlobal(t, &buildTime, "2026-10-01T12:00:00Z")
+	want = buildInfo{Version: "1.2.0", Commit: "0123abc", BuildTime: "2026-10-01T12:00:00Z", GoVersion: runtime.Version()}
+	if got := getVersion(t, w); got != want {
+		t.Errorf("build info = %+v, want %+v", got, want)
+	}
+
+	resp, body := w.post("/version", nil)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+	if resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Errorf("Allow = %q", resp.Header.Get("Allow"))
+	}
+}
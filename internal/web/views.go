@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultViewFlushInterval = 30 * time.Second
+
+// viewCounter counts page views in memory and writes them out in batches,
+// so a popular page does not cost a disk write per view.
+type viewCounter struct {
+	mu      sync.Mutex
+	totals  map[string]int64
+	pending map[string]int64
+}
+
+var views = &viewCounter{totals: map[string]int64{}, pending: map[string]int64{}}
+
+func viewsFilename() string {
+	return filepath.Join(config.StoragePath, ".views.json")
+}
+
+func viewFlushInterval() (time.Duration, error) {
+	raw := os.Getenv("VIEW_FLUSH_INTERVAL")
+	if raw == "" {
+		return defaultViewFlushInterval, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid VIEW_FLUSH_INTERVAL %q", raw)
+	}
+
+	return d, nil
+}
+
+func (v *viewCounter) load() error {
+	b, err := os.ReadFile(viewsFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	totals := map[string]int64{}
+	if err := json.Unmarshal(b, &totals); err != nil {
+		return fmt.Errorf("reading %s: %w", viewsFilename(), err)
+	}
+
+	v.mu.Lock()
+	v.totals = totals
+	v.mu.Unlock()
+
+	return nil
+}
+
+// add counts one view and returns the total including unflushed views.
+func (v *viewCounter) add(title string) int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.pending[title]++
+	return v.totals[title] + v.pending[title]
+}
+
+func (v *viewCounter) remove(title string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.totals[title]; ok {
+		// Keep the deletion for the next flush.
+		v.pending[title] = -v.totals[title]
+		return
+	}
+	delete(v.pending, title)
+}
+
+// rename moves the views of a page to its new title.
+func (v *viewCounter) rename(from, to string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	n := v.totals[from] + v.pending[from]
+	if _, ok := v.totals[from]; ok {
+		v.pending[from] = -v.totals[from]
+	} else {
+		delete(v.pending, from)
+	}
+	if n > 0 {
+		v.pending[to] += n
+	}
+}
+
+// flush merges pending views into the totals and writes them out. Nothing is
+// written when there were no views since the last flush.
+func (v *viewCounter) flush() error {
+	v.mu.Lock()
+	if len(v.pending) == 0 {
+		v.mu.Unlock()
+		return nil
+	}
+	for title, n := range v.pending {
+		if v.totals[title]+n <= 0 {
+			delete(v.totals, title)
+			continue
+		}
+		v.totals[title] += n
+	}
+	v.pending = map[string]int64{}
+	b, err := json.Marshal(v.totals)
+	v.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := viewsFilename() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, viewsFilename())
+}
+
+// runViewFlusher flushes the views every interval until ctx is done. The
+// last flush on shutdown is left to the caller, after running requests have
+// finished.
+func runViewFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if readOnly.Load() {
+			continue
+		}
+		if err := views.flush(); err != nil {
+			slog.Error("error writing view counts", "err", err)
+		}
+	}
+}
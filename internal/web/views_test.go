@@ -0,0 +1,223 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+func flushedViews(t *testing.T) map[string]int64 {
+	t.Helper()
+	b, err := os.ReadFile(viewsFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var totals map[string]int64
+	if err := json.Unmarshal(b, &totals); err != nil {
+		t.Fatal(err)
+	}
+	return totals
+}
+
+func TestViewCounterBatching(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.seed("Old", "old")
+
+	for range 3 {
+		resp, body := w.get("/view/Home")
+		wantStatus(t, resp, body, http.StatusOK)
+	}
+	w.get("/view/Old")
+	if got := flushedViews(t); got != nil {
+		t.Errorf("views written before a flush: %v", got)
+	}
+	if views.count("Home") != 3 || views.count("Old") != 1 {
+		t.Errorf("counts = %d, %d", views.count("Home"), views.count("Old"))
+	}
+
+	if err := views.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := flushedViews(t); !maps.Equal(got, map[string]int64{"Home": 3, "Old": 1}) {
+		t.Errorf("flushed views = %v", got)
+	}
+
+	// A flush with nothing new leaves the file alone.
+	if err := os.Remove(viewsFilename()); err != nil {
+		t.Fatal(err)
+	}
+	if err := views.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(viewsFilename()); !os.IsNotExist(err) {
+		t.Errorf("idle flush wrote the file: %v", err)
+	}
+
+	// Renames and deletes reach the file with the next flush.
+	w.get("/view/Home")
+	resp, body := w.post("/rename/Home", map[string][]string{"newTitle": {"Start"}})
+	wantStatus(t, resp, body, http.StatusFound)
+	if err := deletePage(&page.Page{Title: "Old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := views.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := flushedViews(t); !maps.Equal(got, map[string]int64{"Start": 4}) {
+		t.Errorf("views after rename and delete = %v", got)
+	}
+
+	// Loading picks the totals back up.
+	views.mu.Lock()
+	views.totals = map[string]int64{}
+	views.mu.Unlock()
+	if err := views.load(); err != nil || views.count("Start") != 4 {
+		t.Errorf("loaded count = %d, %v", views.count("Start"), err)
+	}
+}
+
+func TestViewFlusher(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.get("/view/Home")
+	w.get("/view/Home")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runViewFlusher(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	waitFor(t, func() bool { return flushedViews(t)["Home"] == 2 })
+
+	cancel()
+	<-done
+	w.get("/view/Home")
+	time.Sleep(30 * time.Millisecond)
+	if got := flushedViews(t)["Home"]; got != 2 {
+		t.Errorf("stopped flusher still writes: %d", got)
+	}
+}
+
+func TestViewFlushInterval(t *testing.T) {
+	for raw, want := range map[string]time.Duration{"": defaultViewFlushInterval, "5s": 5 * time.Second} {
+		t.Setenv("VIEW_FLUSH_INTERVAL", raw)
+		if got, err := viewFlushInterval(); err != nil || got != want {
+			t.Errorf("VIEW_FLUSH_INTERVAL=%q: %v, %v", raw, got, err)
+		}
+	}
+	for _, raw := range []string{"0", "-1s", "often"} {
+		t.Setenv("VIEW_FLUSH_INTERVAL", raw)
+		if _, err := viewFlushInterval(); err == nil {
+			t.Errorf("VIEW_FLUSH_INTERVAL=%q accepted", raw)
+		}
+	}
+}
+
+// TestRunHelper is the wiki process that TestShutdownFlushes starts and
+// stops.
+func TestRunHelper(t *testing.T) {
+	dir := os.Getenv("GOWIKI_TEST_RUN_DIR")
+	if dir == "" {
+		t.Skip("only run by TestShutdownFlushes")
+	}
+	t.Chdir("../..")
+	cfg := Config{StoragePath: dir, ListenAddr: unixAddrPrefix + filepath.Join(dir, "wiki.sock"), AllowAnonymousEdit: true}
+	if err := Run(cfg, storage.NewFileStore(dir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Views and access log lines still in memory are written out when the wiki
+// is stopped.
+func TestShutdownFlushes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a wiki process")
+	}
+	dir := t.TempDir()
+	if err := storage.NewFileStore(dir).Write("Home", []byte("home")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunHelper$")
+	cmd.Env = append(os.Environ(), "GOWIKI_TEST_RUN_DIR="+dir, "VIEW_FLUSH_INTERVAL=1h", "ACCESS_LOG="+filepath.Join(dir, "access.log"))
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-exited
+	})
+
+	socket := filepath.Join(dir, "wiki.sock")
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", socket)
+		},
+	}}
+	waitFor(t, func() bool {
+		resp, err := client.Get("http://wiki/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err == nil
+	})
+	for range 3 {
+		resp, err := client.Get("http://wiki/view/Home")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".views.json")); !os.IsNotExist(err) {
+		t.Fatalf("views written before shutdown: %v", err)
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-exited:
+		exited <- err
+		if err != nil {
+			t.Fatalf("wiki exited with %v\n%s", err, stderr.String())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("wiki did not stop\n%s", stderr.String())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ".views.json"))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stderr.String())
+	}
+	if string(b) != `{"Home":3}` {
+		t.Errorf("views after shutdown = %s", b)
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "access.log"))
+	if err != nil || strings.Count(string(b), `"GET /view/Home HTTP/1.1" 200`) != 3 {
+		t.Errorf("access log after shutdown = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,331 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+type watchedPage struct {
+	Title    string
+	Modified time.Time
+	Unread   bool
+	Deleted  bool
+}
+
+type watchlistData struct {
+	Pages []watchedPage
+}
+
+// watchWebhook is posted to on every change of a watched page, from
+// WATCH_WEBHOOK_URL.
+var (
+	watchWebhook       string
+	watchWebhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+func setupWatchNotifications() error {
+	raw := os.Getenv("WATCH_WEBHOOK_URL")
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid WATCH_WEBHOOK_URL %q", raw)
+	}
+	watchWebhook = raw
+
+	return nil
+}
+
+func watchHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := currentUser(r)
+	if name == "" {
+		http.Redirect(w, r, pageURL("/login"), http.StatusFound)
+		return
+	}
+
+	watching := false
+	err := updateUser(name, func(u *userRecord) {
+		if _, ok := u.Watches[param]; ok {
+			delete(u.Watches, param)
+			return
+		}
+
+		if u.Watches == nil {
+			u.Watches = map[string]*watch{}
+		}
+		u.Watches[param] = &watch{LastVisited: time.Now()}
+		watching = true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := "Stopped watching " + param
+	if watching {
+		msg = "Watching " + param
+	}
+	addFlash(w, r, flash{Level: "success", Text: msg})
+	http.Redirect(w, r, pageURL("/view/"+param), http.StatusFound)
+}
+
+func isWatching(user, title string) bool {
+	if user == "" {
+		return false
+	}
+
+	u, err := loadUser(user)
+	if err != nil {
+		return false
+	}
+
+	_, ok := u.Watches[title]
+	return ok
+}
+
+func watchPage(user, title string) {
+	err := updateUser(user, func(u *userRecord) {
+		if _, ok := u.Watches[title]; ok {
+			return
+		}
+		if u.Watches == nil {
+			u.Watches = map[string]*watch{}
+		}
+		u.Watches[title] = &watch{LastVisited: time.Now()}
+	})
+	if err != nil {
+		slog.Error("error adding page to watchlist", "user", user, "title", title, "err", err)
+	}
+}
+
+// markVisited clears the unread indicator of a watched page for the user.
+func markVisited(user, title string) {
+	if !isWatching(user, title) {
+		return
+	}
+
+	err := updateUser(user, func(u *userRecord) {
+		if w, ok := u.Watches[title]; ok {
+			w.LastVisited = time.Now()
+		}
+	})
+	if err != nil {
+		slog.Error("error updating watchlist", "user", user, "title", title, "err", err)
+	}
+}
+
+// notifyWatchers tells the watchers of title about a change as it happens:
+// a mail to each one who chose instant notifications, and one webhook call
+// naming them all. Nobody is told about their own edit, and minor edits only
+// reach watchers who opted in to them. Sending happens in the background so
+// a slow mail server does not hold up the save.
+func notifyWatchers(title string) {
+	if watchWebhook == "" && digest == nil {
+		return
+	}
+
+	if entry, ok := latestChange(title); ok {
+		notifyChange(entry)
+	}
+}
+
+func notifyChange(entry digestEntry) {
+	title := entry.Title
+	names, err := listUsers()
+	if err != nil {
+		slog.Error("error listing users", "err", err)
+		return
+	}
+
+	var watchers, mails []string
+	for _, name := range names {
+		if name == entry.Editor {
+			continue
+		}
+		u, err := loadUser(name)
+		if err != nil {
+			continue
+		}
+		if _, ok := u.Watches[title]; !ok || !notifiedOf(u, entry) {
+			continue
+		}
+		watchers = append(watchers, name)
+		if digest != nil && u.Preferences.Digest == digestInstant && u.Preferences.Email != "" {
+			mails = append(mails, u.Preferences.Email)
+		}
+	}
+	if len(watchers) == 0 {
+		return
+	}
+
+	go func() {
+		for _, to := range mails {
+			if err := digest.send(to, []digestEntry{entry}); err != nil {
+				slog.Error("error sending change notification", "title", title, "err", err)
+			}
+		}
+		if watchWebhook != "" {
+			if err := postWatchWebhook(entry, watchers); err != nil {
+				slog.Error("error calling watch webhook", "title", title, "err", err)
+			}
+		}
+	}()
+}
+
+func postWatchWebhook(entry digestEntry, watchers []string) error {
+	b, err := json.Marshal(struct {
+		digestEntry
+		URL      string   `json:"url"`
+		Watchers []string `json:"watchers"`
+	}{entry, absoluteURL("/view/" + entry.Title), watchers})
+	if err != nil {
+		return err
+	}
+
+	resp, err := watchWebhookClient.Post(watchWebhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// moveWatches makes every watch of a renamed page follow it to the new title.
+func moveWatches(from, to string) {
+	names, err := listUsers()
+	if err != nil {
+		slog.Error("error listing users", "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if !isWatching(name, from) {
+			continue
+		}
+
+		err := updateUser(name, func(u *userRecord) {
+			if w, ok := u.Watches[from]; ok {
+				delete(u.Watches, from)
+				u.Watches[to] = w
+			}
+		})
+		if err != nil {
+			slog.Error("error updating watchlist", "user", name, "title", to, "err", err)
+		}
+	}
+}
+
+// notifyWatchersOfDelete flags the page as deleted in every watchlist that has it,
+// so each watcher sees the deletion once on their next watchlist visit, and
+// sends the deletion the way changes are sent: by mail, webhook and digest.
+func notifyWatchersOfDelete(title string) {
+	if watchWebhook != "" || digest != nil {
+		entry := digestEntry{Title: title, Time: time.Now().UTC(), Deleted: true}
+		notifyChange(entry)
+		if digest != nil {
+			queueDigestEntry(entry)
+		}
+	}
+
+	names, err := listUsers()
+	if err != nil {
+		slog.Error("error listing users", "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if !isWatching(name, title) {
+			continue
+		}
+
+		err := updateUser(name, func(u *userRecord) {
+			if w, ok := u.Watches[title]; ok {
+				w.Deleted = true
+			}
+		})
+		if err != nil {
+			slog.Error("error updating watchlist", "user", name, "title", title, "err", err)
+		}
+	}
+}
+
+func watchlistHandler(w http.ResponseWriter, r *http.Request) {
+	name := currentUser(r)
+	if name == "" {
+		http.Redirect(w, r, pageURL("/login"), http.StatusFound)
+		return
+	}
+
+	u, err := loadUser(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var pages []watchedPage
+	var deleted []string
+	for title, watch := range u.Watches {
+		fi, err := store.Stat(title)
+		if errors.Is(err, page.ErrUnsafeTitle) || errors.Is(err, storage.ErrOutsideRoot) {
+			continue
+		}
+
+		page := watchedPage{Title: title, Deleted: watch.Deleted}
+		if err == nil {
+			page.Modified = fi.ModTime()
+			page.Unread = fi.ModTime().After(watch.LastVisited)
+			page.Deleted = false
+		} else {
+			page.Unread = watch.Deleted
+		}
+		if page.Deleted {
+			deleted = append(deleted, title)
+		}
+		pages = append(pages, page)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Modified.After(pages[j].Modified)
+	})
+
+	if len(deleted) > 0 {
+		err := updateUser(name, func(u *userRecord) {
+			for _, title := range deleted {
+				delete(u.Watches, title)
+			}
+		})
+		if err != nil {
+			slog.Error("error updating watchlist", "user", name, "err", err)
+		}
+	}
+
+	data := pageData{
+		Title:   "Watchlist",
+		Content: &watchlistData{Pages: pages},
+	}
+
+	renderTemplate(w, r, data, "watchlist")
+}
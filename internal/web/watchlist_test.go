@@ -0,0 +1,293 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+)
+
+func TestWatchToggle(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	alice := w.login("alice", roleEditor)
+
+	resp, body := w.post("/watch/Home", nil, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if !isWatching("alice", "Home") || resp.Header.Get("Location") != "/view/Home" {
+		t.Fatalf("watch did not add Home, redirected to %q", resp.Header.Get("Location"))
+	}
+	w.post("/watch/Home", nil, alice)
+	if isWatching("alice", "Home") {
+		t.Error("second watch did not remove Home")
+	}
+
+	resp, body = w.post("/watch/Home", nil)
+	wantStatus(t, resp, body, http.StatusFound)
+	if resp.Header.Get("Location") != "/login" {
+		t.Errorf("anonymous watch redirects to %q", resp.Header.Get("Location"))
+	}
+	resp, body = w.get("/watch/Home", alice)
+	wantStatus(t, resp, body, http.StatusMethodNotAllowed)
+}
+
+func touchPage(t *testing.T, title string, mtime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(filepath.Join(config.StoragePath, title+".txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchlist(t *testing.T) {
+	w := newTestWiki(t)
+	for _, title := range []string{"Alpha", "Beta", "Gamma"} {
+		w.seed(title, title)
+	}
+	alice := w.login("alice", roleEditor)
+	for _, title := range []string{"Alpha", "Beta", "Gamma"} {
+		w.post("/watch/"+title, nil, alice)
+	}
+	past := time.Now().Add(-time.Hour)
+	touchPage(t, "Alpha", past.Add(-time.Minute))
+	touchPage(t, "Gamma", past)
+
+	// Beta changes after alice started watching it.
+	time.Sleep(10 * time.Millisecond)
+	resp, body := w.post("/save/Beta", url.Values{"title": {"Beta"}, "body": {"new beta"}}, w.login("bob", roleEditor))
+	wantStatus(t, resp, body, http.StatusFound)
+
+	_, body = w.get("/watchlist", alice)
+	beta, gamma, alpha := strings.Index(body, "/view/Beta"), strings.Index(body, "/view/Gamma"), strings.Index(body, "/view/Alpha")
+	if beta < 0 || !(beta < gamma && gamma < alpha) {
+		t.Errorf("watchlist not ordered by last change:\n%s", body)
+	}
+	if strings.Count(body, "(changed)") != 1 || !strings.Contains(body, `<b><a href="/view/Beta">Beta</a></b> (changed)`) {
+		t.Errorf("only Beta should be marked changed:\n%s", body)
+	}
+
+	w.get("/view/Beta", alice)
+	if _, body = w.get("/watchlist", alice); strings.Contains(body, "(changed)") {
+		t.Errorf("Beta still marked changed after a visit:\n%s", body)
+	}
+
+	resp, body = w.get("/watchlist")
+	wantStatus(t, resp, body, http.StatusFound)
+}
+
+func TestWatchesFollowRenameAndDelete(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	w.seed("Old", "old")
+	alice := w.login("alice", roleEditor)
+	w.post("/watch/Home", nil, alice)
+	w.post("/watch/Old", nil, alice)
+
+	resp, body := w.post("/rename/Old", url.Values{"newTitle": {"New"}}, alice)
+	wantStatus(t, resp, body, http.StatusFound)
+	if isWatching("alice", "Old") || !isWatching("alice", "New") {
+		t.Error("watch did not follow the rename")
+	}
+
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	_, body = w.get("/watchlist", alice)
+	if !strings.Contains(body, "<s>Home</s> was deleted") || !strings.Contains(body, "/view/New") {
+		t.Errorf("watchlist does not report the deletion:\n%s", body)
+	}
+	// The deletion is reported once.
+	_, body = w.get("/watchlist", alice)
+	if strings.Contains(body, "<s>Home</s>") || !strings.Contains(body, "/view/New") {
+		t.Errorf("watchlist after seeing the deletion:\n%s", body)
+	}
+}
+
+// webhookSink points WATCH_WEBHOOK_URL at a server that hands over the
+// payloads it receives.
+func webhookSink(t *testing.T) <-chan map[string]any {
+	t.Helper()
+	calls := make(chan map[string]any, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		calls <- payload
+	}))
+	t.Cleanup(hook.Close)
+	setGlobal(t, &watchWebhook, hook.URL)
+	return calls
+}
+
+func webhookWatchers(t *testing.T, calls <-chan map[string]any) []any {
+	t.Helper()
+	select {
+	case payload := <-calls:
+		watchers, _ := payload["watchers"].([]any)
+		return watchers
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+		return nil
+	}
+}
+
+func TestWatchWebhook(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	calls := webhookSink(t)
+
+	alice, bob := w.login("alice", roleEditor), w.login("bob", roleEditor)
+	w.login("carol", roleEditor)
+	w.post("/watch/Home", nil, alice)
+	w.post("/watch/Home", nil, bob)
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"bob's edit"}, "summary": {"tidy"}}, bob)
+	wantStatus(t, resp, body, http.StatusFound)
+
+	select {
+	case payload := <-calls:
+		watchers, _ := payload["watchers"].([]any)
+		if payload["title"] != "Home" || payload["editor"] != "bob" || payload["summary"] != "tidy" ||
+			!strings.HasSuffix(payload["url"].(string), "/view/Home") || !slices.Equal(watchers, []any{"alice"}) {
+			t.Errorf("webhook payload = %v, want alice told about bob's edit", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	// Once alice stops watching only the editor is left, and nobody is told.
+	w.post("/watch/Home", nil, alice)
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"again"}}, bob)
+	wantStatus(t, resp, body, http.StatusFound)
+	select {
+	case payload := <-calls:
+		t.Errorf("webhook called for the editor's own watch: %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// A deletion is sent once to every watcher, the same ways as a change.
+func TestDeleteNotifications(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	calls := webhookSink(t)
+	mails := withMail(t)
+	w.post("/watch/Home", nil, w.login("alice", roleEditor))
+	w.post("/watch/Home", nil, w.login("bob", roleEditor))
+	setPreferences(t, "alice", func(p *preferences) { p.Email, p.Digest = "alice@example.com", digestInstant })
+	setPreferences(t, "bob", func(p *preferences) { p.Email, p.Digest = "bob@example.com", digestDaily })
+
+	if err := deletePage(&page.Page{Title: "Home"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-calls:
+		watchers, _ := payload["watchers"].([]any)
+		if payload["title"] != "Home" || payload["deleted"] != true || !slices.Equal(watchers, []any{"alice", "bob"}) {
+			t.Errorf("webhook payload = %v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called for the deletion")
+	}
+	select {
+	case m := <-mails:
+		if !strings.Contains(m, "To: alice@example.com") || !strings.Contains(m, "Home was deleted") {
+			t.Errorf("deletion mail:\n%s", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail about the deletion")
+	}
+	if got := receivedMails(mails); got != nil {
+		t.Errorf("more mails about the deletion: %v", got)
+	}
+	entries, err := loadDigestEntries("bob")
+	if err != nil || len(entries) != 1 || !entries[0].Deleted || entries[0].Title != "Home" {
+		t.Errorf("bob's digest = %+v, %v", entries, err)
+	}
+	select {
+	case payload := <-calls:
+		t.Errorf("webhook called twice: %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Minor edits only reach the watchers who asked for them, by webhook, mail
+// and digest alike.
+func TestMinorEditNotifications(t *testing.T) {
+	w := newTestWiki(t)
+	w.seed("Home", "home")
+	calls := webhookSink(t)
+	mails := withMail(t)
+
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		w.post("/watch/Home", nil, w.login(name, roleEditor))
+	}
+	for name, prefs := range map[string]preferences{
+		"alice": {Email: "alice@example.com", Digest: digestInstant},
+		"bob":   {Email: "bob@example.com", Digest: digestDaily},
+		"carol": {Email: "carol@example.com", Digest: digestInstant, NotifyMinor: true},
+		"dave":  {Email: "dave@example.com", Digest: digestDaily, NotifyMinor: true},
+	} {
+		setPreferences(t, name, func(p *preferences) { *p = prefs })
+	}
+	eve := w.login("eve", roleEditor)
+
+	resp, body := w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"typo"}, "minor": {"on"}}, eve)
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := webhookWatchers(t, calls); !slices.Equal(got, []any{"carol", "dave"}) {
+		t.Errorf("minor edit webhook watchers = %v", got)
+	}
+	if got := receivedMails(mails); !slices.Equal(got, []string{"carol@example.com"}) {
+		t.Errorf("minor edit mailed to %v", got)
+	}
+	if digestTitles(t, "bob") != nil || !slices.Equal(digestTitles(t, "dave"), []string{"Home"}) {
+		t.Errorf("digests after a minor edit: bob %v, dave %v", digestTitles(t, "bob"), digestTitles(t, "dave"))
+	}
+
+	resp, body = w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"rewrite"}}, eve)
+	wantStatus(t, resp, body, http.StatusFound)
+	if got := webhookWatchers(t, calls); !slices.Equal(got, []any{"alice", "bob", "carol", "dave"}) {
+		t.Errorf("edit webhook watchers = %v", got)
+	}
+	got := receivedMails(mails)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"alice@example.com", "carol@example.com"}) {
+		t.Errorf("edit mailed to %v", got)
+	}
+	if !slices.Equal(digestTitles(t, "bob"), []string{"Home"}) {
+		t.Errorf("bob's digest = %v", digestTitles(t, "bob"))
+	}
+
+	// Nobody opted in: a minor edit calls no webhook at all.
+	setPreferences(t, "carol", func(p *preferences) { p.NotifyMinor = false })
+	setPreferences(t, "dave", func(p *preferences) { p.NotifyMinor = false })
+	w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"typo again"}, "minor": {"on"}}, eve)
+	select {
+	case payload := <-calls:
+		t.Errorf("webhook called for an unwanted minor edit: %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSetupWatchNotifications(t *testing.T) {
+	setGlobal(t, &watchWebhook, "")
+	for _, raw := range []string{"ftp://hooks.example.com", "hooks.example.com/x", "https://"} {
+		t.Setenv("WATCH_WEBHOOK_URL", raw)
+		if err := setupWatchNotifications(); err == nil {
+			t.Errorf("WATCH_WEBHOOK_URL=%q accepted", raw)
+		}
+	}
+	t.Setenv("WATCH_WEBHOOK_URL", "https://hooks.example.com/wiki")
+	if err := setupWatchNotifications(); err != nil || watchWebhook != "https://hooks.example.com/wiki" {
+		t.Errorf("setupWatchNotifications = %v, %q", err, watchWebhook)
+	}
+}
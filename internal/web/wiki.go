@@ -0,0 +1,1095 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+	"golang.org/x/text/encoding"
+)
+
+type pageData struct {
+	Title     string
+	Status    int
+	Canonical string
+	Content   interface{}
+}
+
+type indexData struct {
+	Items    []pageInfo
+	Sort     string
+	Order    string
+	IsAdmin  bool
+	ReadOnly bool
+	CanEdit  bool
+	Page     int
+	PerPage  int
+	PrevPage int
+	NextPage int
+}
+
+type viewData struct {
+	*page.Page
+	HTML        template.HTML
+	Protection  string
+	CanEdit     bool
+	CanUndo     bool
+	CanWatch    bool
+	Watching    bool
+	Views       int64
+	Related     []string
+	DownloadURL string
+	CSRF        string
+}
+
+type largeData struct {
+	Title string
+	Size  int64
+}
+
+type errorData struct {
+	Message string
+}
+
+type editData struct {
+	*page.Page
+	DisplayTitle string
+	Param        string
+	Protection   string
+	CanProtect   bool
+	Base         int
+	Error        string
+	Confirm      bool
+	HasDraft     bool
+	DraftEdited  time.Time
+}
+
+const shutdownTimeout = 10 * time.Second
+
+var validPath = regexp.MustCompile("^(?:/|/(view|edit|save|delete|undo|download|draft|discard|history|diff|watch|protect|revert|copy|rename|raw)/([a-zA-Z0-9]+(?:-[a-zA-Z0-9]+)*))$")
+
+func indexHandler(w http.ResponseWriter, r *http.Request, param string) {
+	files, err := listPageInfos()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	remembered := readIndexSort(r)
+	explicit := indexSort{Sort: r.FormValue("sort"), Order: r.FormValue("order")}
+	if n, err := strconv.Atoi(r.FormValue("per_page")); err == nil && n > 0 && n <= 500 {
+		explicit.PerPage = n
+	}
+	current := remembered.merge(explicit)
+	if explicit != (indexSort{}) {
+		rememberIndexSort(w, current)
+	}
+	if current.PerPage == 0 {
+		current.PerPage = requestPreferences(r).PerPage
+	}
+	sortBy, perPage := current.Sort, current.PerPage
+
+	var compare func(a, b pageInfo) int
+	switch sortBy {
+	case "size":
+		compare = func(a, b pageInfo) int { return cmp.Compare(a.Size, b.Size) }
+	case "modified":
+		compare = func(a, b pageInfo) int { return a.Modified.Compare(b.Modified) }
+	default:
+		compare = func(a, b pageInfo) int { return cmp.Compare(a.Title, b.Title) }
+	}
+	slices.SortStableFunc(files, func(a, b pageInfo) int {
+		if current.Order == "desc" {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+
+	pageNum := 1
+	if n, err := strconv.Atoi(r.FormValue("page")); err == nil && n > 0 {
+		pageNum = n
+	}
+
+	s, _ := currentSession(r)
+	content := &indexData{Page: pageNum, PerPage: perPage, Sort: sortBy, Order: current.Order, CanEdit: canEdit(r), IsAdmin: s.Role == roleAdmin, ReadOnly: readOnly.Load()}
+	start := min((pageNum-1)*perPage, len(files))
+	end := min(start+perPage, len(files))
+	content.Items = files[start:end]
+	if pageNum > 1 {
+		content.PrevPage = pageNum - 1
+	}
+	if end < len(files) {
+		content.NextPage = pageNum + 1
+	}
+
+	data := pageData{
+		Title:   "All Pages",
+		Content: content,
+	}
+
+	renderTemplate(w, r, data, "index")
+}
+
+func viewHandler(w http.ResponseWriter, r *http.Request, param string) {
+	enc, err := requestEncoding(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if redirectToCanonical(w, r, param) {
+		return
+	}
+
+	if size, large := isLargePage(param); large {
+		data := pageData{
+			Title:     "View " + param,
+			Canonical: requestURL(r, "/view/"+param),
+			Content:   &largeData{Title: param, Size: size},
+		}
+		renderTemplate(w, r, data, "large")
+		return
+	}
+
+	p, err := loadPageAs(param, enc)
+	if err != nil {
+		if r.FormValue("create") != "1" {
+			if suggestions := suggestTitles(param); len(suggestions) > 0 {
+				renderMissing(w, r, param, suggestions)
+				return
+			}
+		}
+		if !canEdit(r) {
+			renderError(w, r, http.StatusNotFound, "Page "+param+" does not exist.")
+			return
+		}
+		http.Redirect(w, r, pageURL("/edit/"+param), http.StatusFound)
+		return
+	}
+
+	html, err := renderPage(r.Context(), p.Title, p.Body)
+	if err != nil {
+		renderFailed(w, r, err)
+		return
+	}
+
+	user := currentUser(r)
+	protection := pageProtection(param)
+	data := pageData{
+		Title:     "View " + displayTitle(param),
+		Canonical: requestURL(r, "/view/"+param),
+		Content: &viewData{
+			Page:        p,
+			HTML:        html,
+			Protection:  protection,
+			CanEdit:     allowedByProtection(r, protection),
+			CanUndo:     hasUndo(param),
+			CanWatch:    user != "",
+			Watching:    isWatching(user, param),
+			Views:       views.add(param),
+			Related:     relatedPages.get(param),
+			DownloadURL: pageURL("/download/" + param),
+			CSRF:        csrfToken(r),
+		},
+	}
+
+	markVisited(user, param)
+
+	renderTemplate(w, r, data, "view")
+}
+
+func saveHandler(w http.ResponseWriter, r *http.Request, param string) {
+	body := r.FormValue("body")
+	display := strings.Join(strings.Fields(r.FormValue("title")), " ")
+	title := slugTitle(display)
+	p := &page.Page{
+		Title:   title,
+		Body:    []byte(body),
+		Editor:  currentUser(r),
+		Summary: capSummary(r.FormValue("summary")),
+		Minor:   r.FormValue("minor") == "on",
+	}
+
+	if p.Title != param && validTitle(p.Title) {
+		if level := pageProtection(p.Title); !allowedByProtection(r, level) {
+			renderProtected(w, r, p.Title, level)
+			return
+		}
+	}
+
+	outcome, err := submitEdit(r, param, p, r.FormValue("base"), r.FormValue("confirm") == "on")
+	if status, ok := saveErrorStatus(err); ok {
+		setRetryAfter(w, err)
+		data := pageData{
+			Title:  "Edit " + param,
+			Status: status,
+			Content: &editData{
+				Page:         p,
+				DisplayTitle: display,
+				Param:        param,
+				Error:        err.Error(),
+				Confirm:      outcome.confirm,
+				Base:         currentBase(param),
+			},
+		}
+
+		renderTemplate(w, r, data, "edit")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !outcome.queued {
+		if err := setDisplayTitle(p.Title, display); err != nil {
+			slog.Error("error saving display title", "title", p.Title, "err", err)
+		}
+	}
+	if err := removeDraft(draftOwner(w, r, false), param); err != nil {
+		slog.Error("error removing draft", "title", param, "err", err)
+	}
+	if outcome.queued {
+		addFlash(w, r, flash{Level: "success", Text: "Your edit of " + title + " was sent for review"})
+		http.Redirect(w, r, pageURL("/"), http.StatusFound)
+		return
+	}
+
+	if p.Editor != "" && requestPreferences(r).WatchEdits {
+		watchPage(p.Editor, title)
+	}
+
+	if outcome.merged {
+		addFlash(w, r, flash{Level: "success", Text: "Page " + title + " saved, merged with changes made while you were editing"})
+	} else {
+		addFlash(w, r, flash{Level: "success", Text: "Page " + title + " saved"})
+	}
+	http.Redirect(w, r, pageURL("/view/"+title), http.StatusFound)
+}
+
+func deleteHandler(w http.ResponseWriter, r *http.Request, param string) {
+	p, err := loadPage(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = deletePage(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Page " + param + " deleted"})
+	http.Redirect(w, r, pageURL("/"), http.StatusFound)
+}
+
+func undoHandler(w http.ResponseWriter, r *http.Request, param string) {
+	if err := admitWrite(r); err != nil {
+		if status, ok := saveErrorStatus(err); ok {
+			setRetryAfter(w, err)
+			renderError(w, r, status, err.Error())
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err := undoPage(param, currentUser(r))
+	if os.IsNotExist(err) {
+		http.Error(w, "Nothing to undo for "+param, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	addFlash(w, r, flash{Level: "success", Text: "Last edit of " + param + " undone"})
+	http.Redirect(w, r, pageURL("/view/"+param), http.StatusFound)
+}
+
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	title := r.FormValue("title")
+	html, err := renderPage(r.Context(), title, []byte(r.FormValue("body")))
+	if err != nil {
+		renderFailed(w, r, err)
+		return
+	}
+
+	data := pageData{
+		Title: "Preview " + title,
+		Content: &viewData{
+			Page: &page.Page{Title: title},
+			HTML: html,
+		},
+	}
+
+	renderTemplate(w, r, data, "preview")
+}
+
+func downloadHandler(w http.ResponseWriter, r *http.Request, param string) {
+	servePageSource(w, r, param, true)
+}
+
+func rawHandler(w http.ResponseWriter, r *http.Request, param string) {
+	servePageSource(w, r, param, false)
+}
+
+// servePageSource sends the page source as Markdown, as an attachment for
+// downloads and inline for /raw. Both answer conditional requests, so
+// clients can poll a page cheaply.
+func servePageSource(w http.ResponseWriter, r *http.Request, param string, attachment bool) {
+	enc, err := requestEncoding(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fi, err := statPage(param)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if notModified(w, r, fi) {
+		return
+	}
+
+	body, size, err := openPage(param, enc)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	if attachment {
+		w.Header().Set("Content-Disposition", contentDisposition(param, displayTitle(param)+".md"))
+	}
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		slog.Error("error streaming page", "title", param, "err", err)
+	}
+}
+
+func editHandler(w http.ResponseWriter, r *http.Request, param string) {
+	enc, err := requestEncoding(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if size, large := isLargePage(param); large {
+		renderError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Page %s is too large to edit in the browser (%d bytes). Download it and edit it locally instead.", param, size))
+		return
+	}
+
+	p, err := loadPageAs(param, enc)
+	if err != nil {
+		p = &page.Page{Title: param}
+	}
+
+	s, _ := currentSession(r)
+	content := &editData{
+		Page:         p,
+		DisplayTitle: displayTitle(param),
+		Param:        param,
+		Protection:   pageProtection(param),
+		CanProtect:   s.Role == roleAdmin,
+		Base:         currentBase(param),
+	}
+	if body, edited, ok := loadDraft(draftOwner(w, r, false), param); ok {
+		content.Page = &page.Page{Title: p.Title, Body: body}
+		content.HasDraft = true
+		content.DraftEdited = edited
+	}
+
+	data := pageData{
+		Title:   "Edit " + param,
+		Content: content,
+	}
+
+	renderTemplate(w, r, data, "edit")
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	data := pageData{
+		Title:   http.StatusText(status),
+		Status:  status,
+		Content: &errorData{Message: msg},
+	}
+
+	renderTemplate(w, r, data, "error")
+}
+
+func makeHandler(fn func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := validPath.FindStringSubmatch(r.URL.Path)
+		if m == nil {
+			if !redirectToSlug(w, r) {
+				http.NotFound(w, r)
+			}
+			return
+		}
+		if m[2] != "" {
+			if err := validateTitle(m[2]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		fn(w, r, m[2])
+	}
+}
+
+// noindexPaths are routes whose pages are forms or drafts, never content of
+// their own, so search engines are told to skip them.
+var noindexPaths = []string{"/edit/", "/save/", "/preview", "/draft/", "/rename/", "/copy/"}
+
+func noindex(r *http.Request) bool {
+	for _, path := range noindexPaths {
+		if strings.HasPrefix(r.URL.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderTemplate(w http.ResponseWriter, r *http.Request, pageData pageData, tmpl string) {
+	srv, ok := serverFrom(r)
+	if !ok {
+		http.Error(w, "Templates are not loaded", http.StatusInternalServerError)
+		return
+	}
+
+	tmpls, reloadErr := srv.currentTemplates()
+	devError := ""
+	if reloadErr != nil {
+		devError = reloadErr.Error()
+	}
+
+	baseTmpl := tmpls.Lookup("base.html")
+	contentTmpl := tmpls.Lookup(tmpl + ".html")
+
+	if baseTmpl == nil || contentTmpl == nil {
+		http.Error(w, "Not found base or content template", http.StatusInternalServerError)
+		return
+	}
+
+	// Waiting for a slot takes no longer than a page render may.
+	ctx := r.Context()
+	if renderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, renderTimeout)
+		defer cancel()
+	}
+
+	contentBuf := getBuffer()
+	defer putBuffer(contentBuf)
+
+	if err := acquireRender(ctx); err != nil {
+		http.Error(w, errRenderBusy, http.StatusServiceUnavailable)
+		return
+	}
+	err := contentTmpl.Execute(contentBuf, pageData.Content)
+	releaseRender()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content := template.HTML(contentBuf.String())
+
+	prefs := requestPreferences(r)
+
+	baseData := struct {
+		Title     string
+		Canonical string
+		Theme     string
+		Locale    string
+		Flashes   []flash
+		User      string
+		Login     bool
+		DevError  string
+		ReadOnly  bool
+		Nav       []navItem
+		NoIndex   bool
+		Content   template.HTML
+	}{
+		Title:     pageData.Title,
+		Canonical: pageData.Canonical,
+		Theme:     prefs.Theme,
+		Locale:    prefs.Locale,
+		Flashes:   consumeFlashes(w, r),
+		User:      currentUser(r),
+		Login:     authenticator != nil || github != nil,
+		DevError:  devError,
+		ReadOnly:  readOnly.Load(),
+		Nav:       navigation(),
+		NoIndex:   noindex(r),
+		Content:   content,
+	}
+	if baseData.NoIndex {
+		baseData.Canonical = ""
+	}
+
+	out := getBuffer()
+	defer putBuffer(out)
+
+	if err := acquireRender(ctx); err != nil {
+		http.Error(w, errRenderBusy, http.StatusServiceUnavailable)
+		return
+	}
+	err = baseTmpl.Execute(out, baseData)
+	releaseRender()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Nothing is written until both stages succeeded, so a failing template
+	// still produces a clean error response instead of half a page.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+	if pageData.Status != 0 {
+		w.WriteHeader(pageData.Status)
+	}
+	w.Write(out.Bytes())
+}
+
+// undoFilename is the backup kept next to the page file. The title is
+// checked as the store checks it, since the backup is not a page of its own.
+func undoFilename(title string) (string, error) {
+	if err := page.CheckPathTitle(title); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(config.StoragePath, title+".txt.prev"), nil
+}
+
+func savePage(p *page.Page) error {
+	if err := page.CheckPathTitle(p.Title); err != nil {
+		return err
+	}
+
+	body, err := hooks.runBeforeSave(p.Title, p.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Write(p.Title, body); err != nil {
+		return err
+	}
+	p.Body = body
+
+	if err := recordRevision(p.Title, p.Body, revision{Editor: p.Editor, Summary: p.Summary, Minor: p.Minor}); err != nil {
+		slog.Error("error recording revision", "title", p.Title, "err", err)
+	}
+
+	hooks.runAfterSave(p.Title)
+
+	return nil
+}
+
+// backupPage keeps a single copy of the current content so the last save can be undone.
+func backupPage(title string) error {
+	undo, err := undoFilename(title)
+	if err != nil {
+		return err
+	}
+
+	body, err := store.Read(title)
+	if os.IsNotExist(err) {
+		return removeBackup(title)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(undo, body, 0600)
+}
+
+func removeBackup(title string) error {
+	undo, err := undoFilename(title)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(undo)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func hasUndo(title string) bool {
+	undo, err := undoFilename(title)
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(undo)
+	return err == nil
+}
+
+func undoPage(title, editor string) error {
+	undo, err := undoFilename(title)
+	if err != nil {
+		return err
+	}
+
+	body, err := os.ReadFile(undo)
+	if err != nil {
+		return err
+	}
+	if err := store.Write(title, body); err != nil {
+		return err
+	}
+	if err := os.Remove(undo); err != nil {
+		return err
+	}
+
+	if err := recordRevision(title, body, revision{Editor: editor, Summary: "Undid last edit"}); err != nil {
+		slog.Error("error recording revision", "title", title, "err", err)
+	}
+
+	hooks.runAfterSave(title)
+
+	return nil
+}
+
+func deletePage(p *page.Page) error {
+	if err := moveToTrash(p.Title); err != nil {
+		return err
+	}
+
+	hooks.runOnDelete(p.Title)
+
+	return nil
+}
+
+func listPages() ([]string, error) {
+	if pages.ready() {
+		return pages.titles(), nil
+	}
+
+	return scanPages()
+}
+
+func scanPages() ([]string, error) {
+	return store.List()
+}
+
+type pageInfo struct {
+	Title    string
+	Modified time.Time
+	Size     int64
+}
+
+// HumanSize formats the page size for display, e.g. "1.5 KB".
+func (p pageInfo) HumanSize() string {
+	const unit = 1024
+	if p.Size < unit {
+		return fmt.Sprintf("%d B", p.Size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := p.Size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(p.Size)/float64(div), "KMGTPE"[exp])
+}
+
+func listPageInfos() ([]pageInfo, error) {
+	if pages.ready() {
+		return pages.infos(), nil
+	}
+
+	return scanPageInfos()
+}
+
+func scanPageInfos() ([]pageInfo, error) {
+	titles, err := scanPages()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]pageInfo, 0, len(titles))
+	for _, title := range titles {
+		fi, err := store.Stat(title)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, pageInfo{Title: title, Modified: fi.ModTime(), Size: fi.Size()})
+	}
+
+	return infos, nil
+}
+
+func loadPage(param string) (*page.Page, error) {
+	return loadPageAs(param, pageEncoding)
+}
+
+func loadPageAs(param string, enc encoding.Encoding) (*page.Page, error) {
+	body, err := store.Read(param)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = toUTF8(body, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page.Page{Title: param, Body: body}, nil
+}
+
+var baseURL = &url.URL{Scheme: "http", Host: "localhost:8080"}
+
+// baseURLSet is true when BASE_URL was configured, rather than guessed from
+// the requests.
+var baseURLSet bool
+
+func setupBaseURL() error {
+	raw := os.Getenv("BASE_URL")
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(raw, "/"))
+	if err != nil {
+		return fmt.Errorf("invalid BASE_URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid BASE_URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("invalid BASE_URL %q: expected scheme and host only", raw)
+	}
+
+	baseURL, baseURLSet = u, true
+	return nil
+}
+
+func absoluteURL(path string) string {
+	u := *baseURL
+	u.Path = pageURL(path)
+	return u.String()
+}
+
+// setup reads the settings from the environment and registers the hooks.
+func setup() error {
+	if err := setupTimeFormat(); err != nil {
+		return err
+	}
+	if err := setupPasswordPolicy(); err != nil {
+		return err
+	}
+	if err := setupBaseURL(); err != nil {
+		return err
+	}
+	if err := setupBasePath(); err != nil {
+		return err
+	}
+	if err := setupSecretKey(); err != nil {
+		return err
+	}
+	if err := setupBlocklist(); err != nil {
+		return err
+	}
+	if err := setupTrustedProxies(); err != nil {
+		return err
+	}
+	if err := setupIPBlocklist(); err != nil {
+		return err
+	}
+	if err := setupWriteLimit(); err != nil {
+		return err
+	}
+	if err := setupAbuseRules(); err != nil {
+		return err
+	}
+	if err := setupPageEncoding(); err != nil {
+		return err
+	}
+	if err := setupModeration(); err != nil {
+		return err
+	}
+	if err := setupLinkPolicy(); err != nil {
+		return err
+	}
+	if err := setupSanitizer(); err != nil {
+		return err
+	}
+	setupAdmonitions()
+	setupMerge()
+	setupQR()
+	setupCanonicalTitles()
+	setupReadOnly()
+	if err := setupHandlerTimeout(); err != nil {
+		return err
+	}
+	if err := setupRenderTimeout(); err != nil {
+		return err
+	}
+	if err := setupTransclusion(); err != nil {
+		return err
+	}
+	if err := setupSessionIdleTimeout(); err != nil {
+		return err
+	}
+	if err := setupRelatedPages(); err != nil {
+		return err
+	}
+	if err := setupSearchLanguage(); err != nil {
+		return err
+	}
+	if err := setupSearchRanking(); err != nil {
+		return err
+	}
+	if err := setupIndexer(); err != nil {
+		return err
+	}
+	if err := setupSuggestions(); err != nil {
+		return err
+	}
+	if err := setupGraph(); err != nil {
+		return err
+	}
+	if err := setupChangeJournal(); err != nil {
+		return err
+	}
+	if err := setupWriteLog(); err != nil {
+		return err
+	}
+	if err := setupAccessLog(); err != nil {
+		return err
+	}
+	if err := setupTLS(); err != nil {
+		return err
+	}
+	if err := setupTitleSlugs(); err != nil {
+		return err
+	}
+	if err := setupLargePages(); err != nil {
+		return err
+	}
+	if err := setupTrash(); err != nil {
+		return err
+	}
+	if err := setupNav(); err != nil {
+		return err
+	}
+	if err := setupLimits(); err != nil {
+		return err
+	}
+	if err := setupGitHubOAuth(); err != nil {
+		return err
+	}
+	if err := setupAuthBackend(); err != nil {
+		return err
+	}
+	if err := setupAPITokens(); err != nil {
+		return err
+	}
+	if err := setupJWTAuth(); err != nil {
+		return err
+	}
+	if err := setupDigest(); err != nil {
+		return err
+	}
+	if err := setupWatchNotifications(); err != nil {
+		return err
+	}
+	registerHooks()
+
+	return nil
+}
+
+// Run configures every feature from the environment, registers the handlers
+// and serves the wiki until the listener fails.
+func Run(cfg Config, st storage.Storage) error {
+	config = cfg
+	store = st
+
+	if err := setup(); err != nil {
+		return err
+	}
+	srv, err := newServer(config, templatesDir)
+	if err != nil {
+		return err
+	}
+	if err := pages.rebuild(); err != nil {
+		return err
+	}
+	if err := pages.watchStorage(); err != nil {
+		slog.Error("error watching storage, out-of-band changes need a manual refresh", "err", err)
+	}
+	rebuildIndexes()
+	searchIndexer.start()
+
+	interval, err := expiryInterval()
+	if err != nil {
+		return err
+	}
+	if interval > 0 {
+		go runExpiryJanitor(interval)
+	}
+	go runRetentionJanitor()
+	if digest != nil {
+		go runDigestScheduler()
+	}
+	flushInterval, err := viewFlushInterval()
+	if err != nil {
+		return err
+	}
+	if err := views.load(); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go runViewFlusher(ctx, flushInterval)
+	go watchReadOnlySignal()
+
+	handler := newHandler(srv, routes())
+
+	httpServer := &http.Server{Addr: config.ListenAddr, Handler: handler, TLSConfig: serverTLSConfig()}
+
+	listener, where, err := listen(config.ListenAddr)
+	if err != nil {
+		return err
+	}
+	log.Println("Server starting on " + where)
+	unixListener = listener.Addr().Network() == "unix"
+	if unixListener && !trustUnixPeers {
+		slog.Warn("listening on a Unix socket without \"unix\" in TRUSTED_PROXIES, client addresses are unknown and the IP blocklist and rate limit do not apply")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if tlsFiles.cert != "" {
+			errc <- httpServer.ServeTLS(listener, tlsFiles.cert, tlsFiles.key)
+			return
+		}
+		errc <- httpServer.Serve(listener)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let running requests finish, then write out what is only kept in
+	// memory.
+	log.Println("Server shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error shutting down server", "err", err)
+	}
+
+	searchIndexer.stop()
+	if accessLog != nil {
+		if err := accessLog.Close(); err != nil {
+			slog.Error("error closing access log", "err", err)
+		}
+	}
+
+	return views.flush()
+}
+
+// routes registers every page and API endpoint of the wiki.
+func routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", makeHandler(indexHandler))
+	mux.HandleFunc("/view/", makeHandler(viewHandler))
+	mux.HandleFunc("/edit/", makeHandler(requireEdit(editHandler)))
+	mux.HandleFunc("/save/", makeHandler(requireEdit(saveHandler)))
+	mux.HandleFunc("/delete/", makeHandler(requireEdit(refuseHeldForReview(requireForm(deleteHandler)))))
+	mux.HandleFunc("/revert/", makeHandler(requireEdit(revertHandler)))
+	mux.HandleFunc("/copy/", makeHandler(requireEdit(refuseHeldForReview(copyHandler))))
+	mux.HandleFunc("/rename/", makeHandler(requireEdit(refuseHeldForReview(renameHandler))))
+	mux.HandleFunc("/undo/", makeHandler(requireEdit(refuseHeldForReview(requireForm(undoHandler)))))
+	mux.HandleFunc("/raw/", makeHandler(rawHandler))
+	mux.HandleFunc("/download/", makeHandler(downloadHandler))
+	mux.HandleFunc("/preview", previewHandler)
+	mux.HandleFunc("/search", searchHandler)
+	mux.HandleFunc("/draft/", makeHandler(requireEdit(draftHandler)))
+	mux.HandleFunc("/discard/", makeHandler(requireEdit(discardHandler)))
+	mux.HandleFunc("/drafts", draftsHandler)
+	mux.HandleFunc("/history/", makeHandler(historyHandler))
+	mux.HandleFunc("/diff/", makeHandler(diffHandler))
+	mux.HandleFunc("/preferences", preferencesHandler)
+	mux.HandleFunc("/watch/", makeHandler(watchHandler))
+	mux.HandleFunc("/protect/", requireClientCert(makeHandler(protectHandler)))
+	mux.HandleFunc("/watchlist", watchlistHandler)
+	mux.HandleFunc("/changelog", changelogHandler)
+	mux.HandleFunc("/feed.xml", feedHandler)
+	mux.HandleFunc("/sitemap.xml", sitemapHandler)
+	mux.HandleFunc("/admin/ipblocks", requireClientCert(ipBlocksHandler))
+	mux.HandleFunc("/admin/rules", requireClientCert(abuseRulesHandler))
+	mux.HandleFunc("/moderation", moderationHandler)
+	mux.HandleFunc("/qr/", qrHandler)
+	mux.HandleFunc("/admin/refresh", requireClientCert(refreshPagesHandler))
+	mux.HandleFunc("/admin/import", requireClientCert(importHandler))
+	mux.HandleFunc("/admin/readonly", requireClientCert(readOnlyHandler))
+	mux.HandleFunc("/admin/branding", requireClientCert(brandingHandler))
+	mux.HandleFunc("/admin/history/collapse", requireClientCert(collapseHistoryHandler))
+	mux.HandleFunc("/favicon.ico", faviconHandler)
+	mux.HandleFunc("/logo", logoHandler)
+	mux.HandleFunc("/trash", trashHandler)
+	mux.HandleFunc("/export", exportHandler)
+	mux.HandleFunc("/validate", validateHandler)
+	mux.HandleFunc("/auth/github/login", githubLoginHandler)
+	mux.HandleFunc("/auth/github/callback", githubCallbackHandler)
+	mux.Handle("/api/pages", requireAPIAuth(http.HandlerFunc(apiPagesHandler)))
+	mux.Handle("/api/pages/", requireAPIAuth(http.HandlerFunc(apiPagesHandler)))
+	mux.Handle("/api/pages/batch", requireAPIAuth(http.HandlerFunc(apiBatchHandler)))
+	mux.HandleFunc("/api/openapi.json", apiOpenAPIHandler)
+	mux.HandleFunc("/version", versionHandler)
+	mux.Handle("/api/recent", requireAPIAuth(http.HandlerFunc(apiRecentHandler)))
+	mux.Handle("/api/search", requireAPIAuth(http.HandlerFunc(apiSearchHandler)))
+	mux.Handle("/api/backlinks/", requireAPIAuth(http.HandlerFunc(apiBacklinksHandler)))
+	mux.Handle("/api/preview/", requireAPIAuth(http.HandlerFunc(apiPreviewHandler)))
+	mux.Handle("/api/changes", requireAPIAuth(http.HandlerFunc(apiChangesHandler)))
+	mux.Handle("/api/graph", requireAPIAuth(http.HandlerFunc(apiGraphHandler)))
+	mux.Handle("/api/stats", requireAPIAuth(http.HandlerFunc(apiStatsHandler)))
+	mux.HandleFunc("/login", loginHandler)
+	mux.HandleFunc("/logout", logoutHandler)
+
+	return mux
+}
+
+// newHandler wraps the routes in the middleware every request goes through.
+func newHandler(srv *server, mux http.Handler) http.Handler {
+	return withClientInfo(withAccessLog(withBasePath(withSecurityHeaders(securityHeadersFromEnv(), withTimeout(handlerTimeout, withServer(srv, withReadOnly(withSessionIdle(withWriteLog(mux)))))))))
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlexKvashin21/gowiki/internal/page"
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+)
+
+// testServer holds the templates, parsed once for the whole package.
+var testServer *server
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gowiki-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Setenv("SECRET_KEY", "test-secret")
+	config = Config{StoragePath: dir, AllowAnonymousEdit: true}
+	store = storage.NewFileStore(dir)
+	if err := setup(); err != nil {
+		log.Fatal(err)
+	}
+	if testServer, err = newServer(config, "../../templates"); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setGlobal sets a package variable for the duration of the test.
+func setGlobal[T any](t testing.TB, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// testWiki is a wiki served from a fresh storage directory.
+type testWiki struct {
+	*httptest.Server
+	t   testing.TB
+	dir string
+}
+
+func newTestWiki(t testing.TB) *testWiki {
+	t.Helper()
+	return newTestWikiWith(t, Config{AllowAnonymousEdit: true})
+}
+
+// newTestWikiWith serves the wiki with cfg, the way Run would. Without a
+// storage path it gets a fresh directory.
+func newTestWikiWith(t testing.TB, cfg Config) *testWiki {
+	t.Helper()
+
+	if cfg.StoragePath == "" {
+		cfg.StoragePath = t.TempDir()
+	}
+	dir := cfg.StoragePath
+	fileStore := storage.NewFileStore(dir)
+	fileStore.SetFollowSymlinks(cfg.FollowSymlinks)
+	setGlobal(t, &config, cfg)
+	setGlobal(t, &store, storage.Storage(fileStore))
+	setGlobal(t, &writes, nil)
+	resetState(t)
+
+	ts := httptest.NewServer(newHandler(testServer, routes()))
+	t.Cleanup(ts.Close)
+	ts.Client().CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testWiki{Server: ts, t: t, dir: dir}
+}
+
+// resetState forgets what the previous test left in the in-memory caches.
+func resetState(t testing.TB) {
+	t.Helper()
+
+	if err := pages.rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	rebuildIndexes()
+
+	journal.mu.Lock()
+	journal.events, journal.next = nil, 1
+	journal.mu.Unlock()
+
+	views.mu.Lock()
+	views.totals, views.pending = map[string]int64{}, map[string]int64{}
+	views.mu.Unlock()
+
+	ipBlocks.mu.Lock()
+	ipBlocks.static, ipBlocks.entries = nil, nil
+	ipBlocks.mu.Unlock()
+
+	readOnly.Store(false)
+}
+
+// seed saves a page the way an edit would, hooks included.
+func (w *testWiki) seed(title, body string) {
+	w.t.Helper()
+	if err := savePage(&page.Page{Title: title, Body: []byte(body)}); err != nil {
+		w.t.Fatal(err)
+	}
+}
+
+// login returns a session cookie for user with role.
+func (w *testWiki) login(user, role string) *http.Cookie {
+	w.t.Helper()
+	rec := httptest.NewRecorder()
+	if err := setSession(rec, user, role); err != nil {
+		w.t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+func (w *testWiki) do(req *http.Request, cookies ...*http.Cookie) (*http.Response, string) {
+	w.t.Helper()
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := w.Client().Do(req)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func (w *testWiki) get(path string, cookies ...*http.Cookie) (*http.Response, string) {
+	w.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, w.URL+path, nil)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	return w.do(req, cookies...)
+}
+
+func (w *testWiki) post(path string, form url.Values, cookies ...*http.Cookie) (*http.Response, string) {
+	w.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, w.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return w.do(req, cookies...)
+}
+
+// csrf returns a form carrying the CSRF token of the session in cookies, as
+// the rendered page would.
+func (w *testWiki) csrf(cookies ...*http.Cookie) url.Values {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	return url.Values{csrfFieldName: {csrfToken(req)}}
+}
+
+func (w *testWiki) api(method, path, body string, cookies ...*http.Cookie) (*http.Response, string) {
+	w.t.Helper()
+	req, err := http.NewRequest(method, w.URL+path, strings.NewReader(body))
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return w.do(req, cookies...)
+}
+
+func wantStatus(t *testing.T, resp *http.Response, body string, status int) {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s = %d, want %d\n%s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, body)
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// writeLog records who changed what from which address. It is kept apart
+// from the access log so moderators only see mutations. Nil when WRITE_LOG
+// is not set.
+var writeLog *slog.Logger
+
+// setupWriteLog reads WRITE_LOG: "slog" sends entries to the default logger,
+// anything else is a file that gets one JSON object per line.
+func setupWriteLog() error {
+	switch target := os.Getenv("WRITE_LOG"); target {
+	case "":
+		return nil
+	case "slog":
+		writeLog = slog.Default().With("log", "write")
+	default:
+		f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		writeLog = slog.New(slog.NewJSONHandler(f, nil))
+	}
+
+	return nil
+}
+
+// writeAction names the mutation a request makes and the page it makes it
+// on. ok is false for requests that only read.
+func writeAction(r *http.Request) (action, title string, ok bool) {
+	m := validPath.FindStringSubmatch(r.URL.Path)
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		// Discarding a draft is a plain link.
+		if m != nil && m[1] == "discard" {
+			return m[1], m[2], true
+		}
+		return "", "", false
+	}
+	if slices.Contains(readOnlyPosts, r.URL.Path) {
+		return "", "", false
+	}
+
+	if m != nil && m[1] != "" {
+		return m[1], m[2], true
+	}
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/api/pages/"); ok {
+		title, action, _ := strings.Cut(rest, "/")
+		if action == "" {
+			action = strings.ToLower(r.Method)
+		}
+		return "api " + action, title, true
+	}
+
+	return strings.TrimPrefix(r.URL.Path, "/"), r.FormValue("title"), true
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func withWriteLog(next http.Handler) http.Handler {
+	if writeLog == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action, title, ok := writeAction(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		writeLog.Info("write",
+			"ip", clientIP(r).String(),
+			"user", currentUser(r),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"action", action,
+			"title", title,
+			"status", rec.status,
+		)
+	})
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type writeLogEntry struct {
+	Msg    string
+	IP     string
+	User   string
+	Method string
+	Path   string
+	Action string
+	Title  string
+	Status int
+}
+
+func readWriteLog(t *testing.T, b []byte) []writeLogEntry {
+	t.Helper()
+	var entries []writeLogEntry
+	for sc := bufio.NewScanner(bytes.NewReader(b)); sc.Scan(); {
+		var e writeLogEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// logWrites serves w's wiki with the write log going to the returned buffer.
+func logWrites(t *testing.T, w *testWiki) (*testWiki, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	setGlobal(t, &writeLog, slog.New(slog.NewJSONHandler(&buf, nil)))
+	ts := httptest.NewServer(newHandler(testServer, routes()))
+	t.Cleanup(ts.Close)
+	ts.Client().CheckRedirect = w.Client().CheckRedirect
+	return &testWiki{Server: ts, t: t, dir: w.dir}, &buf
+}
+
+func TestWriteLog(t *testing.T) {
+	w, buf := logWrites(t, newTestWiki(t))
+	w.seed("Gone", "gone")
+	alice := w.login("alice", roleEditor)
+
+	w.post("/save/Home", url.Values{"title": {"Home"}, "body": {"home"}}, alice)
+	w.post("/delete/Gone", w.csrf(alice), alice)
+	w.api(http.MethodPut, "/api/pages/Api", `{"body":"api"}`)
+	// Reads and posts that change nothing are not logged, nor are delete
+	// links, which only take a POST.
+	w.get("/view/Home", alice)
+	w.get("/delete/Home", alice)
+	w.post("/preview", url.Values{"title": {"Home"}, "body": {"draft"}}, alice)
+
+	want := []writeLogEntry{
+		{Msg: "write", IP: "127.0.0.1", User: "alice", Method: http.MethodPost, Path: "/save/Home", Action: "save", Title: "Home", Status: http.StatusFound},
+		{Msg: "write", IP: "127.0.0.1", User: "alice", Method: http.MethodPost, Path: "/delete/Gone", Action: "delete", Title: "Gone", Status: http.StatusFound},
+		{Msg: "write", IP: "127.0.0.1", Method: http.MethodPut, Path: "/api/pages/Api", Action: "api put", Title: "Api", Status: http.StatusCreated},
+	}
+	if got := readWriteLog(t, buf.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("write log =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSetupWriteLog(t *testing.T) {
+	setGlobal(t, &writeLog, nil)
+	t.Setenv("WRITE_LOG", "")
+	if err := setupWriteLog(); err != nil || writeLog != nil {
+		t.Errorf("without WRITE_LOG: %v, logger %v", err, writeLog)
+	}
+
+	fn := filepath.Join(t.TempDir(), "writes.jsonl")
+	t.Setenv("WRITE_LOG", fn)
+	if err := setupWriteLog(); err != nil {
+		t.Fatal(err)
+	}
+	writeLog.Info("write", "action", "save", "title", "Home")
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readWriteLog(t, b); len(got) != 1 || got[0].Action != "save" || got[0].Title != "Home" {
+		t.Errorf("write log file = %s", b)
+	}
+
+	t.Setenv("WRITE_LOG", filepath.Join(t.TempDir(), "missing", "writes.jsonl"))
+	if err := setupWriteLog(); err == nil {
+		t.Error("unwritable WRITE_LOG accepted")
+	}
+}
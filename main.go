@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/AlexKvashin21/gowiki/internal/storage"
+	"github.com/AlexKvashin21/gowiki/internal/web"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		slog.Error("error initializing env variables", "err", err)
+	}
+
+	cfg, err := web.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := storage.NewFileStore(cfg.StoragePath)
+	store.SetFollowSymlinks(cfg.FollowSymlinks)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "passwd":
+			if err := web.Passwd(cfg, os.Args[2:], os.Stdin); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "import":
+			if err := web.Import(cfg, store, os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "history":
+			if err := web.History(cfg, store, os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "export":
+			if err := web.Export(cfg, store, os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	if err := store.SelfTest(); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Storage self-test passed: " + cfg.StoragePath)
+
+	if err := web.Run(cfg, store); err != nil {
+		log.Fatal("Ошибка сервера:", err)
+	}
+}